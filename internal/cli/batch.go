@@ -0,0 +1,299 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// batchItem is one line of batch input.
+type batchItem struct {
+	ID        string `json:"id,omitempty"`
+	Prompt    string `json:"prompt"`
+	System    string `json:"system,omitempty"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
+	User      string `json:"user,omitempty"`
+}
+
+// batchResult is one line of batch output.
+type batchResult struct {
+	Index     int         `json:"index"`
+	ID        string      `json:"id,omitempty"`
+	Content   string      `json:"content,omitempty"`
+	Model     string      `json:"model,omitempty"`
+	Usage     *batchUsage `json:"usage,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Attempts  int         `json:"attempts"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type batchUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func runBatch(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "submit":
+			return runBatchSubmit(args[1:])
+		case "status":
+			return runBatchStatus(args[1:])
+		case "results":
+			return runBatchResults(args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile to use (default: use default profile)")
+	output := fs.String("output", "", "write results to this file (default: stdout)")
+	concurrency := fs.Int("concurrency", 4, "number of requests in flight")
+	retries := fs.Int("retries", -1, "retries per item after a failed request (default: the profile's retry settings)")
+	rate := fs.Float64("rate", 0, "maximum requests started per second (0 = unlimited)")
+	noProgress := fs.Bool("no-progress", false, "don't show the progress bar")
+	moderate := fs.String("moderate", "", "check each prompt with this moderation profile first; flagged items fail")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage batch [flags] <input.jsonl>
+       sage batch submit|status|results [flags] ...
+
+Run many completions from a JSONL file.
+
+The submit, status and results subcommands run the file as an asynchronous
+batch job on the provider instead, at half price; see 'sage batch submit
+--help'.
+
+Each input line is a JSON object:
+  {"id": "a1", "prompt": "...", "system": "...", "max_tokens": 200, "user": "u1"}
+
+Only "prompt" is required. Results are written as JSONL in input order, with
+"error" set on items that failed after all retries. Use "-" to read stdin.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage batch prompts.jsonl > results.jsonl
+  sage batch --profile=fast --concurrency=8 --output=results.jsonl prompts.jsonl
+  sage batch --rate=2 prompts.jsonl
+  sage batch --moderate=mod user-prompts.jsonl
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("input file required")
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+
+	items, err := readBatchInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *moderate != "" {
+		client.SetPreSendHook(client.ModerationHook(*moderate))
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("cannot create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	runner := &sage.BatchRunner{
+		Client:            client,
+		Profile:           *profile,
+		Concurrency:       *concurrency,
+		RequestsPerSecond: *rate,
+	}
+	if *retries >= 0 {
+		policy, err := client.RetryPolicy(*profile)
+		if err != nil {
+			return err
+		}
+		policy.MaxRetries = *retries
+		runner.Retry = &policy
+	}
+
+	progress := newProgressBar(len(items), !*noProgress && isTerminal(os.Stderr))
+	failed := runBatchItems(runner, items, out, progress)
+	progress.finish()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d items failed", failed, len(items))
+	}
+	return nil
+}
+
+// readBatchInput parses a JSONL file of batch items. Blank lines are skipped.
+func readBatchInput(path string) ([]batchItem, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open input: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var items []batchItem
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var item batchItem
+		if err := json.Unmarshal([]byte(text), &item); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", line, err)
+		}
+		if item.Prompt == "" {
+			return nil, fmt.Errorf("line %d: prompt is required", line)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read input: %w", err)
+	}
+	return items, nil
+}
+
+// runBatchItems completes items with a sage.BatchRunner and writes results
+// to out in input order as they become available. Returns the number of
+// failed items.
+func runBatchItems(runner *sage.BatchRunner, items []batchItem, out io.Writer, progress *progressBar) int {
+	reqs := batchRequests(items)
+
+	// Buffer out-of-order results so output follows input order
+	enc := json.NewEncoder(out)
+	pending := make(map[int]batchResult)
+	next, failed := 0, 0
+	runner.OnProgress = func(p sage.BatchProgress) {
+		progress.add(p.Result.Err != nil)
+		if p.Result.Err != nil {
+			failed++
+		}
+
+		pending[p.Result.Index] = newBatchResult(items[p.Result.Index], p.Result)
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			enc.Encode(res)
+			delete(pending, next)
+			next++
+		}
+	}
+
+	// Ctrl-C stops the batch; unfinished items are written as failed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner.Run(ctx, reqs)
+	return failed
+}
+
+// batchRequests converts batch items into library requests.
+func batchRequests(items []batchItem) []sage.Request {
+	reqs := make([]sage.Request, len(items))
+	for i, item := range items {
+		reqs[i] = sage.Request{
+			Prompt:    item.Prompt,
+			System:    item.System,
+			MaxTokens: item.MaxTokens,
+			User:      item.User,
+		}
+	}
+	return reqs
+}
+
+// newBatchResult converts a library batch result into an output line.
+func newBatchResult(item batchItem, r sage.BatchResult) batchResult {
+	result := batchResult{Index: r.Index, ID: item.ID, Attempts: r.Attempts}
+	if r.Err != nil {
+		result.Error = r.Err.Error()
+		return result
+	}
+
+	result.Content = r.Response.Content
+	result.Model = r.Response.Model
+	result.RequestID = r.Response.RequestID
+	result.Usage = &batchUsage{
+		PromptTokens:     r.Response.Usage.PromptTokens,
+		CompletionTokens: r.Response.Usage.CompletionTokens,
+	}
+	return result
+}
+
+// progressBar draws a single-line progress bar on stderr.
+type progressBar struct {
+	total, done, failed int
+	enabled             bool
+}
+
+func newProgressBar(total int, enabled bool) *progressBar {
+	p := &progressBar{total: total, enabled: enabled}
+	p.draw()
+	return p
+}
+
+func (p *progressBar) add(failed bool) {
+	p.done++
+	if failed {
+		p.failed++
+	}
+	p.draw()
+}
+
+func (p *progressBar) draw() {
+	if !p.enabled || p.total == 0 {
+		return
+	}
+	const width = 30
+	filled := width * p.done / p.total
+	line := fmt.Sprintf("\r[%s%s] %d/%d", strings.Repeat("#", filled), strings.Repeat(".", width-filled), p.done, p.total)
+	if p.failed > 0 {
+		line += fmt.Sprintf(" (%d failed)", p.failed)
+	}
+	fmt.Fprint(os.Stderr, line)
+}
+
+func (p *progressBar) finish() {
+	if p.enabled && p.total > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && (stat.Mode()&os.ModeCharDevice) != 0
+}
@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// batchJobStatus is the --json output of sage batch status.
+type batchJobStatus struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Done      bool      `json:"done"`
+	Total     int       `json:"total"`
+	Completed int       `json:"completed"`
+	Failed    int       `json:"failed"`
+	CreatedAt time.Time `json:"created_at"`
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+func runBatchSubmit(args []string) error {
+	fs := flag.NewFlagSet("batch submit", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile to use (default: use default profile)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage batch submit [flags] <input.jsonl>
+
+Submit a JSONL file as an asynchronous batch job.
+
+The input is the same as for 'sage batch'. The provider runs the job offline,
+usually within 24 hours, at half the usual price. The job's ID is printed;
+follow it with 'sage batch status' and collect the output with
+'sage batch results'. Supported by openai.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage batch submit --profile=fast prompts.jsonl
+  sage batch status --wait batch_abc123
+  sage batch results batch_abc123 > results.jsonl
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("input file required")
+	}
+
+	items, err := readBatchInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	job, err := client.SubmitBatch(*profile, batchRequests(items))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(job.ID)
+	fmt.Fprintf(os.Stderr, "Submitted %d requests as batch %s (%s)\n", len(items), job.ID, job.Status)
+	return nil
+}
+
+func runBatchStatus(args []string) error {
+	fs := flag.NewFlagSet("batch status", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile the batch was submitted with (default: use default profile)")
+	wait := fs.Bool("wait", false, "poll until the batch is done")
+	interval := fs.Duration("interval", 30*time.Second, "with --wait, how often to poll")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage batch status [flags] <batch-id>
+
+Show the state of a batch job.
+
+With --wait, progress is printed to stderr until the job is done.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage batch status batch_abc123
+  sage batch status --wait --interval=1m batch_abc123 && sage batch results batch_abc123
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("batch ID required")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	job, err := client.BatchStatus(*profile, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for *wait && !job.Done {
+		fmt.Fprintf(os.Stderr, "%s\n", formatBatchJob(job))
+		time.Sleep(*interval)
+		if job, err = client.BatchStatus(*profile, fs.Arg(0)); err != nil {
+			return err
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(batchJobStatus{
+			ID:        job.ID,
+			Status:    job.Status,
+			Done:      job.Done,
+			Total:     job.Total,
+			Completed: job.Completed,
+			Failed:    job.Failed,
+			CreatedAt: job.CreatedAt,
+			Errors:    job.Errors,
+		})
+	}
+	fmt.Println(formatBatchJob(job))
+	for _, e := range job.Errors {
+		fmt.Printf("  error: %s\n", e)
+	}
+	return nil
+}
+
+// formatBatchJob describes a job on one line.
+func formatBatchJob(job *sage.BatchJob) string {
+	line := fmt.Sprintf("%s: %s, %d/%d completed", job.ID, job.Status, job.Completed, job.Total)
+	if job.Failed > 0 {
+		line += fmt.Sprintf(", %d failed", job.Failed)
+	}
+	return line
+}
+
+func runBatchResults(args []string) error {
+	fs := flag.NewFlagSet("batch results", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile the batch was submitted with (default: use default profile)")
+	output := fs.String("output", "", "write results to this file (default: stdout)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage batch results [flags] <batch-id>
+
+Download the results of a finished batch job.
+
+Results are written as JSONL in the same format as 'sage batch', one line per
+input line in input order, with "error" set on items that failed.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage batch results batch_abc123 > results.jsonl
+  sage batch results --output=results.jsonl batch_abc123
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("batch ID required")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	results, err := client.BatchResults(*profile, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("cannot create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+		enc.Encode(newBatchResult(batchItem{}, r))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d items failed", failed, len(results))
+	}
+	return nil
+}
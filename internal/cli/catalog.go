@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+func runCatalog(args []string) error {
+	if len(args) == 0 {
+		return showCatalogHelp()
+	}
+
+	switch args[0] {
+	case "update":
+		return runCatalogUpdate(args[1:])
+	case "help", "-h", "--help":
+		return showCatalogHelp()
+	default:
+		return fmt.Errorf("unknown catalog command: %s\nRun 'sage catalog help' for usage", args[0])
+	}
+}
+
+func showCatalogHelp() error {
+	help := `Usage: sage catalog <command> [flags]
+
+The model catalog holds context windows, pricing, parameter quirks, and
+deprecation status for known models. A copy is built into sage; 'update'
+downloads a newer one into the config directory.
+
+Commands:
+  update    Download the latest model catalog
+
+Examples:
+  sage catalog update
+  sage catalog update --url=https://example.com/models.json
+`
+	fmt.Print(help)
+	return nil
+}
+
+func runCatalogUpdate(args []string) error {
+	fs := flag.NewFlagSet("catalog update", flag.ExitOnError)
+	url := fs.String("url", sage.DefaultCatalogURL, "catalog URL")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage catalog update [flags]
+
+Download the latest model catalog into the config directory.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	catalog, err := client.UpdateCatalog(context.Background(), *url)
+	if err != nil {
+		return err
+	}
+
+	path, err := sage.CatalogPath()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Model catalog updated to version %s (%d models)\n", catalog.Version, len(catalog.Models))
+	fmt.Printf("Saved to %s\n", path)
+	return nil
+}
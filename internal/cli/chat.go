@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+func runChat(args []string) error {
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+
+	profile := fs.String("profile", "", "profile to use (default: use default profile)")
+	model := fs.String("model", "", "model to use instead of the profile's, on the same provider account")
+	system := fs.String("system", "", "system message")
+	maxTokens := fs.Int("max-tokens", 0, "maximum tokens to generate per reply")
+	reasoningEffort := fs.String("reasoning-effort", "", "how long reasoning models think: low, medium or high (default: profile's)")
+	resume := fs.String("resume", "", "continue a saved session: its ID, or last")
+	noSave := fs.Bool("no-save", false, "don't save this conversation")
+	render := fs.Bool("render", false, "render Markdown in replies for the terminal, when stdout is one (default: config's render setting)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage chat [flags]
+
+Chat with an LLM: each reply streams in and the conversation so far is sent
+with every message. Lines can be edited with the arrow keys and the usual
+readline keys (Ctrl-A, Ctrl-E, Ctrl-W, Ctrl-U, ...), and the up arrow recalls
+earlier messages. End a line with \ to continue the message on the next.
+
+Ctrl-C stops a reply, or clears the line being written. Ctrl-D or /exit
+quits.
+
+Conversations are saved as you go, under sessions/ in the sage data
+directory (~/.local/share/sage by default), and --resume continues one with
+its profile, model and system prompt. 'sage session' lists, shows, exports
+and deletes saved conversations.
+
+Commands:
+  /reset  Start a new conversation (the current one stays saved)
+  /exit   Quit
+  /help   Show the commands
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage chat
+  sage chat --profile=claude
+  sage chat --system="You are a terse code reviewer"
+  sage chat --resume=last
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	var session *sage.Session
+	if *resume != "" {
+		session, err = sage.LoadSession(*resume)
+		if err != nil {
+			return err
+		}
+		// Flags given override the session's settings from here on
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "profile":
+				session.Profile = *profile
+			case "model":
+				session.Model = *model
+			case "system":
+				session.System = *system
+			}
+		})
+	} else {
+		p, err := client.GetProfile(*profile)
+		if err != nil {
+			return err
+		}
+		// Keep the system prompt in the session, so resuming it uses the
+		// same one even if the profile's changes
+		prompt := *system
+		if prompt == "" {
+			if prompt, err = p.SystemPrompt(); err != nil {
+				return err
+			}
+		}
+		session = sage.NewSession(p.Name, *model, prompt)
+	}
+
+	p, err := client.GetProfile(session.Profile)
+	if err != nil {
+		return err
+	}
+	if dep, err := client.CheckModel(p.Name, session.Model); err == nil && dep != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", dep)
+	}
+
+	req := sage.Request{
+		System:          session.System,
+		MaxTokens:       *maxTokens,
+		Model:           session.Model,
+		ReasoningEffort: *reasoningEffort,
+	}
+	if *resume != "" {
+		fmt.Printf("Resumed session %s (%d messages).\n", session.ID, len(session.Messages))
+	}
+	fmt.Printf("Chatting with %s (%s). /help for commands, Ctrl-D to quit.\n", p.Name, requestModel(client, p.Name, req))
+
+	renderOn := client.RenderMarkdown()
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "render" {
+			renderOn = *render
+		}
+	})
+	w := newResponseWriter(renderOn)
+
+	saved := false
+	defer func() {
+		if saved {
+			fmt.Fprintf(os.Stderr, "Saved session %s; continue it with: sage chat --resume=%s\n", session.ID, session.ID)
+		}
+	}()
+
+	editor := newLineEditor()
+	history := session.Messages
+	for {
+		message, err := readMessage(editor)
+		if errors.Is(err, errLineInterrupted) {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch message {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		case "/reset":
+			session = sage.NewSession(session.Profile, session.Model, session.System)
+			history = nil
+			fmt.Println("Started a new conversation.")
+			continue
+		case "/help":
+			fmt.Println("/reset  Start a new conversation\n/exit   Quit\n/help   Show the commands")
+			continue
+		}
+		if strings.HasPrefix(message, "/") && !strings.ContainsAny(message, " \n") {
+			fmt.Fprintf(os.Stderr, "unknown command %s: /help lists them\n", message)
+			continue
+		}
+
+		req.Messages = history
+		req.Prompt = message
+		reply, usage, err := chatReply(client, p.Name, req, w)
+		session.AddUsage(usage)
+		// Keep what was shown of a reply that was cut short, so the
+		// conversation carries on from what the user saw
+		if reply != "" {
+			history = append(history,
+				sage.Message{Role: "user", Content: message},
+				sage.Message{Role: "assistant", Content: reply},
+			)
+			if !*noSave {
+				session.Messages = history
+				if err := session.Save(); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				} else {
+					saved = true
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		fmt.Println()
+	}
+}
+
+// readMessage reads a chat message, continuing it onto the next line while
+// lines end with a backslash.
+func readMessage(editor *lineEditor) (string, error) {
+	var lines []string
+	prompt := "> "
+	for {
+		line, err := editor.readLine(prompt)
+		if err != nil {
+			return "", err
+		}
+		editor.addHistory(line)
+		if rest, ok := strings.CutSuffix(line, `\`); ok {
+			lines = append(lines, rest)
+			prompt = ". "
+			continue
+		}
+		lines = append(lines, line)
+		return strings.TrimSpace(strings.Join(lines, "\n")), nil
+	}
+}
+
+// chatReply streams the reply to req, returning the text received: all of
+// it, or what came before the stream failed or Ctrl-C stopped it. Token
+// usage is returned when the provider reports it.
+func chatReply(client *sage.Client, profile string, req sage.Request, w responseWriter) (string, *sage.Usage, error) {
+	// Cancelled on return, so a stopped stream's connection is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	chunks, err := client.CompleteStream(ctx, profile, req)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var content strings.Builder
+	for {
+		select {
+		case <-interrupt:
+			w.End()
+			return content.String(), nil, fmt.Errorf("interrupted")
+		case chunk, ok := <-chunks:
+			if !ok {
+				w.End()
+				return content.String(), nil, nil
+			}
+			if chunk.Error != nil {
+				if content.Len() > 0 {
+					w.End()
+				}
+				return content.String(), nil, chunk.Error
+			}
+			if chunk.Done {
+				w.End()
+				printSources(chunk.Citations)
+				printFinishWarning(chunk.FinishReason)
+				return content.String(), chunk.Usage, nil
+			}
+			w.Write([]byte(chunk.Content))
+			content.WriteString(chunk.Content)
+		}
+	}
+}
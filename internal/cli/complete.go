@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 
 	"github.com/not-emily/sage/pkg/sage"
@@ -15,16 +19,45 @@ func runComplete(args []string) error {
 	fs := flag.NewFlagSet("complete", flag.ExitOnError)
 
 	profile := fs.String("profile", "", "profile to use (default: use default profile)")
+	model := fs.String("model", "", "model to use instead of the profile's, on the same provider account")
 	system := fs.String("system", "", "system message")
+	promptFile := fs.String("prompt-file", "", "read the prompt from a file (piped stdin is appended as content)")
 	maxTokens := fs.Int("max-tokens", 0, "maximum tokens to generate")
+	temperature := fs.Float64("temperature", 0, "sampling temperature, 0 to 2 (default: provider's)")
+	topP := fs.Float64("top-p", 0, "nucleus sampling cutoff, 0 to 1 (default: provider's)")
+	reasoningEffort := fs.String("reasoning-effort", "", "how long reasoning models think: low, medium or high (default: profile's)")
+	seed := fs.Int("seed", 0, "sampling seed, for reproducible output where the provider supports it")
+	extraBody := fs.String("extra-body", "", "JSON object merged into the request body, for provider parameters sage doesn't model")
 	jsonOutput := fs.Bool("json", false, "output JSON instead of streaming")
+	user := fs.String("user", "", "end-user ID forwarded to the provider for attribution")
+	requestID := fs.String("request-id", "", "request ID sent to the provider for correlation (default: generated)")
+	tee := fs.String("tee", "", "also write the response to this file")
+	teeMeta := fs.Bool("tee-meta", false, "with --tee, write usage metadata to <file>.meta.json")
+	strict := fs.Bool("strict", false, "fail instead of warning when the prompt is counted and won't fit the model's context window")
+	resume := fs.Bool("resume", false, "if the stream drops mid-response, reconnect and continue from what was received")
+	idleTimeout := fs.Duration("idle-timeout", 0, "abort a stream that receives nothing, not even a keep-alive, for this long (e.g. 60s)")
+	var images []string
+	fs.Func("image", "attach an image file, http(s) URL or base64 data: URL (repeatable)", func(s string) error {
+		images = append(images, s)
+		return nil
+	})
+	var docs []string
+	fs.Func("doc", "attach a PDF or text document file or base64 data: URL (repeatable)", func(s string) error {
+		docs = append(docs, s)
+		return nil
+	})
+	jsonSchema := fs.String("json-schema", "", "ask for JSON output matching the JSON Schema in this file")
+	stats := fs.Bool("stats", false, "after streaming, print time to first token, total time, and token usage to stderr")
+	moderate := fs.String("moderate", "", "check the prompt with this moderation profile first, and refuse it if flagged")
+	render := fs.Bool("render", false, "render Markdown in the response for the terminal, when stdout is one (default: config's render setting)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: sage complete [flags] [prompt]
 
 Send a completion request to an LLM.
 
-If no prompt is provided, reads from stdin.
+If no prompt is provided, reads from stdin. With --prompt-file, the prompt
+comes from the file and any piped stdin is appended to it.
 
 Flags:
 `)
@@ -33,40 +66,167 @@ Flags:
 Examples:
   sage complete "Hello, world!"
   sage complete --profile=big_brain "Explain quantum computing"
+  sage complete --model=gpt-4.1-mini "Hello, world!"
   sage complete --json "What is 2+2?"
   echo "Summarize this" | sage complete
+  git diff | sage complete --prompt-file=prompts/review.md
+  sage complete --tee=story.md --tee-meta "Write a long story"
+  sage complete --stats "Write a haiku"
+  sage complete --render "Compare Go and Rust in a table"
+  sage complete --json-schema=person.json "Invent a fictional person"
+  sage complete --image=chart.png "What does this chart show?"
+  sage complete --profile=claude --doc=paper.pdf "What are the key findings?"
+  sage complete --temperature=0 "Classify this as spam or not: ..."
+  sage complete --seed=42 --json "Pick a random number"
+  sage complete --profile=o3 --reasoning-effort=low "Is 1009 prime?"
+  sage complete --profile=local --extra-body='{"options": {"num_ctx": 32768}}' "..."
+  cat user-input.txt | sage complete --moderate=mod
 `)
 	}
 
 	fs.Parse(args)
 
-	// Get prompt from args or stdin
-	prompt := getPrompt(fs.Args())
+	// Get prompt from file, args, or stdin
+	var prompt string
+	if *promptFile != "" {
+		if fs.NArg() > 0 {
+			return fmt.Errorf("--prompt-file cannot be combined with a prompt argument")
+		}
+		var err error
+		prompt, err = getPromptFile(*promptFile)
+		if err != nil {
+			return err
+		}
+	} else {
+		prompt = getPrompt(fs.Args())
+	}
 	if prompt == "" {
 		return fmt.Errorf("no prompt provided")
 	}
 
+	extra, err := parseExtraBody(*extraBody)
+	if err != nil {
+		return err
+	}
+
 	// Create client
-	client, err := sage.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
+	if *moderate != "" {
+		client.SetPreSendHook(client.ModerationHook(*moderate))
+	}
 
 	req := sage.Request{
 		Prompt:    prompt,
 		System:    *system,
 		MaxTokens: *maxTokens,
+		Model:     *model,
+		RequestID: *requestID,
+		User:      *user,
+
+		ReasoningEffort: *reasoningEffort,
+		ExtraBody:       extra,
+
+		ResumeOnDisconnect: *resume,
+		IdleTimeout:        *idleTimeout,
+	}
+	// Only send sampling parameters that were set, since 0 is meaningful
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "temperature":
+			req.Temperature = temperature
+		case "top-p":
+			req.TopP = topP
+		case "seed":
+			req.Seed = seed
+		}
+	})
+	for _, source := range images {
+		img, err := sage.LoadImage(source)
+		if err != nil {
+			return err
+		}
+		req.Images = append(req.Images, img)
+	}
+	for _, source := range docs {
+		doc, err := sage.LoadDocument(source)
+		if err != nil {
+			return err
+		}
+		req.Documents = append(req.Documents, doc)
+	}
+	if *jsonSchema != "" {
+		req.ResponseFormat, err = readSchemaFile(*jsonSchema)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Warn about deprecated or retired models
+	if dep, err := client.CheckModel(*profile, *model); err == nil && dep != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", dep)
+	}
+
+	// Catch requests that are guaranteed to be rejected before sending them.
+	// An estimate is too rough to fail on, even with --strict.
+	if err := client.CheckPromptSize(*profile, req); err != nil {
+		var sizeErr *sage.PromptSizeError
+		if !errors.As(err, &sizeErr) || (*strict && !sizeErr.Estimated) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	var out *teeFile
+	if *tee != "" {
+		out, err = openTee(*tee, *teeMeta)
+		if err != nil {
+			return err
+		}
+	} else if *teeMeta {
+		return fmt.Errorf("--tee-meta requires --tee")
 	}
 
 	if *jsonOutput {
-		return completeJSON(client, *profile, req)
+		return completeJSON(client, *profile, req, out)
 	}
 
-	return completeStream(client, *profile, req)
+	// --render=false turns off rendering the config asks for
+	renderOn := client.RenderMarkdown()
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "render" {
+			renderOn = *render
+		}
+	})
+	return completeStream(client, *profile, req, newResponseWriter(renderOn), out, *stats)
 }
 
-func completeJSON(client *sage.Client, profile string, req sage.Request) error {
-	resp, err := client.Complete(profile, req)
+func completeJSON(client *sage.Client, profile string, req sage.Request, tee *teeFile) error {
+	resp, err := client.Complete(context.Background(), profile, req)
+	if tee != nil {
+		meta := newTeeMeta(client, profile, req)
+		if err != nil {
+			meta.Error = err.Error()
+		} else {
+			tee.Write([]byte(resp.Content))
+			meta.Complete = true
+			meta.Model = resp.Model
+			meta.RequestID = resp.RequestID
+			meta.ProviderRequestID = resp.ProviderRequestID
+			meta.SystemFingerprint = resp.SystemFingerprint
+			meta.FinishReason = resp.FinishReason
+			meta.FirstTokenMS = resp.Timing.FirstToken.Milliseconds()
+			meta.Usage = teeUsage{
+				PromptTokens:     resp.Usage.PromptTokens,
+				CompletionTokens: resp.Usage.CompletionTokens,
+			}
+		}
+		if closeErr := tee.Close(meta); err == nil {
+			err = closeErr
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -78,6 +238,30 @@ func completeJSON(client *sage.Client, profile string, req sage.Request) error {
 			"prompt_tokens":     resp.Usage.PromptTokens,
 			"completion_tokens": resp.Usage.CompletionTokens,
 		},
+		"request_id": resp.RequestID,
+		"timing": map[string]int64{
+			"total_ms":       resp.Timing.Total.Milliseconds(),
+			"first_token_ms": resp.Timing.FirstToken.Milliseconds(),
+			"provider_ms":    resp.Timing.Provider.Milliseconds(),
+		},
+	}
+	if req.User != "" {
+		output["user"] = req.User
+	}
+	if resp.ProviderRequestID != "" {
+		output["provider_request_id"] = resp.ProviderRequestID
+	}
+	if len(resp.Citations) > 0 {
+		output["citations"] = resp.Citations
+	}
+	if req.Seed != nil {
+		output["seed"] = *req.Seed
+	}
+	if resp.SystemFingerprint != "" {
+		output["system_fingerprint"] = resp.SystemFingerprint
+	}
+	if resp.FinishReason != "" {
+		output["finish_reason"] = resp.FinishReason
 	}
 
 	enc := json.NewEncoder(os.Stdout)
@@ -85,31 +269,225 @@ func completeJSON(client *sage.Client, profile string, req sage.Request) error {
 	return enc.Encode(output)
 }
 
-func completeStream(client *sage.Client, profile string, req sage.Request) error {
-	chunks, err := client.CompleteStream(profile, req)
+func completeStream(client *sage.Client, profile string, req sage.Request, w responseWriter, tee *teeFile, stats bool) (err error) {
+	var content strings.Builder
+	complete := false
+	var timing *sage.Timing
+	var providerRequestID, systemFingerprint, finishReason string
+	var reported *sage.Usage
+
+	// Use the provider's token counts if it reported them, and otherwise
+	// estimate usage from what was streamed
+	usage := func() sage.Usage {
+		if reported != nil {
+			return *reported
+		}
+		return sage.Usage{
+			PromptTokens:     sage.EstimateTokens(req.System) + sage.EstimateTokens(req.Prompt),
+			CompletionTokens: sage.EstimateTokens(content.String()),
+		}
+	}
+
+	if tee != nil {
+		defer func() {
+			meta := newTeeMeta(client, profile, req)
+			meta.Complete = complete
+			meta.ProviderRequestID = providerRequestID
+			meta.SystemFingerprint = systemFingerprint
+			meta.FinishReason = finishReason
+			if timing != nil {
+				meta.FirstTokenMS = timing.FirstToken.Milliseconds()
+			}
+			if err != nil {
+				meta.Error = err.Error()
+			}
+			u := usage()
+			meta.Usage = teeUsage{
+				PromptTokens:     u.PromptTokens,
+				CompletionTokens: u.CompletionTokens,
+				Estimated:        reported == nil,
+			}
+			if closeErr := tee.Close(meta); err == nil {
+				err = closeErr
+			}
+		}()
+	}
+
+	// Cancelled on return, so an interrupted stream's connection is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chunks, err := client.CompleteStream(ctx, profile, req)
 	if err != nil {
 		return err
 	}
 
-	for chunk := range chunks {
-		if chunk.Error != nil {
-			return chunk.Error
+	// Catch Ctrl-C so partial usage can be reported
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	// Report what was received if the stream ends early
+	defer func() {
+		if err != nil && content.Len() > 0 {
+			w.End()
+			printPartialUsage(requestModel(client, profile, req), usage())
+		}
+	}()
+
+	for {
+		select {
+		case <-interrupt:
+			return fmt.Errorf("interrupted")
+		case chunk, ok := <-chunks:
+			if !ok {
+				w.End() // Final newline
+				return nil
+			}
+			if chunk.Error != nil {
+				return chunk.Error
+			}
+			if chunk.Done {
+				complete = true
+				timing = chunk.Timing
+				providerRequestID = chunk.ProviderRequestID
+				systemFingerprint = chunk.SystemFingerprint
+				finishReason = chunk.FinishReason
+				reported = chunk.Usage
+				w.End() // Final newline
+				printSources(chunk.Citations)
+				printFinishWarning(finishReason)
+				if stats && timing != nil {
+					printStats(requestModel(client, profile, req), *timing, reported)
+				}
+				return nil
+			}
+			w.Write([]byte(chunk.Content))
+			content.WriteString(chunk.Content)
+			if tee != nil {
+				if _, err := tee.Write([]byte(chunk.Content)); err != nil {
+					return fmt.Errorf("cannot write tee file: %w", err)
+				}
+			}
+		}
+	}
+}
+
+// requestModel returns the model req is sent to: its own, or the profile's.
+func requestModel(client *sage.Client, profile string, req sage.Request) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	if p, err := client.GetProfile(profile); err == nil {
+		return p.Model
+	}
+	return ""
+}
+
+// printFinishWarning notes on stderr when a response was cut short by the
+// token limit or withheld by a content filter, which is otherwise easy to
+// miss in streamed output.
+func printFinishWarning(reason string) {
+	switch reason {
+	case sage.FinishLength:
+		fmt.Fprintln(os.Stderr, "warning: response truncated at the max tokens limit")
+	case sage.FinishContentFilter:
+		fmt.Fprintln(os.Stderr, "warning: response stopped by the provider's content filter")
+	}
+}
+
+// printPartialUsage reports estimated usage of an incomplete stream on stderr.
+func printPartialUsage(model string, usage sage.Usage) {
+	msg := fmt.Sprintf("partial response: ~%d prompt + ~%d completion tokens", usage.PromptTokens, usage.CompletionTokens)
+	if cost, ok := sage.EstimateCost(model, usage); ok {
+		msg += fmt.Sprintf(" (~$%.4f)", cost)
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// printSources lists the sources a search-backed model cited, numbered to
+// match the [n] markers in its response.
+func printSources(citations []sage.Citation) {
+	if len(citations) == 0 {
+		return
+	}
+	fmt.Println("\nSources:")
+	for i, c := range citations {
+		if c.Title != "" {
+			fmt.Printf("  [%d] %s - %s\n", i+1, c.Title, c.URL)
+		} else {
+			fmt.Printf("  [%d] %s\n", i+1, c.URL)
 		}
-		if chunk.Done {
-			break
+	}
+}
+
+// printStats prints a streamed response's timings, and its usage and cost
+// if the provider reported them, to stderr.
+func printStats(model string, t sage.Timing, usage *sage.Usage) {
+	msg := fmt.Sprintf("first token %dms, total %.2fs", t.FirstToken.Milliseconds(), t.Total.Seconds())
+	if usage != nil {
+		msg += fmt.Sprintf(", %d prompt + %d completion tokens", usage.PromptTokens, usage.CompletionTokens)
+		if cost, ok := sage.EstimateCost(model, *usage); ok {
+			msg += fmt.Sprintf(" ($%.4f)", cost)
 		}
-		fmt.Print(chunk.Content)
 	}
-	fmt.Println() // Final newline
+	fmt.Fprintln(os.Stderr, msg)
+}
 
-	return nil
+// newTeeMeta fills in sidecar fields known before the response arrives.
+func newTeeMeta(client *sage.Client, profile string, req sage.Request) teeMeta {
+	meta := teeMeta{RequestID: req.RequestID, User: req.User, Model: requestModel(client, profile, req)}
+	if p, err := client.GetProfile(profile); err == nil {
+		meta.Profile = p.Name
+	}
+	return meta
 }
 
 func getPrompt(args []string) string {
 	if len(args) > 0 {
 		return strings.Join(args, " ")
 	}
+	return readStdin()
+}
+
+// getPromptFile reads a prompt from path, followed by any piped stdin content.
+func getPromptFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read prompt file: %w", err)
+	}
+
+	prompt := strings.TrimSpace(string(data))
+	if content := readStdin(); content != "" {
+		prompt += "\n\n" + content
+	}
+	return prompt, nil
+}
+
+// readSchemaFile reads a JSON Schema for --json-schema. The schema is named
+// after the file, since some providers require a name.
+func readSchemaFile(path string) (*sage.ResponseFormat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema file: %w", err)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("schema file %s is not valid JSON", path)
+	}
+
+	base := filepath.Base(path)
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSuffix(base, filepath.Ext(base)))
+
+	return &sage.ResponseFormat{Type: "json_schema", Name: name, Schema: data}, nil
+}
 
+// readStdin returns piped stdin content, or "" if stdin is a terminal.
+func readStdin() string {
 	// Check if stdin has data
 	stat, _ := os.Stdin.Stat()
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
@@ -0,0 +1,379 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return showConfigHelp()
+	}
+
+	switch args[0] {
+	case "validate":
+		return runConfigValidate(args[1:])
+	case "edit":
+		return runConfigEdit(args[1:])
+	case "show":
+		return runConfigShow(args[1:])
+	case "get":
+		return runConfigGet(args[1:])
+	case "set":
+		return runConfigSet(args[1:])
+	case "help", "-h", "--help":
+		return showConfigHelp()
+	default:
+		return fmt.Errorf("unknown config command: %s\nRun 'sage config help' for usage", args[0])
+	}
+}
+
+func showConfigHelp() error {
+	help := `Usage: sage config <command> [flags]
+
+Commands:
+  validate  Check the config for mistakes, with how to fix each
+  edit      Edit the config file in $EDITOR, saving it only if it's valid
+  show      Print the config in effect, without secrets
+  get       Print one setting, by its dotted path
+  set       Change one setting, by its dotted path
+
+Examples:
+  sage config validate
+  sage config validate --json
+  sage config edit
+  sage config show --format=yaml
+  sage config get profiles.fast.model
+  sage config set default_profile fast
+`
+	fmt.Print(help)
+	return nil
+}
+
+func runConfigValidate(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage config validate [flags]
+
+Check the config for mistakes that otherwise only show up when a request
+is made: unknown providers, bad base URLs and proxies, profiles using
+providers or accounts that aren't configured, a default profile that
+doesn't exist, and stored keys for accounts that aren't configured.
+
+Exits non-zero if any errors are found; warnings alone don't fail.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage config validate
+  sage config validate --json
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	issues := client.ValidateConfig()
+	errs := 0
+	for _, issue := range issues {
+		if issue.Severity == sage.IssueError {
+			errs++
+		}
+	}
+
+	if *jsonOutput {
+		if issues == nil {
+			issues = []sage.ConfigIssue{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(issues); err != nil {
+			return err
+		}
+	} else if len(issues) == 0 {
+		fmt.Println("Config OK.")
+	} else {
+		printIssues(issues)
+	}
+
+	if errs > 0 {
+		return fmt.Errorf("%d errors in config", errs)
+	}
+	return nil
+}
+
+// printIssues prints config issues with their fixes.
+func printIssues(issues []sage.ConfigIssue) {
+	for _, issue := range issues {
+		fmt.Println(issue)
+		if issue.Fix != "" {
+			fmt.Printf("  fix: %s\n", issue.Fix)
+		}
+	}
+}
+
+func runConfigEdit(args []string) error {
+	fs := flag.NewFlagSet("config edit", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage config edit
+
+Open the user config file in $VISUAL or $EDITOR (default vi, or notepad on
+Windows). When the editor exits, the file is checked as 'sage config
+validate' would: if it doesn't parse or has errors, such as an unknown
+provider, nothing is saved and you can edit it again.
+
+Examples:
+  sage config edit
+  EDITOR="code --wait" sage config edit
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	path, err := sage.ConfigPath()
+	if err != nil {
+		return err
+	}
+	old, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read config: %w", err)
+	}
+	data := old
+	if data == nil && filepath.Ext(path) == ".json" {
+		data = []byte("{\n}\n")
+	}
+
+	// Edit a copy, so the config is only replaced once it's valid
+	tmp, err := os.CreateTemp("", "sage-config-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	for {
+		if err := runEditor(tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		edited, err := os.ReadFile(tmpPath)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, data) {
+			os.Remove(tmpPath)
+			fmt.Println("No changes.")
+			return nil
+		}
+
+		issues, err := client.CheckConfigFile(edited)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		} else {
+			printIssues(issues)
+			if !hasConfigErrors(issues) {
+				if err := client.ReplaceConfigFile(old, edited); err != nil {
+					return fmt.Errorf("%w\nYour edits are in %s", err, tmpPath)
+				}
+				os.Remove(tmpPath)
+				fmt.Printf("Saved %s\n", path)
+				return nil
+			}
+		}
+
+		fmt.Print("Edit again? [Y/n] ")
+		answer, err := readLine()
+		if err != nil || strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "n") {
+			return fmt.Errorf("config not saved; your edits are in %s", tmpPath)
+		}
+	}
+}
+
+// runEditor opens path in the user's editor and waits for it to exit.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// Editors may take arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", fields[0], err)
+	}
+	return nil
+}
+
+// hasConfigErrors reports whether any of issues is an error.
+func hasConfigErrors(issues []sage.ConfigIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == sage.IssueError {
+			return true
+		}
+	}
+	return false
+}
+
+func runConfigShow(args []string) error {
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	format := fs.String("format", "json", "output format: json, yaml or toml")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage config show [flags]
+
+Print the config in effect: the system, user and project configs layered.
+API keys are never shown, nor are proxy passwords or the values of
+credential headers such as Authorization.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage config show
+  sage config show --format=yaml
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	data, err := client.ShowConfig(*format)
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		fmt.Println()
+	}
+	return nil
+}
+
+func runConfigGet(args []string) error {
+	fs := flag.NewFlagSet("config get", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "output JSON, even for text")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage config get <path> [flags]
+
+Print the setting at a dotted path of config keys, in the config in effect.
+Text is printed as it is; other values, and any value with --json, as JSON.
+Exits non-zero if the setting isn't set.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage config get default_profile
+  sage config get profiles.fast.model
+  sage config get providers.openai --json
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("setting path required")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	value, err := client.GetConfigValue(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if s, ok := value.(string); ok && !*jsonOutput {
+		fmt.Println(s)
+		return nil
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func runConfigSet(args []string) error {
+	fs := flag.NewFlagSet("config set", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage config set <path> <value>
+
+Change the setting at a dotted path of config keys under providers,
+profiles, default_profile, retry or render. The value is read as the setting's
+type: text, true or false, a number, or JSON for lists and objects. The
+change is refused if it would make the config invalid, e.g. a default
+profile that doesn't exist.
+
+Examples:
+  sage config set default_profile fast
+  sage config set profiles.fast.model gpt-4.1-mini
+  sage config set providers.openai.rotate_keys true
+  sage config set retry.max_retries 5
+  sage config set render true
+  sage config set providers.openrouter.headers '{"X-Title": "my-app"}'
+`)
+	}
+
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("setting path and value required")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	if err := client.SetConfigValue(fs.Arg(0), fs.Arg(1)); err != nil {
+		return err
+	}
+	fmt.Printf("Set %s\n", fs.Arg(0))
+	return nil
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// embedInput is one text to embed and where it came from.
+type embedInput struct {
+	Source string // File name, or "-" for stdin
+	Line   int    // Line number with --lines, otherwise 0
+	Text   string
+}
+
+// embedResult is one embedding in the output.
+type embedResult struct {
+	Index     int       `json:"index"`
+	Source    string    `json:"source"`
+	Line      int       `json:"line,omitempty"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// embedOutput is the --format=json output.
+type embedOutput struct {
+	Model      string        `json:"model"`
+	Embeddings []embedResult `json:"embeddings"`
+	Usage      embedUsage    `json:"usage"`
+}
+
+type embedUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+}
+
+func runEmbed(args []string) error {
+	fs := flag.NewFlagSet("embed", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile to use; its model must be an embedding model (default: use default profile)")
+	dimensions := fs.Int("dimensions", 0, "shorten vectors to this many dimensions, for models that support it")
+	lines := fs.Bool("lines", false, "embed each non-blank line separately instead of each file as a whole")
+	format := fs.String("format", "json", "output format: json or jsonl")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage embed [flags] [file...]
+
+Embed text and print the vectors.
+
+Each file is embedded as one input; with --lines, each non-blank line is.
+With no files, or "-", stdin is read.
+
+With --format=json (the default) the output is one object with the model,
+usage, and an "embeddings" array. With --format=jsonl each embedding is
+printed on its own line. Every embedding records its input's "index",
+"source" file and, with --lines, "line".
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  echo "hello world" | sage embed --profile=embed
+  sage embed --profile=embed doc1.txt doc2.txt
+  sage embed --profile=embed --lines --format=jsonl sentences.txt > vectors.jsonl
+  sage embed --profile=embed --dimensions=256 notes.md
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if *format != "json" && *format != "jsonl" {
+		return fmt.Errorf("--format must be json or jsonl")
+	}
+
+	sources := fs.Args()
+	if len(sources) == 0 {
+		sources = []string{"-"}
+	}
+	inputs, err := readEmbedInputs(sources, *lines)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("no input to embed")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	texts := make([]string, len(inputs))
+	for i, in := range inputs {
+		texts[i] = in.Text
+	}
+	resp, err := client.Embed(*profile, sage.EmbedRequest{Input: texts, Dimensions: *dimensions})
+	if err != nil {
+		return err
+	}
+
+	results := make([]embedResult, len(inputs))
+	for i, in := range inputs {
+		results[i] = embedResult{Index: i, Source: in.Source, Line: in.Line, Embedding: resp.Embeddings[i]}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	if *format == "jsonl" {
+		for _, r := range results {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return enc.Encode(embedOutput{
+		Model:      resp.Model,
+		Embeddings: results,
+		Usage:      embedUsage{PromptTokens: resp.Usage.PromptTokens},
+	})
+}
+
+// readEmbedInputs reads the texts to embed from files ("-" for stdin),
+// whole or line by line.
+func readEmbedInputs(sources []string, lines bool) ([]embedInput, error) {
+	var inputs []embedInput
+	for _, source := range sources {
+		var r io.Reader = os.Stdin
+		if source != "-" {
+			f, err := os.Open(source)
+			if err != nil {
+				return nil, fmt.Errorf("cannot open input: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		if !lines {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read %s: %w", source, err)
+			}
+			text := strings.TrimSpace(string(data))
+			if text == "" {
+				return nil, fmt.Errorf("%s is empty", source)
+			}
+			inputs = append(inputs, embedInput{Source: source, Text: text})
+			continue
+		}
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			inputs = append(inputs, embedInput{Source: source, Line: line, Text: text})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", source, err)
+		}
+	}
+	return inputs, nil
+}
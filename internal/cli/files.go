@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// fileInfo is the --json output of sage files list and upload.
+type fileInfo struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Purpose   string    `json:"purpose"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newFileInfo(f sage.File) fileInfo {
+	return fileInfo{ID: f.ID, Filename: f.Filename, Purpose: f.Purpose, Bytes: f.Bytes, CreatedAt: f.CreatedAt}
+}
+
+func runFiles(args []string) error {
+	if len(args) == 0 {
+		return showFilesHelp()
+	}
+
+	switch args[0] {
+	case "upload":
+		return runFilesUpload(args[1:])
+	case "list":
+		return runFilesList(args[1:])
+	case "delete":
+		return runFilesDelete(args[1:])
+	case "help", "-h", "--help":
+		return showFilesHelp()
+	default:
+		return fmt.Errorf("unknown files command: %s\nRun 'sage files help' for usage", args[0])
+	}
+}
+
+func showFilesHelp() error {
+	help := `Usage: sage files <command> [flags]
+
+Manage files stored with a provider, such as fine-tuning data. Supported by
+openai.
+
+Commands:
+  upload      Upload a file and print its ID
+  list        List stored files
+  delete      Delete a stored file
+
+Examples:
+  sage files upload --purpose=fine-tune train.jsonl
+  sage files list --purpose=fine-tune
+  sage finetune create --training-file=file-abc123
+  sage files delete file-abc123
+`
+	fmt.Print(help)
+	return nil
+}
+
+func runFilesUpload(args []string) error {
+	fs := flag.NewFlagSet("files upload", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile whose provider account to use (default: use default profile)")
+	purpose := fs.String("purpose", "", "what the file is for, e.g. fine-tune or batch (required)")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage files upload [flags] <file>
+
+Upload a file and print its ID.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage files upload --purpose=fine-tune train.jsonl
+  sage files upload --profile=work --purpose=batch prompts.jsonl
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("file required")
+	}
+	if *purpose == "" {
+		return fmt.Errorf("--purpose required")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("cannot read file: %w", err)
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	file, err := client.UploadFile(*profile, sage.FileUpload{
+		Data:     data,
+		Filename: filepath.Base(fs.Arg(0)),
+		Purpose:  *purpose,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(newFileInfo(*file))
+	}
+	fmt.Println(file.ID)
+	return nil
+}
+
+func runFilesList(args []string) error {
+	fs := flag.NewFlagSet("files list", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile whose provider account to use (default: use default profile)")
+	purpose := fs.String("purpose", "", "only list files uploaded for this purpose")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+	fs.Parse(reorderArgs(args))
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	files, err := client.ListFiles(*profile, *purpose)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		infos := make([]fileInfo, len(files))
+		for i, f := range files {
+			infos[i] = newFileInfo(f)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No files.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFILENAME\tPURPOSE\tBYTES\tCREATED")
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", f.ID, f.Filename, f.Purpose, f.Bytes, f.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func runFilesDelete(args []string) error {
+	fs := flag.NewFlagSet("files delete", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile whose provider account to use (default: use default profile)")
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("file ID required")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range fs.Args() {
+		if err := client.DeleteFile(*profile, id); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", id)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// fineTuneStatus is the --json output of sage finetune status and list.
+type fineTuneStatus struct {
+	ID             string     `json:"id"`
+	Model          string     `json:"model"`
+	FineTunedModel string     `json:"fine_tuned_model,omitempty"`
+	Status         string     `json:"status"`
+	Done           bool       `json:"done"`
+	CreatedAt      time.Time  `json:"created_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	TrainedTokens  int        `json:"trained_tokens,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
+
+func newFineTuneStatus(job sage.FineTuneJob) fineTuneStatus {
+	status := fineTuneStatus{
+		ID:             job.ID,
+		Model:          job.Model,
+		FineTunedModel: job.FineTunedModel,
+		Status:         job.Status,
+		Done:           job.Done,
+		CreatedAt:      job.CreatedAt,
+		TrainedTokens:  job.TrainedTokens,
+		Error:          job.Error,
+	}
+	if !job.FinishedAt.IsZero() {
+		status.FinishedAt = &job.FinishedAt
+	}
+	return status
+}
+
+func runFineTune(args []string) error {
+	if len(args) == 0 {
+		return showFineTuneHelp()
+	}
+
+	switch args[0] {
+	case "create":
+		return runFineTuneCreate(args[1:])
+	case "list":
+		return runFineTuneList(args[1:])
+	case "status":
+		return runFineTuneStatus(args[1:])
+	case "cancel":
+		return runFineTuneCancel(args[1:])
+	case "help", "-h", "--help":
+		return showFineTuneHelp()
+	default:
+		return fmt.Errorf("unknown finetune command: %s\nRun 'sage finetune help' for usage", args[0])
+	}
+}
+
+func showFineTuneHelp() error {
+	help := `Usage: sage finetune <command> [flags]
+
+Fine-tune the model of a profile on your own examples. Supported by openai.
+
+Commands:
+  create      Upload training data and start a job
+  list        List recent jobs
+  status      Show a job's state, optionally following its events
+  cancel      Cancel a running job
+
+Examples:
+  sage finetune create --profile=mini --suffix=support train.jsonl
+  sage finetune status --follow ftjob-abc123
+  sage finetune list
+  sage finetune cancel ftjob-abc123
+`
+	fmt.Print(help)
+	return nil
+}
+
+func runFineTuneCreate(args []string) error {
+	fs := flag.NewFlagSet("finetune create", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile whose model to fine-tune (default: use default profile)")
+	trainingFile := fs.String("training-file", "", "ID of an uploaded training file, instead of a local file")
+	validation := fs.String("validation", "", "JSONL file of validation examples")
+	validationFile := fs.String("validation-file", "", "ID of an uploaded validation file")
+	suffix := fs.String("suffix", "", "text to include in the fine-tuned model's name")
+	epochs := fs.Int("epochs", 0, "number of training epochs (default: chosen by the provider)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage finetune create [flags] <training.jsonl>
+       sage finetune create [flags] --training-file=<file-id>
+
+Upload training data and start fine-tuning the profile's model.
+
+Each line of the training file is one example conversation in the provider's
+format, e.g. for openai:
+  {"messages": [{"role": "user", "content": "..."}, {"role": "assistant", "content": "..."}]}
+
+Files already uploaded with 'sage files upload' can be used by ID instead.
+
+The job's ID is printed. Follow it with 'sage finetune status --follow'; once
+it succeeds, the fine-tuned model can be used in any profile.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage finetune create --profile=mini train.jsonl
+  sage finetune create --profile=mini --validation=valid.jsonl --suffix=support --epochs=3 train.jsonl
+  sage finetune create --profile=mini --training-file=file-abc123
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if (fs.NArg() < 1) == (*trainingFile == "") {
+		fs.Usage()
+		return fmt.Errorf("training file or --training-file required")
+	}
+	if *validation != "" && *validationFile != "" {
+		return fmt.Errorf("--validation and --validation-file can't be combined")
+	}
+	if *epochs < 0 {
+		return fmt.Errorf("--epochs must not be negative")
+	}
+
+	req := sage.FineTuneRequest{
+		TrainingFileID:   *trainingFile,
+		ValidationFileID: *validationFile,
+		Suffix:           *suffix,
+		Epochs:           *epochs,
+	}
+	var err error
+	if fs.NArg() > 0 {
+		req.TrainingFilename = filepath.Base(fs.Arg(0))
+		if req.TrainingData, err = os.ReadFile(fs.Arg(0)); err != nil {
+			return fmt.Errorf("cannot read training file: %w", err)
+		}
+	}
+	if *validation != "" {
+		if req.ValidationData, err = os.ReadFile(*validation); err != nil {
+			return fmt.Errorf("cannot read validation file: %w", err)
+		}
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	job, err := client.CreateFineTune(*profile, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(job.ID)
+	fmt.Fprintf(os.Stderr, "Started fine-tuning %s as job %s (%s)\n", job.Model, job.ID, job.Status)
+	return nil
+}
+
+func runFineTuneList(args []string) error {
+	fs := flag.NewFlagSet("finetune list", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile to use (default: use default profile)")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+	fs.Parse(reorderArgs(args))
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	jobs, err := client.ListFineTunes(*profile)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		statuses := make([]fineTuneStatus, len(jobs))
+		for i, job := range jobs {
+			statuses[i] = newFineTuneStatus(job)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("No fine-tuning jobs.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tMODEL\tFINE-TUNED MODEL\tCREATED")
+	for _, job := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", job.ID, job.Status, job.Model, job.FineTunedModel, job.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func runFineTuneStatus(args []string) error {
+	fs := flag.NewFlagSet("finetune status", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile the job was created with (default: use default profile)")
+	follow := fs.Bool("follow", false, "print the job's events until it's done")
+	interval := fs.Duration("interval", 30*time.Second, "with --follow, how often to poll")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage finetune status [flags] <job-id>
+
+Show the state of a fine-tuning job.
+
+With --follow, the job's events are printed to stderr as they arrive until the
+job is done.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage finetune status ftjob-abc123
+  sage finetune status --follow --interval=1m ftjob-abc123
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("job ID required")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	id := fs.Arg(0)
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	job, err := client.FineTuneStatus(*profile, id)
+	if err != nil {
+		return err
+	}
+	if *follow {
+		seen := make(map[string]bool)
+		for {
+			events, err := client.FineTuneEvents(*profile, id)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				if !seen[e.ID] {
+					seen[e.ID] = true
+					fmt.Fprintf(os.Stderr, "%s  %s\n", e.CreatedAt.Local().Format("15:04:05"), e.Message)
+				}
+			}
+			if job.Done {
+				break
+			}
+			time.Sleep(*interval)
+			if job, err = client.FineTuneStatus(*profile, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(newFineTuneStatus(*job))
+	}
+	printFineTuneJob(job)
+	return nil
+}
+
+func runFineTuneCancel(args []string) error {
+	fs := flag.NewFlagSet("finetune cancel", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile the job was created with (default: use default profile)")
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("job ID required")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	job, err := client.CancelFineTune(*profile, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	printFineTuneJob(job)
+	return nil
+}
+
+func printFineTuneJob(job *sage.FineTuneJob) {
+	fmt.Printf("%s: %s\n", job.ID, job.Status)
+	fmt.Printf("  model:    %s\n", job.Model)
+	if job.FineTunedModel != "" {
+		fmt.Printf("  fine-tuned model: %s\n", job.FineTunedModel)
+	}
+	if job.TrainedTokens > 0 {
+		fmt.Printf("  trained tokens: %d\n", job.TrainedTokens)
+	}
+	if job.Error != "" {
+		fmt.Printf("  error:    %s\n", job.Error)
+	}
+}
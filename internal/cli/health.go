@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// healthEntry is the JSON form of a provider account's health.
+type healthEntry struct {
+	Provider  string `json:"provider"`
+	Account   string `json:"account"`
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+func runProviderHealth(args []string) error {
+	fs := flag.NewFlagSet("provider health", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage provider health [flags]
+
+Check that every configured provider account is reachable and its API key
+is accepted. Most providers are checked by listing models, and Vertex AI
+by fetching an access token.
+
+Exits non-zero if any account is unhealthy.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage provider health
+  sage provider health --json
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	results := client.CheckHealth()
+	if len(results) == 0 {
+		fmt.Println("No providers configured.")
+		return nil
+	}
+
+	unhealthy := 0
+	entries := make([]healthEntry, len(results))
+	for i, h := range results {
+		entries[i] = healthEntry{
+			Provider:  h.Provider,
+			Account:   h.Account,
+			Status:    h.Status,
+			LatencyMS: h.Latency.Milliseconds(),
+		}
+		if h.Err != nil {
+			entries[i].Error = h.Err.Error()
+		}
+		if h.Status != sage.HealthOK {
+			unhealthy++
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROVIDER\tACCOUNT\tSTATUS\tLATENCY\tERROR")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%dms\t%s\n", e.Provider, e.Account, e.Status, e.LatencyMS, summarizeError(e.Error))
+		}
+		w.Flush()
+	}
+
+	if unhealthy > 0 {
+		return fmt.Errorf("%d of %d accounts unhealthy", unhealthy, len(results))
+	}
+	return nil
+}
+
+// verifyEntry is the JSON form of a verified API key.
+type verifyEntry struct {
+	Provider string `json:"provider"`
+	Account  string `json:"account"`
+	Key      string `json:"key"`
+	Error    string `json:"error,omitempty"`
+}
+
+// keyVerdicts describes a key by the health status of its account.
+var keyVerdicts = map[string]string{
+	sage.HealthOK:          "valid",
+	sage.HealthAuthFailed:  "invalid",
+	sage.HealthKeyExpired:  "expired",
+	sage.HealthUnreachable: "unchecked",
+	sage.HealthError:       "unchecked",
+}
+
+func runProviderVerify(args []string) error {
+	fs := flag.NewFlagSet("provider verify", flag.ExitOnError)
+	account := fs.String("account", "", "verify only this account")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage provider verify [provider] [flags]
+
+Check that provider accounts' API keys are accepted, with the cheapest
+authenticated request each provider offers: listing models, or a 1-token
+completion where there's no models endpoint. Keys are reported valid,
+invalid or expired, or unchecked if the provider couldn't be reached.
+
+Without a provider, verifies every configured account. Exits non-zero
+unless every key verified is valid.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage provider verify
+  sage provider verify openai
+  sage provider verify openai --account=work
+  sage provider verify --json
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+	providerName := fs.Arg(0)
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	results := client.CheckHealthFor(providerName, *account)
+	if len(results) == 0 {
+		switch {
+		case providerName != "" && *account != "":
+			return fmt.Errorf("no account %s:%s", providerName, *account)
+		case providerName != "":
+			return fmt.Errorf("provider %s is not configured", providerName)
+		case *account != "":
+			return fmt.Errorf("no provider has an account %s", *account)
+		}
+		fmt.Println("No providers configured.")
+		return nil
+	}
+
+	failed := 0
+	entries := make([]verifyEntry, len(results))
+	for i, h := range results {
+		entries[i] = verifyEntry{
+			Provider: h.Provider,
+			Account:  h.Account,
+			Key:      keyVerdicts[h.Status],
+		}
+		if h.Err != nil {
+			entries[i].Error = h.Err.Error()
+		}
+		if h.Status != sage.HealthOK {
+			failed++
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROVIDER\tACCOUNT\tKEY\tERROR")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Provider, e.Account, e.Key, summarizeError(e.Error))
+		}
+		w.Flush()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d keys not verified", failed, len(results))
+	}
+	return nil
+}
+
+// summarizeError shortens an error to one line for the health table.
+// Provider error bodies can be whole HTML pages.
+func summarizeError(msg string) string {
+	const maxLen = 80
+	msg, _, _ = strings.Cut(msg, "\n")
+	if len(msg) > maxLen {
+		msg = msg[:maxLen-3] + "..."
+	}
+	return msg
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+func runImage(args []string) error {
+	fs := flag.NewFlagSet("image", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile to use; its model must be an image model (default: use default profile)")
+	out := fs.String("out", "", "file to write the image to; with --count, numbered files like cat-1.png (required)")
+	count := fs.Int("count", 1, "number of images to generate")
+	size := fs.String("size", "", "image size, e.g. 1024x1024 or 1536x1024 (default: the model's)")
+	quality := fs.String("quality", "", "image quality, e.g. low, medium or high for gpt-image-1, hd for dall-e-3 (default: the model's)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage image [flags] [prompt]
+
+Generate images from a prompt and save them.
+
+If no prompt is provided, reads from stdin. Supported by openai
+(gpt-image-1, dall-e-3) and xai (grok-2-image). The paths written are
+printed, one per line; prompts the model rewrote are shown on stderr.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage profile add draw --provider=openai --model=gpt-image-1
+  sage image --profile=draw --out=lighthouse.png "A lighthouse at dusk, oil painting"
+  sage image --profile=draw --out=logo.png --count=4 --size=1024x1024 --quality=high "A minimal fox logo"
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	prompt := getPrompt(fs.Args())
+	if prompt == "" {
+		return fmt.Errorf("no prompt provided")
+	}
+	if *out == "" {
+		return fmt.Errorf("--out required")
+	}
+	if *count < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.GenerateImages(*profile, sage.ImageGenerationRequest{
+		Prompt:  prompt,
+		N:       *count,
+		Size:    *size,
+		Quality: *quality,
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Images) == 0 {
+		return fmt.Errorf("no images returned")
+	}
+
+	for i, img := range resp.Images {
+		path := *out
+		if len(resp.Images) > 1 {
+			path = numberedPath(*out, i+1)
+		}
+		if err := os.WriteFile(path, img.Data, 0644); err != nil {
+			return fmt.Errorf("cannot write image: %w", err)
+		}
+		fmt.Println(path)
+		if img.RevisedPrompt != "" {
+			fmt.Fprintf(os.Stderr, "%s: revised prompt: %s\n", path, img.RevisedPrompt)
+		}
+	}
+	return nil
+}
+
+// numberedPath inserts a number before path's extension: cat.png becomes
+// cat-2.png.
+func numberedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
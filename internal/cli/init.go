@@ -16,7 +16,8 @@ func runInit(args []string) error {
 Initialize sage configuration.
 
 Creates:
-  ~/.config/sage/config.json   Configuration file
+  ~/.config/sage/config.json   Configuration file, unless a config.json,
+                               config.yaml or config.toml exists
   ~/.config/sage/master.key    Encryption key for API secrets
   ~/.config/sage/secrets.enc   Encrypted secrets storage
 
@@ -46,13 +47,19 @@ Creates:
 		return fmt.Errorf("failed to initialize secrets: %w", err)
 	}
 
-	// Create empty config
-	config := &sage.Config{
-		Providers: make(map[string]sage.ProviderConfig),
-		Profiles:  make(map[string]sage.Profile),
+	// Create empty config, unless one was written by hand
+	configPath, err := sage.ConfigPath()
+	if err != nil {
+		return err
 	}
-	if err := config.Save(); err != nil {
-		return fmt.Errorf("failed to create config: %w", err)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		config := &sage.Config{
+			Providers: make(map[string]sage.ProviderConfig),
+			Profiles:  make(map[string]sage.Profile),
+		}
+		if err := config.Save(); err != nil {
+			return fmt.Errorf("failed to create config: %w", err)
+		}
 	}
 
 	fmt.Printf("Sage initialized at %s\n", configDir)
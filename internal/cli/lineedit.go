@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errLineInterrupted is returned by lineEditor.readLine when Ctrl-C is
+// pressed.
+var errLineInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines of input with the usual readline editing keys and
+// history when stdin is a terminal, and plain lines otherwise.
+type lineEditor struct {
+	in      *bufio.Reader
+	fd      int
+	tty     bool
+	history []string
+}
+
+func newLineEditor() *lineEditor {
+	stat, _ := os.Stdin.Stat()
+	return &lineEditor{
+		in:  bufio.NewReader(os.Stdin),
+		fd:  int(os.Stdin.Fd()),
+		tty: stat != nil && stat.Mode()&os.ModeCharDevice != 0,
+	}
+}
+
+// addHistory adds a line to the history recalled with the up arrow.
+func (e *lineEditor) addHistory(line string) {
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+// readLine prints prompt and reads a line. It returns io.EOF for Ctrl-D on
+// an empty line, or at the end of piped input, and errLineInterrupted for
+// Ctrl-C.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	var restore func()
+	if e.tty {
+		restore, _ = makeRaw(e.fd)
+	}
+	if restore == nil {
+		fmt.Print(prompt)
+		line, err := e.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	defer restore()
+
+	s := &lineState{prompt: []rune(prompt), width: terminalWidth(e.fd)}
+	histIndex := len(e.history)
+	var edited string // The line being written, while browsing history
+	s.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			s.moveEnd()
+			fmt.Print("\r\n")
+			return string(s.line), nil
+		case 3: // Ctrl-C
+			s.moveEnd()
+			fmt.Print("^C\r\n")
+			return "", errLineInterrupted
+		case 4: // Ctrl-D
+			if len(s.line) == 0 {
+				fmt.Print("\r\n")
+				return "", io.EOF
+			}
+			s.deleteAt()
+		case 127, 8: // Backspace
+			if s.pos > 0 {
+				s.pos--
+				s.deleteAt()
+			}
+		case 1: // Ctrl-A
+			s.pos = 0
+		case 5: // Ctrl-E
+			s.pos = len(s.line)
+		case 2: // Ctrl-B
+			s.left()
+		case 6: // Ctrl-F
+			s.right()
+		case 11: // Ctrl-K
+			s.line = s.line[:s.pos]
+		case 21: // Ctrl-U
+			s.line = s.line[s.pos:]
+			s.pos = 0
+		case 23: // Ctrl-W
+			s.deleteWord()
+		case 12: // Ctrl-L
+			fmt.Print("\x1b[H\x1b[2J")
+			s.rows = 0
+		case 16, 14: // Ctrl-P, Ctrl-N
+			histIndex, edited = e.recall(s, histIndex, edited, r == 16)
+		case 27: // Escape sequences: arrows, Home, End, Delete
+			switch e.readEscape() {
+			case "[A", "OA":
+				histIndex, edited = e.recall(s, histIndex, edited, true)
+			case "[B", "OB":
+				histIndex, edited = e.recall(s, histIndex, edited, false)
+			case "[C", "OC":
+				s.right()
+			case "[D", "OD":
+				s.left()
+			case "[H", "OH", "[1~", "[7~":
+				s.pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				s.pos = len(s.line)
+			case "[3~":
+				s.deleteAt()
+			case "b":
+				s.wordLeft()
+			case "f":
+				s.wordRight()
+			}
+		default:
+			if unicode.IsPrint(r) || r == '\t' {
+				s.insert(r)
+			}
+		}
+		s.refresh()
+	}
+}
+
+// readEscape reads the rest of an escape sequence after ESC, e.g. "[A" for
+// the up arrow, or "b" for Alt-B.
+func (e *lineEditor) readEscape() string {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return ""
+	}
+	if r != '[' && r != 'O' {
+		return string(r)
+	}
+	seq := []rune{r}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+		// Sequences end with a letter or ~, after any digits and semicolons
+		if r == '~' || unicode.IsLetter(r) {
+			return string(seq)
+		}
+	}
+}
+
+// recall replaces the line with the previous (older) or next history entry.
+// edited keeps the line that was being written, restored past the newest
+// entry.
+func (e *lineEditor) recall(s *lineState, index int, edited string, older bool) (int, string) {
+	if index == len(e.history) {
+		edited = string(s.line)
+	}
+	switch {
+	case older && index > 0:
+		index--
+	case !older && index < len(e.history):
+		index++
+	default:
+		return index, edited
+	}
+	if index == len(e.history) {
+		s.line = []rune(edited)
+	} else {
+		s.line = []rune(e.history[index])
+	}
+	s.pos = len(s.line)
+	return index, edited
+}
+
+// lineState is a line being edited on the terminal.
+type lineState struct {
+	prompt []rune
+	line   []rune
+	pos    int // Cursor position in line
+	width  int // Terminal columns
+	rows   int // Rows the cursor is below the prompt's first row
+}
+
+func (s *lineState) insert(r rune) {
+	s.line = append(s.line, 0)
+	copy(s.line[s.pos+1:], s.line[s.pos:])
+	s.line[s.pos] = r
+	s.pos++
+}
+
+// deleteAt deletes the character under the cursor.
+func (s *lineState) deleteAt() {
+	if s.pos < len(s.line) {
+		s.line = append(s.line[:s.pos], s.line[s.pos+1:]...)
+	}
+}
+
+// deleteWord deletes the word before the cursor, and spaces after it.
+func (s *lineState) deleteWord() {
+	end := s.pos
+	s.wordLeft()
+	s.line = append(s.line[:s.pos], s.line[end:]...)
+}
+
+func (s *lineState) left() {
+	if s.pos > 0 {
+		s.pos--
+	}
+}
+
+func (s *lineState) right() {
+	if s.pos < len(s.line) {
+		s.pos++
+	}
+}
+
+func (s *lineState) wordLeft() {
+	for s.pos > 0 && unicode.IsSpace(s.line[s.pos-1]) {
+		s.pos--
+	}
+	for s.pos > 0 && !unicode.IsSpace(s.line[s.pos-1]) {
+		s.pos--
+	}
+}
+
+func (s *lineState) wordRight() {
+	for s.pos < len(s.line) && unicode.IsSpace(s.line[s.pos]) {
+		s.pos++
+	}
+	for s.pos < len(s.line) && !unicode.IsSpace(s.line[s.pos]) {
+		s.pos++
+	}
+}
+
+// moveEnd moves the terminal cursor past the end of the line, so output
+// that follows doesn't overwrite it.
+func (s *lineState) moveEnd() {
+	s.pos = len(s.line)
+	s.refresh()
+}
+
+// refresh redraws the prompt and line, which may wrap over several rows,
+// and puts the cursor at pos.
+func (s *lineState) refresh() {
+	var b strings.Builder
+	if s.rows > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", s.rows)
+	}
+	b.WriteString("\r\x1b[J")
+	b.WriteString(string(s.prompt))
+	b.WriteString(string(s.line))
+
+	end := len(s.prompt) + len(s.line)
+	if end > 0 && end%s.width == 0 {
+		// Terminals wait to wrap until the next character; wrap now so the
+		// cursor can be placed
+		b.WriteString("\r\n")
+	}
+	endRow := end / s.width
+
+	cursor := len(s.prompt) + s.pos
+	row, col := cursor/s.width, cursor%s.width
+	if up := endRow - row; up > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", up)
+	}
+	b.WriteString("\r")
+	if col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	s.rows = row
+	fmt.Print(b.String())
+}
@@ -0,0 +1,564 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ANSI styles. Each is turned off by its own code rather than a reset, so
+// styles can nest.
+const (
+	ansiBold        = "\x1b[1m"
+	ansiDim         = "\x1b[2m"
+	ansiNoBold      = "\x1b[22m" // Ends bold and dim
+	ansiItalic      = "\x1b[3m"
+	ansiNoItalic    = "\x1b[23m"
+	ansiUnderline   = "\x1b[4m"
+	ansiNoUnderline = "\x1b[24m"
+	ansiStrike      = "\x1b[9m"
+	ansiNoStrike    = "\x1b[29m"
+	ansiCyan        = "\x1b[36m"
+	ansiBlue        = "\x1b[34m"
+	ansiNoColor     = "\x1b[39m"
+)
+
+// responseWriter prints a streamed response. End finishes it, ending the
+// last line.
+type responseWriter interface {
+	io.Writer
+	End()
+}
+
+// newResponseWriter returns a writer that renders Markdown for the terminal
+// if render is set and stdout is a terminal that allows color (NO_COLOR
+// isn't set), and otherwise prints responses as they are.
+func newResponseWriter(render bool) responseWriter {
+	if render && isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+		return newMarkdownWriter(os.Stdout, terminalWidth(int(os.Stdout.Fd())))
+	}
+	return plainWriter{}
+}
+
+// plainWriter prints responses unchanged.
+type plainWriter struct{}
+
+func (plainWriter) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (plainWriter) End()                        { fmt.Println() }
+
+// markdownWriter renders Markdown written to it for the terminal: headings,
+// emphasis, lists, quotes, code and tables. It renders a line once it's
+// complete, and a table once it ends, so streamed text appears a line at a
+// time.
+type markdownWriter struct {
+	out   io.Writer
+	width int      // Terminal columns
+	line  []byte   // The line being written, until its newline
+	fence string   // The fence that opened the code block being written
+	table []string // Rows of the table being written
+}
+
+func newMarkdownWriter(out io.Writer, width int) *markdownWriter {
+	return &markdownWriter{out: out, width: width}
+}
+
+func (m *markdownWriter) Write(p []byte) (int, error) {
+	m.line = append(m.line, p...)
+	for {
+		i := strings.IndexByte(string(m.line), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimSuffix(string(m.line[:i]), "\r")
+		m.line = m.line[i+1:]
+		if err := m.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// End renders what's left, a last line without a newline and a table
+// still being written, and starts afresh for the next response.
+func (m *markdownWriter) End() {
+	if len(m.line) > 0 {
+		m.writeLine(string(m.line))
+	}
+	m.writeTable()
+	m.line, m.fence = nil, ""
+}
+
+func (m *markdownWriter) println(s string) error {
+	_, err := fmt.Fprintln(m.out, s)
+	return err
+}
+
+var (
+	mdHeading = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)(\s+#+)?\s*$`)
+	mdRule    = regexp.MustCompile(`^ {0,3}([-*_])(\s*([-*_]))+\s*$`)
+	mdQuote   = regexp.MustCompile(`^ {0,3}>\s?(.*)$`)
+	mdBullet  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdNumber  = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdTask    = regexp.MustCompile(`^\[([ xX])\]\s+`)
+)
+
+func (m *markdownWriter) writeLine(line string) error {
+	trimmed := strings.TrimSpace(line)
+	if m.fence != "" {
+		if strings.HasPrefix(trimmed, m.fence) && strings.Trim(trimmed, m.fence[:1]) == "" {
+			m.fence = ""
+			return nil
+		}
+		return m.println("  " + ansiCyan + line + ansiNoColor)
+	}
+
+	if strings.HasPrefix(trimmed, "|") {
+		m.table = append(m.table, trimmed)
+		return nil
+	}
+	if err := m.writeTable(); err != nil {
+		return err
+	}
+
+	if fence := codeFence(trimmed); fence != "" {
+		m.fence = fence
+		if lang := strings.TrimSpace(trimmed[len(fence):]); lang != "" {
+			return m.println("  " + ansiDim + lang + ansiNoBold)
+		}
+		return nil
+	}
+	return m.println(m.renderBlock(line))
+}
+
+// codeFence returns the ``` or ~~~ (or longer) fence line opens a code
+// block with, or "".
+func codeFence(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			return line[:n]
+		}
+	}
+	return ""
+}
+
+// renderBlock renders a line that isn't in a code block or table.
+func (m *markdownWriter) renderBlock(line string) string {
+	if match := mdHeading.FindStringSubmatch(line); match != nil {
+		style, end := ansiBold, ansiNoBold
+		if len(match[1]) == 1 {
+			style, end = ansiBold+ansiUnderline, ansiNoUnderline+ansiNoBold
+		}
+		return style + renderInline(match[2]) + end
+	}
+	if mdRule.MatchString(line) {
+		return ansiDim + strings.Repeat("─", min(m.width, 80)) + ansiNoBold
+	}
+	if match := mdQuote.FindStringSubmatch(line); match != nil {
+		return ansiDim + "│" + ansiNoBold + " " + m.renderBlock(match[1])
+	}
+	if match := mdBullet.FindStringSubmatch(line); match != nil {
+		item := match[2]
+		marker := "•"
+		if task := mdTask.FindStringSubmatch(item); task != nil {
+			marker = "☐"
+			if task[1] != " " {
+				marker = "☑"
+			}
+			item = item[len(task[0]):]
+		}
+		return match[1] + marker + " " + renderInline(item)
+	}
+	if match := mdNumber.FindStringSubmatch(line); match != nil {
+		return match[1] + match[2] + " " + renderInline(match[3])
+	}
+	return renderInline(line)
+}
+
+// writeTable renders the table being written, if any, with its columns
+// lined up. Rows without the line of dashes under the header aren't a
+// table, and are rendered as text.
+func (m *markdownWriter) writeTable() error {
+	rows := m.table
+	m.table = nil
+	if len(rows) == 0 {
+		return nil
+	}
+	if len(rows) < 2 || !isTableDivider(rows[1]) {
+		for _, row := range rows {
+			if err := m.println(renderInline(row)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	aligns := splitTableRow(rows[1])
+	cells := make([][]string, 0, len(rows)-1)
+	for i, row := range rows {
+		if i == 1 {
+			continue
+		}
+		var rendered []string
+		for _, cell := range splitTableRow(row) {
+			rendered = append(rendered, renderInline(cell))
+		}
+		cells = append(cells, rendered)
+	}
+
+	var widths []int
+	for _, row := range cells {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], visibleWidth(cell))
+		}
+	}
+
+	for r, row := range cells {
+		parts := make([]string, len(widths))
+		for i, width := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			align := ""
+			if i < len(aligns) {
+				align = aligns[i]
+			}
+			cell = padCell(cell, width, align)
+			if r == 0 {
+				cell = ansiBold + cell + ansiNoBold
+			}
+			parts[i] = cell
+		}
+		if err := m.println(strings.Join(parts, ansiDim+" │ "+ansiNoBold)); err != nil {
+			return err
+		}
+		if r == 0 {
+			rule := make([]string, len(widths))
+			for i, width := range widths {
+				rule[i] = strings.Repeat("─", width)
+			}
+			if err := m.println(ansiDim + strings.Join(rule, "─┼─") + ansiNoBold); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTableDivider reports whether row is the line under a table's header,
+// such as |---|:--:|.
+func isTableDivider(row string) bool {
+	cells := splitTableRow(row)
+	for _, cell := range cells {
+		if strings.Trim(cell, ":-") != "" || !strings.Contains(cell, "-") {
+			return false
+		}
+	}
+	return len(cells) > 0
+}
+
+// splitTableRow splits a table row into its trimmed cells. \| is a pipe
+// within a cell.
+func splitTableRow(row string) []string {
+	row = strings.TrimPrefix(strings.TrimSpace(row), "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// padCell pads a rendered cell to width columns, aligned as its column's
+// divider cell says: :-- or --- left, :-: center, --: right.
+func padCell(cell string, width int, align string) string {
+	pad := width - visibleWidth(cell)
+	switch {
+	case strings.HasPrefix(align, ":") && strings.HasSuffix(align, ":"):
+		return strings.Repeat(" ", pad/2) + cell + strings.Repeat(" ", pad-pad/2)
+	case strings.HasSuffix(align, ":"):
+		return strings.Repeat(" ", pad) + cell
+	default:
+		return cell + strings.Repeat(" ", pad)
+	}
+}
+
+// visibleWidth returns the columns s takes on the terminal, not counting
+// ANSI escape sequences.
+func visibleWidth(s string) int {
+	width := 0
+	joined := false
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			end := strings.IndexByte(s[i:], 'm')
+			if end < 0 {
+				break
+			}
+			i += end + 1
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case joined:
+			// Emoji joined by a zero-width joiner show as one
+			joined = false
+		case r == '\u200d':
+			joined = true
+		default:
+			width += runeWidth(r)
+		}
+	}
+	return width
+}
+
+// runeWidth returns the columns r takes on the terminal: two for wide
+// characters, none for combining marks and other invisible characters, and
+// otherwise one.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || r >= 0x1f3fb && r <= 0x1f3ff: // Skin tones
+		return 0
+	case unicode.Is(wideRunes, r):
+		return 2
+	}
+	return 1
+}
+
+// wideRunes are the characters terminals show two columns wide: East Asian
+// wide and fullwidth characters, and emoji.
+var wideRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115f, 1}, // Hangul Jamo
+		{0x231a, 0x231b, 1},
+		{0x2329, 0x232a, 1},
+		{0x23e9, 0x23ec, 1},
+		{0x23f0, 0x23f0, 1},
+		{0x23f3, 0x23f3, 1},
+		{0x25fd, 0x25fe, 1},
+		{0x2614, 0x2615, 1},
+		{0x2648, 0x2653, 1},
+		{0x267f, 0x267f, 1},
+		{0x2693, 0x2693, 1},
+		{0x26a1, 0x26a1, 1},
+		{0x26aa, 0x26ab, 1},
+		{0x26bd, 0x26be, 1},
+		{0x26c4, 0x26c5, 1},
+		{0x26ce, 0x26ce, 1},
+		{0x26d4, 0x26d4, 1},
+		{0x26ea, 0x26ea, 1},
+		{0x26f2, 0x26f3, 1},
+		{0x26f5, 0x26f5, 1},
+		{0x26fa, 0x26fa, 1},
+		{0x26fd, 0x26fd, 1},
+		{0x2705, 0x2705, 1},
+		{0x270a, 0x270b, 1},
+		{0x2728, 0x2728, 1},
+		{0x274c, 0x274c, 1},
+		{0x274e, 0x274e, 1},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2795, 0x2797, 1},
+		{0x27b0, 0x27b0, 1},
+		{0x27bf, 0x27bf, 1},
+		{0x2b1b, 0x2b1c, 1},
+		{0x2b50, 0x2b50, 1},
+		{0x2b55, 0x2b55, 1},
+		{0x2e80, 0x303e, 1}, // CJK radicals, symbols and punctuation
+		{0x3041, 0x33ff, 1}, // Kana, Bopomofo, Hangul compatibility Jamo, CJK compatibility
+		{0x3400, 0x4dbf, 1}, // CJK extension A
+		{0x4e00, 0x9fff, 1}, // CJK unified ideographs
+		{0xa000, 0xa4cf, 1}, // Yi
+		{0xa960, 0xa97f, 1},
+		{0xac00, 0xd7a3, 1}, // Hangul syllables
+		{0xf900, 0xfaff, 1}, // CJK compatibility ideographs
+		{0xfe10, 0xfe19, 1},
+		{0xfe30, 0xfe6f, 1},
+		{0xff00, 0xff60, 1}, // Fullwidth forms
+		{0xffe0, 0xffe6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x16fe0, 0x16fe4, 1},
+		{0x17000, 0x18cff, 1}, // Tangut
+		{0x1b000, 0x1b2ff, 1}, // Kana supplement and extensions
+		{0x1f004, 0x1f004, 1},
+		{0x1f0cf, 0x1f0cf, 1},
+		{0x1f18e, 0x1f18e, 1},
+		{0x1f191, 0x1f19a, 1},
+		{0x1f200, 0x1f202, 1},
+		{0x1f210, 0x1f23b, 1},
+		{0x1f240, 0x1f248, 1},
+		{0x1f250, 0x1f251, 1},
+		{0x1f260, 0x1f265, 1},
+		{0x1f300, 0x1f320, 1}, // Emoji
+		{0x1f32d, 0x1f335, 1},
+		{0x1f337, 0x1f37c, 1},
+		{0x1f37e, 0x1f393, 1},
+		{0x1f3a0, 0x1f3ca, 1},
+		{0x1f3cf, 0x1f3d3, 1},
+		{0x1f3e0, 0x1f3f0, 1},
+		{0x1f3f4, 0x1f3f4, 1},
+		{0x1f3f8, 0x1f43e, 1},
+		{0x1f440, 0x1f440, 1},
+		{0x1f442, 0x1f4fc, 1},
+		{0x1f4ff, 0x1f53d, 1},
+		{0x1f54b, 0x1f54e, 1},
+		{0x1f550, 0x1f567, 1},
+		{0x1f57a, 0x1f57a, 1},
+		{0x1f595, 0x1f596, 1},
+		{0x1f5a4, 0x1f5a4, 1},
+		{0x1f5fb, 0x1f64f, 1},
+		{0x1f680, 0x1f6c5, 1},
+		{0x1f6cc, 0x1f6cc, 1},
+		{0x1f6d0, 0x1f6d2, 1},
+		{0x1f6d5, 0x1f6d7, 1},
+		{0x1f6dc, 0x1f6df, 1},
+		{0x1f6eb, 0x1f6ec, 1},
+		{0x1f6f4, 0x1f6fc, 1},
+		{0x1f7e0, 0x1f7eb, 1},
+		{0x1f7f0, 0x1f7f0, 1},
+		{0x1f90c, 0x1f93a, 1},
+		{0x1f93c, 0x1f945, 1},
+		{0x1f947, 0x1f9ff, 1},
+		{0x1fa70, 0x1faff, 1},
+		{0x20000, 0x2fffd, 1}, // CJK extensions B and on
+		{0x30000, 0x3fffd, 1},
+	},
+}
+
+// mdEscapable are the characters a backslash makes literal.
+const mdEscapable = "\\`*_{}[]()#+-.!|~<>"
+
+// renderInline renders the spans within a line: `code`, **bold**,
+// *italic*, ~~strikethrough~~ and [links](url).
+func renderInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(mdEscapable, s[i+1]) >= 0:
+			b.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '`':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			delim := s[i : i+n]
+			if end := strings.Index(s[i+n:], delim); end >= 0 {
+				code := s[i+n : i+n+end]
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString(ansiCyan + code + ansiNoColor)
+				i += n + end + n
+				continue
+			}
+			b.WriteString(delim)
+			i += n
+			continue
+		case strings.HasPrefix(s[i:], "**") || strings.HasPrefix(s[i:], "__"):
+			if end := closingDelim(s, i, s[i:i+2]); end >= 0 {
+				b.WriteString(ansiBold + renderInline(s[i+2:end]) + ansiNoBold)
+				i = end + 2
+				continue
+			}
+		case strings.HasPrefix(s[i:], "~~"):
+			if end := closingDelim(s, i, "~~"); end >= 0 {
+				b.WriteString(ansiStrike + renderInline(s[i+2:end]) + ansiNoStrike)
+				i = end + 2
+				continue
+			}
+		case c == '*' || c == '_':
+			if end := closingDelim(s, i, s[i:i+1]); end >= 0 {
+				b.WriteString(ansiItalic + renderInline(s[i+1:end]) + ansiNoItalic)
+				i = end + 1
+				continue
+			}
+		case c == '[':
+			if text, url, n := parseLink(s[i:]); n > 0 {
+				b.WriteString(ansiUnderline + renderInline(text) + ansiNoUnderline)
+				if url != text {
+					b.WriteString(" " + ansiBlue + "(" + url + ")" + ansiNoColor)
+				}
+				i += n
+				continue
+			}
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String()
+}
+
+// closingDelim returns the index of the delimiter that closes the emphasis
+// opened by delim at s[open], or -1 if there's none. As in Markdown, the
+// text within can't start or end with a space, and _ only emphasizes whole
+// words, so snake_case names are left alone.
+func closingDelim(s string, open int, delim string) int {
+	start := open + len(delim)
+	if start >= len(s) || s[start] == ' ' {
+		return -1
+	}
+	if delim[0] == '_' && open > 0 && isWordByte(s[open-1]) {
+		return -1
+	}
+	for j := start + 1; j+len(delim) <= len(s); j++ {
+		if !strings.HasPrefix(s[j:], delim) {
+			continue
+		}
+		// A single * or _ isn't closed by half of a double one
+		if len(delim) == 1 && j+1 < len(s) && s[j+1] == delim[0] {
+			j++
+			continue
+		}
+		if s[j-1] == ' ' {
+			continue
+		}
+		if delim[0] == '_' && j+len(delim) < len(s) && isWordByte(s[j+len(delim)]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= utf8.RuneSelf
+}
+
+// parseLink parses a [text](url) link at the start of s, returning its
+// text, its URL and its length, or a length of 0 if s doesn't start with
+// one.
+func parseLink(s string) (text, url string, n int) {
+	mid := strings.Index(s, "](")
+	if mid < 0 || strings.IndexByte(s[1:mid], '[') >= 0 {
+		return "", "", 0
+	}
+	end := strings.IndexByte(s[mid+2:], ')')
+	if end < 0 {
+		return "", "", 0
+	}
+	url = s[mid+2 : mid+2+end]
+	if url == "" || strings.ContainsAny(url, " \t") {
+		return "", "", 0
+	}
+	return s[1:mid], url, mid + 2 + end + 1
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// ansiCode matches the escape sequences markdownWriter styles text with.
+var ansiCode = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// renderMarkdown writes chunks to a markdownWriter, one Write each, and
+// returns what it rendered.
+func renderMarkdown(chunks ...string) string {
+	var buf bytes.Buffer
+	m := newMarkdownWriter(&buf, 80)
+	for _, chunk := range chunks {
+		m.Write([]byte(chunk))
+	}
+	m.End()
+	return buf.String()
+}
+
+func TestMarkdownWriter_SplitLine(t *testing.T) {
+	var buf bytes.Buffer
+	m := newMarkdownWriter(&buf, 80)
+
+	m.Write([]byte("# Hel"))
+	if buf.Len() != 0 {
+		t.Errorf("rendered %q before the line ended", buf.String())
+	}
+	m.Write([]byte("lo\nsome **bo"))
+	m.Write([]byte("ld** text"))
+	m.End()
+
+	want := ansiBold + ansiUnderline + "Hello" + ansiNoUnderline + ansiNoBold + "\n" +
+		"some " + ansiBold + "bold" + ansiNoBold + " text\n"
+	if got := buf.String(); got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+}
+
+func TestMarkdownWriter_CodeFence(t *testing.T) {
+	got := renderMarkdown("````go\n", "x := *y*\n", "```\n", "````\n", "after *it*\n")
+
+	want := "  " + ansiDim + "go" + ansiNoBold + "\n" +
+		"  " + ansiCyan + "x := *y*" + ansiNoColor + "\n" +
+		"  " + ansiCyan + "```" + ansiNoColor + "\n" + // Too short to close the block
+		"after " + ansiItalic + "it" + ansiNoItalic + "\n"
+	if got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+
+	// A new response starts outside any block
+	var buf bytes.Buffer
+	m := newMarkdownWriter(&buf, 80)
+	m.Write([]byte("```\ncode"))
+	m.End()
+	buf.Reset()
+	m.Write([]byte("*text*\n"))
+	if want := ansiItalic + "text" + ansiNoItalic + "\n"; buf.String() != want {
+		t.Errorf("rendered %q after an unclosed block, want %q", buf.String(), want)
+	}
+}
+
+func TestMarkdownWriter_Table(t *testing.T) {
+	got := renderMarkdown(
+		"| Name | 数 | Note |\n",
+		"|:--|--:|:-:|\n",
+		"| 寿司 | 1 | 🍣 |\n",
+		"| **ramen** | 22 | ok |\n",
+		"after\n",
+	)
+
+	want := []string{
+		"Name  │ 数 │ Note",
+		"──────┼────┼─────",
+		"寿司  │  1 │  🍣 ",
+		"ramen │ 22 │  ok ",
+		"after",
+	}
+	lines := strings.Split(strings.TrimSuffix(ansiCode.ReplaceAllString(got, ""), "\n"), "\n")
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rendered:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Rows without a divider aren't a table
+	if got := renderMarkdown("| a | b |\n", "text\n"); got != "| a | b |\ntext\n" {
+		t.Errorf("rendered %q, want the rows as text", got)
+	}
+}
+
+func TestVisibleWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{ansiBold + "abc" + ansiNoBold, 3},
+		{"寿司", 4},
+		{"ｈｉ", 4},
+		{"🍣", 2},
+		{"e\u0301", 1},
+		{"👍🏽", 2},
+		{"👩\u200d💻", 2},
+		{"🇯🇵", 2},
+		{"☑ done", 6},
+	}
+	for _, tt := range tests {
+		if got := visibleWidth(tt.s); got != tt.want {
+			t.Errorf("visibleWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/not-emily/sage/pkg/sage"
@@ -13,6 +15,9 @@ import (
 func runProviderModels(args []string) error {
 	fs := flag.NewFlagSet("provider models", flag.ExitOnError)
 	account := fs.String("account", "", "provider account to use (defaults to first configured)")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+	capability := fs.String("capability", "", "only models with these capabilities, comma-separated (vision, tools, json, reasoning)")
+	minContext := fs.String("min-context", "", "only models with at least this context window (e.g. 32000, 100k, 1m)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: sage provider models <provider> [flags]
@@ -30,6 +35,7 @@ Examples:
   sage provider models anthropic
   sage provider models ollama
   sage provider models openai --account=work
+  sage provider models openai --json --capability=vision --min-context=100k
 `)
 	}
 
@@ -46,7 +52,12 @@ Examples:
 		return fmt.Errorf("unknown provider: %s\nSupported: %s", providerName, strings.Join(providers.List(), ", "))
 	}
 
-	client, err := sage.NewClient()
+	minTokens, err := parseTokenCount(*minContext)
+	if err != nil {
+		return fmt.Errorf("invalid --min-context: %w", err)
+	}
+
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -56,6 +67,14 @@ Examples:
 		return fmt.Errorf("failed to list models: %w", err)
 	}
 
+	models = filterModels(models, *capability, minTokens)
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(models)
+	}
+
 	if len(models) == 0 {
 		fmt.Println("No models found.")
 		return nil
@@ -74,3 +93,56 @@ Examples:
 
 	return nil
 }
+
+// filterModels keeps models that have every listed capability and at least
+// minContext tokens of context. Models missing from the catalog have no
+// known capabilities or context window, so any filter excludes them.
+func filterModels(models []sage.ModelInfo, capabilities string, minContext int) []sage.ModelInfo {
+	var want []string
+	for _, c := range strings.Split(capabilities, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			want = append(want, c)
+		}
+	}
+
+	filtered := make([]sage.ModelInfo, 0, len(models))
+	for _, m := range models {
+		if m.ContextWindow < minContext {
+			continue
+		}
+		ok := true
+		for _, c := range want {
+			if !m.HasCapability(c) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// parseTokenCount parses a token count with an optional k or m suffix.
+func parseTokenCount(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := 1
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		multiplier = 1000
+		s = s[:len(s)-1]
+	case "m":
+		multiplier = 1000000
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("not a token count: %q", s)
+	}
+	return int(n * float64(multiplier)), nil
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// moderateResult is the --json output of sage moderate.
+type moderateResult struct {
+	Model      string             `json:"model"`
+	Flagged    bool               `json:"flagged"`
+	Categories []string           `json:"categories,omitempty"`
+	Scores     map[string]float64 `json:"scores"`
+}
+
+func runModerate(args []string) error {
+	fs := flag.NewFlagSet("moderate", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile to use; its model must be a moderation model (default: use default profile)")
+	jsonOutput := fs.Bool("json", false, "output JSON with every category's score")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage moderate [flags] [text]
+
+Check text against a moderation model.
+
+If no text is provided, reads from stdin. Prints "ok", or "flagged" and the
+flagged categories; flagged text also makes sage exit with status 1, so
+scripts can stop before sending it on. Supported by openai
+(omni-moderation-latest).
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage profile add mod --provider=openai --model=omni-moderation-latest
+  sage moderate --profile=mod "Some user-submitted text"
+  sage moderate --profile=mod < comment.txt && sage complete "Reply to this" < comment.txt
+  sage moderate --profile=mod --json < comment.txt
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	text := getPrompt(fs.Args())
+	if text == "" {
+		return fmt.Errorf("no text provided")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Moderate(*profile, sage.ModerationRequest{Input: []string{text}})
+	if err != nil {
+		return err
+	}
+	result := resp.Results[0]
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		out := moderateResult{Model: resp.Model, Flagged: result.Flagged, Categories: result.Categories, Scores: result.Scores}
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else if result.Flagged {
+		fmt.Printf("flagged\t%s\n", strings.Join(result.Categories, ", "))
+	} else {
+		fmt.Println("ok")
+	}
+
+	if result.Flagged {
+		return sage.ErrFlagged
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/not-emily/sage/pkg/sage"
 )
@@ -50,7 +52,7 @@ Examples:
 }
 
 func runProfileList(args []string) error {
-	client, err := sage.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -64,15 +66,48 @@ func runProfileList(args []string) error {
 		return nil
 	}
 
+	if path := client.ProjectConfigFile(); path != "" {
+		fmt.Printf("Project config: %s\n\n", path)
+	}
+
 	for _, p := range profiles {
-		marker := ""
+		var markers []string
 		if p.Name == defaultProfile {
-			marker = " (default)"
+			markers = append(markers, "default")
+		}
+		if client.IsProjectProfile(p.Name) {
+			markers = append(markers, "project")
+		}
+		marker := ""
+		if len(markers) > 0 {
+			marker = " (" + strings.Join(markers, ", ") + ")"
 		}
 		fmt.Printf("%s%s\n", p.Name, marker)
 		fmt.Printf("  provider: %s\n", p.Provider)
 		fmt.Printf("  account:  %s\n", p.Account)
 		fmt.Printf("  model:    %s\n", p.Model)
+		if p.SystemFile != "" {
+			fmt.Printf("  system:   @%s\n", p.SystemFile)
+		} else if p.System != "" {
+			fmt.Printf("  system:   %s\n", p.System)
+		}
+		if p.ReasoningEffort != "" {
+			fmt.Printf("  reasoning effort: %s\n", p.ReasoningEffort)
+		}
+		if p.RemapDeprecated {
+			fmt.Printf("  remap deprecated models: yes\n")
+		}
+		if len(p.Betas) > 0 {
+			fmt.Printf("  betas:    %s\n", strings.Join(p.Betas, ", "))
+		}
+		if len(p.ExtraBody) > 0 {
+			extra, _ := json.Marshal(p.ExtraBody)
+			fmt.Printf("  extra_body: %s\n", extra)
+		}
+		if p.Retry != nil {
+			retry, _ := json.Marshal(p.Retry)
+			fmt.Printf("  retry:    %s\n", retry)
+		}
 	}
 	return nil
 }
@@ -82,6 +117,17 @@ func runProfileAdd(args []string) error {
 	provider := fs.String("provider", "", "provider name (required)")
 	account := fs.String("account", "default", "provider account")
 	model := fs.String("model", "", "model name (required)")
+	system := fs.String("system", "", "default system prompt")
+	systemFile := fs.String("system-file", "", "system prompt file, re-read on every request (relative to config dir)")
+	betas := fs.String("beta", "", "provider beta features to enable, comma-separated (Anthropic anthropic-beta)")
+	extraBody := fs.String("extra-body", "", "JSON object merged into every request body (e.g. '{\"store\": false}')")
+	reasoningEffort := fs.String("reasoning-effort", "", "default reasoning effort for reasoning models: low, medium or high")
+	remap := fs.Bool("remap-deprecated", false, "send requests for deprecated models to their recommended successor")
+	retries := fs.Int("retries", -1, "retries after a failed request (default: global setting, or 2)")
+	retryBackoff := fs.String("retry-backoff", "", "delay before the first retry, doubled each time (e.g. 500ms)")
+	retryMaxBackoff := fs.String("retry-max-backoff", "", "upper bound on the retry delay (e.g. 1m)")
+	retryJitter := fs.Float64("retry-jitter", -1, "fraction of each retry delay randomized, 0 to 1 (default: global setting, or 0.2)")
+	retryOn := fs.String("retry-on", "", "error classes to retry, comma-separated: rate_limit, server, network")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: sage profile add <name> --provider=X --model=Y [--account=Z]
@@ -96,6 +142,9 @@ Examples:
   sage profile add default --provider=openai --model=gpt-4o
   sage profile add fast --provider=anthropic --model=claude-3-5-haiku-latest
   sage profile add local --provider=ollama --model=llama3.2 --account=default
+  sage profile add assistant --provider=openai --model=gpt-4o --system-file=prompts/assistant.md
+  sage profile add reasoner --provider=openai --model=o3-mini --reasoning-effort=high
+  sage profile add pipeline --provider=openai --model=gpt-4o-mini --retries=6 --retry-max-backoff=2m
 `)
 	}
 
@@ -113,8 +162,15 @@ Examples:
 	if *model == "" {
 		return fmt.Errorf("--model is required")
 	}
+	if *system != "" && *systemFile != "" {
+		return fmt.Errorf("--system and --system-file are mutually exclusive")
+	}
+	extra, err := parseExtraBody(*extraBody)
+	if err != nil {
+		return err
+	}
 
-	client, err := sage.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -125,10 +181,17 @@ Examples:
 	}
 
 	profile := sage.Profile{
-		Name:     profileName,
-		Provider: *provider,
-		Account:  *account,
-		Model:    *model,
+		Name:            profileName,
+		Provider:        *provider,
+		Account:         *account,
+		Model:           *model,
+		System:          *system,
+		SystemFile:      *systemFile,
+		ReasoningEffort: *reasoningEffort,
+		RemapDeprecated: *remap,
+		Betas:           splitList(*betas),
+		ExtraBody:       extra,
+		Retry:           retryConfig(*retries, *retryBackoff, *retryMaxBackoff, *retryJitter, *retryOn),
 	}
 
 	if err := client.AddProfile(profileName, profile); err != nil {
@@ -139,13 +202,33 @@ Examples:
 	return nil
 }
 
+// retryConfig builds a profile's retry settings from flags, or returns nil
+// if none were given. A negative retries or jitter means unset.
+func retryConfig(retries int, backoff, maxBackoff string, jitter float64, retryOn string) *sage.RetryConfig {
+	rc := &sage.RetryConfig{
+		InitialBackoff: backoff,
+		MaxBackoff:     maxBackoff,
+		RetryOn:        splitList(retryOn),
+	}
+	if retries >= 0 {
+		rc.MaxRetries = &retries
+	}
+	if jitter >= 0 {
+		rc.Jitter = &jitter
+	}
+	if rc.MaxRetries == nil && backoff == "" && maxBackoff == "" && rc.Jitter == nil && rc.RetryOn == nil {
+		return nil
+	}
+	return rc
+}
+
 func runProfileRemove(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: sage profile remove <name>")
 	}
 	profileName := args[0]
 
-	client, err := sage.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -164,7 +247,7 @@ func runProfileSetDefault(args []string) error {
 	}
 	profileName := args[0]
 
-	client, err := sage.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -176,3 +259,26 @@ func runProfileSetDefault(args []string) error {
 	fmt.Printf("Default profile set to '%s'\n", profileName)
 	return nil
 }
+
+// parseExtraBody parses an --extra-body flag value as a JSON object.
+func parseExtraBody(s string) (map[string]any, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var extra map[string]any
+	if err := json.Unmarshal([]byte(s), &extra); err != nil {
+		return nil, fmt.Errorf("--extra-body must be a JSON object: %w", err)
+	}
+	return extra, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -2,9 +2,11 @@ package cli
 
 import (
 	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/not-emily/sage/pkg/sage"
@@ -25,6 +27,10 @@ func runProvider(args []string) error {
 		return runProviderRemove(args[1:])
 	case "models":
 		return runProviderModels(args[1:])
+	case "health":
+		return runProviderHealth(args[1:])
+	case "verify":
+		return runProviderVerify(args[1:])
 	case "help", "-h", "--help":
 		return showProviderHelp()
 	default:
@@ -40,6 +46,8 @@ Commands:
   add       Add a provider account
   remove    Remove a provider account
   models    List available models from a provider
+  health    Check that provider accounts are reachable and authenticated
+  verify    Check that provider accounts' API keys are valid
 
 Examples:
   sage provider list
@@ -47,6 +55,8 @@ Examples:
   sage provider add openai --account=work
   sage provider add openai --api-key-env=OPENAI_API_KEY
   sage provider models openai
+  sage provider health
+  sage provider verify openai --account=work
   sage provider remove openai --account=work
 `
 	fmt.Print(help)
@@ -54,7 +64,7 @@ Examples:
 }
 
 func runProviderList(args []string) error {
-	client, err := sage.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -75,15 +85,71 @@ func runProviderList(args []string) error {
 		if p.BaseURL != "" {
 			fmt.Printf("  base_url: %s\n", p.BaseURL)
 		}
+		if p.APIVersion != "" {
+			fmt.Printf("  api_version: %s\n", p.APIVersion)
+		}
+		if len(p.Betas) > 0 {
+			fmt.Printf("  betas: %s\n", strings.Join(p.Betas, ", "))
+		}
+		if p.Platform != "" {
+			fmt.Printf("  platform: %s\n", p.Platform)
+		}
+		if p.Proxy != "" {
+			fmt.Printf("  proxy: %s\n", p.Proxy)
+		}
+		if caps, _ := sage.ProviderCapabilities(p.Name); len(caps) > 0 {
+			fmt.Printf("  capabilities: %s\n", strings.Join(caps, ", "))
+		}
+		if len(p.Headers) > 0 {
+			names := make([]string, 0, len(p.Headers))
+			for name := range p.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("  headers: %s\n", strings.Join(names, ", "))
+		}
 	}
 	return nil
 }
 
+// parseHeaders parses the --headers flag: a JSON object of header names to
+// string values.
+func parseHeaders(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(s), &headers); err != nil {
+		return nil, fmt.Errorf("--headers must be a JSON object of strings: %w", err)
+	}
+	return headers, nil
+}
+
+// optionalKeyPrompts holds the key prompt for providers that can work
+// without one: local servers, and cloud platforms that fall back on their
+// SDKs' ambient credentials.
+var optionalKeyPrompts = map[string]string{
+	"ollama":   "Enter API key (press Enter to skip for local Ollama): ",
+	"lmstudio": "Enter API key (press Enter to skip for local LM Studio): ",
+	"bedrock":  "Enter AWS credentials as ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]\n(press Enter to use the AWS_* environment variables): ",
+	"vertex":   "Enter the path to a service-account JSON key\n(press Enter to use Application Default Credentials): ",
+}
+
 func runProviderAdd(args []string) error {
 	fs := flag.NewFlagSet("provider add", flag.ExitOnError)
 	account := fs.String("account", "default", "account name")
 	apiKeyEnv := fs.String("api-key-env", "", "environment variable containing API key")
 	baseURL := fs.String("base-url", "", "custom base URL (for proxies or compatible APIs)")
+	addKey := fs.Bool("add-key", false, "add another API key to an existing account instead of replacing its key")
+	keyFromEnv := fs.Bool("key-from-env", false, "don't store the API key; read it from the --api-key-env variable (default e.g. OPENAI_API_KEY) on every request")
+	apiKeyCmd := fs.String("api-key-cmd", "", "don't store the API key; run this shell command and use its output (e.g. 'op read op://vault/openai/key')")
+	extraBody := fs.String("extra-body", "", "JSON object merged into every request body sent to this provider")
+	betas := fs.String("beta", "", "beta features to enable for this provider, comma-separated (Anthropic anthropic-beta)")
+	rotateKeys := fs.Bool("rotate-keys", false, "rotate through an account's API keys on every request")
+	apiVersion := fs.String("api-version", "", "API version for providers that require one (azure-openai, default 2024-10-21)")
+	headers := fs.String("headers", "", "JSON object of HTTP headers sent with every request to this provider")
+	platform := fs.String("platform", "", "route anthropic requests through bedrock or vertex, using that platform's credentials")
+	proxy := fs.String("proxy", "", "proxy URL for this provider (http, https or socks5), or 'direct' to ignore HTTPS_PROXY")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: sage provider add <provider> [flags]
@@ -100,7 +166,20 @@ Examples:
   sage provider add openai
   sage provider add openai --account=work
   sage provider add openai --api-key-env=OPENAI_API_KEY
+  sage provider add openai --key-from-env
+  sage provider add anthropic --account=ci --key-from-env --api-key-env=CI_ANTHROPIC_KEY
+  sage provider add openai --api-key-cmd='op read op://Private/OpenAI/credential'
+  sage provider add anthropic --api-key-cmd='pass show anthropic'
   sage provider add ollama --base-url=http://remote:11434
+  sage provider add lmstudio
+  sage provider add openai --add-key --rotate-keys
+  sage provider add azure-openai --base-url=https://myresource.openai.azure.com
+  sage provider add openrouter --headers='{"HTTP-Referer":"https://myapp.example","X-Title":"My App"}'
+  sage provider add vertex --base-url=https://us-east5-aiplatform.googleapis.com
+  sage provider add bedrock --base-url=https://bedrock-runtime.us-west-2.amazonaws.com
+  sage provider add anthropic --account=aws --platform=bedrock
+  sage provider add openai --proxy=http://egress.corp.example:3128
+  sage provider add ollama --base-url=http://ollama.internal:11434 --proxy=direct
 `)
 	}
 
@@ -117,11 +196,51 @@ Examples:
 		return fmt.Errorf("unknown provider: %s\nSupported: %s", providerName, strings.Join(providers.List(), ", "))
 	}
 
-	// Get API key (optional for ollama)
+	extra, err := parseExtraBody(*extraBody)
+	if err != nil {
+		return err
+	}
+	extraHeaders, err := parseHeaders(*headers)
+	if err != nil {
+		return err
+	}
+
+	if *platform != "" {
+		if providerName != "anthropic" {
+			return fmt.Errorf("--platform is only supported for anthropic")
+		}
+		if *platform != "bedrock" && *platform != "vertex" {
+			return fmt.Errorf("--platform must be bedrock or vertex")
+		}
+	}
+
+	if *proxy != "" {
+		if err := sage.ValidateProxy(*proxy); err != nil {
+			return err
+		}
+	}
+
+	// Azure URLs are per resource, so there's no default to fall back on
+	if providerName == "azure-openai" && *baseURL == "" && !*addKey {
+		return fmt.Errorf("--base-url is required for azure-openai (e.g. https://myresource.openai.azure.com)")
+	}
+
+	// Get API key (optional for some providers)
 	var apiKey string
-	if providerName == "ollama" && *apiKeyEnv == "" {
-		// Ollama typically doesn't need an API key
-		fmt.Print("Enter API key (press Enter to skip for local Ollama): ")
+	keyProvider := providerName
+	if *platform != "" {
+		keyProvider = *platform
+	}
+	if *apiKeyCmd != "" {
+		if *addKey || *keyFromEnv {
+			return fmt.Errorf("--api-key-cmd can't be used with --add-key or --key-from-env")
+		}
+	} else if *keyFromEnv {
+		if *addKey {
+			return fmt.Errorf("--key-from-env can't be used with --add-key")
+		}
+	} else if prompt, ok := optionalKeyPrompts[keyProvider]; ok && *apiKeyEnv == "" {
+		fmt.Print(prompt)
 		key, err := readLine()
 		if err != nil {
 			return err
@@ -140,37 +259,83 @@ Examples:
 			return err
 		}
 		apiKey = strings.TrimSpace(key)
-		if apiKey == "" && providerName != "ollama" {
+		if apiKey == "" {
 			return fmt.Errorf("API key required for %s", providerName)
 		}
 	}
 
-	client, err := sage.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
 
-	// Add the provider account
-	if err := client.AddProviderAccount(providerName, *account, apiKey); err != nil {
+	// Add the provider account, or an extra key for it
+	if *apiKeyCmd != "" {
+		if err := client.AddProviderAccountCmd(providerName, *account, *apiKeyCmd); err != nil {
+			return err
+		}
+	} else if *keyFromEnv {
+		if err := client.AddProviderAccountEnv(providerName, *account, *apiKeyEnv); err != nil {
+			return err
+		}
+	} else if *addKey {
+		if err := client.AddProviderKey(providerName, *account, apiKey); err != nil {
+			return err
+		}
+	} else if err := client.AddProviderAccount(providerName, *account, apiKey); err != nil {
 		return err
 	}
 
-	// Update base URL if provided
-	if *baseURL != "" {
-		// Need to update config directly for base URL
-		config, err := sage.LoadConfig()
+	// Update provider settings if provided
+	if *baseURL != "" || *rotateKeys || extra != nil || *betas != "" || *apiVersion != "" || extraHeaders != nil || *platform != "" || *proxy != "" {
+		// Need to update config directly for provider settings
+		err := sage.UpdateConfig(func(config *sage.Config) error {
+			providerConfig := config.Providers[providerName]
+			if *baseURL != "" {
+				providerConfig.BaseURL = *baseURL
+			}
+			if *rotateKeys {
+				providerConfig.RotateKeys = true
+			}
+			if extra != nil {
+				providerConfig.ExtraBody = extra
+			}
+			if *betas != "" {
+				providerConfig.Betas = splitList(*betas)
+			}
+			if *apiVersion != "" {
+				providerConfig.APIVersion = *apiVersion
+			}
+			if extraHeaders != nil {
+				providerConfig.Headers = extraHeaders
+			}
+			if *platform != "" {
+				providerConfig.Platform = *platform
+			}
+			if *proxy != "" {
+				providerConfig.Proxy = *proxy
+			}
+			config.Providers[providerName] = providerConfig
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-		providerConfig := config.Providers[providerName]
-		providerConfig.BaseURL = *baseURL
-		config.Providers[providerName] = providerConfig
-		if err := config.Save(); err != nil {
-			return err
-		}
 	}
 
-	fmt.Printf("Added %s:%s\n", providerName, *account)
+	if *addKey {
+		fmt.Printf("Added API key to %s:%s\n", providerName, *account)
+	} else if *apiKeyCmd != "" {
+		fmt.Printf("Added %s:%s (API key read from '%s')\n", providerName, *account, *apiKeyCmd)
+	} else if *keyFromEnv {
+		envVar := *apiKeyEnv
+		if envVar == "" {
+			envVar = sage.APIKeyEnv(providerName)
+		}
+		fmt.Printf("Added %s:%s (API key read from $%s)\n", providerName, *account, envVar)
+	} else {
+		fmt.Printf("Added %s:%s\n", providerName, *account)
+	}
 	return nil
 }
 
@@ -196,7 +361,7 @@ Flags:
 	}
 	providerName := fs.Arg(0)
 
-	client, err := sage.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,9 @@ package cli
 
 import (
 	"fmt"
+	"os"
+
+	"github.com/not-emily/sage/pkg/sage"
 )
 
 // Version is set at build time.
@@ -19,10 +22,38 @@ func Run(args []string) error {
 		return runInit(args[1:])
 	case "complete":
 		return runComplete(args[1:])
+	case "chat":
+		return runChat(args[1:])
+	case "session":
+		return runSession(args[1:])
+	case "batch":
+		return runBatch(args[1:])
+	case "embed":
+		return runEmbed(args[1:])
+	case "tokens":
+		return runTokens(args[1:])
+	case "transcribe":
+		return runTranscribe(args[1:])
+	case "speak":
+		return runSpeak(args[1:])
+	case "image":
+		return runImage(args[1:])
+	case "moderate":
+		return runModerate(args[1:])
+	case "finetune":
+		return runFineTune(args[1:])
+	case "files":
+		return runFiles(args[1:])
 	case "provider":
 		return runProvider(args[1:])
 	case "profile":
 		return runProfile(args[1:])
+	case "catalog":
+		return runCatalog(args[1:])
+	case "secrets":
+		return runSecrets(args[1:])
+	case "config":
+		return runConfig(args[1:])
 	case "version":
 		return showVersion()
 	case "help", "-h", "--help":
@@ -32,6 +63,19 @@ func Run(args []string) error {
 	}
 }
 
+// newClient creates a client, warning if the downloaded model catalog
+// couldn't be used.
+func newClient() (*sage.Client, error) {
+	client, err := sage.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := client.CatalogError(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v; using the built-in model catalog\n", err)
+	}
+	return client, nil
+}
+
 func showVersion() error {
 	fmt.Printf("sage v%s\n", Version)
 	return nil
@@ -46,8 +90,22 @@ Usage:
 Commands:
   init        Initialize sage (create config, generate master key)
   complete    Send a completion request
+  chat        Chat with an LLM, keeping the conversation
+  session     List, show, export and delete saved chats
+  batch       Run completions from a JSONL file
+  embed       Embed text and print the vectors
+  tokens      Count the tokens text would use as a prompt
+  transcribe  Transcribe speech in an audio file
+  speak       Read text aloud and save the audio
+  image       Generate images from a prompt
+  moderate    Check text against a moderation model
+  finetune    Fine-tune a model on your own examples
+  files       Manage files stored with a provider
   provider    Manage provider accounts
   profile     Manage profiles
+  catalog     Manage the model catalog
+  secrets     Manage the encrypted API key store
+  config      Check the config for mistakes
   version     Show version
   help        Show this help
 
@@ -0,0 +1,455 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+func runSecrets(args []string) error {
+	if len(args) == 0 {
+		return showSecretsHelp()
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return runSecretsList(args[1:])
+	case "show":
+		return runSecretsShow(args[1:])
+	case "set":
+		return runSecretsSet(args[1:])
+	case "rm", "remove":
+		return runSecretsRemove(args[1:])
+	case "rotate":
+		return runSecretsRotate(args[1:])
+	case "export":
+		return runSecretsExport(args[1:])
+	case "import":
+		return runSecretsImport(args[1:])
+	case "age":
+		return runSecretsAge(args[1:])
+	case "help", "-h", "--help":
+		return showSecretsHelp()
+	default:
+		return fmt.Errorf("unknown secrets command: %s\nRun 'sage secrets help' for usage", args[0])
+	}
+}
+
+func showSecretsHelp() error {
+	help := `Usage: sage secrets <command> [flags]
+
+API keys are stored in secrets.enc, encrypted with master.key or for age
+recipients.
+
+Commands:
+  list      List each account's API key source, with stored keys masked
+  show      Show an account's keys, when they were added and last used
+  set       Store an account's API key, read from stdin
+  rm        Delete an account's stored API keys
+  rotate    Replace the master key and re-encrypt the secrets
+  export    Save providers, profiles and API keys to a passphrase-encrypted bundle
+  import    Add the providers, profiles and API keys from a bundle
+  age       Encrypt the secrets for age recipients instead of master.key
+
+Examples:
+  sage secrets list
+  sage secrets show openai:work
+  echo "$OPENAI_API_KEY" | sage secrets set openai
+  sage secrets rm openai:work
+  sage secrets rotate
+  sage secrets rotate --confirm
+  sage secrets export --out=setup.sage
+  sage secrets import setup.sage
+  sage secrets age --recipients-file=team.txt --identity=~/.ssh/id_ed25519
+`
+	fmt.Print(help)
+	return nil
+}
+
+func runSecretsList(args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	infos := client.ListSecrets()
+	if len(infos) == 0 {
+		fmt.Println("No provider accounts configured.")
+		fmt.Println("\nRun 'sage provider add <name>' to add one.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tKEY\tSOURCE\tLAST USED")
+	for _, info := range infos {
+		key, lastUsed := "-", "-"
+		if len(info.Keys) > 0 {
+			key = info.Keys[0].Masked
+			if len(info.Keys) > 1 {
+				key += fmt.Sprintf(" (+%d)", len(info.Keys)-1)
+			}
+			lastUsed = formatKeyTime(info.Keys[0].LastUsed)
+		}
+		fmt.Fprintf(w, "%s:%s\t%s\t%s\t%s\n", info.Provider, info.Account, key, secretSource(info), lastUsed)
+	}
+	return w.Flush()
+}
+
+func runSecretsShow(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("provider[:account] required")
+	}
+	providerName, account := splitAccountRef(args[0])
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	for _, info := range client.ListSecrets() {
+		if info.Provider != providerName || info.Account != account {
+			continue
+		}
+		fmt.Printf("%s:%s\n", info.Provider, info.Account)
+		fmt.Printf("  source: %s\n", secretSource(info))
+		for i, k := range info.Keys {
+			fmt.Printf("  key %d: %s\n", i+1, k.Masked)
+			fmt.Printf("    added:     %s\n", formatKeyTime(k.Created))
+			fmt.Printf("    last used: %s\n", formatKeyTime(k.LastUsed))
+		}
+		return nil
+	}
+	return fmt.Errorf("account not found: %s:%s", providerName, account)
+}
+
+func runSecretsSet(args []string) error {
+	fs := flag.NewFlagSet("secrets set", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage secrets set <provider>[:<account>]
+
+Store the API key read from the first line of stdin for a provider account
+(default account "default"), adding the account if needed and replacing
+any keys it has. Nothing is echoed, so it works from scripts and pipes.
+
+Examples:
+  echo "$OPENAI_API_KEY" | sage secrets set openai
+  op read op://Private/Anthropic/credential | sage secrets set anthropic:work
+`)
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("provider[:account] required")
+	}
+	providerName, account := splitAccountRef(fs.Arg(0))
+
+	// Unlike readLine, keep a key piped in without a trailing newline
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	apiKey := strings.TrimSpace(line)
+	if apiKey == "" {
+		return fmt.Errorf("no API key on stdin")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	client.DeleteProviderKeys(providerName, account) // Replace any extra keys too
+	if err := client.AddProviderAccount(providerName, account, apiKey); err != nil {
+		return err
+	}
+	fmt.Printf("Stored key %s for %s:%s\n", sage.MaskKey(apiKey), providerName, account)
+	return nil
+}
+
+func runSecretsRemove(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("provider[:account] required")
+	}
+	providerName, account := splitAccountRef(args[0])
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteProviderKeys(providerName, account); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted stored keys for %s:%s\n", providerName, account)
+	fmt.Println("The account remains; remove it with 'sage provider remove'.")
+	return nil
+}
+
+// splitAccountRef splits "provider:account", defaulting the account to
+// "default".
+func splitAccountRef(ref string) (providerName, account string) {
+	providerName, account, _ = strings.Cut(ref, ":")
+	if account == "" {
+		account = "default"
+	}
+	return providerName, account
+}
+
+// secretSource describes where an account's key comes from.
+func secretSource(info sage.SecretInfo) string {
+	switch info.Source {
+	case "stored":
+		return "secrets.enc"
+	case "command":
+		return "api_key_cmd"
+	default:
+		return "$" + info.EnvVar
+	}
+}
+
+// formatKeyTime formats a key timestamp, or "-" if it's unknown.
+func formatKeyTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func runSecretsRotate(args []string) error {
+	fs := flag.NewFlagSet("secrets rotate", flag.ExitOnError)
+	confirm := fs.Bool("confirm", false, "delete the previous key and secrets kept by the last rotation")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage secrets rotate [flags]
+
+Generate a new master key and re-encrypt secrets.enc with it.
+
+The previous files are kept as master.key.bak and secrets.enc.bak. Once sage
+works with the new key, run with --confirm to delete them. To undo the
+rotation instead, move both backups back over the new files.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	keyPath, err := sage.MasterKeyPath()
+	if err != nil {
+		return err
+	}
+
+	if *confirm {
+		if err := sage.ConfirmKeyRotation(); err != nil {
+			return err
+		}
+		fmt.Println("Key rotation confirmed; previous key deleted")
+		return nil
+	}
+
+	if err := sage.RotateMasterKey(); err != nil {
+		return err
+	}
+	fmt.Printf("Master key rotated; previous key kept at %s.bak\n", keyPath)
+	fmt.Println("Run 'sage secrets rotate --confirm' once sage works with the new key.")
+	return nil
+}
+
+func runSecretsExport(args []string) error {
+	fs := flag.NewFlagSet("secrets export", flag.ExitOnError)
+	out := fs.String("out", "", "bundle file to write (required)")
+	passphraseEnv := fs.String("passphrase-env", "", "environment variable containing the passphrase (default: prompt)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage secrets export --out=FILE [flags]
+
+Save every provider account, profile and API key to a bundle encrypted with a
+passphrase, for moving your setup to another machine with 'sage secrets
+import'. Anyone with the bundle and passphrase can use your API keys.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage secrets export --out=setup.sage
+  SAGE_PASSPHRASE=... sage secrets export --out=setup.sage --passphrase-env=SAGE_PASSPHRASE
+`)
+	}
+
+	fs.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("--out is required")
+	}
+	passphrase, err := readPassphrase(*passphraseEnv)
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	bundle, err := client.ExportBundle(passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, bundle, 0600); err != nil {
+		return fmt.Errorf("cannot write bundle: %w", err)
+	}
+
+	fmt.Printf("Exported %d providers and %d profiles to %s\n", len(client.ListProviders()), len(client.ListProfiles()), *out)
+	return nil
+}
+
+func runSecretsImport(args []string) error {
+	fs := flag.NewFlagSet("secrets import", flag.ExitOnError)
+	passphraseEnv := fs.String("passphrase-env", "", "environment variable containing the passphrase (default: prompt)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage secrets import [flags] <file>
+
+Add the provider accounts, profiles and API keys from a bundle made by 'sage
+secrets export'. Accounts and profiles with the same names are replaced;
+others are kept. Initializes sage first if needed.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("bundle file required")
+	}
+	bundle, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("cannot read bundle: %w", err)
+	}
+	passphrase, err := readPassphrase(*passphraseEnv)
+	if err != nil {
+		return err
+	}
+
+	if err := sage.InitSecrets(); err != nil {
+		return fmt.Errorf("failed to initialize secrets: %w", err)
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	if err := client.ImportBundle(bundle, passphrase); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %s\n", fs.Arg(0))
+	fmt.Println("Run 'sage provider list' and 'sage profile list' to review.")
+	return nil
+}
+
+func runSecretsAge(args []string) error {
+	fs := flag.NewFlagSet("secrets age", flag.ExitOnError)
+	recipients := fs.String("recipient", "", "age recipients, comma-separated (age1..., SSH public keys, or plugin recipients)")
+	recipientsFile := fs.String("recipients-file", "", "file with one age recipient per line (# comments allowed)")
+	identity := fs.String("identity", "", "your age identity file or SSH private key, used to decrypt")
+	disable := fs.Bool("disable", false, "go back to encrypting the secrets with master.key")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage secrets age [flags]
+
+Re-encrypt secrets.enc for age recipients instead of with master.key, so a
+team can share one secrets file that only its members can open. Recipients
+can be age keys, SSH public keys, or hardware key plugin recipients such as
+age1yubikey1.... Requires the age command on PATH.
+
+The secrets are decrypted again with --identity before the change is kept,
+so it must be the identity of one of the recipients. Run again to change the
+recipients.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage secrets age --recipient=age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p --identity=~/.config/age/key.txt
+  sage secrets age --recipients-file=team.txt --identity=~/.ssh/id_ed25519
+  sage secrets age --disable
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if *disable {
+		if err := sage.ConfigureAge(nil); err != nil {
+			return err
+		}
+		fmt.Println("Secrets encrypted with master.key")
+		return nil
+	}
+
+	list := splitList(*recipients)
+	if *recipientsFile != "" {
+		data, err := os.ReadFile(*recipientsFile)
+		if err != nil {
+			return fmt.Errorf("cannot read recipients file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				list = append(list, line)
+			}
+		}
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("--recipient or --recipients-file required")
+	}
+
+	// The config resolves relative paths against its own directory
+	path := *identity
+	if path != "" && !strings.HasPrefix(path, "~/") {
+		var err error
+		if path, err = filepath.Abs(path); err != nil {
+			return err
+		}
+	}
+
+	if err := sage.ConfigureAge(&sage.AgeConfig{Recipients: list, Identity: path}); err != nil {
+		return err
+	}
+	fmt.Printf("Secrets encrypted for %d age recipients\n", len(list))
+	return nil
+}
+
+// readPassphrase returns the passphrase from the named environment variable,
+// or prompts for it if envVar is empty.
+func readPassphrase(envVar string) (string, error) {
+	if envVar != "" {
+		passphrase := os.Getenv(envVar)
+		if passphrase == "" {
+			return "", fmt.Errorf("environment variable %s is not set", envVar)
+		}
+		return passphrase, nil
+	}
+
+	fmt.Print("Enter passphrase: ")
+	line, err := readLine()
+	if err != nil {
+		return "", err
+	}
+	passphrase := strings.TrimSpace(line)
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase required")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// sessionInfo is the --json output of sage session list.
+type sessionInfo struct {
+	ID               string    `json:"id"`
+	Title            string    `json:"title"`
+	Profile          string    `json:"profile"`
+	Model            string    `json:"model,omitempty"`
+	Created          time.Time `json:"created"`
+	Updated          time.Time `json:"updated"`
+	Messages         int       `json:"messages"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+}
+
+func newSessionInfo(s *sage.Session) sessionInfo {
+	return sessionInfo{
+		ID: s.ID, Title: s.Title, Profile: s.Profile, Model: s.Model,
+		Created: s.Created, Updated: s.Updated, Messages: len(s.Messages),
+		PromptTokens: s.PromptTokens, CompletionTokens: s.CompletionTokens,
+	}
+}
+
+func runSession(args []string) error {
+	if len(args) == 0 {
+		return showSessionHelp()
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return runSessionList(args[1:])
+	case "show":
+		return runSessionShow(args[1:])
+	case "delete", "rm":
+		return runSessionDelete(args[1:])
+	case "export":
+		return runSessionExport(args[1:])
+	case "help", "-h", "--help":
+		return showSessionHelp()
+	default:
+		return fmt.Errorf("unknown session command: %s\nRun 'sage session help' for usage", args[0])
+	}
+}
+
+func showSessionHelp() error {
+	help := `Usage: sage session <command> [flags]
+
+Manage the conversations saved by 'sage chat', kept in sessions/ in the sage
+data directory. Sessions are named by ID, or "last" for the most recently
+updated one.
+
+Commands:
+  list      List saved sessions, most recent first
+  show      Show a session's details and conversation
+  delete    Delete sessions
+  export    Write a session as Markdown or JSON
+
+Examples:
+  sage session list
+  sage session show last
+  sage session export --output=chat.md 20261016-203120-461886
+  sage session delete --older-than=30d
+  sage chat --resume=20261016-203120-461886
+`
+	fmt.Print(help)
+	return nil
+}
+
+func runSessionList(args []string) error {
+	fs := flag.NewFlagSet("session list", flag.ExitOnError)
+	profile := fs.String("profile", "", "only list sessions with this profile")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+	fs.Parse(reorderArgs(args))
+
+	sessions, err := sage.ListSessions()
+	if err != nil {
+		return err
+	}
+	if *profile != "" {
+		var matched []*sage.Session
+		for _, s := range sessions {
+			if s.Profile == *profile {
+				matched = append(matched, s)
+			}
+		}
+		sessions = matched
+	}
+
+	if *jsonOutput {
+		infos := make([]sessionInfo, len(sessions))
+		for i, s := range sessions {
+			infos[i] = newSessionInfo(s)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println("No saved sessions.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUPDATED\tPROFILE\tMESSAGES\tTOKENS\tTITLE")
+	for _, s := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", s.ID, s.Updated.Local().Format("2006-01-02 15:04"),
+			s.Profile, len(s.Messages), s.PromptTokens+s.CompletionTokens, s.Title)
+	}
+	return w.Flush()
+}
+
+func runSessionShow(args []string) error {
+	fs := flag.NewFlagSet("session show", flag.ExitOnError)
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("session ID required (or last)")
+	}
+	s, err := sage.LoadSession(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", s.ID)
+	if s.Title != "" {
+		fmt.Fprintf(w, "Title:\t%s\n", s.Title)
+	}
+	fmt.Fprintf(w, "Profile:\t%s\n", s.Profile)
+	if s.Model != "" {
+		fmt.Fprintf(w, "Model:\t%s\n", s.Model)
+	}
+	fmt.Fprintf(w, "Started:\t%s\n", s.Created.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "Updated:\t%s\n", s.Updated.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "Messages:\t%d\n", len(s.Messages))
+	fmt.Fprintf(w, "Tokens:\t%d prompt, %d completion\n", s.PromptTokens, s.CompletionTokens)
+	if s.System != "" {
+		fmt.Fprintf(w, "System:\t%s\n", summarizeError(s.System))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// The conversation as it looked in sage chat
+	for _, m := range s.Messages {
+		fmt.Println()
+		if m.Role == "user" {
+			fmt.Println("> " + strings.ReplaceAll(m.Content, "\n", "\n. "))
+		} else {
+			fmt.Println(m.Content)
+		}
+	}
+	return nil
+}
+
+func runSessionDelete(args []string) error {
+	fs := flag.NewFlagSet("session delete", flag.ExitOnError)
+	olderThan := fs.String("older-than", "", "delete every session not updated for this long, e.g. 30d or 12h")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage session delete [flags] <id|last>...
+
+Delete saved sessions, by ID or all those not used for a while.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage session delete 20261016-203120-461886
+  sage session delete --older-than=30d
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	ids := fs.Args()
+	if *olderThan != "" {
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			return fmt.Errorf("invalid --older-than: %w", err)
+		}
+		sessions, err := sage.ListSessions()
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			if time.Since(s.Updated) > age {
+				ids = append(ids, s.ID)
+			}
+		}
+		if len(ids) == 0 {
+			fmt.Println("No sessions to delete.")
+			return nil
+		}
+	} else if len(ids) == 0 {
+		return fmt.Errorf("session ID or --older-than required")
+	}
+
+	for _, id := range ids {
+		if id == "last" {
+			s, err := sage.LoadSession(id)
+			if err != nil {
+				return err
+			}
+			id = s.ID
+		}
+		if err := sage.DeleteSession(id); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", id)
+	}
+	return nil
+}
+
+// parseAge parses a duration such as 12h, or a number of days such as 30d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("want a number of days, such as 30d: %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func runSessionExport(args []string) error {
+	fs := flag.NewFlagSet("session export", flag.ExitOnError)
+	format := fs.String("format", "markdown", "markdown or json")
+	output := fs.String("output", "", "write to a file (default: stdout)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage session export [flags] <id|last>
+
+Write a saved session as a Markdown transcript, or as JSON as it's saved.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage session export last > chat.md
+  sage session export --format=json --output=chat.json 20261016-203120-461886
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("session ID required (or last)")
+	}
+	s, err := sage.LoadSession(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := s.Export(*format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0600); err != nil {
+		return fmt.Errorf("cannot write %s: %w", *output, err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %s to %s\n", s.ID, *output)
+	return nil
+}
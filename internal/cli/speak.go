@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+func runSpeak(args []string) error {
+	fs := flag.NewFlagSet("speak", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile to use; its model must be a text-to-speech model (default: use default profile)")
+	voice := fs.String("voice", "alloy", "voice to speak with")
+	format := fs.String("format", "", "audio format, e.g. mp3, opus, aac, flac, wav or pcm (default: from --out's extension, else mp3)")
+	speed := fs.Float64("speed", 1, "playback speed multiplier (OpenAI takes 0.25 to 4)")
+	out := fs.String("out", "", "write the audio to this file (default: stdout, if it isn't a terminal)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage speak [flags] [text]
+
+Read text aloud and save the audio.
+
+If no text is provided, reads from stdin. Supported by openai
+(gpt-4o-mini-tts, tts-1, tts-1-hd) and groq (playai-tts).
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage profile add tts --provider=openai --model=gpt-4o-mini-tts
+  sage speak --profile=tts --out=hello.mp3 "Hello, world!"
+  sage speak --profile=tts --voice=nova --out=notes.wav < notes.txt
+  sage complete "Write a haiku" | sage speak --profile=tts --speed=0.8 > haiku.mp3
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	text := getPrompt(fs.Args())
+	if text == "" {
+		return fmt.Errorf("no text provided")
+	}
+	if *out == "" && isTerminal(os.Stdout) {
+		return fmt.Errorf("--out required when stdout is a terminal")
+	}
+	if *format == "" && *out != "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*out)), ".")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	req := sage.SpeechRequest{Text: text, Voice: *voice, Format: *format}
+	// Only send speed if it was set
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "speed" {
+			req.Speed = speed
+		}
+	})
+
+	resp, err := client.Speak(*profile, req)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err := os.Stdout.Write(resp.Audio)
+		return err
+	}
+	if err := os.WriteFile(*out, resp.Audio, 0644); err != nil {
+		return fmt.Errorf("cannot write audio: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d bytes of %s audio to %s\n", len(resp.Audio), resp.Format, *out)
+	return nil
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// teeFile mirrors completion output to a file as it is produced, and can
+// write a JSON sidecar (<path>.meta.json) with usage metadata when done.
+type teeFile struct {
+	path    string
+	file    *os.File
+	meta    bool
+	started time.Time
+}
+
+// teeMeta is the content of the JSON sidecar.
+type teeMeta struct {
+	Profile           string    `json:"profile,omitempty"`
+	Model             string    `json:"model,omitempty"`
+	User              string    `json:"user,omitempty"`
+	RequestID         string    `json:"request_id,omitempty"`
+	ProviderRequestID string    `json:"provider_request_id,omitempty"`
+	SystemFingerprint string    `json:"system_fingerprint,omitempty"`
+	FinishReason      string    `json:"finish_reason,omitempty"`
+	StartedAt         time.Time `json:"started_at"`
+	DurationMS        int64     `json:"duration_ms"`
+	FirstTokenMS      int64     `json:"first_token_ms,omitempty"`
+	Complete          bool      `json:"complete"`
+	Error             string    `json:"error,omitempty"`
+	Usage             teeUsage  `json:"usage"`
+}
+
+type teeUsage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	Estimated        bool `json:"estimated,omitempty"` // Not reported by the provider
+}
+
+// openTee creates (or truncates) the tee file at path.
+func openTee(path string, meta bool) (*teeFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create tee file: %w", err)
+	}
+	return &teeFile{path: path, file: f, meta: meta, started: time.Now()}, nil
+}
+
+// Write writes p straight to the file so partial output survives interrupts.
+func (t *teeFile) Write(p []byte) (int, error) {
+	return t.file.Write(p)
+}
+
+// Close closes the file and writes the sidecar if requested.
+func (t *teeFile) Close(m teeMeta) error {
+	if err := t.file.Close(); err != nil {
+		return fmt.Errorf("cannot write tee file: %w", err)
+	}
+	if !t.meta {
+		return nil
+	}
+
+	m.StartedAt = t.started
+	m.DurationMS = time.Since(t.started).Milliseconds()
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(t.path+".meta.json", append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write tee metadata: %w", err)
+	}
+	return nil
+}
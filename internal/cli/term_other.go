@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package cli
+
+import "errors"
+
+// makeRaw isn't supported here, so sage chat reads whole lines without
+// editing keys.
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode not supported")
+}
+
+func terminalWidth(fd int) int {
+	return 80
+}
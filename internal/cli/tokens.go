@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// tokensResult is the --json output of sage tokens.
+type tokensResult struct {
+	Model         string       `json:"model"`
+	Tokens        int          `json:"tokens"`
+	Estimated     bool         `json:"estimated,omitempty"`
+	ContextWindow int          `json:"context_window,omitempty"`
+	Files         []tokensFile `json:"files,omitempty"`
+}
+
+type tokensFile struct {
+	File   string `json:"file"`
+	Tokens int    `json:"tokens"`
+}
+
+func runTokens(args []string) error {
+	fs := flag.NewFlagSet("tokens", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile whose model to count for (default: use default profile)")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage tokens [flags] [file...]
+
+Count the tokens text would use as a prompt, without sending it.
+
+Anthropic profiles are counted exactly by the API; other providers are
+estimated locally. With no files, or "-", stdin is read. Several files are
+counted separately and totalled.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage tokens prompt.md
+  git diff | sage tokens --profile=claude
+  sage tokens --json docs/*.md
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	sources := fs.Args()
+	if len(sources) == 0 {
+		sources = []string{"-"}
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	result := tokensResult{}
+	for _, source := range sources {
+		text, err := readTokensInput(source)
+		if err != nil {
+			return err
+		}
+		count, err := client.CountTokens(*profile, text)
+		if err != nil {
+			return err
+		}
+		result.Model = count.Model
+		result.Tokens += count.Tokens
+		result.Estimated = result.Estimated || count.Estimated
+		result.Files = append(result.Files, tokensFile{File: source, Tokens: count.Tokens})
+	}
+	if len(sources) == 1 {
+		result.Files = nil
+	}
+	result.ContextWindow, _ = sage.ContextWindow(result.Model)
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	approx := ""
+	if result.Estimated {
+		approx = "~"
+	}
+	for _, f := range result.Files {
+		fmt.Printf("%s%d\t%s\n", approx, f.Tokens, f.File)
+	}
+	if result.Files != nil {
+		fmt.Printf("%s%d\ttotal\n", approx, result.Tokens)
+	} else {
+		fmt.Printf("%s%d\n", approx, result.Tokens)
+	}
+	if result.ContextWindow > 0 {
+		fmt.Fprintf(os.Stderr, "%.1f%% of %s's %d token context window\n",
+			100*float64(result.Tokens)/float64(result.ContextWindow), result.Model, result.ContextWindow)
+	}
+	return nil
+}
+
+// readTokensInput reads a file, or stdin for "-".
+func readTokensInput(source string) (string, error) {
+	if source == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("cannot read stdin: %w", err)
+		}
+		return string(data), nil
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return "", fmt.Errorf("cannot read input: %w", err)
+	}
+	return string(data), nil
+}
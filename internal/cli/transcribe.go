@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/not-emily/sage/pkg/sage"
+)
+
+// transcribeResult is the --json output of sage transcribe.
+type transcribeResult struct {
+	File  string `json:"file"`
+	Model string `json:"model"`
+	Text  string `json:"text"`
+}
+
+func runTranscribe(args []string) error {
+	fs := flag.NewFlagSet("transcribe", flag.ExitOnError)
+	profile := fs.String("profile", "", "profile to use; its model must be a speech-to-text model (default: use default profile)")
+	language := fs.String("language", "", "ISO-639-1 code of the spoken language, e.g. en (default: detect)")
+	prompt := fs.String("prompt", "", "text that guides spelling and style, such as names or jargon")
+	jsonOutput := fs.Bool("json", false, "output JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: sage transcribe [flags] <audio-file>
+
+Transcribe speech in an audio file and print the text.
+
+Supported by openai (whisper-1, gpt-4o-transcribe) and groq
+(whisper-large-v3, whisper-large-v3-turbo). The file's extension tells the
+provider its format, e.g. mp3, wav, m4a or webm.
+
+Flags:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  sage profile add whisper --provider=openai --model=whisper-1
+  sage transcribe --profile=whisper memo.m4a
+  sage transcribe --profile=whisper --language=de interview.mp3 > interview.txt
+  sage transcribe --profile=whisper meeting.wav | sage complete "Summarize this meeting"
+`)
+	}
+
+	fs.Parse(reorderArgs(args))
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("audio file required")
+	}
+	file := fs.Arg(0)
+
+	audio, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("cannot read audio: %w", err)
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Transcribe(*profile, sage.TranscribeRequest{
+		Audio:    audio,
+		Filename: filepath.Base(file),
+		Language: *language,
+		Prompt:   *prompt,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(transcribeResult{File: file, Model: resp.Model, Text: resp.Text})
+	}
+	fmt.Println(resp.Text)
+	return nil
+}
@@ -0,0 +1,157 @@
+package sage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// AgeConfig encrypts secrets.enc for age (https://age-encryption.org)
+// recipients instead of with master.key, so a team can share one secrets
+// file that only its members' identities open. Encryption and decryption
+// run the age command, which must be on PATH.
+type AgeConfig struct {
+	// Recipients can decrypt the secrets: age public keys (age1...), SSH
+	// public keys ("ssh-ed25519 ..." or "ssh-rsa ..."), or plugin recipients
+	// such as age1yubikey1... for hardware keys, with the plugin installed.
+	Recipients []string `json:"recipients"`
+
+	// Identity is the path to this user's age identity file or SSH private
+	// key. SAGE_AGE_IDENTITY overrides it. Relative paths are resolved
+	// against the config directory.
+	Identity string `json:"identity,omitempty"`
+}
+
+// ageHeaders start age-encrypted files, binary and armored.
+var ageHeaders = []string{"age-encryption.org/v1\n", "-----BEGIN AGE ENCRYPTED FILE-----"}
+
+// isAgeEncrypted reports whether data is an age-encrypted file.
+func isAgeEncrypted(data []byte) bool {
+	for _, h := range ageHeaders {
+		if bytes.HasPrefix(data, []byte(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ageEncrypt encrypts plaintext for recipients.
+func ageEncrypt(recipients []string, plaintext []byte) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("age: no recipients")
+	}
+	args := []string{"-e"}
+	for _, r := range recipients {
+		args = append(args, "-r", r)
+	}
+	return runAge(args, plaintext)
+}
+
+// ageDecrypt decrypts data with the identity configured in age, or
+// SAGE_AGE_IDENTITY.
+func ageDecrypt(age *AgeConfig, data []byte) ([]byte, error) {
+	identity := os.Getenv("SAGE_AGE_IDENTITY")
+	if identity == "" && age != nil {
+		identity = age.Identity
+	}
+	if identity == "" {
+		return nil, errors.New("secrets are encrypted with age: set age.identity in config.json or SAGE_AGE_IDENTITY to your identity file")
+	}
+	path, err := resolveConfigPath(identity)
+	if err != nil {
+		return nil, err
+	}
+	return runAge([]string{"-d", "-i", path}, data)
+}
+
+// runAge runs the age command with input on stdin and returns its output.
+func runAge(args []string, input []byte) ([]byte, error) {
+	if _, err := exec.LookPath("age"); err != nil {
+		return nil, errors.New("age not found: install it from https://age-encryption.org")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("age", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("age: %s", msg)
+		}
+		return nil, fmt.Errorf("age: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// ConfigureAge re-encrypts the secrets for age's recipients and saves age in
+// the config, or with a nil age, re-encrypts them with master.key (created if
+// needed). The secrets are decrypted again before the change is kept, so
+// enabling age fails unless the configured identity is one of the
+// recipients'.
+func ConfigureAge(age *AgeConfig) error {
+	if readOnlyEnv() {
+		return fmt.Errorf("%w: cannot save secrets", ErrReadOnly)
+	}
+	if age != nil && len(age.Recipients) == 0 {
+		return errors.New("at least one age recipient is required")
+	}
+	if age == nil {
+		if err := InitSecrets(); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := lockConfigDir()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	secretsPath, err := SecretsPath()
+	if err != nil {
+		return err
+	}
+	secrets, err := LoadSecrets()
+	if err != nil {
+		return err
+	}
+	oldSecrets, err := os.ReadFile(secretsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read secrets file: %w", err)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	oldAge := cfg.Age
+
+	restore := func(cause error) error {
+		cfg.Age = oldAge
+		cfg.Save()
+		if oldSecrets != nil {
+			writeFileAtomic(secretsPath, oldSecrets, 0600)
+		}
+		return cause
+	}
+
+	cfg.Age = age
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+	if err := writeSecrets(secrets); err != nil {
+		return restore(err)
+	}
+	got, err := LoadSecrets()
+	if err != nil {
+		return restore(fmt.Errorf("re-encrypted secrets don't decrypt: %w", err))
+	}
+	if !maps.Equal(got, secrets) {
+		return restore(errors.New("re-encrypted secrets don't match the originals"))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package sage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeAge puts an age command on PATH that base64-encodes instead of
+// encrypting, and decrypts only with an identity file containing "me".
+func fakeAge(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake age is a shell script")
+	}
+	bin := t.TempDir()
+	script := `#!/bin/sh
+if [ "$1" = "-e" ]; then
+	printf 'age-encryption.org/v1\n'
+	base64
+	exit
+fi
+if ! grep -q me "$3" 2>/dev/null; then
+	echo "no identity matched any of the recipients" >&2
+	exit 1
+fi
+tail -n +2 | base64 -d
+`
+	os.WriteFile(filepath.Join(bin, "age"), []byte(script), 0755)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestConfigureAge(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	fakeAge(t)
+
+	InitSecrets()
+	SetSecret("openai", "default", "sk-test")
+	dir, _ := ConfigDir()
+	os.WriteFile(filepath.Join(dir, "me.txt"), []byte("me"), 0600)
+	os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other"), 0600)
+
+	// An identity that can't decrypt the result is refused, keeping the
+	// master key encryption
+	if err := ConfigureAge(&AgeConfig{Recipients: []string{"age1team"}, Identity: "other.txt"}); err == nil {
+		t.Fatal("ConfigureAge() with a non-matching identity should error")
+	}
+	if cfg, _ := LoadConfig(); cfg.Age != nil {
+		t.Errorf("config age = %+v after failed switch, want nil", cfg.Age)
+	}
+	if secret, err := GetSecret("openai", "default"); err != nil || secret != "sk-test" {
+		t.Fatalf("GetSecret() = %q, %v after failed switch", secret, err)
+	}
+
+	if err := ConfigureAge(&AgeConfig{Recipients: []string{"age1team"}, Identity: "me.txt"}); err != nil {
+		t.Fatalf("ConfigureAge() error = %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "secrets.enc"))
+	if !isAgeEncrypted(data) {
+		t.Fatal("secrets.enc is not age-encrypted")
+	}
+
+	// Secrets still load and save, without the master key
+	os.Remove(filepath.Join(dir, "master.key"))
+	SetSecret("anthropic", "default", "sk-ant")
+	if secret, err := GetSecret("openai", "default"); err != nil || secret != "sk-test" {
+		t.Errorf("GetSecret() = %q, %v; want sk-test", secret, err)
+	}
+	if err := RotateMasterKey(); err == nil || !strings.Contains(err.Error(), "age") {
+		t.Errorf("RotateMasterKey() error = %v, want refusal for age", err)
+	}
+
+	// Another user's identity can't open them
+	t.Setenv("SAGE_AGE_IDENTITY", filepath.Join(dir, "other.txt"))
+	if _, err := LoadSecrets(); err == nil || !strings.Contains(err.Error(), "no identity matched") {
+		t.Errorf("LoadSecrets() error = %v, want age's error", err)
+	}
+	t.Setenv("SAGE_AGE_IDENTITY", "")
+
+	// Switching back re-encrypts with a new master key
+	if err := ConfigureAge(nil); err != nil {
+		t.Fatalf("ConfigureAge(nil) error = %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "secrets.enc"))
+	if isAgeEncrypted(data) {
+		t.Error("secrets.enc still age-encrypted")
+	}
+	if secret, err := GetSecret("anthropic", "default"); err != nil || secret != "sk-ant" {
+		t.Errorf("GetSecret() = %q, %v; want sk-ant", secret, err)
+	}
+}
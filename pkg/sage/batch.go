@@ -0,0 +1,237 @@
+package sage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultBatchConcurrency is the number of requests a BatchRunner keeps in flight.
+const defaultBatchConcurrency = 4
+
+// BatchResult is the outcome of one request in a batch.
+type BatchResult struct {
+	Index    int       // Position in the input slice
+	Response *Response // Nil if the request failed
+	Err      error     // Last error if every attempt failed
+	Attempts int
+}
+
+// BatchProgress is reported after each request in a batch finishes.
+type BatchProgress struct {
+	Result BatchResult // The request that just finished
+	Done   int         // Finished so far, including failures
+	Failed int
+	Total  int
+}
+
+// BatchRunner completes many requests concurrently against one profile.
+type BatchRunner struct {
+	Client  *Client
+	Profile string // Empty uses the default profile
+
+	Concurrency       int     // Requests in flight (default 4)
+	RequestsPerSecond float64 // Max request starts per second, including retries (0 = unlimited)
+
+	// Retry controls per-request retries. If it's nil, the profile's retry
+	// policy is used; &RetryPolicy{} makes no retries.
+	Retry *RetryPolicy
+
+	// OnProgress, if set, is called after each request finishes.
+	// Calls are serialized, so it doesn't need its own locking.
+	OnProgress func(BatchProgress)
+}
+
+// Run completes every request and returns the results in input order.
+// Once ctx is done, requests not yet finished fail with its error.
+func (r *BatchRunner) Run(ctx context.Context, reqs []Request) []BatchResult {
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	limiter := newRateLimiter(r.RequestsPerSecond)
+
+	var policy RetryPolicy
+	if r.Retry != nil {
+		policy = *r.Retry
+	} else {
+		// Errors resolving the profile surface from each request instead
+		policy, _ = r.Client.RetryPolicy(r.Profile)
+	}
+
+	jobs := make(chan int)
+	finished := make(chan BatchResult)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				finished <- r.runOne(ctx, i, reqs[i], policy, limiter)
+			}
+		}()
+	}
+
+	go func() {
+		for i := range reqs {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(finished)
+	}()
+
+	results := make([]BatchResult, len(reqs))
+	progress := BatchProgress{Total: len(reqs)}
+	for res := range finished {
+		results[res.Index] = res
+
+		progress.Result = res
+		progress.Done++
+		if res.Err != nil {
+			progress.Failed++
+		}
+		if r.OnProgress != nil {
+			r.OnProgress(progress)
+		}
+	}
+	return results
+}
+
+// runOne completes a single request, retrying according to policy.
+func (r *BatchRunner) runOne(ctx context.Context, index int, req Request, policy RetryPolicy, limiter *rateLimiter) BatchResult {
+	result := BatchResult{Index: index}
+
+	// Every attempt shares one key so providers can deduplicate retries
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = newRequestID()
+	}
+
+	for {
+		if err := limiter.wait(ctx); err != nil {
+			result.Err = err
+			return result
+		}
+		result.Attempts++
+
+		// Retries happen here so each attempt waits for the rate limiter
+		resp, err := r.Client.complete(ctx, r.Profile, req, RetryPolicy{})
+		if err == nil {
+			result.Response = resp
+			result.Err = nil
+			return result
+		}
+
+		result.Err = err
+		if ctx.Err() != nil || !policy.shouldRetry(err, result.Attempts) {
+			return result
+		}
+		if err := sleepContext(ctx, policy.delay(result.Attempts, result.Err)); err != nil {
+			return result
+		}
+	}
+}
+
+// rateLimiter spaces out events to a maximum rate. A nil limiter never waits.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the next event is allowed, or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, delay)
+}
+
+// BatchOptions configures CompleteBatch. The zero value keeps 4 requests in
+// flight with the profile's retry policy.
+type BatchOptions struct {
+	Concurrency       int     // Requests in flight (default 4)
+	RequestsPerSecond float64 // Max request starts per second, including retries (0 = unlimited)
+
+	// Retry controls per-request retries. If it's nil, the profile's retry
+	// policy is used; &RetryPolicy{} makes no retries.
+	Retry *RetryPolicy
+
+	// OnProgress, if set, is called after each request finishes.
+	OnProgress func(BatchProgress)
+}
+
+// BatchError reports the requests that failed in a CompleteBatch call.
+// errors.Is and errors.As check each request's error.
+type BatchError struct {
+	Failed []BatchResult // Failed requests, in input order
+	Total  int           // Requests in the batch
+}
+
+func (e *BatchError) Error() string {
+	first := e.Failed[0]
+	if len(e.Failed) == 1 {
+		return fmt.Sprintf("1 of %d requests failed: request %d: %v", e.Total, first.Index, first.Err)
+	}
+	return fmt.Sprintf("%d of %d requests failed, first: request %d: %v", len(e.Failed), e.Total, first.Index, first.Err)
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, r := range e.Failed {
+		errs[i] = r.Err
+	}
+	return errs
+}
+
+// CompleteBatch completes every request with a profile, a few at a time,
+// and returns the results in input order. If profileName is empty, the
+// default profile is used. When any request fails, the results are
+// returned along with a *BatchError listing the failures; the rest still
+// have their responses. Once ctx is done, unfinished requests fail with
+// its error.
+func (c *Client) CompleteBatch(ctx context.Context, profileName string, reqs []Request, opts BatchOptions) ([]BatchResult, error) {
+	if _, err := c.config.GetProfile(profileName); err != nil {
+		return nil, err
+	}
+
+	runner := &BatchRunner{
+		Client:            c,
+		Profile:           profileName,
+		Concurrency:       opts.Concurrency,
+		RequestsPerSecond: opts.RequestsPerSecond,
+		Retry:             opts.Retry,
+		OnProgress:        opts.OnProgress,
+	}
+	results := runner.Run(ctx, reqs)
+
+	var failed []BatchResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &BatchError{Failed: failed, Total: len(reqs)}
+	}
+	return results, nil
+}
@@ -0,0 +1,248 @@
+package sage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// setupBatchClient returns a client whose default profile talks to a fake
+// OpenAI server. Prompts listed in failOnce fail on their first attempt;
+// the prompt "always-fail" never succeeds.
+func setupBatchClient(t *testing.T, failOnce ...string) *Client {
+	client := setupTestClient(t)
+
+	var mu sync.Mutex
+	failed := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompt := body.Messages[len(body.Messages)-1].Content
+
+		mu.Lock()
+		fail := prompt == "always-fail"
+		for _, p := range failOnce {
+			if p == prompt && !failed[p] {
+				failed[p] = true
+				fail = true
+			}
+		}
+		mu.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": "re: " + prompt}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+	client.SetDefaultProfile("test")
+
+	return client
+}
+
+func TestBatchRunner_Run(t *testing.T) {
+	client := setupBatchClient(t, "b")
+
+	var calls []BatchProgress
+	runner := &BatchRunner{
+		Client:      client,
+		Concurrency: 2,
+		Retry:       &RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond},
+		OnProgress: func(p BatchProgress) {
+			calls = append(calls, p)
+		},
+	}
+
+	reqs := []Request{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "always-fail"}, {Prompt: "c"}}
+	results := runner.Run(context.Background(), reqs)
+
+	if len(results) != len(reqs) {
+		t.Fatalf("results = %d, want %d", len(results), len(reqs))
+	}
+	for i, want := range []string{"re: a", "re: b", "", "re: c"} {
+		if results[i].Index != i {
+			t.Errorf("results[%d].Index = %d", i, results[i].Index)
+		}
+		if want == "" {
+			continue
+		}
+		if results[i].Err != nil || results[i].Response.Content != want {
+			t.Errorf("results[%d] = %+v, want content %q", i, results[i], want)
+		}
+	}
+
+	if results[1].Attempts != 2 {
+		t.Errorf("retried item Attempts = %d, want 2", results[1].Attempts)
+	}
+	if results[2].Err == nil || results[2].Attempts != 3 {
+		t.Errorf("failing item = %+v, want error after 3 attempts", results[2])
+	}
+
+	if len(calls) != len(reqs) {
+		t.Fatalf("OnProgress calls = %d, want %d", len(calls), len(reqs))
+	}
+	last := calls[len(calls)-1]
+	if last.Done != 4 || last.Failed != 1 || last.Total != 4 {
+		t.Errorf("final progress = %+v, want Done=4 Failed=1 Total=4", last)
+	}
+}
+
+func TestBatchRunner_Retryable(t *testing.T) {
+	client := setupBatchClient(t)
+
+	runner := &BatchRunner{
+		Client: client,
+		Retry: &RetryPolicy{
+			MaxRetries:     5,
+			InitialBackoff: time.Millisecond,
+			Retryable:      func(err error) bool { return false },
+		},
+	}
+
+	results := runner.Run(context.Background(), []Request{{Prompt: "always-fail"}})
+	if results[0].Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1 when error isn't retryable", results[0].Attempts)
+	}
+}
+
+func TestBatchRunner_NoRetries(t *testing.T) {
+	client := setupBatchClient(t, "b")
+
+	// An empty policy overrides the profile's retries rather than using it
+	runner := &BatchRunner{Client: client, Retry: &RetryPolicy{}}
+	results := runner.Run(context.Background(), []Request{{Prompt: "b"}})
+	if results[0].Err == nil || results[0].Attempts != 1 {
+		t.Errorf("result = %v after %d attempts, want an error after 1", results[0].Err, results[0].Attempts)
+	}
+}
+
+func TestBatchRunner_IdempotencyKeyReusedOnRetry(t *testing.T) {
+	client := setupTestClient(t)
+
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		first := len(keys) == 1
+		mu.Unlock()
+
+		if first {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	runner := &BatchRunner{
+		Client:  client,
+		Profile: "test",
+		Retry:   &RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond},
+	}
+	results := runner.Run(context.Background(), []Request{{Prompt: "hi"}})
+	if results[0].Err != nil || results[0].Attempts != 2 {
+		t.Fatalf("result = %v after %d attempts, want success after 2", results[0].Err, results[0].Attempts)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Idempotency-Key per attempt = %v, want the same generated key", keys)
+	}
+}
+
+func TestBatchRunner_Cancelled(t *testing.T) {
+	client := setupBatchClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runner := &BatchRunner{Client: client}
+	results := runner.Run(ctx, []Request{{Prompt: "a"}, {Prompt: "b"}})
+	for _, r := range results {
+		if !errors.Is(r.Err, context.Canceled) || r.Attempts != 0 {
+			t.Errorf("result %d = %v after %d attempts, want context.Canceled before any", r.Index, r.Err, r.Attempts)
+		}
+	}
+}
+
+func TestClient_CompleteBatch(t *testing.T) {
+	client := setupBatchClient(t)
+	ctx := context.Background()
+	opts := BatchOptions{Concurrency: 3, Retry: &RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond}}
+
+	results, err := client.CompleteBatch(ctx, "", []Request{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "c"}}, opts)
+	if err != nil {
+		t.Fatalf("CompleteBatch() error = %v", err)
+	}
+	for i, want := range []string{"re: a", "re: b", "re: c"} {
+		if results[i].Response == nil || results[i].Response.Content != want {
+			t.Errorf("results[%d] = %+v, want content %q", i, results[i], want)
+		}
+	}
+
+	results, err = client.CompleteBatch(ctx, "", []Request{{Prompt: "a"}, {Prompt: "always-fail"}}, opts)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 1 || batchErr.Failed[0].Index != 1 || batchErr.Total != 2 {
+		t.Fatalf("CompleteBatch() error = %v, want a BatchError for request 1", err)
+	}
+	if !errors.Is(err, providers.ErrServerError) {
+		t.Errorf("errors.Is(%v, ErrServerError) = false, want the item's error to match", err)
+	}
+	if results[0].Response == nil || results[0].Response.Content != "re: a" {
+		t.Errorf("results[0] = %+v, want the successful response kept", results[0])
+	}
+
+	if _, err := client.CompleteBatch(ctx, "missing", []Request{{Prompt: "a"}}, opts); err == nil {
+		t.Error("CompleteBatch() with an unknown profile should error")
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(100) // 10ms apart
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		limiter.wait(context.Background())
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("4 events took %v, want at least 30ms", elapsed)
+	}
+
+	// A nil limiter never waits
+	var none *rateLimiter
+	none.wait(context.Background())
+}
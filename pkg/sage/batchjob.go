@@ -0,0 +1,182 @@
+package sage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// BatchJob is the state of an asynchronous batch job, run by the provider
+// offline at a discount.
+type BatchJob struct {
+	ID        string
+	Status    string // As the provider reports it, e.g. "in_progress" or "completed"
+	Done      bool   // Finished, whether completed, failed, expired or cancelled
+	Total     int
+	Completed int
+	Failed    int
+	CreatedAt time.Time
+	Errors    []string // Job-level errors, such as invalid input
+}
+
+// SubmitBatch starts an asynchronous batch job completing reqs with the
+// profile's provider and model. If profileName is empty, the default profile
+// is used. Use BatchStatus to follow the job and BatchResults to collect its
+// results once it's done, typically within 24 hours.
+func (c *Client) SubmitBatch(profileName string, reqs []Request) (*BatchJob, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("no requests to submit")
+	}
+
+	profile, batcher, err := c.batcher(profileName)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	batchReq := providers.BatchRequest{Items: make([]providers.BatchItem, len(reqs))}
+	for i, req := range reqs {
+		providerReq, err := c.buildProviderRequest(profileName, req)
+		if err != nil {
+			return nil, fmt.Errorf("request %d: %w", i, err)
+		}
+		batchReq.Items[i] = providers.BatchItem{ID: strconv.Itoa(i), Request: providerReq}
+		if i == 0 {
+			batchReq.Endpoint = providerReq.Endpoint
+		}
+	}
+
+	var job *providers.BatchJob
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &batchReq.APIKey, func() error {
+			job, err = batcher.SubmitBatch(batchReq)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertBatchJob(job), nil
+}
+
+// BatchStatus returns the state of a batch job submitted with the profile.
+func (c *Client) BatchStatus(profileName, id string) (*BatchJob, error) {
+	var job *providers.BatchJob
+	err := c.withBatchJob(profileName, id, func(batcher providers.Batcher, req providers.BatchJobRequest) error {
+		var err error
+		job, err = batcher.GetBatch(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertBatchJob(job), nil
+}
+
+// BatchResults returns the results of a finished batch job in the order its
+// requests were submitted. Requests that failed have Err set; Attempts is
+// always 1, since the provider doesn't report its own retries.
+func (c *Client) BatchResults(profileName, id string) ([]BatchResult, error) {
+	var itemResults []providers.BatchItemResult
+	err := c.withBatchJob(profileName, id, func(batcher providers.Batcher, req providers.BatchJobRequest) error {
+		var err error
+		itemResults, err = batcher.BatchResults(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BatchResult
+	for _, item := range itemResults {
+		index, err := strconv.Atoi(item.ID)
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("batch result has unexpected ID %q", item.ID)
+		}
+		for len(results) <= index {
+			results = append(results, BatchResult{Index: len(results), Attempts: 1, Err: errors.New("no result returned")})
+		}
+
+		results[index].Err = nil
+		if item.Response == nil {
+			results[index].Err = errors.New(item.Error)
+			continue
+		}
+		results[index].Response = &Response{
+			Content: item.Response.Content,
+			Model:   item.Response.Model,
+			Usage: Usage{
+				PromptTokens:     item.Response.Usage.PromptTokens,
+				CompletionTokens: item.Response.Usage.CompletionTokens,
+			},
+			SystemFingerprint: item.Response.SystemFingerprint,
+			FinishReason:      item.Response.FinishReason,
+		}
+	}
+	return results, nil
+}
+
+// batcher returns the profile and its provider's batch API.
+func (c *Client) batcher(profileName string) (*Profile, providers.Batcher, error) {
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	batcher, ok := provider.(providers.Batcher)
+	if !ok || !providers.Supports(provider, providers.CapBatch) {
+		return nil, nil, errIncapable(profile, providers.CapBatch)
+	}
+	return profile, batcher, nil
+}
+
+// withBatchJob calls fn for an existing job, with retries and key failover.
+func (c *Client) withBatchJob(profileName, id string, fn func(providers.Batcher, providers.BatchJobRequest) error) error {
+	if id == "" {
+		return fmt.Errorf("batch ID is required")
+	}
+	profile, batcher, err := c.batcher(profileName)
+	if err != nil {
+		return err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return err
+	}
+	req := providers.BatchJobRequest{
+		ID:       id,
+		Endpoint: endpoint,
+	}
+	return policy.do(func() error {
+		return c.withKeyFailover(profile, &req.APIKey, func() error {
+			return fn(batcher, req)
+		})
+	})
+}
+
+func convertBatchJob(job *providers.BatchJob) *BatchJob {
+	return &BatchJob{
+		ID:        job.ID,
+		Status:    job.Status,
+		Done:      job.Done,
+		Total:     job.Total,
+		Completed: job.Completed,
+		Failed:    job.Failed,
+		CreatedAt: job.CreatedAt,
+		Errors:    job.Errors,
+	}
+}
@@ -0,0 +1,81 @@
+package sage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_BatchJob(t *testing.T) {
+	client := setupTestClient(t)
+
+	var uploaded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/files":
+			file, _, _ := r.FormFile("file")
+			data, _ := io.ReadAll(file)
+			uploaded = string(data)
+			w.Write([]byte(`{"id": "file-in"}`))
+		case "/v1/batches":
+			w.Write([]byte(`{"id": "batch_1", "status": "validating"}`))
+		case "/v1/batches/batch_1":
+			w.Write([]byte(`{"id": "batch_1", "status": "completed", "output_file_id": "file-out",
+				"request_counts": {"total": 2, "completed": 1, "failed": 1}}`))
+		case "/v1/files/file-out/content":
+			w.Write([]byte(`{"custom_id": "1", "response": {"status_code": 200, "body": {"choices": [{"message": {"content": "second"}}]}}}
+{"custom_id": "0", "error": {"message": "invalid model"}}
+`))
+		}
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("fast", Profile{Provider: "openai", Account: "default", Model: "gpt-4o-mini"})
+
+	job, err := client.SubmitBatch("fast", []Request{{Prompt: "first"}, {Prompt: "second"}})
+	if err != nil {
+		t.Fatalf("SubmitBatch() error = %v", err)
+	}
+	if job.ID != "batch_1" || job.Done {
+		t.Errorf("job = %+v", job)
+	}
+	if strings.Count(uploaded, "gpt-4o-mini") != 2 {
+		t.Errorf("uploaded = %s, want both requests with the profile's model", uploaded)
+	}
+
+	job, err = client.BatchStatus("fast", "batch_1")
+	if err != nil || !job.Done || job.Completed != 1 {
+		t.Errorf("BatchStatus() = %+v, %v", job, err)
+	}
+
+	results, err := client.BatchResults("fast", "batch_1")
+	if err != nil {
+		t.Fatalf("BatchResults() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[0].Err == nil || results[0].Err.Error() != "invalid model" {
+		t.Errorf("results[0].Err = %v, want the item's error", results[0].Err)
+	}
+	if results[1].Response == nil || results[1].Response.Content != "second" {
+		t.Errorf("results[1] = %+v, want results in submission order", results[1])
+	}
+}
+
+func TestClient_SubmitBatch_Unsupported(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-5-haiku-latest"})
+
+	if _, err := client.SubmitBatch("claude", []Request{{Prompt: "hi"}}); err == nil {
+		t.Error("SubmitBatch() should fail for a provider without batch jobs")
+	}
+}
@@ -0,0 +1,172 @@
+package sage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	bundleFormat     = "sage-bundle"
+	bundleVersion    = 1
+	bundleKDF        = "pbkdf2-sha256"
+	bundleIterations = 600000 // OWASP's recommendation for PBKDF2-HMAC-SHA256
+	bundleSaltSize   = 16
+)
+
+// bundleFile is an exported bundle as written to disk. Data is the
+// bundleContent JSON, encrypted like secrets.enc but with a key derived
+// from the passphrase.
+type bundleFile struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Data       []byte `json:"data"` // nonce || AES-256-GCM ciphertext
+}
+
+// bundleContent is the decrypted content of a bundle.
+type bundleContent struct {
+	Providers      map[string]ProviderConfig `json:"providers"`
+	Profiles       map[string]Profile        `json:"profiles"`
+	DefaultProfile string                    `json:"default_profile,omitempty"`
+	Retry          *RetryConfig              `json:"retry,omitempty"`
+	Secrets        map[string]string         `json:"secrets"`
+}
+
+// ExportBundle returns the client's providers, profiles, retry settings and
+// API keys as a bundle encrypted with passphrase, for moving a setup to
+// another machine with ImportBundle.
+func (c *Client) ExportBundle(passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase required")
+	}
+
+	content := bundleContent{
+		Providers:      c.config.Providers,
+		Profiles:       c.config.Profiles,
+		DefaultProfile: c.config.DefaultProfile,
+		Retry:          c.config.Retry,
+		Secrets:        make(map[string]string),
+	}
+	for name, p := range c.config.Providers {
+		for _, account := range p.Accounts {
+			for i, key := range c.apiKeys(name, account) {
+				if i == 0 {
+					content.Secrets[secretKey(name, account)] = key
+				} else {
+					content.Secrets[extraSecretKey(name, account, i+1)] = key
+				}
+			}
+		}
+	}
+	plaintext, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal bundle: %w", err)
+	}
+
+	salt := make([]byte, bundleSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("cannot generate salt: %w", err)
+	}
+	data, err := encrypt(pbkdf2Key([]byte(passphrase), salt, bundleIterations), plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt bundle: %w", err)
+	}
+
+	return json.MarshalIndent(bundleFile{
+		Format:     bundleFormat,
+		Version:    bundleVersion,
+		KDF:        bundleKDF,
+		Iterations: bundleIterations,
+		Salt:       salt,
+		Data:       data,
+	}, "", "  ")
+}
+
+// ImportBundle decrypts a bundle made by ExportBundle and adds its
+// providers, profiles and API keys to the client's, replacing any with the
+// same names. Imported accounts keep only the bundle's keys. The bundle's
+// default profile and retry settings are used only where the client has
+// none.
+func (c *Client) ImportBundle(bundle []byte, passphrase string) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	var f bundleFile
+	if err := json.Unmarshal(bundle, &f); err != nil || f.Format != bundleFormat {
+		return errors.New("not a sage bundle")
+	}
+	if f.Version != bundleVersion || f.KDF != bundleKDF {
+		return fmt.Errorf("unsupported bundle version %d (%s)", f.Version, f.KDF)
+	}
+	if f.Iterations < 1 || f.Iterations > 10*bundleIterations {
+		return fmt.Errorf("invalid bundle iteration count %d", f.Iterations)
+	}
+	plaintext, err := decrypt(pbkdf2Key([]byte(passphrase), f.Salt, f.Iterations), f.Data)
+	if err != nil {
+		return errors.New("cannot decrypt bundle: wrong passphrase or corrupt file")
+	}
+	var content bundleContent
+	if err := json.Unmarshal(plaintext, &content); err != nil {
+		return fmt.Errorf("invalid bundle content: %w", err)
+	}
+
+	for name, p := range content.Providers {
+		accounts := c.config.Providers[name].Accounts
+		for _, account := range p.Accounts {
+			c.removeAPIKeys(name, account)
+			if !slices.Contains(accounts, account) {
+				accounts = append(accounts, account)
+			}
+		}
+		p.Accounts = accounts
+		c.config.Providers[name] = p
+	}
+	for k, v := range content.Secrets {
+		c.secrets[k] = v
+	}
+	for name, p := range content.Profiles {
+		c.config.Profiles[name] = p
+	}
+	if c.config.DefaultProfile == "" {
+		c.config.DefaultProfile = content.DefaultProfile
+	}
+	if c.config.Retry == nil {
+		c.config.Retry = content.Retry
+	}
+
+	if err := c.saveConfig(); err != nil {
+		return err
+	}
+	return c.saveSecrets()
+}
+
+// pbkdf2Key derives an AES-256 key from passphrase with PBKDF2-HMAC-SHA256
+// (RFC 8018). The key is one SHA-256 block long, so only the first block is
+// computed.
+func pbkdf2Key(passphrase, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, passphrase)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1}) // Block index
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
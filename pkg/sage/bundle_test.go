@@ -0,0 +1,64 @@
+package sage
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestPBKDF2Key(t *testing.T) {
+	// Published PBKDF2-HMAC-SHA256 test vector
+	got := hex.EncodeToString(pbkdf2Key([]byte("password"), []byte("salt"), 4096))
+	want := "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"
+	if got != want {
+		t.Errorf("pbkdf2Key() = %s, want %s", got, want)
+	}
+}
+
+func TestClient_ExportImportBundle(t *testing.T) {
+	src, _ := NewClientWith(nil, nil)
+	src.AddProviderAccount("openai", "default", "sk-1")
+	src.AddProviderKey("openai", "default", "sk-2")
+	src.AddProviderAccount("anthropic", "work", "sk-ant")
+	src.AddProfile("chat", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+	src.SetDefaultProfile("chat")
+
+	bundle, err := src.ExportBundle("correct horse")
+	if err != nil {
+		t.Fatalf("ExportBundle() error = %v", err)
+	}
+
+	// The destination has its own account and a stale extra key for the
+	// imported one
+	dst, _ := NewClientWith(nil, nil)
+	dst.AddProviderAccount("openai", "personal", "sk-mine")
+	dst.AddProviderAccount("openai", "default", "sk-old")
+	dst.AddProviderKey("openai", "default", "sk-old-2")
+	dst.AddProviderKey("openai", "default", "sk-old-3")
+
+	if err := dst.ImportBundle(bundle, "wrong"); err == nil {
+		t.Fatal("ImportBundle() with the wrong passphrase should error")
+	}
+	if err := dst.ImportBundle(bundle, "correct horse"); err != nil {
+		t.Fatalf("ImportBundle() error = %v", err)
+	}
+
+	if keys := dst.apiKeys("openai", "default"); len(keys) != 2 || keys[0] != "sk-1" || keys[1] != "sk-2" {
+		t.Errorf("openai:default keys = %v, want the bundle's [sk-1 sk-2]", keys)
+	}
+	if keys := dst.apiKeys("openai", "personal"); len(keys) != 1 || keys[0] != "sk-mine" {
+		t.Errorf("openai:personal keys = %v, want the existing account kept", keys)
+	}
+	if !dst.HasProviderAccount("anthropic", "work") || dst.apiKeys("anthropic", "work")[0] != "sk-ant" {
+		t.Error("anthropic:work not imported")
+	}
+	if p, err := dst.GetProfile(""); err != nil || p.Model != "gpt-4o" {
+		t.Errorf("default profile = %+v, %v; want the bundle's chat profile", p, err)
+	}
+
+	if _, err := src.ExportBundle(""); err == nil {
+		t.Error("ExportBundle() without a passphrase should error")
+	}
+	if err := dst.ImportBundle([]byte(`{"hello": "world"}`), "x"); err == nil {
+		t.Error("ImportBundle() of a non-bundle should error")
+	}
+}
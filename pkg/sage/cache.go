@@ -0,0 +1,132 @@
+package sage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// Cache stores completion responses so that repeating an identical request
+// returns the earlier response without calling the provider. Keys are
+// opaque hashes. Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) (*Response, bool)
+	Set(key string, resp *Response)
+}
+
+// SetCache makes Complete answer requests from cache when it has a response
+// for an identical one, and store new responses in it. Requests match when
+// the provider account, end user, model, prompt, messages, attachments and
+// parameters are all equal, so sampled responses are replayed rather than
+// re-sampled. Streams
+// aren't cached. Pass nil to stop caching. Set it before sending requests.
+func (c *Client) SetCache(cache Cache) {
+	c.cache = cache
+}
+
+// MemoryCache is a Cache held in memory, with entries that expire after a
+// TTL.
+type MemoryCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]memoryCacheEntry
+	nextSweep time.Time // When Set next drops expired entries
+}
+
+type memoryCacheEntry struct {
+	resp    Response
+	expires time.Time // Zero if the entry never expires
+}
+
+// NewMemoryCache creates an empty in-memory cache whose entries expire ttl
+// after being stored. A ttl of zero keeps entries for the cache's lifetime.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl, entries: make(map[string]memoryCacheEntry)}
+}
+
+// Get returns the response stored under key, if it hasn't expired.
+func (m *MemoryCache) Get(key string) (*Response, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(m.entries, key)
+		return nil, false
+	}
+	resp := e.resp
+	return &resp, true
+}
+
+// Set stores a copy of resp under key. Expired entries are dropped when
+// they're looked up, and the rest at most once per TTL, so that entries
+// nobody asks for again don't pile up.
+func (m *MemoryCache) Set(key string, resp *Response) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.ttl > 0 && now.After(m.nextSweep) {
+		for k, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.nextSweep = now.Add(m.ttl)
+	}
+	e := memoryCacheEntry{resp: *resp}
+	if m.ttl > 0 {
+		e.expires = now.Add(m.ttl)
+	}
+	m.entries[key] = e
+}
+
+// cacheKey hashes the parts of a request that determine its response, and
+// who it's for: everything but credentials, IDs and stream settings. The
+// account and end user are included so one's responses are never replayed
+// to another.
+func cacheKey(providerName, account string, req providers.Request) string {
+	data, _ := json.Marshal(struct {
+		Provider         string
+		Account          string
+		User             string
+		Headers          map[string]string
+		Platform         string
+		BaseURL          string
+		APIVersion       string
+		Model            string
+		ModelAlias       string
+		System           string
+		Prompt           string
+		Messages         []providers.Message
+		Images           []providers.Image
+		Documents        []providers.Document
+		MaxTokens        int
+		Temperature      *float64
+		TopP             *float64
+		FrequencyPenalty *float64
+		PresencePenalty  *float64
+		Seed             *int
+		Logprobs         bool
+		TopLogprobs      int
+		ReasoningEffort  string
+		Betas            []string
+		ExtraBody        map[string]any
+		ResponseFormat   *providers.ResponseFormat
+	}{
+		providerName, account, req.User, req.Headers, req.Platform, req.BaseURL, req.APIVersion,
+		req.Model, req.ModelAlias, req.System, req.Prompt, req.Messages, req.Images, req.Documents,
+		req.MaxTokens, req.Temperature, req.TopP, req.FrequencyPenalty, req.PresencePenalty, req.Seed,
+		req.Logprobs, req.TopLogprobs, req.ReasoningEffort, req.Betas, req.ExtraBody, req.ResponseFormat,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
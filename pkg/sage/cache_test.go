@@ -0,0 +1,89 @@
+package sage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_TTL(t *testing.T) {
+	cache := NewMemoryCache(20 * time.Millisecond)
+	cache.Set("k", &Response{Content: "hi"})
+
+	resp, ok := cache.Get("k")
+	if !ok || resp.Content != "hi" {
+		t.Fatalf("Get() = %+v, %v; want the stored response", resp, ok)
+	}
+	resp.Content = "changed"
+	if resp, _ := cache.Get("k"); resp.Content != "hi" {
+		t.Error("changing a returned response changed the cached one")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.Get("k"); ok {
+		t.Error("Get() returned an expired entry")
+	}
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("Get() of a missing key should miss")
+	}
+
+	// Expired entries that are never looked up again are swept on Set
+	cache.Set("old", &Response{Content: "old"})
+	time.Sleep(30 * time.Millisecond)
+	cache.Set("new", &Response{Content: "new"})
+	if _, ok := cache.entries["old"]; ok || len(cache.entries) != 1 {
+		t.Errorf("entries = %d, want only the new one after expiry", len(cache.entries))
+	}
+}
+
+func TestClient_Complete_Cache(t *testing.T) {
+	client := setupTestClient(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-1")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+	client.SetCache(NewMemoryCache(time.Minute))
+
+	ctx := context.Background()
+	first, err := client.Complete(ctx, "test", Request{Prompt: "hi", RequestID: "req-1"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	second, err := client.Complete(ctx, "test", Request{Prompt: "hi", RequestID: "req-2"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d after a repeated request, want 1", calls)
+	}
+	if first.Cached || !second.Cached || second.Content != "ok" || second.RequestID != "req-2" {
+		t.Errorf("responses = %+v, %+v; want the second served from cache with its own request ID", first, second)
+	}
+
+	// Any change to the request, or NoCache, goes to the provider
+	client.Complete(ctx, "test", Request{Prompt: "hi", Temperature: Float(0)})
+	client.Complete(ctx, "test", Request{Prompt: "hi", NoCache: true})
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+
+	// Another end user or account never gets this one's response
+	client.AddProviderAccount("openai", "other", "sk-2")
+	client.AddProfile("other", Profile{Provider: "openai", Account: "other", Model: "gpt-4o"})
+	client.Complete(ctx, "test", Request{Prompt: "hi", User: "alice"})
+	client.Complete(ctx, "other", Request{Prompt: "hi"})
+	if calls != 5 {
+		t.Errorf("calls = %d, want 5", calls)
+	}
+}
@@ -1,16 +1,45 @@
 package sage
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/not-emily/sage/pkg/sage/providers"
 )
 
 // Client provides the high-level API for LLM completions.
 type Client struct {
-	config  *Config
-	secrets map[string]string
+	config   *Config
+	secrets  map[string]string
+	inMemory bool // Created by NewClientWith; changes aren't saved
+
+	catalogErr error // Why the downloaded model catalog couldn't be used
+
+	// Config and secrets as last loaded or saved, so that saving applies
+	// only this client's changes over those made by other processes
+	savedConfig  *Config
+	savedSecrets map[string]string
+
+	mu           sync.Mutex
+	keyIndex     map[string]int          // Current API key per provider:account
+	proxyClients map[string]*http.Client // HTTP client per proxy setting
+	cmdKeys      map[string]cmdKey       // Output of each api_key_cmd run
+	keyUsed      map[string]time.Time    // When each key's last use was recorded
+
+	preSend    PreSendHook  // Set by SetPreSendHook
+	httpClient *http.Client // Set by SetHTTPClient
+	cache      Cache        // Set by SetCache
 }
 
 // NewClient creates a new client, loading config and secrets.
@@ -25,69 +54,295 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("failed to load secrets: %w", err)
 	}
 
+	// A bad download mustn't stop 'sage catalog update' from replacing it
+	catalogErr := loadCatalog()
+
+	return &Client{
+		config:     config,
+		secrets:    secrets,
+		keyIndex:   make(map[string]int),
+		catalogErr: catalogErr,
+
+		savedConfig:  config.clone(),
+		savedSecrets: maps.Clone(secrets),
+	}, nil
+}
+
+// NewClientWith creates a client from config and secrets held in memory,
+// without reading or writing any files, for servers and tests. secrets maps
+// "provider:account" to the account's API key, e.g. "openai:default". The
+// client uses both as given: profile and account changes made through it
+// update them but aren't saved anywhere. A nil config is treated as empty.
+func NewClientWith(config *Config, secrets map[string]string) (*Client, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	if config.Providers == nil {
+		config.Providers = make(map[string]ProviderConfig)
+	}
+	if config.Profiles == nil {
+		config.Profiles = make(map[string]Profile)
+	}
+	if secrets == nil {
+		secrets = make(map[string]string)
+	}
+	if _, err := retryPolicy(config.Retry); err != nil {
+		return nil, err
+	}
+
 	return &Client{
-		config:  config,
-		secrets: secrets,
+		config:   config,
+		secrets:  secrets,
+		inMemory: true,
+		keyIndex: make(map[string]int),
 	}, nil
 }
 
+// SetHTTPClient makes every provider send its requests through hc instead
+// of http.DefaultClient, e.g. to add a proxy, TLS settings or
+// instrumentation. Pass nil to go back to the default. Set it before
+// sending requests.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.httpClient = hc
+	c.proxyClients = nil
+}
+
+// getProvider returns the named provider, set up to send requests through
+// the HTTP client for its settings.
+func (c *Client) getProvider(providerName string) (providers.Provider, error) {
+	return c.newProvider(providerName, providerName)
+}
+
+// platformProvider is getProvider for calls that go to the cloud platform
+// a provider's accounts are routed through, if any.
+func (c *Client) platformProvider(providerName string) (providers.Provider, error) {
+	name := providerName
+	if platform := c.config.Providers[providerName].Platform; platform != "" {
+		name = platform
+	}
+	return c.newProvider(name, providerName)
+}
+
+// newProvider creates the provider name, using the HTTP client for
+// providerName's settings.
+func (c *Client) newProvider(name, providerName string) (providers.Provider, error) {
+	provider, err := providers.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	hc, err := c.providerHTTPClient(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if setter, ok := provider.(providers.HTTPClientSetter); ok && hc != nil {
+		setter.SetHTTPClient(hc)
+	}
+	return provider, nil
+}
+
 // Complete sends a completion request using the specified profile.
-// If profileName is empty, the default profile is used.
-func (c *Client) Complete(profileName string, req Request) (*Response, error) {
+// If profileName is empty, the default profile is used. Failed requests are
+// retried according to the profile's RetryPolicy. Cancelling ctx aborts the
+// request and any wait between retries.
+func (c *Client) Complete(ctx context.Context, profileName string, req Request) (*Response, error) {
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+	return c.complete(ctx, profileName, req, policy)
+}
+
+// complete sends a completion request, retrying according to policy.
+func (c *Client) complete(ctx context.Context, profileName string, req Request, policy RetryPolicy) (*Response, error) {
+	start := time.Now()
+
 	providerReq, err := c.buildProviderRequest(profileName, req)
 	if err != nil {
 		return nil, err
 	}
+	if c.preSend != nil {
+		if err := c.preSend(profileName, req); err != nil {
+			return nil, err
+		}
+	}
+
+	// Retries below reuse the key so providers can deduplicate them
+	if providerReq.IdempotencyKey == "" {
+		providerReq.IdempotencyKey = newRequestID()
+	}
 
 	profile, _ := c.config.GetProfile(profileName)
-	provider, err := providers.Get(profile.Provider)
+
+	var cacheKeyHash string
+	if c.cache != nil && !req.NoCache {
+		cacheKeyHash = cacheKey(profile.Provider, profile.Account, providerReq)
+		if resp, ok := c.cache.Get(cacheKeyHash); ok {
+			resp.RequestID = providerReq.RequestID
+			resp.Cached = true
+			resp.Timing = Timing{Total: time.Since(start)}
+			return resp, nil
+		}
+	}
+
+	provider, err := c.getProvider(profile.Provider)
 	if err != nil {
 		return nil, err
 	}
 
-	providerResp, err := provider.Complete(providerReq)
+	var providerResp *providers.Response
+	var roundTrip time.Duration
+	err = policy.doContext(ctx, func() error {
+		return c.withKeyFailover(profile, &providerReq.APIKey, func() error {
+			sent := time.Now()
+			providerResp, err = provider.Complete(ctx, providerReq)
+			roundTrip = time.Since(sent)
+			return err
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
+	received := time.Since(start)
 
-	return &Response{
+	resp := &Response{
 		Content: providerResp.Content,
 		Model:   providerResp.Model,
 		Usage: Usage{
 			PromptTokens:     providerResp.Usage.PromptTokens,
 			CompletionTokens: providerResp.Usage.CompletionTokens,
 		},
-	}, nil
+		RequestID:         providerReq.RequestID,
+		ProviderRequestID: providerResp.RequestID,
+		Citations:         convertCitations(providerResp.Citations),
+		SystemFingerprint: providerResp.SystemFingerprint,
+		FinishReason:      providerResp.FinishReason,
+		Logprobs:          convertLogprobs(providerResp.Logprobs),
+		Timing: Timing{
+			Total:      time.Since(start),
+			FirstToken: received,
+			Provider:   roundTrip,
+		},
+	}
+	if cacheKeyHash != "" {
+		c.cache.Set(cacheKeyHash, resp)
+	}
+	return resp, nil
 }
 
 // CompleteStream sends a streaming completion request.
-// If profileName is empty, the default profile is used.
-func (c *Client) CompleteStream(profileName string, req Request) (<-chan Chunk, error) {
+// If profileName is empty, the default profile is used. Opening the stream
+// is retried according to the profile's RetryPolicy. With
+// req.ResumeOnDisconnect, a stream that drops mid-response is reopened with
+// the content received so far, up to the policy's MaxRetries times.
+//
+// Cancelling ctx aborts the stream: a chunk with ctx's error is sent if the
+// reader is still receiving, and the channel is closed.
+func (c *Client) CompleteStream(ctx context.Context, profileName string, req Request) (<-chan Chunk, error) {
+	start := time.Now()
+
 	providerReq, err := c.buildProviderRequest(profileName, req)
 	if err != nil {
 		return nil, err
 	}
+	if c.preSend != nil {
+		if err := c.preSend(profileName, req); err != nil {
+			return nil, err
+		}
+	}
 
 	profile, _ := c.config.GetProfile(profileName)
-	provider, err := providers.Get(profile.Provider)
+	provider, err := c.getProvider(profile.Provider)
 	if err != nil {
 		return nil, err
 	}
 
-	providerCh, err := provider.CompleteStream(providerReq)
+	policy, err := c.RetryPolicy(profileName)
 	if err != nil {
 		return nil, err
 	}
 
+	var providerCh <-chan providers.Chunk
+	var opened time.Time
+	open := func() error {
+		return policy.doContext(ctx, func() error {
+			return c.withKeyFailover(profile, &providerReq.APIKey, func() error {
+				opened = time.Now()
+				providerCh, err = provider.CompleteStream(ctx, providerReq)
+				return err
+			})
+		})
+	}
+	if err := open(); err != nil {
+		return nil, err
+	}
+
 	// Convert provider chunks to sage chunks
 	ch := make(chan Chunk)
+	send := func(chunk Chunk) bool {
+		select {
+		case ch <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
 	go func() {
 		defer close(ch)
-		for providerChunk := range providerCh {
-			ch <- Chunk{
-				Content: providerChunk.Content,
-				Done:    providerChunk.Done,
-				Error:   providerChunk.Error,
+		var received strings.Builder
+		var firstToken time.Duration
+		for resumes := 1; ; resumes++ {
+			var streamErr error
+			for providerChunk := range providerCh {
+				if providerChunk.Error != nil {
+					streamErr = providerChunk.Error
+					break
+				}
+				if providerChunk.Done {
+					send(Chunk{
+						Done: true,
+						Timing: &Timing{
+							Total:      time.Since(start),
+							FirstToken: firstToken,
+							Provider:   time.Since(opened),
+						},
+						ProviderRequestID: providerChunk.RequestID,
+						Citations:         convertCitations(providerChunk.Citations),
+						SystemFingerprint: providerChunk.SystemFingerprint,
+						FinishReason:      providerChunk.FinishReason,
+						Usage:             convertUsage(providerChunk.Usage),
+					})
+					return
+				}
+				if firstToken == 0 && providerChunk.Content != "" {
+					firstToken = time.Since(start)
+				}
+				received.WriteString(providerChunk.Content)
+				if !send(Chunk{Content: providerChunk.Content, Logprobs: convertLogprobs(providerChunk.Logprobs)}) {
+					return
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				send(Chunk{Error: err})
+				return
+			}
+			if streamErr == nil {
+				return // Closed without a done marker
+			}
+
+			if !req.ResumeOnDisconnect || !isDisconnect(streamErr) || resumes > policy.MaxRetries {
+				send(Chunk{Error: streamErr})
+				return
+			}
+			if err := sleepContext(ctx, policy.delay(resumes, streamErr)); err != nil {
+				send(Chunk{Error: err})
+				return
+			}
+			providerReq.Continue = received.String()
+			if err := open(); err != nil {
+				send(Chunk{Error: fmt.Errorf("cannot resume stream: %w (after %v)", err, streamErr)})
+				return
 			}
 		}
 	}()
@@ -95,31 +350,353 @@ func (c *Client) CompleteStream(profileName string, req Request) (<-chan Chunk,
 	return ch, nil
 }
 
+// convertUsage converts provider usage to sage usage, keeping nil as nil.
+func convertUsage(u *providers.Usage) *Usage {
+	if u == nil {
+		return nil
+	}
+	return &Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+// convertLogprobs converts provider logprobs to sage logprobs.
+func convertLogprobs(lps []providers.TokenLogprob) []TokenLogprob {
+	if len(lps) == 0 {
+		return nil
+	}
+	converted := make([]TokenLogprob, len(lps))
+	for i, lp := range lps {
+		converted[i] = TokenLogprob{
+			Token:       lp.Token,
+			Logprob:     lp.Logprob,
+			TopLogprobs: convertLogprobs(lp.TopLogprobs),
+		}
+	}
+	return converted
+}
+
+// convertCitations converts provider citations to sage citations.
+func convertCitations(cs []providers.Citation) []Citation {
+	if len(cs) == 0 {
+		return nil
+	}
+	citations := make([]Citation, len(cs))
+	for i, c := range cs {
+		citations[i] = Citation{URL: c.URL, Title: c.Title}
+	}
+	return citations
+}
+
+// isDisconnect reports whether a stream error means the connection dropped
+// or stalled, as opposed to the provider reporting a problem.
+func isDisconnect(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, providers.ErrStreamIdle) || errorClass(err) == RetryNetwork
+}
+
+// endpoint returns where the profile's requests go: its account's next API
+// key and its provider's base URL and headers.
+func (c *Client) endpoint(profile *Profile) (providers.Endpoint, error) {
+	apiKey, err := c.selectAPIKey(profile.Provider, profile.Account)
+	if err != nil {
+		return providers.Endpoint{}, err
+	}
+	providerConfig := c.config.Providers[profile.Provider]
+	return providers.Endpoint{
+		APIKey:  apiKey,
+		BaseURL: providerConfig.BaseURL,
+		Headers: providerConfig.Headers,
+	}, nil
+}
+
 // buildProviderRequest creates a provider request from a sage request.
 func (c *Client) buildProviderRequest(profileName string, req Request) (providers.Request, error) {
+	return c.buildRequestWithKey(profileName, req, c.selectAPIKey)
+}
+
+// buildRequestWithKey is buildProviderRequest with the API key chosen by apiKey.
+func (c *Client) buildRequestWithKey(profileName string, req Request, apiKey func(provider, account string) (string, error)) (providers.Request, error) {
 	profile, err := c.config.GetProfile(profileName)
 	if err != nil {
 		return providers.Request{}, err
 	}
 
+	// Fall back to the profile's system prompt
+	system := req.System
+	if system == "" {
+		system, err = profile.SystemPrompt()
+		if err != nil {
+			return providers.Request{}, err
+		}
+	}
+
+	messages, err := convertMessages(req.Messages)
+	if err != nil {
+		return providers.Request{}, err
+	}
+	if err := checkSampling(req); err != nil {
+		return providers.Request{}, err
+	}
+
+	// Fall back to the profile's reasoning effort
+	effort := req.ReasoningEffort
+	if effort == "" {
+		effort = profile.ReasoningEffort
+	}
+	if err := checkReasoningEffort(effort); err != nil {
+		return providers.Request{}, err
+	}
+	images, err := convertImages(req.Images)
+	if err != nil {
+		return providers.Request{}, err
+	}
+	documents, err := convertDocuments(req.Documents)
+	if err != nil {
+		return providers.Request{}, err
+	}
+	format, err := convertResponseFormat(req.ResponseFormat)
+	if err != nil {
+		return providers.Request{}, err
+	}
+
 	// Get API key for this provider:account
-	secretKey := profile.Provider + ":" + profile.Account
-	apiKey := c.secrets[secretKey]
+	key, err := apiKey(profile.Provider, profile.Account)
+	if err != nil {
+		return providers.Request{}, err
+	}
 
-	// Get provider config for BaseURL
+	// Get provider config for BaseURL and extra body fields
 	var baseURL string
-	if providerConfig, ok := c.config.Providers[profile.Provider]; ok {
+	providerConfig, ok := c.config.Providers[profile.Provider]
+	if ok {
 		baseURL = providerConfig.BaseURL
 	}
+	extraBody := mergeExtraBody(providerConfig.ExtraBody, profile.ExtraBody, req.ExtraBody)
 
-	return providers.Request{
-		Model:     profile.Model,
-		System:    req.System,
-		Prompt:    req.Prompt,
-		MaxTokens: req.MaxTokens,
-		APIKey:    apiKey,
-		BaseURL:   baseURL,
-	}, nil
+	betas := append([]string(nil), providerConfig.Betas...)
+	for _, b := range profile.Betas {
+		if !containsString(betas, b) {
+			betas = append(betas, b)
+		}
+	}
+
+	requestID := req.RequestID
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+
+	model := profile.Model
+	if req.Model != "" {
+		model = req.Model
+	}
+	if profile.RemapDeprecated {
+		model = resolveModel(model)
+	}
+
+	providerReq := providers.Request{
+		Model:          model,
+		ModelAlias:     providerConfig.ModelAliases[model],
+		System:         system,
+		Prompt:         req.Prompt,
+		Messages:       messages,
+		Images:         images,
+		Documents:      documents,
+		ResponseFormat: format,
+		MaxTokens:      req.MaxTokens,
+
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Seed:             req.Seed,
+		Logprobs:         req.Logprobs,
+		TopLogprobs:      req.TopLogprobs,
+		ReasoningEffort:  effort,
+
+		Endpoint:       providers.Endpoint{APIKey: key, BaseURL: baseURL, Headers: providerConfig.Headers},
+		APIVersion:     providerConfig.APIVersion,
+		RequestID:      requestID,
+		User:           req.User,
+		IdempotencyKey: req.IdempotencyKey,
+		IdleTimeout:    req.IdleTimeout,
+		OnHeartbeat:    req.OnHeartbeat,
+		Betas:          betas,
+		ExtraBody:      extraBody,
+		Platform:       providerConfig.Platform,
+	}
+	if err := checkCapabilities(profile, providerReq); err != nil {
+		return providers.Request{}, err
+	}
+	return providerReq, nil
+}
+
+// capabilityNames describes capabilities in errors.
+var capabilityNames = map[string]string{
+	providers.CapVision:          "vision",
+	providers.CapImageURLs:       "image URLs (attach the image file instead)",
+	providers.CapDocuments:       "documents",
+	providers.CapJSON:            "JSON output",
+	providers.CapEmbeddings:      "embeddings",
+	providers.CapTranscription:   "transcription",
+	providers.CapSpeech:          "text-to-speech",
+	providers.CapImageGeneration: "image generation",
+	providers.CapModeration:      "moderation",
+	providers.CapBatch:           "batch jobs",
+	providers.CapFineTuning:      "fine-tuning",
+	providers.CapFiles:           "file storage",
+	providers.CapLogprobs:        "logprobs",
+	providers.CapSeed:            "seed",
+	providers.CapPenalties:       "frequency or presence penalties",
+	providers.CapReasoningEffort: "reasoning effort",
+}
+
+// errIncapable reports a capability a profile's provider lacks.
+func errIncapable(profile *Profile, capability string) error {
+	return fmt.Errorf("%s profile %s does not support %s", profile.Provider, profile.Name, capabilityNames[capability])
+}
+
+// checkCapabilities returns an error if the request uses a feature the
+// profile's provider doesn't support, before anything is sent.
+func checkCapabilities(profile *Profile, req providers.Request) error {
+	provider, err := providers.Get(profile.Provider)
+	if err != nil {
+		return err
+	}
+
+	var needed []string
+	if len(req.Images) > 0 {
+		needed = append(needed, providers.CapVision)
+	}
+	for _, img := range req.Images {
+		if img.URL != "" {
+			needed = append(needed, providers.CapImageURLs)
+			break
+		}
+	}
+	if len(req.Documents) > 0 {
+		needed = append(needed, providers.CapDocuments)
+	}
+	if req.ResponseFormat != nil {
+		needed = append(needed, providers.CapJSON)
+	}
+	if req.Logprobs {
+		needed = append(needed, providers.CapLogprobs)
+	}
+	if req.Seed != nil {
+		needed = append(needed, providers.CapSeed)
+	}
+	if req.FrequencyPenalty != nil || req.PresencePenalty != nil {
+		needed = append(needed, providers.CapPenalties)
+	}
+	if req.ReasoningEffort != "" {
+		needed = append(needed, providers.CapReasoningEffort)
+	}
+
+	for _, capability := range needed {
+		if !providers.Supports(provider, capability) {
+			return errIncapable(profile, capability)
+		}
+	}
+	return nil
+}
+
+// convertMessages checks a conversation's roles and converts it for
+// providers.
+func convertMessages(messages []Message) ([]providers.Message, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	converted := make([]providers.Message, len(messages))
+	for i, m := range messages {
+		switch m.Role {
+		case "user", "assistant", "system":
+		default:
+			return nil, fmt.Errorf("message %d: unknown role %q (want user, assistant or system)", i, m.Role)
+		}
+		converted[i] = providers.Message{Role: m.Role, Content: m.Content}
+	}
+	return converted, nil
+}
+
+// checkSampling checks that sampling and logprob parameters are in range.
+func checkSampling(req Request) error {
+	params := []struct {
+		name     string
+		value    *float64
+		min, max float64
+	}{
+		{"temperature", req.Temperature, 0, 2},
+		{"top_p", req.TopP, 0, 1},
+		{"frequency_penalty", req.FrequencyPenalty, -2, 2},
+		{"presence_penalty", req.PresencePenalty, -2, 2},
+	}
+	for _, p := range params {
+		if p.value != nil && (*p.value < p.min || *p.value > p.max) {
+			return fmt.Errorf("%s %g out of range (%g to %g)", p.name, *p.value, p.min, p.max)
+		}
+	}
+	if req.TopLogprobs < 0 || req.TopLogprobs > 20 {
+		return fmt.Errorf("top_logprobs %d out of range (0 to 20)", req.TopLogprobs)
+	}
+	if req.TopLogprobs > 0 && !req.Logprobs {
+		return fmt.Errorf("top_logprobs requires logprobs")
+	}
+	return nil
+}
+
+// checkReasoningEffort checks a reasoning effort level; empty means unset.
+func checkReasoningEffort(effort string) error {
+	switch effort {
+	case "", "low", "medium", "high":
+		return nil
+	}
+	return fmt.Errorf("unknown reasoning effort %q (want low, medium or high)", effort)
+}
+
+// convertResponseFormat checks a response format and converts it for
+// providers.
+func convertResponseFormat(f *ResponseFormat) (*providers.ResponseFormat, error) {
+	if f == nil {
+		return nil, nil
+	}
+	switch f.Type {
+	case "json_object":
+	case "json_schema":
+		if !json.Valid(f.Schema) {
+			return nil, fmt.Errorf("response format json_schema needs a valid JSON schema")
+		}
+	default:
+		return nil, fmt.Errorf("unknown response format %q (want json_object or json_schema)", f.Type)
+	}
+	return &providers.ResponseFormat{Type: f.Type, Name: f.Name, Schema: f.Schema}, nil
+}
+
+// mergeExtraBody combines extra body maps; later maps override earlier ones,
+// and objects present in both are merged key by key. The inputs aren't
+// modified.
+func mergeExtraBody(maps ...map[string]any) map[string]any {
+	var merged map[string]any
+	for _, m := range maps {
+		for k, v := range m {
+			if merged == nil {
+				merged = make(map[string]any)
+			}
+			sub, ok := v.(map[string]any)
+			existing, isObject := merged[k].(map[string]any)
+			if ok && isObject {
+				v = mergeExtraBody(existing, sub)
+			}
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return "sage-" + hex.EncodeToString(b)
 }
 
 // --- Profile Management ---
@@ -129,6 +706,30 @@ func (c *Client) GetDefaultProfile() string {
 	return c.config.DefaultProfile
 }
 
+// RenderMarkdown reports whether the config's render setting asks for
+// Markdown in responses to be rendered for the terminal.
+func (c *Client) RenderMarkdown() bool {
+	return c.config.Render != nil && *c.config.Render
+}
+
+// ProjectConfigFile returns the path of the project config the client's
+// profiles were loaded with, or "" if there is none.
+func (c *Client) ProjectConfigFile() string {
+	return c.config.ProjectFile()
+}
+
+// CatalogError returns why the downloaded model catalog couldn't be read
+// when the client was created, or nil. The embedded catalog is used instead;
+// 'sage catalog update' replaces the broken file.
+func (c *Client) CatalogError() error {
+	return c.catalogErr
+}
+
+// IsProjectProfile reports whether a profile comes from the project config.
+func (c *Client) IsProjectProfile(name string) bool {
+	return c.config.IsProjectProfile(name)
+}
+
 // GetProfile returns a profile by name. If name is empty, returns the default.
 func (c *Client) GetProfile(name string) (*Profile, error) {
 	return c.config.GetProfile(name)
@@ -150,20 +751,43 @@ func (c *Client) ListProfiles() []Profile {
 
 // AddProfile adds or updates a profile.
 func (c *Client) AddProfile(name string, p Profile) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+
 	// Validate provider exists
 	if !providers.Exists(p.Provider) {
 		return fmt.Errorf("unknown provider: %s", p.Provider)
 	}
+	if _, err := retryPolicy(c.config.Retry, p.Retry); err != nil {
+		return err
+	}
+	if err := checkReasoningEffort(p.ReasoningEffort); err != nil {
+		return err
+	}
+	if err := c.config.checkNotProject(name); err != nil {
+		return err
+	}
 
 	c.config.Profiles[name] = p
-	return c.config.Save()
+	return c.saveConfig()
 }
 
 // RemoveProfile removes a profile.
 func (c *Client) RemoveProfile(name string) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+
 	if _, ok := c.config.Profiles[name]; !ok {
 		return fmt.Errorf("profile not found: %s", name)
 	}
+	if err := c.config.checkNotProject(name); err != nil {
+		return err
+	}
+	if _, ok := c.config.systemProfile(name); ok {
+		return fmt.Errorf("profile %s is set by the system config %s and can't be removed", name, SystemConfigPath())
+	}
 
 	// Don't allow removing the default profile
 	if c.config.DefaultProfile == name {
@@ -171,23 +795,88 @@ func (c *Client) RemoveProfile(name string) error {
 	}
 
 	delete(c.config.Profiles, name)
-	return c.config.Save()
+	return c.saveConfig()
 }
 
 // SetDefaultProfile sets the default profile.
 func (c *Client) SetDefaultProfile(name string) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+
 	if _, ok := c.config.Profiles[name]; !ok {
 		return fmt.Errorf("profile not found: %s", name)
 	}
+	if err := c.config.checkNotProject(""); err != nil {
+		return err
+	}
 
 	c.config.DefaultProfile = name
-	return c.config.Save()
+	return c.saveConfig()
+}
+
+// saveConfig writes the client's changes to the config to disk, unless the
+// client is in-memory.
+func (c *Client) saveConfig() error {
+	if c.inMemory {
+		return nil
+	}
+
+	before, after := c.savedConfig, c.config
+	cfg, err := updateConfig(func(cfg *Config) error {
+		return cfg.applyChanges(before, after)
+	})
+	if errors.Is(err, ErrConfigConflict) {
+		// Take the other process's changes, so the caller can retry on them
+		if current, loadErr := LoadConfig(); loadErr == nil {
+			c.config = current
+			c.savedConfig = current.clone()
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	c.config = cfg
+	c.savedConfig = cfg.clone()
+	return nil
+}
+
+// saveSecrets writes the client's changes to the secrets to disk, unless the
+// client is in-memory.
+func (c *Client) saveSecrets() error {
+	if c.inMemory {
+		return nil
+	}
+
+	secrets, err := updateSecrets(func(secrets map[string]string) {
+		for k, v := range c.secrets {
+			if old, ok := c.savedSecrets[k]; !ok || old != v {
+				secrets[k] = v
+			}
+		}
+		for k := range c.savedSecrets {
+			if _, ok := c.secrets[k]; !ok {
+				delete(secrets, k)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	c.secrets = secrets
+	c.savedSecrets = maps.Clone(secrets)
+	return nil
 }
 
 // --- Provider Account Management ---
 
 // AddProviderAccount adds a provider account with an API key.
 func (c *Client) AddProviderAccount(providerName, account, apiKey string) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+
 	// Validate provider exists
 	if !providers.Exists(providerName) {
 		return fmt.Errorf("unknown provider: %s", providerName)
@@ -201,7 +890,7 @@ func (c *Client) AddProviderAccount(providerName, account, apiKey string) error
 		if a == account {
 			// Account exists, just update the key
 			c.secrets[providerName+":"+account] = apiKey
-			return SaveSecrets(c.secrets)
+			return c.saveSecrets()
 		}
 	}
 
@@ -213,14 +902,18 @@ func (c *Client) AddProviderAccount(providerName, account, apiKey string) error
 	c.secrets[providerName+":"+account] = apiKey
 
 	// Save both config and secrets
-	if err := c.config.Save(); err != nil {
+	if err := c.saveConfig(); err != nil {
 		return err
 	}
-	return SaveSecrets(c.secrets)
+	return c.saveSecrets()
 }
 
 // RemoveProviderAccount removes a provider account and its API key.
 func (c *Client) RemoveProviderAccount(providerName, account string) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+
 	providerConfig, ok := c.config.Providers[providerName]
 	if !ok {
 		return fmt.Errorf("provider not configured: %s", providerName)
@@ -240,18 +933,27 @@ func (c *Client) RemoveProviderAccount(providerName, account string) error {
 	if !found {
 		return fmt.Errorf("account not found: %s:%s", providerName, account)
 	}
+	if base, ok := c.config.systemProvider(providerName); ok && containsString(base.Accounts, account) {
+		return fmt.Errorf("account %s:%s is set by the system config %s and can't be removed", providerName, account, SystemConfigPath())
+	}
 
 	providerConfig.Accounts = newAccounts
+	delete(providerConfig.APIKeyEnv, account)
+	delete(providerConfig.APIKeyCmd, account)
 	c.config.Providers[providerName] = providerConfig
 
-	// Remove the secret
-	delete(c.secrets, providerName+":"+account)
+	// Remove the account's keys
+	stored := len(c.apiKeys(providerName, account)) > 0
+	c.removeAPIKeys(providerName, account)
 
-	// Save both
-	if err := c.config.Save(); err != nil {
+	// Save both, unless the key came from the environment
+	if err := c.saveConfig(); err != nil {
 		return err
 	}
-	return SaveSecrets(c.secrets)
+	if !stored {
+		return nil
+	}
+	return c.saveSecrets()
 }
 
 // ListProviders returns all configured providers with their accounts.
@@ -262,6 +964,12 @@ func (c *Client) ListProviders() []ProviderInfo {
 			Name:     name,
 			Accounts: config.Accounts,
 			BaseURL:  config.BaseURL,
+			Betas:    config.Betas,
+			Headers:  config.Headers,
+			Platform: config.Platform,
+			Proxy:    redactProxy(config.Proxy),
+
+			APIVersion: config.APIVersion,
 		})
 	}
 	// Sort by name for consistent ordering
@@ -288,31 +996,44 @@ func (c *Client) HasProviderAccount(providerName, account string) bool {
 // --- Model Discovery ---
 
 // ModelInfo describes an available model.
+// Context window, capabilities, and pricing come from the provider or the
+// model catalog and are zero when neither knows them.
 type ModelInfo struct {
-	ID          string `json:"id"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
+	ID            string   `json:"id"`
+	Name          string   `json:"name,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	ContextWindow int      `json:"context_window,omitempty"`
+	Capabilities  []string `json:"capabilities,omitempty"`
+	InputPrice    float64  `json:"input_price,omitempty"`  // USD per million tokens
+	OutputPrice   float64  `json:"output_price,omitempty"` // USD per million tokens
+	Status        string   `json:"status,omitempty"`       // "deprecated" or "retired"
+}
+
+// HasCapability reports whether the model has the given capability.
+func (m ModelInfo) HasCapability(capability string) bool {
+	return containsString(m.Capabilities, capability)
 }
 
 // ListModels returns available models from a provider.
 // If account is empty, uses the first configured account.
 func (c *Client) ListModels(providerName, account string) ([]ModelInfo, error) {
-	provider, err := providers.Get(providerName)
+	// Accounts routed through a cloud platform list the platform's models
+	provider, err := c.platformProvider(providerName)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get API key for the account
-	var apiKey string
+	// Use specified account or first available
 	providerConfig, ok := c.config.Providers[providerName]
-	if ok {
-		// Use specified account or first available
-		if account == "" && len(providerConfig.Accounts) > 0 {
-			account = providerConfig.Accounts[0]
-		}
-		if account != "" {
-			apiKey = c.secrets[providerName+":"+account]
-		}
+	if account == "" && len(providerConfig.Accounts) > 0 {
+		account = providerConfig.Accounts[0]
+	}
+
+	// The account's key as for requests: stored, from its command, or from
+	// the environment
+	apiKey, err := c.selectAPIKey(providerName, account)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get baseURL if configured
@@ -330,9 +1051,24 @@ func (c *Client) ListModels(providerName, account string) ([]ModelInfo, error) {
 	models := make([]ModelInfo, len(providerModels))
 	for i, m := range providerModels {
 		models[i] = ModelInfo{
-			ID:          m.ID,
-			Name:        m.Name,
-			Description: m.Description,
+			ID:            m.ID,
+			Name:          m.Name,
+			Description:   m.Description,
+			ContextWindow: m.ContextWindow,
+			InputPrice:    m.InputPrice,
+			OutputPrice:   m.OutputPrice,
+		}
+		// Figures reported by the provider take precedence over the catalog
+		if spec, ok := providers.LookupModel(m.ID); ok {
+			if models[i].ContextWindow == 0 {
+				models[i].ContextWindow = spec.ContextWindow
+			}
+			if models[i].InputPrice == 0 && models[i].OutputPrice == 0 {
+				models[i].InputPrice = spec.InputPrice
+				models[i].OutputPrice = spec.OutputPrice
+			}
+			models[i].Capabilities = spec.Capabilities
+			models[i].Status = spec.Status
 		}
 	}
 
@@ -343,3 +1079,17 @@ func (c *Client) ListModels(providerName, account string) ([]ModelInfo, error) {
 func ListAvailableProviders() []string {
 	return providers.List()
 }
+
+// ProviderCapabilities lists the features a provider supports, such as
+// "vision", "json" and "embeddings". Individual models may support less.
+func ProviderCapabilities(providerName string) ([]string, error) {
+	provider, err := providers.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := provider.(providers.Capable)
+	if !ok {
+		return nil, nil // Unknown; requests aren't checked
+	}
+	return c.Capabilities(), nil
+}
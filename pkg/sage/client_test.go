@@ -1,9 +1,40 @@
 package sage
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
+// TestMain keeps tests that set HOME from reaching a real config directory
+// named by the environment, the machine's system-wide config, or a project
+// config above the package.
+func TestMain(m *testing.M) {
+	os.Unsetenv("SAGE_CONFIG_DIR")
+	os.Unsetenv("XDG_CONFIG_HOME")
+	os.Unsetenv("SAGE_DATA_DIR")
+	os.Unsetenv("XDG_DATA_HOME")
+	os.Unsetenv("SAGE_READ_ONLY")
+	os.Setenv("SAGE_PROJECT_CONFIG", "off")
+
+	// Unset, it would fall back to /etc/sage/config.json
+	noSystem, err := os.MkdirTemp("", "sage-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("SAGE_SYSTEM_CONFIG", filepath.Join(noSystem, "config.json"))
+
+	code := m.Run()
+	os.RemoveAll(noSystem)
+	os.Exit(code)
+}
+
 func setupTestClient(t *testing.T) *Client {
 	tmp := t.TempDir()
 	t.Setenv("HOME", tmp)
@@ -48,6 +79,60 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestNewClientWith(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "home")
+	t.Setenv("HOME", home)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-mem" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClientWith(&Config{
+		Providers:      map[string]ProviderConfig{"openai": {Accounts: []string{"default"}, BaseURL: server.URL}},
+		Profiles:       map[string]Profile{"test": {Provider: "openai", Account: "default", Model: "gpt-4o"}},
+		DefaultProfile: "test",
+	}, map[string]string{"openai:default": "sk-mem"})
+	if err != nil {
+		t.Fatalf("NewClientWith() error = %v", err)
+	}
+
+	if resp, err := client.Complete(context.Background(), "", Request{Prompt: "hi"}); err != nil || resp.Content != "ok" {
+		t.Fatalf("Complete() = %v, %v; want ok", resp, err)
+	}
+	if err := client.AddProfile("other", Profile{Provider: "openai", Account: "default", Model: "gpt-4o-mini"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := client.AddProviderAccount("anthropic", "default", "sk-ant"); err != nil {
+		t.Fatalf("AddProviderAccount() error = %v", err)
+	}
+	if _, err := client.GetProfile("other"); err != nil {
+		t.Errorf("GetProfile() after AddProfile error = %v", err)
+	}
+
+	if _, err := os.Stat(home); !os.IsNotExist(err) {
+		t.Errorf("in-memory client touched the filesystem: stat(%s) = %v", home, err)
+	}
+}
+
+func TestNewClientWith_Empty(t *testing.T) {
+	client, err := NewClientWith(nil, nil)
+	if err != nil {
+		t.Fatalf("NewClientWith(nil, nil) error = %v", err)
+	}
+	if len(client.ListProfiles()) != 0 {
+		t.Error("empty client should have no profiles")
+	}
+
+	if _, err := NewClientWith(&Config{Retry: &RetryConfig{MaxBackoff: "soon"}}, nil); err == nil {
+		t.Error("NewClientWith() with invalid retry settings should error")
+	}
+}
+
 func TestClient_ProfileManagement(t *testing.T) {
 	client := setupTestClient(t)
 
@@ -224,3 +309,594 @@ func TestClient_UpdateExistingAccount(t *testing.T) {
 		t.Errorf("Accounts count = %d, want 1 (should update, not duplicate)", len(providers[0].Accounts))
 	}
 }
+
+func TestClient_ReadOnly(t *testing.T) {
+	client := setupTestClient(t)
+
+	if err := client.AddProviderAccount("openai", "default", "sk-test"); err != nil {
+		t.Fatalf("AddProviderAccount() error = %v", err)
+	}
+
+	client.config.ReadOnly = true
+
+	profile := Profile{Provider: "openai", Account: "default", Model: "gpt-4o"}
+	if err := client.AddProfile("test", profile); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AddProfile() error = %v, want ErrReadOnly", err)
+	}
+	if _, ok := client.config.Profiles["test"]; ok {
+		t.Error("AddProfile() modified config while read-only")
+	}
+
+	if err := client.RemoveProviderAccount("openai", "default"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("RemoveProviderAccount() error = %v, want ErrReadOnly", err)
+	}
+	if !client.HasProviderAccount("openai", "default") {
+		t.Error("RemoveProviderAccount() modified config while read-only")
+	}
+}
+
+func TestSaveSecrets_ReadOnlyEnv(t *testing.T) {
+	client := setupTestClient(t)
+	t.Setenv("SAGE_READ_ONLY", "1")
+
+	if err := SaveSecrets(map[string]string{"openai:default": "sk"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("SaveSecrets() error = %v, want ErrReadOnly", err)
+	}
+	if err := client.SetDefaultProfile("anything"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("SetDefaultProfile() error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestClient_CheckModel(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProfile("current", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+	client.AddProfile("old", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-opus-latest"})
+	client.AddProfile("remapped", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-opus-latest", RemapDeprecated: true})
+
+	dep, err := client.CheckModel("current", "")
+	if err != nil || dep != nil {
+		t.Errorf("CheckModel(current) = %v, %v; want nil, nil", dep, err)
+	}
+
+	dep, err = client.CheckModel("old", "")
+	if err != nil {
+		t.Fatalf("CheckModel(old) error = %v", err)
+	}
+	if dep == nil || dep.Status != "deprecated" || dep.Successor == "" || dep.Remapped {
+		t.Errorf("CheckModel(old) = %+v, want deprecated with successor, not remapped", dep)
+	}
+
+	// Without remap, the profile's model is sent as-is
+	req, _ := client.buildProviderRequest("old", Request{Prompt: "hi"})
+	if req.Model != "claude-3-opus-latest" {
+		t.Errorf("Model = %q, want original model", req.Model)
+	}
+
+	// With remap, the successor is sent
+	dep, _ = client.CheckModel("remapped", "")
+	if dep == nil || !dep.Remapped {
+		t.Fatalf("CheckModel(remapped) = %+v, want Remapped", dep)
+	}
+	req, _ = client.buildProviderRequest("remapped", Request{Prompt: "hi"})
+	if req.Model != dep.Successor {
+		t.Errorf("Model = %q, want successor %q", req.Model, dep.Successor)
+	}
+
+	// An override is checked instead of the profile's model
+	dep, _ = client.CheckModel("current", "claude-3-opus-latest")
+	if dep == nil || dep.Model != "claude-3-opus-latest" || !dep.Override {
+		t.Errorf("CheckModel(current, override) = %+v, want the deprecated override", dep)
+	}
+	if dep, _ := client.CheckModel("old", "gpt-4o"); dep != nil {
+		t.Errorf("CheckModel(old, gpt-4o) = %+v, want nil for a current override", dep)
+	}
+}
+
+func TestClient_ListModels_CatalogData(t *testing.T) {
+	client := setupTestClient(t)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	// Without a key, Anthropic's static list is returned, so no network
+	// access is needed
+	models, err := client.ListModels("anthropic", "")
+	if err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+
+	for _, m := range models {
+		if m.ContextWindow == 0 {
+			t.Errorf("%s: ContextWindow not filled from catalog", m.ID)
+		}
+		if m.ID == "claude-sonnet-4-20250514" && !m.HasCapability("vision") {
+			t.Errorf("%s: expected vision capability", m.ID)
+		}
+	}
+}
+
+func TestClient_Complete_RequestID(t *testing.T) {
+	client := setupTestClient(t)
+
+	var gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Client-Request-Id")
+		w.Header().Set("x-request-id", "req_provider")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	// Caller-supplied ID is propagated
+	resp, err := client.Complete(context.Background(), "test", Request{Prompt: "hi", RequestID: "my-id"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if gotHeader != "my-id" || resp.RequestID != "my-id" {
+		t.Errorf("header = %q, RequestID = %q; want my-id", gotHeader, resp.RequestID)
+	}
+	if resp.ProviderRequestID != "req_provider" {
+		t.Errorf("ProviderRequestID = %q, want %q", resp.ProviderRequestID, "req_provider")
+	}
+
+	// Otherwise one is generated
+	resp, _ = client.Complete(context.Background(), "test", Request{Prompt: "hi"})
+	if resp.RequestID == "" || gotHeader != resp.RequestID {
+		t.Errorf("generated RequestID = %q, header = %q", resp.RequestID, gotHeader)
+	}
+}
+
+func TestClient_Complete_Timing(t *testing.T) {
+	client := setupTestClient(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		time.Sleep(20 * time.Millisecond)
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o", Retry: &RetryConfig{InitialBackoff: "1ms"}})
+
+	resp, err := client.Complete(context.Background(), "test", Request{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	timing := resp.Timing
+	if timing.Provider < 20*time.Millisecond {
+		t.Errorf("Provider = %v, want at least the server's 20ms", timing.Provider)
+	}
+	// Total includes the failed first attempt
+	if timing.Total < timing.Provider+20*time.Millisecond {
+		t.Errorf("Total = %v, want at least Provider (%v) + 20ms", timing.Total, timing.Provider)
+	}
+	if timing.FirstToken < timing.Provider || timing.FirstToken > timing.Total {
+		t.Errorf("FirstToken = %v, want between %v and %v", timing.FirstToken, timing.Provider, timing.Total)
+	}
+}
+
+func TestClient_CompleteStream_Timing(t *testing.T) {
+	client := setupTestClient(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"Hel\"}}]}\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"lo\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	ch, err := client.CompleteStream(context.Background(), "test", Request{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var last Chunk
+	for chunk := range ch {
+		if chunk.Timing != nil && !chunk.Done {
+			t.Errorf("Timing set on non-final chunk %+v", chunk)
+		}
+		last = chunk
+	}
+	if !last.Done || last.Timing == nil {
+		t.Fatalf("final chunk = %+v, want Done with Timing", last)
+	}
+
+	timing := last.Timing
+	if timing.FirstToken < 20*time.Millisecond {
+		t.Errorf("FirstToken = %v, want at least 20ms", timing.FirstToken)
+	}
+	if timing.Total < timing.FirstToken+20*time.Millisecond {
+		t.Errorf("Total = %v, want at least FirstToken (%v) + 20ms", timing.Total, timing.FirstToken)
+	}
+}
+
+func TestClient_CompleteStream_Resume(t *testing.T) {
+	client := setupTestClient(t)
+
+	var continued []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct{ Role, Content string } `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if len(body.Messages) == 1 {
+			// Send part of the response, then drop the connection mid-chunk
+			w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"Hello\"}}]}\n\n"))
+			w.(http.Flusher).Flush()
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			buf.WriteString("ff\r\ndata: {")
+			buf.Flush()
+			conn.Close()
+			return
+		}
+
+		continued = append(continued, body.Messages[1].Content)
+		w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \", world\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o", Retry: &RetryConfig{InitialBackoff: "1ms"}})
+
+	collect := func(req Request) (string, error) {
+		ch, err := client.CompleteStream(context.Background(), "test", req)
+		if err != nil {
+			return "", err
+		}
+		var content string
+		for chunk := range ch {
+			if chunk.Error != nil {
+				return content, chunk.Error
+			}
+			content += chunk.Content
+		}
+		return content, nil
+	}
+
+	// Without resume, the drop is reported
+	if content, err := collect(Request{Prompt: "hi"}); err == nil || content != "Hello" {
+		t.Errorf("collect() = %q, %v; want %q and an error", content, err, "Hello")
+	}
+
+	content, err := collect(Request{Prompt: "hi", ResumeOnDisconnect: true})
+	if err != nil {
+		t.Fatalf("resumed stream error = %v", err)
+	}
+	if content != "Hello, world" {
+		t.Errorf("content = %q, want %q", content, "Hello, world")
+	}
+	if len(continued) != 1 || continued[0] != "Hello" {
+		t.Errorf("assistant context on resume = %v, want [Hello]", continued)
+	}
+}
+
+func TestClient_BuildProviderRequest_ExtraBody(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.ExtraBody = map[string]any{"user": "team", "store": false}
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{
+		Provider:  "openai",
+		Account:   "default",
+		Model:     "gpt-4o",
+		ExtraBody: map[string]any{"store": true},
+	})
+
+	req, err := client.buildProviderRequest("test", Request{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("buildProviderRequest() error = %v", err)
+	}
+	if req.ExtraBody["user"] != "team" {
+		t.Errorf("ExtraBody[user] = %v, want provider value", req.ExtraBody["user"])
+	}
+	if req.ExtraBody["store"] != true {
+		t.Errorf("ExtraBody[store] = %v, want profile to override provider", req.ExtraBody["store"])
+	}
+
+	req, err = client.buildProviderRequest("test", Request{
+		Prompt:    "hi",
+		ExtraBody: map[string]any{"store": "request", "logit_bias": map[string]any{"50256": -100}},
+	})
+	if err != nil {
+		t.Fatalf("buildProviderRequest() error = %v", err)
+	}
+	if req.ExtraBody["store"] != "request" || req.ExtraBody["user"] != "team" || req.ExtraBody["logit_bias"] == nil {
+		t.Errorf("ExtraBody = %v, want request fields over profile and provider", req.ExtraBody)
+	}
+
+	// Objects set at several levels are merged
+	merged := mergeExtraBody(
+		map[string]any{"options": map[string]any{"num_ctx": 8192, "num_keep": 5}},
+		map[string]any{"options": map[string]any{"num_ctx": 32768}},
+	)
+	if opts := merged["options"].(map[string]any); opts["num_ctx"] != 32768 || opts["num_keep"] != 5 {
+		t.Errorf("options = %v, want num_ctx overridden and num_keep kept", opts)
+	}
+}
+
+func TestClient_BuildProviderRequest_Messages(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	req, err := client.buildProviderRequest("test", Request{
+		Messages: []Message{{Role: "user", Content: "Hi"}, {Role: "assistant", Content: "Hello"}},
+		Prompt:   "Again",
+	})
+	if err != nil {
+		t.Fatalf("buildProviderRequest() error = %v", err)
+	}
+	if len(req.Messages) != 2 || req.Messages[1].Role != "assistant" {
+		t.Errorf("Messages = %+v", req.Messages)
+	}
+
+	_, err = client.buildProviderRequest("test", Request{Messages: []Message{{Role: "bot", Content: "Hi"}}})
+	if err == nil {
+		t.Error("buildProviderRequest() should reject an unknown role")
+	}
+}
+
+func TestClient_BuildProviderRequest_ResponseFormat(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	req, err := client.buildProviderRequest("test", Request{
+		Prompt:         "hi",
+		ResponseFormat: &ResponseFormat{Type: "json_schema", Name: "person", Schema: []byte(`{"type": "object"}`)},
+	})
+	if err != nil {
+		t.Fatalf("buildProviderRequest() error = %v", err)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.Name != "person" {
+		t.Errorf("ResponseFormat = %+v", req.ResponseFormat)
+	}
+
+	for _, f := range []*ResponseFormat{{Type: "xml"}, {Type: "json_schema"}} {
+		if _, err := client.buildProviderRequest("test", Request{Prompt: "hi", ResponseFormat: f}); err == nil {
+			t.Errorf("buildProviderRequest(%+v) should fail", f)
+		}
+	}
+}
+
+func TestClient_BuildProviderRequest_Sampling(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	req, err := client.buildProviderRequest("test", Request{Prompt: "hi", Temperature: Float(0), TopP: Float(0.5)})
+	if err != nil {
+		t.Fatalf("buildProviderRequest() error = %v", err)
+	}
+	if req.Temperature == nil || *req.Temperature != 0 || req.TopP == nil || *req.TopP != 0.5 {
+		t.Errorf("Temperature = %v, TopP = %v", req.Temperature, req.TopP)
+	}
+
+	for _, r := range []Request{{Temperature: Float(2.5)}, {TopP: Float(-0.1)}, {PresencePenalty: Float(3)}} {
+		r.Prompt = "hi"
+		if _, err := client.buildProviderRequest("test", r); err == nil {
+			t.Errorf("buildProviderRequest(%+v) should fail", r)
+		}
+	}
+}
+
+func TestClient_BuildProviderRequest_ReasoningEffort(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "o3-mini", ReasoningEffort: "high"})
+
+	req, err := client.buildProviderRequest("test", Request{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("buildProviderRequest() error = %v", err)
+	}
+	if req.ReasoningEffort != "high" {
+		t.Errorf("ReasoningEffort = %q, want profile default %q", req.ReasoningEffort, "high")
+	}
+
+	req, _ = client.buildProviderRequest("test", Request{Prompt: "hi", ReasoningEffort: "low"})
+	if req.ReasoningEffort != "low" {
+		t.Errorf("ReasoningEffort = %q, want request's %q", req.ReasoningEffort, "low")
+	}
+
+	if _, err := client.buildProviderRequest("test", Request{Prompt: "hi", ReasoningEffort: "max"}); err == nil {
+		t.Error("buildProviderRequest() should reject an unknown reasoning effort")
+	}
+	if err := client.AddProfile("bad", Profile{Provider: "openai", Account: "default", Model: "o3-mini", ReasoningEffort: "max"}); err == nil {
+		t.Error("AddProfile() should reject an unknown reasoning effort")
+	}
+}
+
+func TestClient_BuildProviderRequest_Model(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	req, _ := client.buildProviderRequest("test", Request{Prompt: "hi"})
+	if req.Model != "gpt-4o" {
+		t.Errorf("Model = %q, want profile's %q", req.Model, "gpt-4o")
+	}
+
+	req, _ = client.buildProviderRequest("test", Request{Prompt: "hi", Model: "gpt-4.1-mini"})
+	if req.Model != "gpt-4.1-mini" {
+		t.Errorf("Model = %q, want request's %q", req.Model, "gpt-4.1-mini")
+	}
+}
+
+func TestClient_BuildProviderRequest_Capabilities(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("ollama", "default", "")
+	client.AddProfile("local", Profile{Provider: "ollama", Account: "default", Model: "llama3.2"})
+
+	_, err := client.buildProviderRequest("local", Request{Prompt: "hi", Logprobs: true})
+	if err == nil || err.Error() != "ollama profile local does not support logprobs" {
+		t.Errorf("error = %v, want a capability error naming the profile", err)
+	}
+
+	_, err = client.buildProviderRequest("local", Request{Prompt: "hi", Images: []Image{{URL: "https://example.com/a.png"}}})
+	if err == nil {
+		t.Error("buildProviderRequest() should reject image URLs for ollama")
+	}
+
+	if _, err := client.buildProviderRequest("local", Request{Prompt: "hi", Seed: Int(1)}); err != nil {
+		t.Errorf("buildProviderRequest() error = %v, want seed accepted", err)
+	}
+}
+
+func TestClient_Complete_Logprobs(t *testing.T) {
+	client := setupTestClient(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Yes"},
+			"logprobs": {"content": [{"token": "Yes", "logprob": 0}]}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	resp, err := client.Complete(context.Background(), "test", Request{Prompt: "hi", Logprobs: true})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if len(resp.Logprobs) != 1 || resp.Logprobs[0].Probability() != 1 {
+		t.Errorf("Logprobs = %+v, want one certain token", resp.Logprobs)
+	}
+
+	if _, err := client.Complete(context.Background(), "test", Request{Prompt: "hi", TopLogprobs: 5}); err == nil {
+		t.Error("Complete() should reject top_logprobs without logprobs")
+	}
+}
+
+func TestClient_Complete_ProviderHeaders(t *testing.T) {
+	client := setupTestClient(t)
+
+	var gotTitle string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTitle = r.Header.Get("X-Title")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openrouter", "default", "or-key")
+	cfg := client.config.Providers["openrouter"]
+	cfg.BaseURL = server.URL
+	cfg.Headers = map[string]string{"X-Title": "My App"}
+	client.config.Providers["openrouter"] = cfg
+	client.AddProfile("test", Profile{
+		Provider: "openrouter",
+		Account:  "default",
+		Model:    "anthropic/claude-3.5-sonnet",
+	})
+
+	if _, err := client.Complete(context.Background(), "test", Request{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if gotTitle != "My App" {
+		t.Errorf("X-Title = %q, want %q", gotTitle, "My App")
+	}
+}
+
+// roundTripFunc adapts a function to an http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestClient_SetHTTPClient(t *testing.T) {
+	client := setupTestClient(t)
+
+	var gotTrace string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTrace = r.Header.Get("X-Trace")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-1")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	client.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header.Set("X-Trace", "abc")
+		return http.DefaultTransport.RoundTrip(r)
+	})})
+
+	if _, err := client.Complete(context.Background(), "test", Request{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if gotTrace != "abc" {
+		t.Errorf("X-Trace = %q, want the request sent through the custom client", gotTrace)
+	}
+}
+
+func TestClient_Complete_AnthropicBetas(t *testing.T) {
+	client := setupTestClient(t)
+
+	var gotBeta string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBeta = r.Header.Get("anthropic-beta")
+		w.Write([]byte(`{"content": [{"type": "text", "text": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	cfg := client.config.Providers["anthropic"]
+	cfg.BaseURL = server.URL
+	cfg.Betas = []string{"files-api-2025-04-14"}
+	client.config.Providers["anthropic"] = cfg
+	client.AddProfile("test", Profile{
+		Provider: "anthropic",
+		Account:  "default",
+		Model:    "claude-sonnet-4-20250514",
+		Betas:    []string{"output-128k-2025-02-19", "files-api-2025-04-14"},
+	})
+
+	if _, err := client.Complete(context.Background(), "test", Request{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	want := "files-api-2025-04-14,output-128k-2025-02-19"
+	if gotBeta != want {
+		t.Errorf("anthropic-beta = %q, want %q", gotBeta, want)
+	}
+}
@@ -4,32 +4,124 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // Config represents the sage configuration.
 type Config struct {
+	// Version is the config schema version the file was written for, set
+	// on save. Older files are upgraded when loaded; see configMigrations.
+	Version int `json:"version,omitempty"`
+
 	Providers      map[string]ProviderConfig `json:"providers"`
 	Profiles       map[string]Profile        `json:"profiles"`
 	DefaultProfile string                    `json:"default_profile"`
+
+	// ReadOnly locks the config and secrets against changes, e.g. when they
+	// are mounted from a secret manager. SAGE_READ_ONLY=1 has the same effect.
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// Retry sets the default retry policy for every profile.
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// Age, if set, encrypts secrets.enc for age recipients instead of with
+	// master.key. Change it with ConfigureAge, which re-encrypts the secrets.
+	Age *AgeConfig `json:"age,omitempty"`
+
+	// Render sets whether the CLI renders Markdown in responses for the
+	// terminal when not told with --render. Unset means it doesn't.
+	Render *bool `json:"render,omitempty"`
+
+	// system is the system-wide layer this config was loaded over, if any.
+	system *Config
+
+	// migratedFrom is the schema version of a file upgraded when read.
+	migratedFrom int
+
+	// project is the project config layered over this one, if any, read
+	// from projectPath over belowProject.
+	project      *Config
+	projectPath  string
+	belowProject *Config
 }
 
 // ProviderConfig stores provider-specific settings.
 type ProviderConfig struct {
 	Accounts []string `json:"accounts"`
 	BaseURL  string   `json:"base_url,omitempty"`
+
+	// APIVersion is sent by providers that version their API per request
+	// (Azure OpenAI's api-version). Empty uses the provider's default.
+	APIVersion string `json:"api_version,omitempty"`
+
+	// APIKeyEnv maps account names to the environment variable holding the
+	// account's API key, used when no key is stored for it. Accounts not
+	// listed fall back to the provider's conventional variable (APIKeyEnv).
+	APIKeyEnv map[string]string `json:"api_key_env,omitempty"`
+
+	// APIKeyCmd maps account names to a shell command whose output is the
+	// account's API key, e.g. "op read op://vault/openai/key", used when no
+	// key is stored for it. Takes precedence over APIKeyEnv.
+	APIKeyCmd map[string]string `json:"api_key_cmd,omitempty"`
+
+	// RotateKeys cycles through an account's API keys on every request
+	// instead of only switching keys when one is rate limited.
+	RotateKeys bool `json:"rotate_keys,omitempty"`
+
+	// Betas lists beta features enabled for every request to this provider
+	// (sent as Anthropic's anthropic-beta header).
+	Betas []string `json:"betas,omitempty"`
+
+	// ExtraBody is merged into every JSON request body sent to this provider.
+	ExtraBody map[string]any `json:"extra_body,omitempty"`
+
+	// Headers are sent with every request to this provider, e.g.
+	// OpenRouter's HTTP-Referer and X-Title app attribution.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Platform routes Anthropic requests through a cloud platform
+	// ("bedrock" or "vertex") instead of Anthropic's API. The account key
+	// and base URL are then the platform's, as for its own provider.
+	Platform string `json:"platform,omitempty"`
+
+	// Proxy routes requests to this provider through an HTTP, HTTPS or
+	// SOCKS5 proxy URL, or "direct" to bypass HTTPS_PROXY and friends.
+	// Empty uses the proxy environment variables.
+	Proxy string `json:"proxy,omitempty"`
+
+	// ModelAliases maps custom model names, such as Azure deployments, to
+	// the catalog model they serve, e.g. {"prod-chat": "gpt-4o"}, so the
+	// catalog's parameter quirks and context window apply to them.
+	ModelAliases map[string]string `json:"model_aliases,omitempty"`
 }
 
 // ConfigDir returns the sage config directory path, creating it if needed.
-// Default: ~/.config/sage/
+// SAGE_CONFIG_DIR overrides it. Default: $XDG_CONFIG_HOME/sage/ or
+// ~/.config/sage/ (~/Library/Application Support/sage/ on macOS,
+// %AppData%\sage\ on Windows)
 func ConfigDir() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	dir := os.Getenv("SAGE_CONFIG_DIR")
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", fmt.Errorf("invalid SAGE_CONFIG_DIR: %w", err)
+		}
+		dir = abs
+	} else {
+		base, err := userConfigBase()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "sage")
 	}
 
-	dir := filepath.Join(home, ".config", "sage")
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", fmt.Errorf("cannot create config directory: %w", err)
 	}
@@ -37,23 +129,75 @@ func ConfigDir() (string, error) {
 	return dir, nil
 }
 
-// ConfigPath returns the path to config.json.
+// ConfigPath returns the path to the config file: config.json, or
+// config.yaml, config.yml or config.toml if one of those exists instead.
 func ConfigPath() (string, error) {
 	dir, err := ConfigDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(dir, "config.json"), nil
+	return findConfigFile(dir)
+}
+
+// ErrReadOnly is returned by operations that would modify a locked config.
+var ErrReadOnly = errors.New("sage config is read-only")
+
+// systemConfigPath is the default location of the system-wide base config.
+const systemConfigPath = "/etc/sage/config.json"
+
+// SystemConfigPath returns the path to the system-wide config.
+// Default: /etc/sage/config.json, overridden by SAGE_SYSTEM_CONFIG.
+func SystemConfigPath() string {
+	if path := os.Getenv("SAGE_SYSTEM_CONFIG"); path != "" {
+		return path
+	}
+	return systemConfigPath
 }
 
-// LoadConfig reads config from ~/.config/sage/config.json.
-// Returns an empty config if the file doesn't exist.
+// LoadConfig reads config from ConfigPath, layered over the system-wide
+// config (see SystemConfigPath) and under the project config, if any (see
+// ProjectConfigPath). User settings take precedence over system ones, and
+// project profiles over both. Returns an empty config if no file exists.
 func LoadConfig() (*Config, error) {
 	path, err := ConfigPath()
 	if err != nil {
 		return nil, err
 	}
 
+	system, err := readConfigFile(SystemConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("system config: %w", err)
+	}
+
+	user, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if user.migratedFrom != 0 && !user.ReadOnly && !system.ReadOnly && !readOnlyEnv() {
+		// Best effort: the config is upgraded in memory regardless, and the
+		// file again on the next load or save
+		upgradeConfigFile(path)
+	}
+
+	cfg := mergeSystemConfig(system, user)
+
+	projectPath, err := ProjectConfigPath()
+	if err != nil {
+		return nil, fmt.Errorf("project config: %w", err)
+	}
+	if projectPath != "" {
+		project, err := readProjectConfig(projectPath)
+		if err != nil {
+			return nil, fmt.Errorf("project config %s: %w", projectPath, err)
+		}
+		cfg = cfg.withProject(projectPath, project)
+	}
+	return cfg, nil
+}
+
+// readConfigFile reads a single config file, in JSON, YAML or TOML by its
+// extension. Returns an empty config if the file doesn't exist.
+func readConfigFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -66,41 +210,597 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("cannot read config: %w", err)
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("invalid config JSON: %w", err)
+	cfg, err := decodeConfig(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if cfg, err = migrateConfig(path, data, cfg); err != nil {
+		return nil, err
 	}
 
-	// Initialize maps if nil
-	if cfg.Providers == nil {
-		cfg.Providers = make(map[string]ProviderConfig)
+	cfg.initMaps()
+	return cfg, nil
+}
+
+// initMaps makes c's nil provider and profile maps empty ones.
+func (c *Config) initMaps() {
+	if c.Providers == nil {
+		c.Providers = make(map[string]ProviderConfig)
 	}
-	if cfg.Profiles == nil {
-		cfg.Profiles = make(map[string]Profile)
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]Profile)
+	}
+}
+
+// mergeConfig layers overlay on top of base and returns a new config.
+// Provider accounts are combined; everything else in overlay replaces base.
+func mergeConfig(base, overlay *Config) *Config {
+	cfg := &Config{
+		Providers:      make(map[string]ProviderConfig),
+		Profiles:       make(map[string]Profile),
+		DefaultProfile: base.DefaultProfile,
+		Retry:          base.Retry,
+		Age:            base.Age,
+		Render:         base.Render,
+	}
+
+	for name, p := range base.Providers {
+		p.Accounts = append([]string(nil), p.Accounts...)
+		p.Betas = append([]string(nil), p.Betas...)
+		p.APIKeyEnv = maps.Clone(p.APIKeyEnv)
+		p.APIKeyCmd = maps.Clone(p.APIKeyCmd)
+		p.ModelAliases = maps.Clone(p.ModelAliases)
+		p.ExtraBody = maps.Clone(p.ExtraBody)
+		p.Headers = maps.Clone(p.Headers)
+		cfg.Providers[name] = p
+	}
+	for name, p := range overlay.Providers {
+		p.ExtraBody = maps.Clone(p.ExtraBody)
+		p.Headers = maps.Clone(p.Headers)
+		merged, ok := cfg.Providers[name]
+		if !ok {
+			p.Accounts = append([]string(nil), p.Accounts...)
+			p.Betas = append([]string(nil), p.Betas...)
+			p.APIKeyEnv = maps.Clone(p.APIKeyEnv)
+			p.APIKeyCmd = maps.Clone(p.APIKeyCmd)
+			p.ModelAliases = maps.Clone(p.ModelAliases)
+			cfg.Providers[name] = p
+			continue
+		}
+		for _, a := range p.Accounts {
+			if !containsString(merged.Accounts, a) {
+				merged.Accounts = append(merged.Accounts, a)
+			}
+		}
+		if p.BaseURL != "" {
+			merged.BaseURL = p.BaseURL
+		}
+		if p.APIVersion != "" {
+			merged.APIVersion = p.APIVersion
+		}
+		merged.RotateKeys = merged.RotateKeys || p.RotateKeys
+		for account, env := range p.APIKeyEnv {
+			merged.APIKeyEnv = setAccountValue(merged.APIKeyEnv, account, env)
+		}
+		for account, command := range p.APIKeyCmd {
+			merged.APIKeyCmd = setAccountValue(merged.APIKeyCmd, account, command)
+		}
+		for alias, model := range p.ModelAliases {
+			if merged.ModelAliases == nil {
+				merged.ModelAliases = make(map[string]string)
+			}
+			merged.ModelAliases[alias] = model
+		}
+		if p.ExtraBody != nil {
+			merged.ExtraBody = p.ExtraBody
+		}
+		if p.Headers != nil {
+			merged.Headers = p.Headers
+		}
+		if p.Platform != "" {
+			merged.Platform = p.Platform
+		}
+		if p.Proxy != "" {
+			merged.Proxy = p.Proxy
+		}
+		for _, b := range p.Betas {
+			if !containsString(merged.Betas, b) {
+				merged.Betas = append(merged.Betas, b)
+			}
+		}
+		cfg.Providers[name] = merged
+	}
+
+	for name, p := range base.Profiles {
+		cfg.Profiles[name] = cloneProfile(p)
+	}
+	for name, p := range overlay.Profiles {
+		cfg.Profiles[name] = cloneProfile(p)
+	}
+
+	if overlay.DefaultProfile != "" {
+		cfg.DefaultProfile = overlay.DefaultProfile
+	}
+	if overlay.Retry != nil {
+		cfg.Retry = overlay.Retry
+	}
+	if overlay.Age != nil {
+		cfg.Age = overlay.Age
+	}
+	if overlay.Render != nil {
+		cfg.Render = overlay.Render
+	}
+
+	// Either layer can lock the config
+	cfg.ReadOnly = base.ReadOnly || overlay.ReadOnly
+
+	return cfg
+}
+
+// cloneProfile returns p with its own copies of its slices and maps, so
+// changing one layer of a merged config doesn't change another.
+func cloneProfile(p Profile) Profile {
+	p.Betas = append([]string(nil), p.Betas...)
+	p.ExtraBody = maps.Clone(p.ExtraBody)
+	return p
+}
+
+// mergeSystemConfig layers the user config over the system-wide one. Unlike
+// project profiles, which replace a profile whole, a user profile named
+// like a system profile overrides only the fields it sets.
+func mergeSystemConfig(system, user *Config) *Config {
+	cfg := mergeConfig(system, user)
+	for name, p := range user.Profiles {
+		if base, ok := system.Profiles[name]; ok {
+			cfg.Profiles[name] = mergeProfile(base, p)
+		}
+	}
+	cfg.system = system
+	return cfg
+}
+
+// mergeProfile layers the fields overlay sets over base. Betas are
+// combined; system and system_file are taken together, so that setting
+// either replaces the base's system prompt.
+func mergeProfile(base, overlay Profile) Profile {
+	p := cloneProfile(base)
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&p.Name, overlay.Name},
+		{&p.Provider, overlay.Provider},
+		{&p.Account, overlay.Account},
+		{&p.Model, overlay.Model},
+		{&p.ReasoningEffort, overlay.ReasoningEffort},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+	if overlay.System != "" || overlay.SystemFile != "" {
+		p.System, p.SystemFile = overlay.System, overlay.SystemFile
+	}
+	p.RemapDeprecated = p.RemapDeprecated || overlay.RemapDeprecated
+	for _, b := range overlay.Betas {
+		if !containsString(p.Betas, b) {
+			p.Betas = append(p.Betas, b)
+		}
+	}
+	if overlay.ExtraBody != nil {
+		p.ExtraBody = maps.Clone(overlay.ExtraBody)
+	}
+	if overlay.Retry != nil {
+		p.Retry = overlay.Retry
+	}
+	return p
+}
+
+// userLayer returns the parts of c that differ from the system config,
+// without the project config, so that saving doesn't copy system-wide or
+// project settings into the user file. Providers and profiles the system
+// config sets keep only the fields the user changed, so later changes to
+// the system config still reach the rest.
+func (c *Config) userLayer() *Config {
+	c = c.withoutProject()
+	if c.system == nil {
+		return c
+	}
+
+	user := &Config{
+		Providers: make(map[string]ProviderConfig),
+		Profiles:  make(map[string]Profile),
+	}
+
+	for name, p := range c.Providers {
+		base, ok := c.system.Providers[name]
+		switch {
+		case !ok:
+			user.Providers[name] = p
+		case !reflect.DeepEqual(base, p):
+			user.Providers[name] = providerChanges(base, p)
+		}
+	}
+	for name, p := range c.Profiles {
+		base, ok := c.system.Profiles[name]
+		switch {
+		case !ok:
+			user.Profiles[name] = p
+		case !reflect.DeepEqual(base, p):
+			user.Profiles[name] = profileChanges(base, p)
+		}
+	}
+	if c.DefaultProfile != c.system.DefaultProfile {
+		user.DefaultProfile = c.DefaultProfile
+	}
+	if !reflect.DeepEqual(c.Retry, c.system.Retry) {
+		user.Retry = c.Retry
+	}
+	if !reflect.DeepEqual(c.Age, c.system.Age) {
+		user.Age = c.Age
+	}
+	if !reflect.DeepEqual(c.Render, c.system.Render) {
+		user.Render = c.Render
+	}
+	if c.ReadOnly && !c.system.ReadOnly {
+		user.ReadOnly = true
+	}
+
+	return user
+}
+
+// providerChanges returns the settings of p that differ from base, such
+// that merging them over base (see mergeConfig) gives p back.
+func providerChanges(base, p ProviderConfig) ProviderConfig {
+	changes := ProviderConfig{
+		Accounts:     []string{},
+		RotateKeys:   p.RotateKeys && !base.RotateKeys,
+		APIKeyEnv:    changedValues(base.APIKeyEnv, p.APIKeyEnv),
+		APIKeyCmd:    changedValues(base.APIKeyCmd, p.APIKeyCmd),
+		ModelAliases: changedValues(base.ModelAliases, p.ModelAliases),
+	}
+	for _, a := range p.Accounts {
+		if !containsString(base.Accounts, a) {
+			changes.Accounts = append(changes.Accounts, a)
+		}
+	}
+	for _, b := range p.Betas {
+		if !containsString(base.Betas, b) {
+			changes.Betas = append(changes.Betas, b)
+		}
+	}
+	if p.BaseURL != base.BaseURL {
+		changes.BaseURL = p.BaseURL
+	}
+	if p.APIVersion != base.APIVersion {
+		changes.APIVersion = p.APIVersion
+	}
+	if p.Platform != base.Platform {
+		changes.Platform = p.Platform
+	}
+	if p.Proxy != base.Proxy {
+		changes.Proxy = p.Proxy
+	}
+	if !reflect.DeepEqual(p.ExtraBody, base.ExtraBody) {
+		changes.ExtraBody = p.ExtraBody
+	}
+	if !reflect.DeepEqual(p.Headers, base.Headers) {
+		changes.Headers = p.Headers
+	}
+	return changes
+}
+
+// profileChanges returns the fields of p that differ from base, such that
+// merging them over base (see mergeProfile) gives p back.
+func profileChanges(base, p Profile) Profile {
+	changes := Profile{
+		RemapDeprecated: p.RemapDeprecated && !base.RemapDeprecated,
+	}
+	for _, f := range []struct {
+		dst         *string
+		base, value string
+	}{
+		{&changes.Name, base.Name, p.Name},
+		{&changes.Provider, base.Provider, p.Provider},
+		{&changes.Account, base.Account, p.Account},
+		{&changes.Model, base.Model, p.Model},
+		{&changes.ReasoningEffort, base.ReasoningEffort, p.ReasoningEffort},
+	} {
+		if f.value != f.base {
+			*f.dst = f.value
+		}
+	}
+	if p.System != base.System || p.SystemFile != base.SystemFile {
+		changes.System, changes.SystemFile = p.System, p.SystemFile
 	}
+	for _, b := range p.Betas {
+		if !containsString(base.Betas, b) {
+			changes.Betas = append(changes.Betas, b)
+		}
+	}
+	if !reflect.DeepEqual(p.ExtraBody, base.ExtraBody) {
+		changes.ExtraBody = p.ExtraBody
+	}
+	if !reflect.DeepEqual(p.Retry, base.Retry) {
+		changes.Retry = p.Retry
+	}
+	return changes
+}
 
-	return &cfg, nil
+// changedValues returns the entries of m that differ from base, or nil if
+// there are none.
+func changedValues(base, m map[string]string) map[string]string {
+	var changed map[string]string
+	for k, v := range m {
+		if old, ok := base[k]; !ok || old != v {
+			if changed == nil {
+				changed = make(map[string]string)
+			}
+			changed[k] = v
+		}
+	}
+	return changed
 }
 
-// Save writes the config to ~/.config/sage/config.json.
+// systemProvider returns the provider's settings in the system config.
+func (c *Config) systemProvider(name string) (ProviderConfig, bool) {
+	if c.system == nil {
+		return ProviderConfig{}, false
+	}
+	p, ok := c.system.Providers[name]
+	return p, ok
+}
+
+// systemProfile returns the profile's settings in the system config.
+func (c *Config) systemProfile(name string) (Profile, bool) {
+	if c.system == nil {
+		return Profile{}, false
+	}
+	p, ok := c.system.Profiles[name]
+	return p, ok
+}
+
+// checkSystemRemovals refuses a config that lacks a provider, account or
+// profile the system config sets. The user file can't remove them, so
+// they would come back on the next load.
+func (c *Config) checkSystemRemovals() error {
+	if c.system == nil {
+		return nil
+	}
+	for name, base := range c.system.Providers {
+		p, ok := c.Providers[name]
+		if !ok {
+			return fmt.Errorf("provider %s is set by the system config %s and can't be removed", name, SystemConfigPath())
+		}
+		for _, a := range base.Accounts {
+			if !containsString(p.Accounts, a) {
+				return fmt.Errorf("account %s:%s is set by the system config %s and can't be removed", name, a, SystemConfigPath())
+			}
+		}
+	}
+	for name := range c.system.Profiles {
+		if _, ok := c.Profiles[name]; !ok {
+			return fmt.Errorf("profile %s is set by the system config %s and can't be removed", name, SystemConfigPath())
+		}
+	}
+	return nil
+}
+
+// Save writes the config to ConfigPath, replacing the file's contents but
+// keeping the comments of a YAML or TOML file. Settings inherited unchanged
+// from the system config are not written. To change the config safely while
+// other sage processes may too, use UpdateConfig.
 func (c *Config) Save() error {
+	if c.IsReadOnly() {
+		return fmt.Errorf("%w: cannot save config", ErrReadOnly)
+	}
+	if err := c.checkSystemRemovals(); err != nil {
+		return err
+	}
+
 	path, err := ConfigPath()
 	if err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	// YAML and TOML files keep their comments, read from the old file
+	old, _ := os.ReadFile(path)
+	user := c.userLayer()
+	user.Version = currentConfigVersion()
+	data, err := encodeConfig(path, user, old)
 	if err != nil {
 		return fmt.Errorf("cannot marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("%w: cannot write %s", ErrReadOnly, path)
+		}
 		return fmt.Errorf("cannot write config: %w", err)
 	}
 
 	return nil
 }
 
+// UpdateConfig loads the config, lets update change it, and saves the
+// result, holding a lock throughout so concurrent sage processes can't lose
+// each other's changes. Nothing is saved if update returns an error.
+func UpdateConfig(update func(*Config) error) error {
+	_, err := updateConfig(update)
+	return err
+}
+
+// updateConfig is UpdateConfig, returning the saved config.
+func updateConfig(update func(*Config) error) (*Config, error) {
+	if readOnlyEnv() {
+		return nil, fmt.Errorf("%w: cannot save config", ErrReadOnly)
+	}
+
+	unlock, err := lockConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := update(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Save(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ErrConfigConflict is returned when a client saves a change to a setting
+// that another process has changed differently since the client loaded it.
+var ErrConfigConflict = errors.New("config changed by another process")
+
+// applyChanges applies the changes made from before to after onto c, so that
+// a client's edits can be saved over changes other processes made to the
+// file since the client loaded it. Accounts are merged individually; other
+// provider settings and profiles are replaced whole. Nothing is applied if
+// c changed any of the same settings differently since before.
+func (c *Config) applyChanges(before, after *Config) error {
+	if conflicts := c.conflicts(before, after); len(conflicts) > 0 {
+		return fmt.Errorf("%w: %s", ErrConfigConflict, strings.Join(conflicts, ", "))
+	}
+
+	for name, p := range after.Providers {
+		old, existed := before.Providers[name]
+		if existed && reflect.DeepEqual(old, p) {
+			continue
+		}
+		accounts := c.Providers[name].Accounts
+		for _, a := range old.Accounts {
+			if !containsString(p.Accounts, a) {
+				accounts = removeString(accounts, a)
+			}
+		}
+		for _, a := range p.Accounts {
+			if !containsString(accounts, a) {
+				accounts = append(accounts, a)
+			}
+		}
+		p.Accounts = accounts
+		c.Providers[name] = p
+	}
+	for name := range before.Providers {
+		if _, ok := after.Providers[name]; !ok {
+			delete(c.Providers, name)
+		}
+	}
+
+	for name, p := range after.Profiles {
+		if old, ok := before.Profiles[name]; !ok || !reflect.DeepEqual(old, p) {
+			c.Profiles[name] = p
+		}
+	}
+	for name := range before.Profiles {
+		if _, ok := after.Profiles[name]; !ok {
+			delete(c.Profiles, name)
+		}
+	}
+
+	if after.DefaultProfile != before.DefaultProfile {
+		c.DefaultProfile = after.DefaultProfile
+	}
+	if !reflect.DeepEqual(after.Retry, before.Retry) {
+		c.Retry = after.Retry
+	}
+	if !reflect.DeepEqual(after.Age, before.Age) {
+		c.Age = after.Age
+	}
+	if !reflect.DeepEqual(after.Render, before.Render) {
+		c.Render = after.Render
+	}
+	return nil
+}
+
+// conflicts returns the settings changed from before to after that c, the
+// config as saved now, has changed to something else since before.
+func (c *Config) conflicts(before, after *Config) []string {
+	var conflicts []string
+	conflict := func(name string, before, after, current any) {
+		if !reflect.DeepEqual(before, after) && !reflect.DeepEqual(current, before) && !reflect.DeepEqual(current, after) {
+			conflicts = append(conflicts, name)
+		}
+	}
+
+	// Providers' accounts are merged, so only their other settings conflict
+	settings := func(cfg *Config, name string) any {
+		p, ok := cfg.Providers[name]
+		if !ok {
+			return nil
+		}
+		p.Accounts = nil
+		return p
+	}
+	for _, name := range unionKeys(before.Providers, after.Providers) {
+		conflict("provider "+name, settings(before, name), settings(after, name), settings(c, name))
+	}
+
+	profile := func(cfg *Config, name string) any {
+		if p, ok := cfg.Profiles[name]; ok {
+			return p
+		}
+		return nil
+	}
+	for _, name := range unionKeys(before.Profiles, after.Profiles) {
+		conflict("profile "+name, profile(before, name), profile(after, name), profile(c, name))
+	}
+
+	conflict("default_profile", before.DefaultProfile, after.DefaultProfile, c.DefaultProfile)
+	conflict("retry", before.Retry, after.Retry, c.Retry)
+	conflict("age", before.Age, after.Age, c.Age)
+	conflict("render", before.Render, after.Render, c.Render)
+	return conflicts
+}
+
+// unionKeys returns the keys of a and b, sorted.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// clone returns a deep copy of c's settings, for applyChanges.
+func (c *Config) clone() *Config {
+	data, _ := json.Marshal(c)
+	var cfg Config
+	json.Unmarshal(data, &cfg)
+	if cfg.Providers == nil {
+		cfg.Providers = make(map[string]ProviderConfig)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]Profile)
+	}
+	return &cfg
+}
+
+// IsReadOnly reports whether the config is locked against changes,
+// either by the read_only setting or the SAGE_READ_ONLY environment variable.
+func (c *Config) IsReadOnly() bool {
+	return c.ReadOnly || readOnlyEnv()
+}
+
+// readOnlyEnv reports whether SAGE_READ_ONLY is set to a true value.
+func readOnlyEnv() bool {
+	v, _ := strconv.ParseBool(os.Getenv("SAGE_READ_ONLY"))
+	return v
+}
+
 // GetProfile returns a profile by name, or the default profile if name is empty.
 func (c *Config) GetProfile(name string) (*Profile, error) {
 	if name == "" {
@@ -127,3 +827,74 @@ func (c *Config) GetProvider(name string) (*ProviderConfig, error) {
 	}
 	return &provider, nil
 }
+
+// SystemPrompt returns the profile's system prompt.
+// If SystemFile is set, the file is read on each call so edits take effect
+// immediately; otherwise the inline System string is returned.
+func (p *Profile) SystemPrompt() (string, error) {
+	if p.SystemFile == "" {
+		return p.System, nil
+	}
+
+	path, err := resolveConfigPath(p.SystemFile)
+	if err != nil {
+		return "", err
+	}
+	// Read the project's file by its real path, so a symlink swapped after
+	// the check can't redirect the read
+	if p.projectDir != "" {
+		if path, err = containedFile(p.projectDir, path); err != nil {
+			return "", fmt.Errorf("cannot read system prompt file: %w", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read system prompt file: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolveConfigPath expands a leading ~/ and resolves relative paths
+// against the config directory.
+func resolveConfigPath(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot determine home directory: %w", err)
+		}
+		return filepath.Join(home, path[2:]), nil
+	}
+
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, path), nil
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// removeString returns list without s.
+func removeString(list []string, s string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
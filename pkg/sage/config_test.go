@@ -1,8 +1,11 @@
 package sage
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -99,6 +102,9 @@ func TestConfig_SaveAndLoad(t *testing.T) {
 }
 
 func TestConfigDir_CreatesDirectory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("default config directory differs by platform")
+	}
 	tmp := t.TempDir()
 	t.Setenv("HOME", tmp)
 
@@ -122,6 +128,65 @@ func TestConfigDir_CreatesDirectory(t *testing.T) {
 	}
 }
 
+func TestConfigDir_Overrides(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	if runtime.GOOS != "windows" {
+		xdg := filepath.Join(tmp, "xdg")
+		t.Setenv("XDG_CONFIG_HOME", xdg)
+		if dir, err := ConfigDir(); err != nil || dir != filepath.Join(xdg, "sage") {
+			t.Errorf("ConfigDir() with XDG_CONFIG_HOME = %q, %v; want %q", dir, err, filepath.Join(xdg, "sage"))
+		}
+	}
+
+	// SAGE_CONFIG_DIR names the directory itself, and wins over XDG
+	custom := filepath.Join(tmp, "ci-sage")
+	t.Setenv("SAGE_CONFIG_DIR", custom)
+	dir, err := ConfigDir()
+	if err != nil || dir != custom {
+		t.Fatalf("ConfigDir() with SAGE_CONFIG_DIR = %q, %v; want %q", dir, err, custom)
+	}
+	if _, err := os.Stat(custom); err != nil {
+		t.Errorf("SAGE_CONFIG_DIR not created: %v", err)
+	}
+}
+
+func TestConfig_SaveYAML(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	dir, _ := ConfigDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte("# My profiles\nprofiles:\n  local:\n    provider: ollama\n    model: llama3 # fast\n"), 0644)
+
+	if got, _ := ConfigPath(); got != path {
+		t.Errorf("ConfigPath() = %q, want %q", got, path)
+	}
+	err := UpdateConfig(func(cfg *Config) error {
+		cfg.DefaultProfile = "local"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateConfig() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := "# My profiles\nprofiles:\n  local:\n    provider: ollama\n    model: llama3 # fast\nversion: 1\ndefault_profile: local\n"
+	if string(data) != want {
+		t.Errorf("config.yaml = %q, want %q", data, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.json")); err == nil {
+		t.Error("config.json created beside config.yaml")
+	}
+
+	// Two config files are ambiguous
+	os.WriteFile(filepath.Join(dir, "config.toml"), nil, 0644)
+	if _, err := LoadConfig(); err == nil {
+		t.Error("LoadConfig() with config.yaml and config.toml should error")
+	}
+}
+
 func TestConfig_GetProfile(t *testing.T) {
 	cfg := &Config{
 		Profiles: map[string]Profile{
@@ -173,3 +238,229 @@ func TestConfig_GetProfile_NoDefault(t *testing.T) {
 		t.Error("GetProfile('') with no default should return error")
 	}
 }
+
+func TestProfile_SystemPrompt(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	// Inline prompt
+	p := &Profile{System: "Be concise."}
+	got, err := p.SystemPrompt()
+	if err != nil {
+		t.Fatalf("SystemPrompt() error = %v", err)
+	}
+	if got != "Be concise." {
+		t.Errorf("SystemPrompt() = %q, want %q", got, "Be concise.")
+	}
+
+	// File reference, relative to config dir
+	dir, _ := ConfigDir()
+	promptPath := filepath.Join(dir, "prompts", "assistant.md")
+	os.MkdirAll(filepath.Dir(promptPath), 0755)
+	os.WriteFile(promptPath, []byte("You are helpful.\n"), 0644)
+
+	p = &Profile{System: "ignored", SystemFile: "prompts/assistant.md"}
+	got, err = p.SystemPrompt()
+	if err != nil {
+		t.Fatalf("SystemPrompt() error = %v", err)
+	}
+	if got != "You are helpful." {
+		t.Errorf("SystemPrompt() = %q, want %q", got, "You are helpful.")
+	}
+
+	// Edits are picked up on the next call
+	os.WriteFile(promptPath, []byte("You are terse."), 0644)
+	got, _ = p.SystemPrompt()
+	if got != "You are terse." {
+		t.Errorf("SystemPrompt() after edit = %q, want %q", got, "You are terse.")
+	}
+
+	// Missing file
+	p = &Profile{SystemFile: "prompts/missing.md"}
+	if _, err := p.SystemPrompt(); err == nil {
+		t.Error("SystemPrompt() with missing file should error")
+	}
+}
+
+func TestLoadConfig_SystemLayer(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	systemPath := filepath.Join(tmp, "system.json")
+	t.Setenv("SAGE_SYSTEM_CONFIG", systemPath)
+	os.WriteFile(systemPath, []byte(`{
+		"providers": {"ollama": {"accounts": ["shared"], "base_url": "http://gpu-box:11434"}},
+		"profiles": {"team": {"provider": "ollama", "account": "shared", "model": "llama3.2"}},
+		"default_profile": "team"
+	}`), 0644)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if cfg.DefaultProfile != "team" {
+		t.Errorf("DefaultProfile = %q, want %q", cfg.DefaultProfile, "team")
+	}
+	if cfg.Providers["ollama"].BaseURL != "http://gpu-box:11434" {
+		t.Errorf("ollama BaseURL = %q, want system value", cfg.Providers["ollama"].BaseURL)
+	}
+
+	// User changes are layered on top
+	ollama := cfg.Providers["ollama"]
+	ollama.Accounts = append(ollama.Accounts, "mine")
+	cfg.Providers["ollama"] = ollama
+	cfg.Profiles["local"] = Profile{Provider: "ollama", Account: "mine", Model: "qwen2.5"}
+	cfg.DefaultProfile = "local"
+
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// System-only settings must not be copied into the user file
+	path, _ := ConfigPath()
+	data, _ := os.ReadFile(path)
+	var user Config
+	if err := json.Unmarshal(data, &user); err != nil {
+		t.Fatalf("user config invalid: %v", err)
+	}
+	if _, ok := user.Profiles["team"]; ok {
+		t.Error("system profile was written to user config")
+	}
+
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if loaded.DefaultProfile != "local" {
+		t.Errorf("DefaultProfile = %q, want %q", loaded.DefaultProfile, "local")
+	}
+	if len(loaded.Profiles) != 2 {
+		t.Errorf("Profiles count = %d, want 2", len(loaded.Profiles))
+	}
+	if got := loaded.Providers["ollama"].Accounts; len(got) != 2 {
+		t.Errorf("ollama accounts = %v, want [shared mine]", got)
+	}
+}
+
+func TestLoadConfig_SystemLayerChanges(t *testing.T) {
+	setupTestClient(t)
+	tmp := t.TempDir()
+
+	systemPath := filepath.Join(tmp, "system.json")
+	t.Setenv("SAGE_SYSTEM_CONFIG", systemPath)
+	writeSystem := func(baseURL string) {
+		os.WriteFile(systemPath, []byte(`{
+			"providers": {"ollama": {"accounts": ["shared"], "base_url": "`+baseURL+`", "headers": {"X-Team": "ml"}}},
+			"profiles": {"team": {"provider": "ollama", "account": "shared", "model": "llama3.2", "system": "Be brief."}}
+		}`), 0644)
+	}
+	writeSystem("http://gpu-box:11434")
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	// Merged maps are copies: changing them leaves the system layer alone
+	client.config.Providers["ollama"].Headers["X-Team"] = "changed"
+	if got := client.config.system.Providers["ollama"].Headers["X-Team"]; got != "ml" {
+		t.Errorf("system header = %q after changing the merged config, want ml", got)
+	}
+	client.config.Providers["ollama"].Headers["X-Team"] = "ml"
+
+	// Changing one field saves only that field
+	if err := client.SetConfigValue("profiles.team.model", "qwen2.5"); err != nil {
+		t.Fatalf("SetConfigValue() error = %v", err)
+	}
+	if err := client.SetConfigValue("providers.ollama.rotate_keys", "true"); err != nil {
+		t.Fatalf("SetConfigValue() error = %v", err)
+	}
+	path, _ := ConfigPath()
+	data, _ := os.ReadFile(path)
+	var user Config
+	json.Unmarshal(data, &user)
+	if p := user.Profiles["team"]; p.Model != "qwen2.5" || p.Provider != "" || p.System != "" {
+		t.Errorf("saved team profile = %+v, want only the model", p)
+	}
+	if p := user.Providers["ollama"]; !p.RotateKeys || p.BaseURL != "" || p.Headers != nil || len(p.Accounts) != 0 {
+		t.Errorf("saved ollama provider = %+v, want only rotate_keys", p)
+	}
+
+	// Later system changes still reach the fields the user didn't change
+	writeSystem("http://new-box:11434")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if p := cfg.Providers["ollama"]; p.BaseURL != "http://new-box:11434" || !p.RotateKeys {
+		t.Errorf("ollama = %+v, want the new system base_url and the user's rotate_keys", p)
+	}
+	if p := cfg.Profiles["team"]; p.Model != "qwen2.5" || p.Provider != "ollama" || p.System != "Be brief." {
+		t.Errorf("team = %+v, want the user's model over the system profile", p)
+	}
+
+	// System entries can't be removed from the user file
+	if err := client.RemoveProfile("team"); err == nil {
+		t.Error("RemoveProfile() of a system profile should error")
+	}
+	if err := client.RemoveProviderAccount("ollama", "shared"); err == nil {
+		t.Error("RemoveProviderAccount() of a system account should error")
+	}
+	delete(cfg.Profiles, "team")
+	if err := cfg.Save(); err == nil {
+		t.Error("Save() without a system profile should error")
+	}
+}
+
+func TestClient_RenderMarkdown(t *testing.T) {
+	systemPath := filepath.Join(t.TempDir(), "system.json")
+	t.Setenv("SAGE_SYSTEM_CONFIG", systemPath)
+	os.WriteFile(systemPath, []byte(`{"render": true}`), 0644)
+
+	client := setupTestClient(t)
+	if !client.RenderMarkdown() {
+		t.Error("RenderMarkdown() = false, want the system config's true")
+	}
+
+	// The user can turn off what the system config turns on
+	if err := client.SetConfigValue("render", "false"); err != nil {
+		t.Fatalf("SetConfigValue(render, false) error = %v", err)
+	}
+	reloaded, _ := NewClient()
+	if reloaded.RenderMarkdown() {
+		t.Error("RenderMarkdown() = true after setting false")
+	}
+}
+
+func TestClient_SaveConfig_Conflict(t *testing.T) {
+	setup := setupTestClient(t)
+	setup.AddProfile("fast", Profile{Provider: "openai", Account: "default", Model: "gpt-4o-mini"})
+	setup.AddProfile("big", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	// Two processes load the same config, then both change profile fast
+	a, _ := NewClient()
+	b, _ := NewClient()
+	if err := b.AddProfile("fast", Profile{Provider: "openai", Account: "default", Model: "gpt-4.1-mini"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	err := a.AddProfile("fast", Profile{Provider: "openai", Account: "default", Model: "gpt-4.1-nano"})
+	if !errors.Is(err, ErrConfigConflict) {
+		t.Fatalf("AddProfile() over another process's change error = %v, want ErrConfigConflict", err)
+	}
+	if p, _ := a.GetProfile("fast"); p.Model != "gpt-4.1-mini" {
+		t.Errorf("after conflict, client has model %s, want the saved gpt-4.1-mini", p.Model)
+	}
+
+	// Having reloaded, the client can retry, and change other settings
+	if err := a.AddProfile("fast", Profile{Provider: "openai", Account: "default", Model: "gpt-4.1-nano"}); err != nil {
+		t.Errorf("AddProfile() retry error = %v", err)
+	}
+	if err := b.AddProfile("big", Profile{Provider: "openai", Account: "default", Model: "gpt-4.1"}); err != nil {
+		t.Errorf("AddProfile() of another profile error = %v", err)
+	}
+	cfg, _ := LoadConfig()
+	if cfg.Profiles["fast"].Model != "gpt-4.1-nano" || cfg.Profiles["big"].Model != "gpt-4.1" {
+		t.Errorf("profiles = %+v, want both clients' last changes", cfg.Profiles)
+	}
+}
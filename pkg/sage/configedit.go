@@ -0,0 +1,327 @@
+package sage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ShowConfig formats the config in effect, with the system, user and
+// project configs layered, as "json", "yaml" or "toml". API keys are kept in
+// the secrets file and never appear; proxy passwords and the values of
+// credential headers such as Authorization are redacted too.
+func (c *Client) ShowConfig(format string) ([]byte, error) {
+	switch format {
+	case "json", "yaml", "toml":
+	default:
+		return nil, fmt.Errorf("unknown config format %q (want json, yaml or toml)", format)
+	}
+
+	cfg := c.config.clone()
+	for name, p := range cfg.Providers {
+		p.Proxy = redactProxy(p.Proxy)
+		for header, value := range p.Headers {
+			if isCredentialHeader(header) {
+				p.Headers[header] = MaskKey(value)
+			}
+		}
+		cfg.Providers[name] = p
+	}
+	return encodeConfig("config."+format, cfg, nil)
+}
+
+// isCredentialHeader reports whether a header's value is likely a
+// credential, going by its name.
+func isCredentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"auth", "key", "token", "secret", "cookie"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckConfigFile checks data as new contents for the user config file, in
+// the format of ConfigPath: it returns an error if data doesn't parse, and
+// otherwise the issues ValidateConfig finds with it in place of the current
+// file.
+func (c *Client) CheckConfigFile(data []byte) ([]ConfigIssue, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	user, err := decodeConfig(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if user, err = migrateConfig(path, data, user); err != nil {
+		return nil, err
+	}
+	user.initMaps()
+
+	system := c.config.system
+	if system == nil {
+		system = &Config{}
+	}
+	cfg := mergeSystemConfig(system, user)
+	if c.config.project != nil {
+		cfg = cfg.withProject(c.config.projectPath, c.config.project)
+	}
+
+	check := &Client{config: cfg, secrets: c.secrets}
+	return check.ValidateConfig(), nil
+}
+
+// ReplaceConfigFile replaces the contents of the user config file, which
+// the caller read as old, with data, e.g. after the user edited it. It
+// fails, leaving the file as it is, if the file has changed since it was
+// read, or if CheckConfigFile finds data unreadable or finds errors in it.
+func (c *Client) ReplaceConfigFile(old, data []byte) error {
+	if c.inMemory {
+		return errors.New("client has no config file: created with NewClientWith")
+	}
+	if c.config.IsReadOnly() {
+		return fmt.Errorf("%w: cannot save config", ErrReadOnly)
+	}
+
+	issues, err := c.CheckConfigFile(data)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		if issue.Severity == IssueError {
+			return fmt.Errorf("config not saved: %v", issue)
+		}
+	}
+
+	unlock, err := lockConfigDir()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path, err := ConfigPath()
+	if err != nil {
+		return err
+	}
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot read config: %w", err)
+	}
+	if !bytes.Equal(current, old) {
+		return fmt.Errorf("config not saved: %s changed since it was read", path)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("%w: cannot write %s", ErrReadOnly, path)
+		}
+		return fmt.Errorf("cannot write config: %w", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	c.config = cfg
+	c.savedConfig = cfg.clone()
+	return nil
+}
+
+// settableConfigKeys are the top-level config keys SetConfigValue changes.
+// Age recipients are changed with ConfigureAge, which re-encrypts the
+// secrets for them.
+var settableConfigKeys = []string{"providers", "profiles", "default_profile", "retry", "render"}
+
+// GetConfigValue returns the setting at a dotted path of config keys, such
+// as "profiles.fast.model" or "providers.openai", in the config in effect,
+// as decoded from JSON: a string, bool, json.Number, []any or
+// map[string]any.
+func (c *Client) GetConfigValue(path string) (any, error) {
+	keys := strings.Split(path, ".")
+	if _, err := configPathType(keys); err != nil {
+		return nil, err
+	}
+	settings, err := configSettings(c.config)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any = settings
+	for _, key := range keys {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s is not set", path)
+		}
+		if value, ok = m[key]; !ok || value == nil {
+			return nil, fmt.Errorf("%s is not set", path)
+		}
+	}
+	return value, nil
+}
+
+// SetConfigValue changes the setting at a dotted path of config keys, such
+// as "default_profile" or "profiles.fast.model", and saves it. value is
+// read as the setting's type: text for strings, true or false, a number,
+// or JSON for lists and objects. The change is refused if it would add
+// errors to the config (see ValidateConfig).
+func (c *Client) SetConfigValue(path, value string) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+	keys := strings.Split(path, ".")
+	if !containsString(settableConfigKeys, keys[0]) {
+		return fmt.Errorf("%s can't be set here (settable: %s)", keys[0], strings.Join(settableConfigKeys, ", "))
+	}
+	switch {
+	case keys[0] == "profiles" && len(keys) > 1:
+		if err := c.config.checkNotProject(keys[1]); err != nil {
+			return err
+		}
+	case keys[0] == "default_profile":
+		if err := c.config.checkNotProject(""); err != nil {
+			return err
+		}
+	}
+
+	t, err := configPathType(keys)
+	if err != nil {
+		return err
+	}
+	v, err := parseConfigValue(t, value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", path, err)
+	}
+
+	settings, err := configSettings(c.config)
+	if err != nil {
+		return err
+	}
+	m := settings
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[key] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = v
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	var changed Config
+	if err := json.Unmarshal(data, &changed); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", path, err)
+	}
+	changed.initMaps()
+
+	next := *c.config
+	next.Providers = changed.Providers
+	next.Profiles = changed.Profiles
+	next.DefaultProfile = changed.DefaultProfile
+	next.Retry = changed.Retry
+	next.Render = changed.Render
+	if issue, ok := newConfigError(c.ValidateConfig(), (&Client{config: &next, secrets: c.secrets}).ValidateConfig()); ok {
+		return fmt.Errorf("%s not set: %s: %s", path, issue.Path, issue.Message)
+	}
+
+	c.config.Providers = next.Providers
+	c.config.Profiles = next.Profiles
+	c.config.DefaultProfile = next.DefaultProfile
+	c.config.Retry = next.Retry
+	c.config.Render = next.Render
+	return c.saveConfig()
+}
+
+// newConfigError returns the first error in after that isn't in before.
+func newConfigError(before, after []ConfigIssue) (ConfigIssue, bool) {
+	for _, issue := range after {
+		if issue.Severity == IssueError && !slices.Contains(before, issue) {
+			return issue, true
+		}
+	}
+	return ConfigIssue{}, false
+}
+
+// configSettings returns cfg's settings as decoded from JSON.
+func configSettings(cfg *Config) (map[string]any, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// configPathType returns the type of the config setting at keys, failing
+// if there's no such setting. Settings within free-form maps such as
+// extra_body have a nil type.
+func configPathType(keys []string) (reflect.Type, error) {
+	t := reflect.TypeOf(Config{})
+	for i, key := range keys {
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch {
+		case key == "":
+			return nil, fmt.Errorf("invalid setting %q", strings.Join(keys, "."))
+		case t == nil || t.Kind() == reflect.Interface:
+			t = nil
+		case t.Kind() == reflect.Map:
+			t = t.Elem()
+		case t.Kind() == reflect.Struct:
+			if t = jsonFieldType(t, key); t == nil {
+				return nil, fmt.Errorf("unknown setting %s", strings.Join(keys[:i+1], "."))
+			}
+		default:
+			return nil, fmt.Errorf("%s has no settings within it", strings.Join(keys[:i], "."))
+		}
+	}
+	return t, nil
+}
+
+// parseConfigValue reads text as a value for a setting of type t, as
+// decoded from JSON.
+func parseConfigValue(t reflect.Type, text string) (any, error) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() == reflect.Interface {
+		var v any
+		if json.Unmarshal([]byte(text), &v) == nil {
+			return v, nil
+		}
+		return text, nil
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice:
+		var v any
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			return nil, fmt.Errorf("want JSON: %w", err)
+		}
+		return v, nil
+	case reflect.Bool:
+		if text != "true" && text != "false" {
+			return nil, fmt.Errorf("want true or false")
+		}
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		if !jsonNumber.MatchString(text) {
+			return nil, fmt.Errorf("want a number")
+		}
+	}
+	return (&configNode{text: text}).value(t), nil
+}
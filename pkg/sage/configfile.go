@@ -0,0 +1,427 @@
+package sage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// configFileNames are the user config files ConfigPath looks for, in the
+// config directory. Only one may exist; config.json is created by default.
+var configFileNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// findConfigFile returns the config file in dir, or config.json if there is
+// none yet.
+func findConfigFile(dir string) (string, error) {
+	var found []string
+	for _, name := range configFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return filepath.Join(dir, configFileNames[0]), nil
+	case 1:
+		return filepath.Join(dir, found[0]), nil
+	}
+	return "", fmt.Errorf("found %s in %s: remove all but one", strings.Join(found, " and "), dir)
+}
+
+// configFormat returns the format of a config file by its extension:
+// "yaml", "toml" or "json".
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// decodeConfig parses a config file's contents in the format of its path.
+func decodeConfig(path string, data []byte) (*Config, error) {
+	var cfg Config
+	format := configFormat(path)
+	if format == "json" {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid config JSON: %w", err)
+		}
+		return &cfg, nil
+	}
+
+	root, _, err := parseConfigTree(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", strings.ToUpper(format), err)
+	}
+	converted, err := json.Marshal(root.value(reflect.TypeOf(cfg)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", strings.ToUpper(format), err)
+	}
+	if err := json.Unmarshal(converted, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", strings.ToUpper(format), err)
+	}
+	return &cfg, nil
+}
+
+// encodeConfig formats cfg for the config file at path. YAML and TOML files
+// keep the comments and key order of old, the file being replaced.
+func encodeConfig(path string, cfg *Config, old []byte) ([]byte, error) {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	format := configFormat(path)
+	if err != nil || format == "json" {
+		return data, err
+	}
+
+	root, err := jsonToNode(data)
+	if err != nil {
+		return nil, err
+	}
+	comments := newConfigComments()
+	var oldRoot *configNode
+	if old != nil {
+		// An old file that no longer parses just loses its comments
+		var oldComments *configComments
+		if oldRoot, oldComments, err = parseConfigTree(format, old); err == nil {
+			comments = oldComments
+		}
+	}
+	root.dropEmpty(reflect.TypeOf(*cfg), oldRoot)
+	root.orderLike(oldRoot)
+
+	var buf bytes.Buffer
+	if format == "yaml" {
+		emitYAML(&buf, root, comments)
+	} else {
+		emitTOML(&buf, root, comments)
+	}
+	return buf.Bytes(), nil
+}
+
+// parseConfigTree parses a YAML or TOML config file.
+func parseConfigTree(format string, data []byte) (*configNode, *configComments, error) {
+	if format == "yaml" {
+		return parseYAML(string(data))
+	}
+	return parseTOML(string(data))
+}
+
+// configNode is a value in a YAML or TOML config file: a map, a list or a
+// scalar. Map keys keep their file order so saving doesn't reorder them.
+type configNode struct {
+	kind   nodeKind
+	keys   []string
+	fields map[string]*configNode
+	items  []*configNode
+
+	// A scalar's text. Quoted scalars are strings; others are numbers,
+	// booleans and nulls, or strings where the config expects one.
+	text   string
+	quoted bool
+
+	// flow marks maps and lists written on one line: YAML's [a, b] and
+	// {a: 1}, or TOML's inline tables.
+	flow bool
+
+	// multiline marks TOML arrays written over several lines, which are
+	// saved an item per line to keep the comments among their items.
+	multiline bool
+}
+
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	mapNode
+	listNode
+)
+
+func newMapNode() *configNode {
+	return &configNode{kind: mapNode, fields: make(map[string]*configNode)}
+}
+
+// set adds key to a map node, failing if it's already there.
+func (n *configNode) set(key string, value *configNode) error {
+	if _, ok := n.fields[key]; ok {
+		return fmt.Errorf("duplicate key %q", key)
+	}
+	n.keys = append(n.keys, key)
+	n.fields[key] = value
+	return nil
+}
+
+// isNull reports whether n is an unquoted null.
+func (n *configNode) isNull() bool {
+	return n.kind == scalarNode && !n.quoted && (n.text == "null" || n.text == "~" || n.text == "")
+}
+
+// jsonNumber matches the numbers an unquoted scalar is read as.
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// value converts n to the JSON value for a field of type t, so that
+// unquoted scalars are read as strings where the config expects strings,
+// e.g. "model: 3.5" or "api_version: 2024-06-01". A nil t infers types.
+func (n *configNode) value(t reflect.Type) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Interface {
+		t = nil
+	}
+
+	switch n.kind {
+	case mapNode:
+		m := make(map[string]any, len(n.keys))
+		for _, k := range n.keys {
+			var ft reflect.Type
+			if t != nil && t.Kind() == reflect.Map {
+				ft = t.Elem()
+			} else if t != nil && t.Kind() == reflect.Struct {
+				ft = jsonFieldType(t, k)
+			}
+			m[k] = n.fields[k].value(ft)
+		}
+		return m
+	case listNode:
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		}
+		list := make([]any, len(n.items))
+		for i, item := range n.items {
+			list[i] = item.value(et)
+		}
+		return list
+	}
+
+	switch {
+	case n.quoted:
+		return n.text
+	case n.isNull():
+		return nil
+	case t != nil && t.Kind() == reflect.String:
+		return n.text
+	case n.text == "true" || n.text == "false":
+		return n.text == "true"
+	case jsonNumber.MatchString(n.text):
+		return json.Number(n.text)
+	}
+	if number, ok := yamlNumber(n.text); ok {
+		return number
+	}
+	return n.text
+}
+
+// yamlNumberText matches the numbers YAML reads that JSON doesn't, with a
+// leading + or zeros, or no digits on one side of the point: +1, 007, .5.
+var yamlNumberText = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// yamlNumber converts such a number to JSON's form.
+func yamlNumber(text string) (json.Number, bool) {
+	if !yamlNumberText.MatchString(text) {
+		return "", false
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return json.Number(strconv.FormatInt(n, 10)), true
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return "", false
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), true
+}
+
+// jsonFieldType returns the type of the field of struct t with JSON name
+// name, or nil if there is none.
+func jsonFieldType(t reflect.Type, name string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == name || (tag == "" && strings.EqualFold(f.Name, name)) {
+			return f.Type
+		}
+	}
+	return nil
+}
+
+// jsonToNode converts a JSON document to a configNode, keeping key order.
+func jsonToNode(data []byte) (*configNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeJSONNode(dec)
+}
+
+func decodeJSONNode(dec *json.Decoder) (*configNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			n := &configNode{kind: listNode}
+			for dec.More() {
+				item, err := decodeJSONNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, item)
+			}
+			_, err := dec.Token()
+			return n, err
+		}
+		n := newMapNode()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.set(key.(string), value)
+		}
+		_, err := dec.Token()
+		return n, err
+	case string:
+		return &configNode{text: tok, quoted: true}, nil
+	case json.Number:
+		return &configNode{text: tok.String()}, nil
+	case bool:
+		return &configNode{text: fmt.Sprint(tok)}, nil
+	}
+	return &configNode{text: "null"}, nil
+}
+
+// dropEmpty removes the empty strings, maps and lists that JSON encoding
+// gives struct fields without omitempty, except where old has them, so
+// saving doesn't fill a hand-written file with them.
+func (n *configNode) dropEmpty(t reflect.Type, old *configNode) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || n.kind != mapNode || (t.Kind() != reflect.Struct && t.Kind() != reflect.Map) {
+		return
+	}
+	if old != nil && old.kind != mapNode {
+		old = nil
+	}
+
+	keys := n.keys[:0]
+	for _, k := range n.keys {
+		v := n.fields[k]
+		var oldValue *configNode
+		if old != nil {
+			oldValue = old.fields[k]
+		}
+		if t.Kind() == reflect.Map {
+			v.dropEmpty(t.Elem(), oldValue)
+			keys = append(keys, k)
+			continue
+		}
+		empty := (v.kind == scalarNode && v.quoted && v.text == "") || v.isNull() ||
+			(v.kind == mapNode && len(v.keys) == 0) || (v.kind == listNode && len(v.items) == 0)
+		if empty && oldValue == nil {
+			delete(n.fields, k)
+			continue
+		}
+		v.dropEmpty(jsonFieldType(t, k), oldValue)
+		keys = append(keys, k)
+	}
+	n.keys = keys
+}
+
+// orderLike sorts the keys of n's maps that also appear in old into old's
+// order, ahead of keys that don't, and keeps old's flow and multi-line
+// styles.
+func (n *configNode) orderLike(old *configNode) {
+	if old == nil || old.kind != n.kind {
+		return
+	}
+	n.flow, n.multiline = old.flow, old.multiline
+	switch n.kind {
+	case mapNode:
+		keys := make([]string, 0, len(n.keys))
+		for _, k := range old.keys {
+			if _, ok := n.fields[k]; ok {
+				keys = append(keys, k)
+				n.fields[k].orderLike(old.fields[k])
+			}
+		}
+		for _, k := range n.keys {
+			if _, ok := old.fields[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		n.keys = keys
+	case listNode:
+		for i := range n.items {
+			if i < len(old.items) {
+				n.items[i].orderLike(old.items[i])
+			}
+		}
+	}
+}
+
+// configComments holds a YAML or TOML file's comments by the path of the
+// key (or list item) they belong to, so they can be written back on save.
+type configComments struct {
+	before map[string][]string // Comment lines above a key; "" is a blank line
+	inline map[string]string   // Comment after a key's value, on its line
+	footer []string            // Comment lines after the last key
+
+	started bool // A key has taken comments
+}
+
+func newConfigComments() *configComments {
+	return &configComments{before: make(map[string][]string), inline: make(map[string]string)}
+}
+
+// commentPath joins a key path for configComments. Keys may contain dots.
+func commentPath(path []string, key string) string {
+	return strings.Join(append(path[:len(path):len(path)], key), "\x00")
+}
+
+// take attaches the pending comment lines to the key at path. Blank lines
+// at the top of the file are dropped.
+func (c *configComments) take(path string, pending *[]string) {
+	lines := *pending
+	*pending = nil
+	if !c.started {
+		for len(lines) > 0 && lines[0] == "" {
+			lines = lines[1:]
+		}
+		c.started = true
+	}
+	if len(lines) > 0 {
+		c.before[path] = lines
+	}
+}
+
+// finish keeps the comments left pending at the end of the file.
+func (c *configComments) finish(pending []string) {
+	for len(pending) > 0 && pending[len(pending)-1] == "" {
+		pending = pending[:len(pending)-1]
+	}
+	c.footer = pending
+}
+
+// addPending adds a comment or blank line to pending, collapsing runs of
+// blank lines into one.
+func addPending(pending *[]string, line string) {
+	if line == "" && len(*pending) > 0 && (*pending)[len(*pending)-1] == "" {
+		return
+	}
+	*pending = append(*pending, line)
+}
@@ -0,0 +1,74 @@
+package sage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// LoadDocument returns the document at source: a base64 data: URL or a
+// file path, named after the file.
+func LoadDocument(source string) (Document, error) {
+	if rest, ok := strings.CutPrefix(source, "data:"); ok {
+		header, data, ok := strings.Cut(rest, ",")
+		mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+		if !ok || !isBase64 {
+			return Document{}, fmt.Errorf("document data URL must be base64 encoded (data:<type>;base64,<data>)")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return Document{}, fmt.Errorf("invalid base64 in document data URL: %w", err)
+		}
+		return Document{Data: decoded, MediaType: mediaType}, nil
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return Document{}, fmt.Errorf("cannot read document: %w", err)
+	}
+	return Document{
+		Data:      data,
+		MediaType: mime.TypeByExtension(strings.ToLower(filepath.Ext(source))),
+		Name:      filepath.Base(source),
+	}, nil
+}
+
+// convertDocuments checks documents and converts them for providers,
+// detecting the media type of data without one. Text of any kind is sent
+// as text/plain, the only text type providers take.
+func convertDocuments(documents []Document) ([]providers.Document, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+	converted := make([]providers.Document, len(documents))
+	for i, doc := range documents {
+		if len(doc.Data) == 0 {
+			return nil, fmt.Errorf("document %d: no data", i)
+		}
+
+		mediaType := doc.MediaType
+		if mediaType == "" {
+			mediaType = http.DetectContentType(doc.Data)
+		}
+		mediaType, _, _ = strings.Cut(mediaType, ";")
+		switch {
+		case mediaType == "application/pdf":
+		case strings.HasPrefix(mediaType, "text/"):
+			if !utf8.Valid(doc.Data) {
+				return nil, fmt.Errorf("document %d: text is not valid UTF-8", i)
+			}
+			mediaType = "text/plain"
+		default:
+			return nil, fmt.Errorf("document %d: unsupported media type %s (want a PDF or text)", i, mediaType)
+		}
+		converted[i] = providers.Document{Data: doc.Data, MediaType: mediaType, Name: doc.Name}
+	}
+	return converted, nil
+}
@@ -0,0 +1,47 @@
+package sage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDocument(t *testing.T) {
+	doc, err := LoadDocument("data:application/pdf;base64,JVBERi0=")
+	if err != nil || doc.MediaType != "application/pdf" || string(doc.Data) != "%PDF-" {
+		t.Errorf("LoadDocument(data URL) = %+v, %v", doc, err)
+	}
+
+	if _, err := LoadDocument("data:text/plain,hello"); err == nil {
+		t.Error("LoadDocument() should reject a data URL that isn't base64")
+	}
+
+	path := filepath.Join(t.TempDir(), "report.PDF")
+	os.WriteFile(path, []byte("%PDF-1.7"), 0600)
+	doc, err = LoadDocument(path)
+	if err != nil || doc.MediaType != "application/pdf" || doc.Name != "report.PDF" {
+		t.Errorf("LoadDocument(file) = %+v, %v", doc, err)
+	}
+}
+
+func TestConvertDocuments(t *testing.T) {
+	docs, err := convertDocuments([]Document{
+		{Data: []byte("%PDF-1.7\n")},
+		{Data: []byte("# Notes"), MediaType: "text/markdown"},
+	})
+	if err != nil {
+		t.Fatalf("convertDocuments() error = %v", err)
+	}
+	if docs[0].MediaType != "application/pdf" {
+		t.Errorf("MediaType = %q, want application/pdf detected from data", docs[0].MediaType)
+	}
+	if docs[1].MediaType != "text/plain" {
+		t.Errorf("MediaType = %q, want text/plain for markdown", docs[1].MediaType)
+	}
+
+	for _, doc := range []Document{{}, {Data: pngHeader}, {Data: []byte{0xff, 0xfe}, MediaType: "text/plain"}} {
+		if _, err := convertDocuments([]Document{doc}); err == nil {
+			t.Errorf("convertDocuments(%+v) should fail", doc)
+		}
+	}
+}
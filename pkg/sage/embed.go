@@ -0,0 +1,83 @@
+package sage
+
+import (
+	"fmt"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// EmbedRequest is the input for an embedding call.
+type EmbedRequest struct {
+	Input []string // Texts to embed, one vector each
+
+	// Dimensions shortens the vectors, for models that support it.
+	// Zero uses the model's full size.
+	Dimensions int
+}
+
+// EmbedResponse is the result of an embedding call.
+type EmbedResponse struct {
+	Embeddings [][]float64 // One per input, in input order
+	Model      string
+	Usage      Usage // CompletionTokens is always zero
+}
+
+// Embed returns vectors for req.Input using the profile's provider and model,
+// which must be an embedding model. If profileName is empty, the default
+// profile is used. Failed requests are retried according to the profile's
+// RetryPolicy.
+func (c *Client) Embed(profileName string, req EmbedRequest) (*EmbedResponse, error) {
+	if len(req.Input) == 0 {
+		return nil, fmt.Errorf("no input to embed")
+	}
+	if req.Dimensions < 0 {
+		return nil, fmt.Errorf("dimensions must not be negative")
+	}
+
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return nil, err
+	}
+	embedder, ok := provider.(providers.Embedder)
+	if !ok || !providers.Supports(provider, providers.CapEmbeddings) {
+		return nil, errIncapable(profile, providers.CapEmbeddings)
+	}
+
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return nil, err
+	}
+	embedReq := providers.EmbedRequest{
+		Model:      profile.Model,
+		Input:      req.Input,
+		Dimensions: req.Dimensions,
+		Endpoint:   endpoint,
+		APIVersion: c.config.Providers[profile.Provider].APIVersion,
+	}
+
+	var providerResp *providers.EmbedResponse
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &embedReq.APIKey, func() error {
+			providerResp, err = embedder.Embed(embedReq)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmbedResponse{
+		Embeddings: providerResp.Embeddings,
+		Model:      providerResp.Model,
+		Usage:      Usage{PromptTokens: providerResp.Usage.PromptTokens},
+	}, nil
+}
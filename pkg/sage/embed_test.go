@@ -0,0 +1,48 @@
+package sage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Embed(t *testing.T) {
+	client := setupTestClient(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"index": 0, "embedding": [0.1, 0.2]}], "usage": {"prompt_tokens": 2}}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("embed", Profile{Provider: "openai", Account: "default", Model: "text-embedding-3-small"})
+
+	resp, err := client.Embed("embed", EmbedRequest{Input: []string{"hello"}})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(resp.Embeddings) != 1 || len(resp.Embeddings[0]) != 2 {
+		t.Errorf("Embeddings = %v, want one 2-dimensional vector", resp.Embeddings)
+	}
+	if resp.Model != "text-embedding-3-small" || resp.Usage.PromptTokens != 2 {
+		t.Errorf("Model = %q, PromptTokens = %d", resp.Model, resp.Usage.PromptTokens)
+	}
+
+	if _, err := client.Embed("embed", EmbedRequest{}); err == nil {
+		t.Error("Embed() should reject empty input")
+	}
+}
+
+func TestClient_Embed_Unsupported(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-5-haiku-latest"})
+
+	if _, err := client.Embed("claude", EmbedRequest{Input: []string{"hello"}}); err == nil {
+		t.Error("Embed() should fail for a provider without embeddings")
+	}
+}
@@ -0,0 +1,153 @@
+package sage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// File purposes understood by openai.
+const (
+	FilePurposeBatch    = "batch"
+	FilePurposeFineTune = "fine-tune"
+)
+
+// FileUpload is a file to store with a profile's provider.
+type FileUpload struct {
+	Data     []byte
+	Filename string
+	Purpose  string // What the file is for, e.g. FilePurposeFineTune
+}
+
+// File is a file stored with a provider.
+type File struct {
+	ID        string
+	Filename  string
+	Purpose   string
+	Bytes     int64
+	CreatedAt time.Time
+}
+
+// UploadFile stores a file with the profile's provider, for requests such as
+// fine-tuning jobs to refer to by ID. If profileName is empty, the default
+// profile is used.
+func (c *Client) UploadFile(profileName string, upload FileUpload) (*File, error) {
+	if upload.Filename == "" {
+		return nil, fmt.Errorf("filename is required")
+	}
+	if upload.Purpose == "" {
+		return nil, fmt.Errorf("purpose is required")
+	}
+
+	profile, store, err := c.fileStore(profileName)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return nil, err
+	}
+	req := providers.FileUploadRequest{
+		Data:     upload.Data,
+		Filename: upload.Filename,
+		Purpose:  upload.Purpose,
+		Endpoint: endpoint,
+	}
+
+	var file *providers.File
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &req.APIKey, func() error {
+			file, err = store.UploadFile(req)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertFile(file), nil
+}
+
+// ListFiles returns the files stored with the profile's provider account,
+// only those uploaded for purpose if it's set.
+func (c *Client) ListFiles(profileName, purpose string) ([]File, error) {
+	var files []providers.File
+	err := c.withFileStore(profileName, providers.FileRequest{Purpose: purpose}, func(store providers.FileStore, req providers.FileRequest) error {
+		var err error
+		files, err = store.ListFiles(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]File, len(files))
+	for i := range files {
+		result[i] = *convertFile(&files[i])
+	}
+	return result, nil
+}
+
+// DeleteFile deletes a file stored with the profile's provider.
+func (c *Client) DeleteFile(profileName, id string) error {
+	if id == "" {
+		return fmt.Errorf("file ID is required")
+	}
+	return c.withFileStore(profileName, providers.FileRequest{ID: id}, func(store providers.FileStore, req providers.FileRequest) error {
+		return store.DeleteFile(req)
+	})
+}
+
+// fileStore returns the profile and its provider's file storage.
+func (c *Client) fileStore(profileName string) (*Profile, providers.FileStore, error) {
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	store, ok := provider.(providers.FileStore)
+	if !ok || !providers.Supports(provider, providers.CapFiles) {
+		return nil, nil, errIncapable(profile, providers.CapFiles)
+	}
+	return profile, store, nil
+}
+
+// withFileStore fills in req's credentials and calls fn with retries and key
+// failover.
+func (c *Client) withFileStore(profileName string, req providers.FileRequest, fn func(providers.FileStore, providers.FileRequest) error) error {
+	profile, store, err := c.fileStore(profileName)
+	if err != nil {
+		return err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return err
+	}
+
+	req.Endpoint, err = c.endpoint(profile)
+	if err != nil {
+		return err
+	}
+	return policy.do(func() error {
+		return c.withKeyFailover(profile, &req.APIKey, func() error {
+			return fn(store, req)
+		})
+	})
+}
+
+func convertFile(file *providers.File) *File {
+	return &File{
+		ID:        file.ID,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+	}
+}
@@ -0,0 +1,74 @@
+package sage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_Files(t *testing.T) {
+	client := setupTestClient(t)
+
+	var created string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/files":
+			w.Write([]byte(`{"id": "file-1", "filename": "train.jsonl", "purpose": "fine-tune", "bytes": 3}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/files":
+			w.Write([]byte(`{"data": [{"id": "file-1", "filename": "train.jsonl", "purpose": "fine-tune", "bytes": 3}]}`))
+		case r.Method == "DELETE" && r.URL.Path == "/v1/files/file-1":
+			w.Write([]byte(`{"id": "file-1", "deleted": true}`))
+		case r.URL.Path == "/v1/fine_tuning/jobs":
+			body, _ := io.ReadAll(r.Body)
+			created = string(body)
+			w.Write([]byte(`{"id": "ftjob-1", "status": "queued"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("tune", Profile{Provider: "openai", Account: "default", Model: "gpt-4o-mini"})
+
+	file, err := client.UploadFile("tune", FileUpload{Data: []byte("{}\n"), Filename: "train.jsonl", Purpose: FilePurposeFineTune})
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if file.ID != "file-1" {
+		t.Errorf("file = %+v", file)
+	}
+
+	files, err := client.ListFiles("tune", FilePurposeFineTune)
+	if err != nil || len(files) != 1 {
+		t.Errorf("ListFiles() = %+v, %v", files, err)
+	}
+
+	// An uploaded file can be reused for fine-tuning without uploading again
+	if _, err := client.CreateFineTune("tune", FineTuneRequest{TrainingFileID: file.ID}); err != nil {
+		t.Fatalf("CreateFineTune() error = %v", err)
+	}
+	if want := `"training_file":"file-1"`; !strings.Contains(created, want) {
+		t.Errorf("created = %s, want %s", created, want)
+	}
+
+	if err := client.DeleteFile("tune", "file-1"); err != nil {
+		t.Errorf("DeleteFile() error = %v", err)
+	}
+}
+
+func TestClient_UploadFile_Unsupported(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-5-haiku-latest"})
+
+	if _, err := client.UploadFile("claude", FileUpload{Data: []byte("x"), Filename: "x.txt", Purpose: "batch"}); err == nil {
+		t.Error("UploadFile() should fail for a provider without file storage")
+	}
+}
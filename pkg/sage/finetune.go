@@ -0,0 +1,213 @@
+package sage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// FineTuneRequest starts a fine-tuning job on the profile's model. The
+// training and validation data are uploaded with the job, or can instead be
+// files already uploaded with UploadFile.
+type FineTuneRequest struct {
+	TrainingData     []byte // JSONL of chat examples
+	TrainingFilename string // Optional, defaults to "training.jsonl"
+	TrainingFileID   string // Instead of TrainingData
+	ValidationData   []byte // Optional JSONL held out for evaluation
+	ValidationFileID string // Instead of ValidationData
+	Suffix           string // Optional, included in the fine-tuned model's name
+	Epochs           int    // 0 lets the provider choose
+}
+
+// FineTuneJob is the state of a fine-tuning job.
+type FineTuneJob struct {
+	ID             string
+	Model          string // Base model
+	FineTunedModel string // Set once the job succeeds
+	Status         string // As the provider reports it, e.g. "running" or "succeeded"
+	Done           bool   // Finished, whether succeeded, failed or cancelled
+	CreatedAt      time.Time
+	FinishedAt     time.Time // Zero until the job is done
+	TrainedTokens  int
+	Error          string
+}
+
+// FineTuneEvent is a progress message from a fine-tuning job.
+type FineTuneEvent struct {
+	ID        string
+	CreatedAt time.Time
+	Level     string // "info", "warn" or "error"
+	Message   string
+}
+
+// CreateFineTune uploads any training data and starts fine-tuning the
+// profile's model on it. If profileName is empty, the default profile is
+// used. Once the job succeeds, FineTunedModel names the new model, which
+// can be used as any profile's model.
+func (c *Client) CreateFineTune(profileName string, req FineTuneRequest) (*FineTuneJob, error) {
+	if len(req.TrainingData) == 0 && req.TrainingFileID == "" {
+		return nil, fmt.Errorf("training data is required")
+	}
+
+	profile, tuner, err := c.fineTuner(profileName)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	filename := req.TrainingFilename
+	if filename == "" {
+		filename = "training.jsonl"
+	}
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return nil, err
+	}
+	providerReq := providers.FineTuneRequest{
+		Model:            profile.Model,
+		TrainingData:     req.TrainingData,
+		TrainingFilename: filename,
+		TrainingFileID:   req.TrainingFileID,
+		ValidationData:   req.ValidationData,
+		ValidationFileID: req.ValidationFileID,
+		Suffix:           req.Suffix,
+		Epochs:           req.Epochs,
+		Endpoint:         endpoint,
+	}
+
+	var job *providers.FineTuneJob
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &providerReq.APIKey, func() error {
+			job, err = tuner.CreateFineTune(providerReq)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertFineTuneJob(job), nil
+}
+
+// ListFineTunes returns the account's recent fine-tuning jobs, newest first.
+func (c *Client) ListFineTunes(profileName string) ([]FineTuneJob, error) {
+	var jobs []providers.FineTuneJob
+	err := c.withFineTuneJob(profileName, "", func(tuner providers.FineTuner, req providers.FineTuneJobRequest) error {
+		var err error
+		jobs, err = tuner.ListFineTunes(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]FineTuneJob, len(jobs))
+	for i := range jobs {
+		result[i] = *convertFineTuneJob(&jobs[i])
+	}
+	return result, nil
+}
+
+// FineTuneStatus returns the state of a fine-tuning job.
+func (c *Client) FineTuneStatus(profileName, id string) (*FineTuneJob, error) {
+	return c.fineTuneJobCall(profileName, id, providers.FineTuner.GetFineTune)
+}
+
+// CancelFineTune cancels a fine-tuning job and returns its new state.
+func (c *Client) CancelFineTune(profileName, id string) (*FineTuneJob, error) {
+	return c.fineTuneJobCall(profileName, id, providers.FineTuner.CancelFineTune)
+}
+
+// FineTuneEvents returns a fine-tuning job's latest events, oldest first.
+func (c *Client) FineTuneEvents(profileName, id string) ([]FineTuneEvent, error) {
+	var events []providers.FineTuneEvent
+	err := c.withFineTuneJob(profileName, id, func(tuner providers.FineTuner, req providers.FineTuneJobRequest) error {
+		var err error
+		events, err = tuner.FineTuneEvents(req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]FineTuneEvent, len(events))
+	for i, e := range events {
+		result[i] = FineTuneEvent{ID: e.ID, CreatedAt: e.CreatedAt, Level: e.Level, Message: e.Message}
+	}
+	return result, nil
+}
+
+func (c *Client) fineTuneJobCall(profileName, id string, call func(providers.FineTuner, providers.FineTuneJobRequest) (*providers.FineTuneJob, error)) (*FineTuneJob, error) {
+	if id == "" {
+		return nil, fmt.Errorf("fine-tuning job ID is required")
+	}
+	var job *providers.FineTuneJob
+	err := c.withFineTuneJob(profileName, id, func(tuner providers.FineTuner, req providers.FineTuneJobRequest) error {
+		var err error
+		job, err = call(tuner, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertFineTuneJob(job), nil
+}
+
+// fineTuner returns the profile and its provider's fine-tuning API.
+func (c *Client) fineTuner(profileName string) (*Profile, providers.FineTuner, error) {
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	tuner, ok := provider.(providers.FineTuner)
+	if !ok || !providers.Supports(provider, providers.CapFineTuning) {
+		return nil, nil, errIncapable(profile, providers.CapFineTuning)
+	}
+	return profile, tuner, nil
+}
+
+// withFineTuneJob calls fn with retries and key failover. id is empty for
+// calls that aren't about one job.
+func (c *Client) withFineTuneJob(profileName, id string, fn func(providers.FineTuner, providers.FineTuneJobRequest) error) error {
+	profile, tuner, err := c.fineTuner(profileName)
+	if err != nil {
+		return err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return err
+	}
+	req := providers.FineTuneJobRequest{
+		ID:       id,
+		Endpoint: endpoint,
+	}
+	return policy.do(func() error {
+		return c.withKeyFailover(profile, &req.APIKey, func() error {
+			return fn(tuner, req)
+		})
+	})
+}
+
+func convertFineTuneJob(job *providers.FineTuneJob) *FineTuneJob {
+	return &FineTuneJob{
+		ID:             job.ID,
+		Model:          job.Model,
+		FineTunedModel: job.FineTunedModel,
+		Status:         job.Status,
+		Done:           job.Done,
+		CreatedAt:      job.CreatedAt,
+		FinishedAt:     job.FinishedAt,
+		TrainedTokens:  job.TrainedTokens,
+		Error:          job.Error,
+	}
+}
@@ -0,0 +1,77 @@
+package sage
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_FineTune(t *testing.T) {
+	client := setupTestClient(t)
+
+	var created map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/files":
+			if r.FormValue("purpose") != "fine-tune" {
+				t.Errorf("purpose = %q, want fine-tune", r.FormValue("purpose"))
+			}
+			w.Write([]byte(`{"id": "file-train"}`))
+		case "/v1/fine_tuning/jobs":
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &created)
+			w.Write([]byte(`{"id": "ftjob-1", "model": "gpt-4o-mini", "status": "validating_files"}`))
+		case "/v1/fine_tuning/jobs/ftjob-1":
+			w.Write([]byte(`{"id": "ftjob-1", "model": "gpt-4o-mini", "status": "succeeded",
+				"fine_tuned_model": "ft:gpt-4o-mini:acme::abc", "finished_at": 1700000000, "trained_tokens": 1200}`))
+		case "/v1/fine_tuning/jobs/ftjob-1/events":
+			w.Write([]byte(`{"data": [
+				{"id": "ev-2", "level": "info", "message": "Fine-tuning job successfully completed"},
+				{"id": "ev-1", "level": "info", "message": "Validating training file"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("tune", Profile{Provider: "openai", Account: "default", Model: "gpt-4o-mini"})
+
+	job, err := client.CreateFineTune("tune", FineTuneRequest{TrainingData: []byte(`{"messages": []}`), Suffix: "acme", Epochs: 3})
+	if err != nil {
+		t.Fatalf("CreateFineTune() error = %v", err)
+	}
+	if job.ID != "ftjob-1" || job.Done {
+		t.Errorf("job = %+v", job)
+	}
+	if created["model"] != "gpt-4o-mini" || created["training_file"] != "file-train" || created["suffix"] != "acme" {
+		t.Errorf("created = %v, want the profile's model and the uploaded file", created)
+	}
+
+	job, err = client.FineTuneStatus("tune", "ftjob-1")
+	if err != nil || !job.Done || job.FineTunedModel != "ft:gpt-4o-mini:acme::abc" || job.FinishedAt.IsZero() {
+		t.Errorf("FineTuneStatus() = %+v, %v", job, err)
+	}
+
+	events, err := client.FineTuneEvents("tune", "ftjob-1")
+	if err != nil {
+		t.Fatalf("FineTuneEvents() error = %v", err)
+	}
+	if len(events) != 2 || events[0].ID != "ev-1" {
+		t.Errorf("events = %+v, want oldest first", events)
+	}
+}
+
+func TestClient_CreateFineTune_Unsupported(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-5-haiku-latest"})
+
+	if _, err := client.CreateFineTune("claude", FineTuneRequest{TrainingData: []byte("{}")}); err == nil {
+		t.Error("CreateFineTune() should fail for a provider without fine-tuning")
+	}
+}
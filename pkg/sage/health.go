@@ -0,0 +1,127 @@
+package sage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// Health statuses reported by CheckHealth.
+const (
+	HealthOK          = "ok"
+	HealthAuthFailed  = "auth_failed" // Endpoint reachable, API key rejected
+	HealthKeyExpired  = "key_expired" // API key or credentials rejected as expired
+	HealthUnreachable = "unreachable" // Connection failed or timed out
+	HealthError       = "error"       // Any other failure, e.g. a server error
+)
+
+// healthTimeout bounds each check, since provider requests have no timeout.
+const healthTimeout = 15 * time.Second
+
+// errHealthTimeout is reported for checks that didn't finish in time.
+var errHealthTimeout = errors.New("no response")
+
+// HealthStatus is the result of checking one provider account.
+type HealthStatus struct {
+	Provider string
+	Account  string
+	Status   string
+	Latency  time.Duration // Round trip of the check request
+	Err      error         // Nil when Status is HealthOK
+}
+
+// CheckHealth checks every configured provider account concurrently and
+// returns the results sorted by provider and account. Providers are checked
+// by listing their models, or by their own Ping if they implement one.
+func (c *Client) CheckHealth() []HealthStatus {
+	return c.CheckHealthFor("", "")
+}
+
+// CheckHealthFor is CheckHealth for the accounts of one provider, or with an
+// account too, just that account. Empty strings match any.
+func (c *Client) CheckHealthFor(providerName, account string) []HealthStatus {
+	var results []HealthStatus
+	for name, p := range c.config.Providers {
+		if providerName != "" && name != providerName {
+			continue
+		}
+		for _, a := range p.Accounts {
+			if account != "" && a != account {
+				continue
+			}
+			results = append(results, HealthStatus{Provider: name, Account: a})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Provider != results[j].Provider {
+			return results[i].Provider < results[j].Provider
+		}
+		return results[i].Account < results[j].Account
+	})
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(h *HealthStatus) {
+			defer wg.Done()
+			c.checkHealth(h)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results
+}
+
+// checkHealth pings one provider account and fills in h. Accounts routed
+// through a cloud platform are checked against the platform.
+func (c *Client) checkHealth(h *HealthStatus) {
+	provider, err := c.platformProvider(h.Provider)
+	if err != nil {
+		h.Status, h.Err = HealthError, err
+		return
+	}
+
+	apiKey, err := c.selectAPIKey(h.Provider, h.Account)
+	if err != nil {
+		h.Status, h.Err = HealthError, err
+		return
+	}
+	baseURL := c.config.Providers[h.Provider].BaseURL
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		if pinger, ok := provider.(providers.Pinger); ok {
+			done <- pinger.Ping(apiKey, baseURL)
+			return
+		}
+		_, err := provider.ListModels(apiKey, baseURL)
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(healthTimeout):
+		err = fmt.Errorf("%w after %v", errHealthTimeout, healthTimeout)
+	}
+	h.Latency = time.Since(start)
+	h.Err = err
+
+	switch {
+	case err == nil:
+		h.Status = HealthOK
+	case errors.Is(err, providers.ErrUnauthorized) && strings.Contains(strings.ToLower(err.Error()), "expired"):
+		h.Status = HealthKeyExpired
+	case errors.Is(err, providers.ErrUnauthorized):
+		h.Status = HealthAuthFailed
+	case errors.Is(err, errHealthTimeout), errorClass(err) == RetryNetwork:
+		h.Status = HealthUnreachable
+	default:
+		h.Status = HealthError
+	}
+}
@@ -0,0 +1,83 @@
+package sage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_CheckHealth(t *testing.T) {
+	client := setupTestClient(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "bad key"}}`))
+			return
+		}
+		w.Write([]byte(`{"data": [{"id": "gpt-4o"}]}`))
+	}))
+	defer server.Close()
+
+	// A closed server stands in for an unreachable endpoint
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-good")
+	client.AddProviderAccount("openai", "revoked", "sk-bad")
+	client.AddProviderAccount("ollama", "default", "")
+	client.config.Providers["openai"] = ProviderConfig{Accounts: []string{"revoked", "default"}, BaseURL: server.URL}
+	client.config.Providers["ollama"] = ProviderConfig{Accounts: []string{"default"}, BaseURL: down.URL}
+
+	results := client.CheckHealth()
+
+	want := []struct{ provider, account, status string }{
+		{"ollama", "default", HealthUnreachable},
+		{"openai", "default", HealthOK},
+		{"openai", "revoked", HealthAuthFailed},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("CheckHealth() = %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		h := results[i]
+		if h.Provider != w.provider || h.Account != w.account || h.Status != w.status {
+			t.Errorf("results[%d] = %s:%s %s (%v), want %s:%s %s", i, h.Provider, h.Account, h.Status, h.Err, w.provider, w.account, w.status)
+		}
+	}
+	if results[1].Err != nil || results[1].Latency <= 0 {
+		t.Errorf("healthy result = %+v, want no error and a latency", results[1])
+	}
+}
+
+func TestClient_CheckHealthFor(t *testing.T) {
+	client := setupTestClient(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer sk-old" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Your API key has expired"}}`))
+			return
+		}
+		w.Write([]byte(`{"data": [{"id": "gpt-4o"}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-good")
+	client.AddProviderAccount("openai", "old", "sk-old")
+	client.AddProviderAccount("ollama", "default", "")
+	client.config.Providers["openai"] = ProviderConfig{Accounts: []string{"default", "old"}, BaseURL: server.URL}
+
+	results := client.CheckHealthFor("openai", "")
+	if len(results) != 2 || results[0].Status != HealthOK || results[1].Status != HealthKeyExpired {
+		t.Fatalf("CheckHealthFor(openai) = %+v, want default ok and old expired", results)
+	}
+
+	results = client.CheckHealthFor("openai", "old")
+	if len(results) != 1 || results[0].Account != "old" {
+		t.Errorf("CheckHealthFor(openai, old) = %+v, want just old", results)
+	}
+	if results := client.CheckHealthFor("openai", "missing"); len(results) != 0 {
+		t.Errorf("CheckHealthFor(openai, missing) = %+v, want none", results)
+	}
+}
@@ -0,0 +1,68 @@
+package sage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// LoadImage returns the image at source: an http(s) URL, which is passed to
+// the provider as is, a base64 data: URL, or a file path.
+func LoadImage(source string) (Image, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return Image{URL: source}, nil
+	}
+
+	if rest, ok := strings.CutPrefix(source, "data:"); ok {
+		header, data, ok := strings.Cut(rest, ",")
+		mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+		if !ok || !isBase64 {
+			return Image{}, fmt.Errorf("image data URL must be base64 encoded (data:<type>;base64,<data>)")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return Image{}, fmt.Errorf("invalid base64 in image data URL: %w", err)
+		}
+		return Image{Data: decoded, MediaType: mediaType}, nil
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return Image{}, fmt.Errorf("cannot read image: %w", err)
+	}
+	return Image{Data: data, MediaType: mime.TypeByExtension(strings.ToLower(filepath.Ext(source)))}, nil
+}
+
+// convertImages checks images and converts them for providers, detecting
+// the media type of data without one.
+func convertImages(images []Image) ([]providers.Image, error) {
+	if len(images) == 0 {
+		return nil, nil
+	}
+	converted := make([]providers.Image, len(images))
+	for i, img := range images {
+		if (img.URL == "") == (len(img.Data) == 0) {
+			return nil, fmt.Errorf("image %d: set either URL or Data", i)
+		}
+		if img.URL != "" {
+			converted[i] = providers.Image{URL: img.URL}
+			continue
+		}
+
+		mediaType := img.MediaType
+		if mediaType == "" {
+			mediaType = http.DetectContentType(img.Data)
+		}
+		if !strings.HasPrefix(mediaType, "image/") {
+			return nil, fmt.Errorf("image %d: unsupported media type %s", i, mediaType)
+		}
+		converted[i] = providers.Image{Data: img.Data, MediaType: mediaType}
+	}
+	return converted, nil
+}
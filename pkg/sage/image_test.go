@@ -0,0 +1,49 @@
+package sage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pngHeader is enough of a PNG for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestLoadImage(t *testing.T) {
+	img, err := LoadImage("https://example.com/cat.png")
+	if err != nil || img.URL != "https://example.com/cat.png" || img.Data != nil {
+		t.Errorf("LoadImage(URL) = %+v, %v", img, err)
+	}
+
+	img, err = LoadImage("data:image/gif;base64,R0lGODlh")
+	if err != nil || img.MediaType != "image/gif" || string(img.Data) != "GIF89a" {
+		t.Errorf("LoadImage(data URL) = %+v, %v", img, err)
+	}
+
+	if _, err := LoadImage("data:text/plain,hello"); err == nil {
+		t.Error("LoadImage() should reject a data URL that isn't base64")
+	}
+
+	path := filepath.Join(t.TempDir(), "photo.JPG")
+	os.WriteFile(path, []byte("jpeg"), 0600)
+	img, err = LoadImage(path)
+	if err != nil || img.MediaType != "image/jpeg" || string(img.Data) != "jpeg" {
+		t.Errorf("LoadImage(file) = %+v, %v", img, err)
+	}
+}
+
+func TestConvertImages(t *testing.T) {
+	images, err := convertImages([]Image{{Data: pngHeader}})
+	if err != nil {
+		t.Fatalf("convertImages() error = %v", err)
+	}
+	if images[0].MediaType != "image/png" {
+		t.Errorf("MediaType = %q, want image/png detected from data", images[0].MediaType)
+	}
+
+	for _, img := range []Image{{}, {URL: "https://example.com/a.png", Data: pngHeader}, {Data: []byte("plain text")}} {
+		if _, err := convertImages([]Image{img}); err == nil {
+			t.Errorf("convertImages(%+v) should fail", img)
+		}
+	}
+}
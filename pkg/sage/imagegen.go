@@ -0,0 +1,97 @@
+package sage
+
+import (
+	"fmt"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// ImageGenerationRequest is the input for an image generation call.
+type ImageGenerationRequest struct {
+	Prompt string
+	N      int // Number of images; 0 for one
+
+	// Size and Quality are passed to the provider as is, e.g. "1024x1024"
+	// and "high" for gpt-image-1 or "hd" for dall-e-3. Empty uses the
+	// model's defaults.
+	Size    string
+	Quality string
+}
+
+// ImageGenerationResponse is the result of an image generation call.
+type ImageGenerationResponse struct {
+	Images []GeneratedImage
+	Model  string
+}
+
+// GeneratedImage is one generated image.
+type GeneratedImage struct {
+	Data          []byte
+	MediaType     string // e.g. "image/png"
+	RevisedPrompt string // The prompt the model actually drew, if it rewrote it
+}
+
+// GenerateImages draws images for req.Prompt using the profile's provider
+// and model, which must be an image model such as gpt-image-1. If
+// profileName is empty, the default profile is used. Failed requests are
+// retried according to the profile's RetryPolicy.
+func (c *Client) GenerateImages(profileName string, req ImageGenerationRequest) (*ImageGenerationResponse, error) {
+	if req.Prompt == "" {
+		return nil, fmt.Errorf("no prompt provided")
+	}
+	if req.N < 0 {
+		return nil, fmt.Errorf("number of images must not be negative")
+	}
+
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return nil, err
+	}
+	generator, ok := provider.(providers.ImageGenerator)
+	if !ok || !providers.Supports(provider, providers.CapImageGeneration) {
+		return nil, errIncapable(profile, providers.CapImageGeneration)
+	}
+
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return nil, err
+	}
+	imageReq := providers.ImageRequest{
+		Model:    profile.Model,
+		Prompt:   req.Prompt,
+		N:        req.N,
+		Size:     req.Size,
+		Quality:  req.Quality,
+		Endpoint: endpoint,
+	}
+
+	var providerResp *providers.ImageResponse
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &imageReq.APIKey, func() error {
+			providerResp, err = generator.GenerateImages(imageReq)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ImageGenerationResponse{Model: providerResp.Model}
+	for _, img := range providerResp.Images {
+		resp.Images = append(resp.Images, GeneratedImage{
+			Data:          img.Data,
+			MediaType:     img.MediaType,
+			RevisedPrompt: img.RevisedPrompt,
+		})
+	}
+	return resp, nil
+}
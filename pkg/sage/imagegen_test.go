@@ -0,0 +1,45 @@
+package sage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_GenerateImages(t *testing.T) {
+	client := setupTestClient(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"b64_json": "iVBORw0KGgo="}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("draw", Profile{Provider: "openai", Account: "default", Model: "gpt-image-1"})
+
+	resp, err := client.GenerateImages("draw", ImageGenerationRequest{Prompt: "a lighthouse at dusk"})
+	if err != nil {
+		t.Fatalf("GenerateImages() error = %v", err)
+	}
+	if len(resp.Images) != 1 || resp.Images[0].MediaType != "image/png" || resp.Model != "gpt-image-1" {
+		t.Errorf("resp = %+v", resp)
+	}
+
+	if _, err := client.GenerateImages("draw", ImageGenerationRequest{}); err == nil {
+		t.Error("GenerateImages() should reject an empty prompt")
+	}
+}
+
+func TestClient_GenerateImages_Unsupported(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-5-haiku-latest"})
+
+	if _, err := client.GenerateImages("claude", ImageGenerationRequest{Prompt: "a cat"}); err == nil {
+		t.Error("GenerateImages() should fail for a provider without image generation")
+	}
+}
@@ -0,0 +1,222 @@
+package sage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// keyUsedInterval is how often a client records that it used a key. Last
+// use is informational, so busy clients don't need to write it per request.
+const keyUsedInterval = time.Hour
+
+// keyMeta records when a stored API key was added and last used. Kept in
+// keys.json beside secrets.enc, since timestamps aren't secret and last use
+// changes too often to re-encrypt the secrets for.
+type keyMeta struct {
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// SecretInfo describes where a provider account's API key comes from.
+type SecretInfo struct {
+	Provider string
+	Account  string
+
+	// Source is "stored" (secrets.enc), "command" (api_key_cmd) or "env",
+	// with EnvVar naming the variable.
+	Source string
+	EnvVar string
+
+	Keys []KeyInfo // Stored keys, primary first
+}
+
+// KeyInfo describes a stored API key without revealing it.
+type KeyInfo struct {
+	Masked   string    // e.g. "sk-...abcd"
+	Created  time.Time // Zero if stored before sage recorded it
+	LastUsed time.Time // Zero if not used since; updated at most hourly
+}
+
+// MaskKey returns key with all but its first three and last four characters
+// hidden, e.g. "sk-...abcd". Short keys are hidden entirely.
+func MaskKey(key string) string {
+	if len(key) < 12 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:3] + "..." + key[len(key)-4:]
+}
+
+// ListSecrets describes the API key of every configured provider account,
+// sorted by provider and account.
+func (c *Client) ListSecrets() []SecretInfo {
+	meta, _ := c.loadKeyMeta()
+
+	var infos []SecretInfo
+	for name, p := range c.config.Providers {
+		for _, account := range p.Accounts {
+			info := SecretInfo{Provider: name, Account: account}
+			for i, key := range c.apiKeys(name, account) {
+				m := meta[keySecretID(name, account, i)]
+				info.Keys = append(info.Keys, KeyInfo{Masked: MaskKey(key), Created: m.Created, LastUsed: m.LastUsed})
+			}
+			switch {
+			case len(info.Keys) > 0:
+				info.Source = "stored"
+			case p.APIKeyCmd[account] != "":
+				info.Source = "command"
+			default:
+				info.Source = "env"
+				info.EnvVar = p.APIKeyEnv[account]
+				if info.EnvVar == "" {
+					info.EnvVar = APIKeyEnv(name)
+				}
+			}
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Provider != infos[j].Provider {
+			return infos[i].Provider < infos[j].Provider
+		}
+		return infos[i].Account < infos[j].Account
+	})
+	return infos
+}
+
+// DeleteProviderKeys removes an account's stored API keys but keeps the
+// account, which then uses its api_key_cmd or environment variable.
+func (c *Client) DeleteProviderKeys(providerName, account string) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+	if len(c.apiKeys(providerName, account)) == 0 {
+		return fmt.Errorf("no stored key for %s", secretKey(providerName, account))
+	}
+	c.removeAPIKeys(providerName, account)
+	return c.saveSecrets()
+}
+
+// keySecretID returns the secrets map key of an account's ith key (from 0).
+func keySecretID(providerName, account string, i int) string {
+	if i == 0 {
+		return secretKey(providerName, account)
+	}
+	return extraSecretKey(providerName, account, i+1)
+}
+
+// touchKey records that a client used an account's ith key, at most once per
+// keyUsedInterval. Failures are ignored: last use is informational.
+func (c *Client) touchKey(providerName, account string, i int) {
+	if c.inMemory || c.config.IsReadOnly() {
+		return
+	}
+	id := keySecretID(providerName, account, i)
+
+	now := time.Now()
+	c.mu.Lock()
+	if last, ok := c.keyUsed[id]; ok && now.Sub(last) < keyUsedInterval {
+		c.mu.Unlock()
+		return
+	}
+	if c.keyUsed == nil {
+		c.keyUsed = make(map[string]time.Time)
+	}
+	c.keyUsed[id] = now
+	c.mu.Unlock()
+
+	unlock, err := lockConfigDir()
+	if err != nil {
+		return
+	}
+	defer unlock()
+	meta, err := c.loadKeyMeta()
+	if err != nil {
+		return
+	}
+	m := meta[id]
+	m.LastUsed = now
+	meta[id] = m
+	saveKeyMeta(meta)
+}
+
+// keyMetaPath returns the path to keys.json.
+func keyMetaPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "keys.json"), nil
+}
+
+// loadKeyMeta reads keys.json, which in-memory clients don't have.
+func (c *Client) loadKeyMeta() (map[string]keyMeta, error) {
+	if c.inMemory {
+		return make(map[string]keyMeta), nil
+	}
+	return loadKeyMeta()
+}
+
+// loadKeyMeta reads keys.json, returning an empty map if it doesn't exist.
+func loadKeyMeta() (map[string]keyMeta, error) {
+	meta := make(map[string]keyMeta)
+	path, err := keyMetaPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return meta, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read key metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid key metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// saveKeyMeta writes keys.json. The caller holds the config directory lock.
+func saveKeyMeta(meta map[string]keyMeta) error {
+	path, err := keyMetaPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// recordKeyChanges updates key metadata for the secrets that changed from
+// before to after: changed keys get a new created time, removed ones lose
+// their metadata. The caller holds the config directory lock.
+func recordKeyChanges(before, after map[string]string) {
+	meta, err := loadKeyMeta()
+	if err != nil {
+		return
+	}
+	changed := false
+	now := time.Now()
+	for k, v := range after {
+		if old, ok := before[k]; !ok || old != v {
+			meta[k] = keyMeta{Created: now}
+			changed = true
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			delete(meta, k)
+			changed = true
+		}
+	}
+	if changed {
+		saveKeyMeta(meta)
+	}
+}
@@ -0,0 +1,61 @@
+package sage
+
+import "testing"
+
+func TestMaskKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"sk-proj-abcdefghijklmnop1234", "sk-...1234"},
+		{"short", "*****"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MaskKey(tt.key); got != tt.want {
+			t.Errorf("MaskKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestClient_ListSecrets(t *testing.T) {
+	client := setupTestClient(t)
+	client.AddProviderAccount("openai", "default", "sk-proj-abcdefghijklmnop1234")
+	client.AddProviderKey("openai", "default", "sk-proj-zyxwvutsrqponmlk5678")
+	client.AddProviderAccountCmd("anthropic", "vault", "pass show anthropic")
+	client.AddProviderAccountEnv("openai", "ci", "")
+
+	infos := client.ListSecrets()
+	if len(infos) != 3 {
+		t.Fatalf("ListSecrets() returned %d accounts, want 3", len(infos))
+	}
+	if infos[0].Account != "vault" || infos[0].Source != "command" {
+		t.Errorf("anthropic:vault = %+v, want source command", infos[0])
+	}
+	if infos[1].Account != "ci" || infos[1].Source != "env" || infos[1].EnvVar != "OPENAI_API_KEY" {
+		t.Errorf("openai:ci = %+v, want $OPENAI_API_KEY", infos[1])
+	}
+	stored := infos[2]
+	if stored.Source != "stored" || len(stored.Keys) != 2 || stored.Keys[1].Masked != "sk-...5678" {
+		t.Fatalf("openai:default = %+v, want two masked stored keys", stored)
+	}
+	if stored.Keys[0].Created.IsZero() || !stored.Keys[0].LastUsed.IsZero() {
+		t.Errorf("key before use = %+v, want created and never used", stored.Keys[0])
+	}
+
+	client.selectAPIKey("openai", "default")
+	if k := client.ListSecrets()[2].Keys[0]; k.LastUsed.IsZero() {
+		t.Error("LastUsed not recorded after use")
+	}
+
+	// Deleting the keys keeps the account, now on its environment variable
+	if err := client.DeleteProviderKeys("openai", "default"); err != nil {
+		t.Fatalf("DeleteProviderKeys() error = %v", err)
+	}
+	if info := client.ListSecrets()[2]; info.Source != "env" || len(info.Keys) != 0 {
+		t.Errorf("openai:default after delete = %+v, want env", info)
+	}
+	if err := client.DeleteProviderKeys("openai", "default"); err == nil {
+		t.Error("DeleteProviderKeys() with no stored keys should error")
+	}
+}
@@ -0,0 +1,279 @@
+package sage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// apiKeys returns all API keys stored for a provider account, primary first.
+func (c *Client) apiKeys(providerName, account string) []string {
+	var keys []string
+	if k, ok := c.secrets[secretKey(providerName, account)]; ok {
+		keys = append(keys, k)
+	}
+	for n := 2; ; n++ {
+		k, ok := c.secrets[extraSecretKey(providerName, account, n)]
+		if !ok {
+			break
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// selectAPIKey returns the key to use for the next request to an account.
+// With RotateKeys enabled, successive calls cycle through the account's keys;
+// otherwise the current key is reused until it gets rate limited.
+func (c *Client) selectAPIKey(providerName, account string) (string, error) {
+	return c.nextAPIKey(providerName, account, true)
+}
+
+// peekAPIKey returns the key the next request to an account would use,
+// without advancing RotateKeys or recording the key as used. It's for
+// checks made before a request, which shouldn't count as one.
+func (c *Client) peekAPIKey(providerName, account string) (string, error) {
+	return c.nextAPIKey(providerName, account, false)
+}
+
+// nextAPIKey returns the current key for an account. If advance is set,
+// rotation moves on and the key's use is recorded.
+func (c *Client) nextAPIKey(providerName, account string, advance bool) (string, error) {
+	keys := c.apiKeys(providerName, account)
+	if len(keys) == 0 {
+		if command := c.config.Providers[providerName].APIKeyCmd[account]; command != "" {
+			return c.cmdAPIKey(command)
+		}
+		return c.envAPIKey(providerName, account), nil
+	}
+
+	c.mu.Lock()
+	id := secretKey(providerName, account)
+	i := c.keyIndex[id] % len(keys)
+	if advance && c.config.Providers[providerName].RotateKeys {
+		c.keyIndex[id] = i + 1
+	}
+	c.mu.Unlock()
+
+	if advance {
+		c.touchKey(providerName, account, i)
+	}
+	return keys[i], nil
+}
+
+const (
+	// keyCmdTTL is how long an api_key_cmd's output is reused before the
+	// command is run again, so password managers aren't asked on every
+	// request but rotated keys are still picked up.
+	keyCmdTTL = 5 * time.Minute
+
+	// keyCmdTimeout bounds an api_key_cmd run, leaving time for password
+	// managers that ask the user to unlock them.
+	keyCmdTimeout = time.Minute
+)
+
+// cmdKey is an API key printed by an api_key_cmd.
+type cmdKey struct {
+	key     string
+	expires time.Time
+}
+
+// cmdAPIKey returns the API key printed by command, running it through the
+// shell unless its output from the last keyCmdTTL is cached.
+func (c *Client) cmdAPIKey(command string) (string, error) {
+	c.mu.Lock()
+	cached, ok := c.cmdKeys[command]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyCmdTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := shellCommand(ctx, command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("api_key_cmd failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("api_key_cmd failed: %w", err)
+	}
+	key := strings.TrimSpace(stdout.String())
+	if key == "" {
+		return "", errors.New("api_key_cmd printed no API key")
+	}
+
+	c.mu.Lock()
+	if c.cmdKeys == nil {
+		c.cmdKeys = make(map[string]cmdKey)
+	}
+	c.cmdKeys[command] = cmdKey{key: key, expires: time.Now().Add(keyCmdTTL)}
+	c.mu.Unlock()
+	return key, nil
+}
+
+// envAPIKey returns the API key for an account with no stored key: the
+// variable named by the provider's api_key_env for the account, or else the
+// provider's conventional variable, e.g. OPENAI_API_KEY.
+func (c *Client) envAPIKey(providerName, account string) string {
+	if name := c.config.Providers[providerName].APIKeyEnv[account]; name != "" {
+		return os.Getenv(name)
+	}
+	return os.Getenv(APIKeyEnv(providerName))
+}
+
+// APIKeyEnv returns the conventional environment variable holding a
+// provider's API key, used for accounts with no stored key and no
+// api_key_env, e.g. OPENAI_API_KEY or AZURE_OPENAI_API_KEY.
+func APIKeyEnv(providerName string) string {
+	if providerName == "replicate" {
+		return "REPLICATE_API_TOKEN"
+	}
+	return strings.ToUpper(strings.ReplaceAll(providerName, "-", "_")) + "_API_KEY"
+}
+
+// advanceAPIKey moves an account past its current key and returns the next one.
+func (c *Client) advanceAPIKey(providerName, account, current string) string {
+	keys := c.apiKeys(providerName, account)
+	if len(keys) == 0 {
+		return ""
+	}
+
+	c.mu.Lock()
+	id := secretKey(providerName, account)
+	for i, k := range keys {
+		if k == current {
+			c.keyIndex[id] = i + 1
+			break
+		}
+	}
+	i := c.keyIndex[id] % len(keys)
+	c.mu.Unlock()
+
+	c.touchKey(providerName, account, i)
+	return keys[i]
+}
+
+// withKeyFailover calls fn, retrying with each of the profile account's other
+// API keys, set in *apiKey, while the provider reports rate limiting.
+func (c *Client) withKeyFailover(profile *Profile, apiKey *string, fn func() error) error {
+	keys := c.apiKeys(profile.Provider, profile.Account)
+
+	err := fn()
+	for i := 1; i < len(keys) && errors.Is(err, providers.ErrRateLimited); i++ {
+		*apiKey = c.advanceAPIKey(profile.Provider, profile.Account, *apiKey)
+		err = fn()
+	}
+	return err
+}
+
+// AddProviderKey stores an additional API key for an existing provider account.
+// Requests fail over to the next key when one is rate limited, and rotate
+// through all keys if the provider has RotateKeys enabled.
+func (c *Client) AddProviderKey(providerName, account, apiKey string) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+	if !c.HasProviderAccount(providerName, account) {
+		return fmt.Errorf("account not found: %s:%s", providerName, account)
+	}
+
+	for _, k := range c.apiKeys(providerName, account) {
+		if k == apiKey {
+			return nil // Already stored
+		}
+	}
+
+	n := len(c.apiKeys(providerName, account)) + 1
+	if n == 1 {
+		c.secrets[secretKey(providerName, account)] = apiKey
+	} else {
+		c.secrets[extraSecretKey(providerName, account, n)] = apiKey
+	}
+	return c.saveSecrets()
+}
+
+// AddProviderAccountEnv adds a provider account whose API key is read from
+// the environment variable envVar on every request instead of being stored,
+// for CI and containers without secrets.enc. An empty envVar uses the
+// provider's conventional variable (APIKeyEnv). Any stored keys for an
+// existing account are removed.
+func (c *Client) AddProviderAccountEnv(providerName, account, envVar string) error {
+	return c.addUnstoredAccount(providerName, account, func(p *ProviderConfig) {
+		p.APIKeyEnv = setAccountValue(p.APIKeyEnv, account, envVar)
+	})
+}
+
+// AddProviderAccountCmd adds a provider account whose API key is the output
+// of a shell command, such as "op read op://vault/openai/key" or "pass show
+// openai", so keys can stay in a password manager instead of secrets.enc.
+// The output is reused for a few minutes before the command runs again. Any
+// stored keys for an existing account are removed.
+func (c *Client) AddProviderAccountCmd(providerName, account, command string) error {
+	if command == "" {
+		return errors.New("api_key_cmd required")
+	}
+	return c.addUnstoredAccount(providerName, account, func(p *ProviderConfig) {
+		p.APIKeyCmd = setAccountValue(p.APIKeyCmd, account, command)
+		delete(p.APIKeyEnv, account)
+	})
+}
+
+// addUnstoredAccount adds a provider account whose key comes from outside
+// sage's store, as configured by set, and removes any keys stored for it.
+func (c *Client) addUnstoredAccount(providerName, account string, set func(*ProviderConfig)) error {
+	if c.config.IsReadOnly() {
+		return ErrReadOnly
+	}
+	if !providers.Exists(providerName) {
+		return fmt.Errorf("unknown provider: %s", providerName)
+	}
+
+	providerConfig := c.config.Providers[providerName]
+	if !containsString(providerConfig.Accounts, account) {
+		providerConfig.Accounts = append(providerConfig.Accounts, account)
+	}
+	delete(providerConfig.APIKeyCmd, account)
+	set(&providerConfig)
+	c.config.Providers[providerName] = providerConfig
+
+	if err := c.saveConfig(); err != nil {
+		return err
+	}
+	if len(c.apiKeys(providerName, account)) == 0 {
+		return nil // Nothing stored, so no secrets to save
+	}
+	c.removeAPIKeys(providerName, account)
+	return c.saveSecrets()
+}
+
+// setAccountValue sets m[account] to value, or deletes it if value is empty,
+// creating m if needed.
+func setAccountValue(m map[string]string, account, value string) map[string]string {
+	if value == "" {
+		delete(m, account)
+		return m
+	}
+	if m == nil {
+		m = make(map[string]string)
+	}
+	m[account] = value
+	return m
+}
+
+// removeAPIKeys deletes all stored keys for a provider account.
+func (c *Client) removeAPIKeys(providerName, account string) {
+	n := len(c.apiKeys(providerName, account))
+	delete(c.secrets, secretKey(providerName, account))
+	for i := 2; i <= n; i++ {
+		delete(c.secrets, extraSecretKey(providerName, account, i))
+	}
+}
@@ -0,0 +1,246 @@
+package sage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestClient_AddProviderKey(t *testing.T) {
+	client := setupTestClient(t)
+
+	if err := client.AddProviderKey("openai", "default", "sk-2"); err == nil {
+		t.Error("AddProviderKey() for missing account should error")
+	}
+
+	client.AddProviderAccount("openai", "default", "sk-1")
+	if err := client.AddProviderKey("openai", "default", "sk-2"); err != nil {
+		t.Fatalf("AddProviderKey() error = %v", err)
+	}
+	client.AddProviderKey("openai", "default", "sk-2") // Duplicate is ignored
+
+	keys := client.apiKeys("openai", "default")
+	if len(keys) != 2 || keys[0] != "sk-1" || keys[1] != "sk-2" {
+		t.Errorf("apiKeys() = %v, want [sk-1 sk-2]", keys)
+	}
+
+	// Keys persist
+	secrets, _ := LoadSecrets()
+	if secrets["openai:default#2"] != "sk-2" {
+		t.Errorf("second key not saved: %v", secrets)
+	}
+
+	// Removing the account removes every key
+	client.RemoveProviderAccount("openai", "default")
+	if keys := client.apiKeys("openai", "default"); len(keys) != 0 {
+		t.Errorf("apiKeys() after remove = %v, want none", keys)
+	}
+}
+
+func TestClient_SelectAPIKey_Rotation(t *testing.T) {
+	client := setupTestClient(t)
+	client.AddProviderAccount("openai", "default", "sk-1")
+	client.AddProviderKey("openai", "default", "sk-2")
+
+	// Without rotation, the current key is reused
+	for i := 0; i < 3; i++ {
+		if got, _ := client.selectAPIKey("openai", "default"); got != "sk-1" {
+			t.Errorf("selectAPIKey() = %q, want sk-1", got)
+		}
+	}
+
+	// With rotation, keys are cycled
+	cfg := client.config.Providers["openai"]
+	cfg.RotateKeys = true
+	client.config.Providers["openai"] = cfg
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		key, _ := client.selectAPIKey("openai", "default")
+		got = append(got, key)
+	}
+	want := []string{"sk-1", "sk-2", "sk-1", "sk-2"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("rotation = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestClient_SelectAPIKey_EnvFallback(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-env")
+	t.Setenv("CI_OPENAI_KEY", "sk-ci")
+	client, _ := NewClientWith(&Config{
+		Providers: map[string]ProviderConfig{
+			"openai": {
+				Accounts:  []string{"default", "ci", "stored"},
+				APIKeyEnv: map[string]string{"ci": "CI_OPENAI_KEY"},
+			},
+		},
+	}, map[string]string{"openai:stored": "sk-stored"})
+
+	tests := []struct {
+		account string
+		want    string
+	}{
+		{"default", "sk-env"},   // Conventional variable
+		{"ci", "sk-ci"},         // Account's api_key_env
+		{"stored", "sk-stored"}, // Stored keys take precedence
+	}
+	for _, tt := range tests {
+		if got, _ := client.selectAPIKey("openai", tt.account); got != tt.want {
+			t.Errorf("selectAPIKey(openai, %s) = %q, want %q", tt.account, got, tt.want)
+		}
+	}
+
+	// Switching an account to the environment drops its stored key
+	if err := client.AddProviderAccountEnv("openai", "stored", "CI_OPENAI_KEY"); err != nil {
+		t.Fatalf("AddProviderAccountEnv() error = %v", err)
+	}
+	if got, _ := client.selectAPIKey("openai", "stored"); got != "sk-ci" {
+		t.Errorf("selectAPIKey(openai, stored) = %q, want sk-ci", got)
+	}
+
+	if got := APIKeyEnv("azure-openai"); got != "AZURE_OPENAI_API_KEY" {
+		t.Errorf("APIKeyEnv(azure-openai) = %q", got)
+	}
+}
+
+func TestClient_ListModels_EnvKey(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-env")
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"data": [{"id": "gpt-4o"}]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClientWith(&Config{
+		Providers: map[string]ProviderConfig{
+			"openai": {Accounts: []string{"default"}, BaseURL: server.URL},
+		},
+	}, nil)
+	if _, err := client.ListModels("openai", ""); err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+	if gotAuth != "Bearer sk-env" {
+		t.Errorf("Authorization = %q, want the key from OPENAI_API_KEY", gotAuth)
+	}
+}
+
+func TestClient_SelectAPIKey_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands use sh")
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-env")
+	client, _ := NewClientWith(nil, nil)
+	counter := filepath.Join(t.TempDir(), "runs")
+	client.AddProviderAccountCmd("openai", "default", "echo run >> "+counter+"; echo '  sk-cmd  '")
+	client.AddProviderAccountCmd("openai", "broken", "echo 'vault is locked' >&2; exit 1")
+
+	// The output is trimmed and reused rather than running the command again
+	for i := 0; i < 2; i++ {
+		if got, err := client.selectAPIKey("openai", "default"); err != nil || got != "sk-cmd" {
+			t.Errorf("selectAPIKey() = %q, %v; want sk-cmd", got, err)
+		}
+	}
+	if runs, _ := os.ReadFile(counter); strings.Count(string(runs), "run") != 1 {
+		t.Errorf("api_key_cmd ran %d times, want 1", strings.Count(string(runs), "run"))
+	}
+
+	_, err := client.selectAPIKey("openai", "broken")
+	if err == nil || !strings.Contains(err.Error(), "vault is locked") {
+		t.Errorf("selectAPIKey() error = %v, want the command's stderr", err)
+	}
+
+	// Requests fail with the command's error instead of sending no key
+	client.AddProfile("locked", Profile{Provider: "openai", Account: "broken", Model: "gpt-4o"})
+	if _, err := client.Complete(context.Background(), "locked", Request{Prompt: "hi"}); err == nil || !strings.Contains(err.Error(), "api_key_cmd") {
+		t.Errorf("Complete() error = %v, want the api_key_cmd failure", err)
+	}
+}
+
+func TestClient_Complete_FailoverOnRateLimit(t *testing.T) {
+	client := setupTestClient(t)
+
+	var used []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Authorization")
+		used = append(used, key)
+		if key == "Bearer sk-limited" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "slow down"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-limited")
+	client.AddProviderKey("openai", "default", "sk-fresh")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	resp, err := client.Complete(context.Background(), "test", Request{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q, want %q", resp.Content, "ok")
+	}
+	if len(used) != 2 || used[1] != "Bearer sk-fresh" {
+		t.Errorf("keys used = %v, want failover to sk-fresh", used)
+	}
+
+	// The working key sticks for the next request
+	used = nil
+	client.Complete(context.Background(), "test", Request{Prompt: "again"})
+	if len(used) != 1 || used[0] != "Bearer sk-fresh" {
+		t.Errorf("keys used = %v, want [Bearer sk-fresh]", used)
+	}
+}
+
+func TestClient_Complete_IdempotencyKeyReusedOnRetry(t *testing.T) {
+	client := setupTestClient(t)
+
+	var keys []string
+	limited := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if limited {
+			limited = false
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-1")
+	client.AddProviderKey("openai", "default", "sk-2")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	if _, err := client.Complete(context.Background(), "test", Request{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Idempotency-Key per attempt = %v, want the same generated key", keys)
+	}
+
+	// Caller-supplied key is used as-is
+	keys = nil
+	client.Complete(context.Background(), "test", Request{Prompt: "hi", IdempotencyKey: "mine"})
+	if len(keys) != 1 || keys[0] != "mine" {
+		t.Errorf("Idempotency-Key = %v, want [mine]", keys)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows && !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !illumos
+
+package sage
+
+import "os"
+
+// lockFile does nothing on platforms without flock, so changes made by
+// concurrent sage processes there aren't serialized.
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package sage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// configMigrations upgrade config file settings between schema versions:
+// configMigrations[i] upgrades version i+1 to i+2, changing the settings as
+// decoded from JSON (or YAML or TOML) in place. Add one whenever a change
+// would misread older files, such as renaming or restructuring a field; the
+// current version is one more than the number of migrations.
+var configMigrations []func(settings map[string]any) error
+
+// currentConfigVersion returns the config schema version this sage writes.
+func currentConfigVersion() int {
+	return 1 + len(configMigrations)
+}
+
+// fileVersion returns the schema version a config file was written for.
+// Files without one predate versioning and are version 1.
+func (c *Config) fileVersion() int {
+	if c.Version == 0 {
+		return 1
+	}
+	return c.Version
+}
+
+// migrateConfig upgrades a config file decoded as cfg from data to the
+// current schema version, returning cfg unchanged if it's already current.
+func migrateConfig(path string, data []byte, cfg *Config) (*Config, error) {
+	version := cfg.fileVersion()
+	current := currentConfigVersion()
+	if version > current {
+		return nil, fmt.Errorf("config version %d is newer than this sage supports (%d): upgrade sage", version, current)
+	}
+	if version == current {
+		return cfg, nil
+	}
+
+	settings, err := decodeConfigSettings(path, data)
+	if err != nil {
+		return nil, err
+	}
+	for v := version; v < current; v++ {
+		if err := configMigrations[v-1](settings); err != nil {
+			return nil, fmt.Errorf("cannot upgrade config from version %d to %d: %w", v, v+1, err)
+		}
+	}
+	settings["version"] = current
+
+	converted, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("cannot upgrade config: %w", err)
+	}
+	var migrated Config
+	if err := json.Unmarshal(converted, &migrated); err != nil {
+		return nil, fmt.Errorf("cannot upgrade config: %w", err)
+	}
+	migrated.migratedFrom = version
+	return &migrated, nil
+}
+
+// decodeConfigSettings decodes a config file to generic JSON values for
+// migrating.
+func decodeConfigSettings(path string, data []byte) (map[string]any, error) {
+	var settings map[string]any
+	format := configFormat(path)
+	if format == "json" {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&settings); err != nil {
+			return nil, fmt.Errorf("invalid config JSON: %w", err)
+		}
+	} else {
+		root, _, err := parseConfigTree(format, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config %s: %w", strings.ToUpper(format), err)
+		}
+		settings, _ = root.value(reflect.TypeOf(Config{})).(map[string]any)
+	}
+	if settings == nil {
+		settings = make(map[string]any)
+	}
+	return settings, nil
+}
+
+// upgradeConfigFile migrates the user config file at path to the current
+// schema version in place, first copying it to path.v<version>.bak.
+func upgradeConfigFile(path string) error {
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cfg, err := decodeConfig(path, old)
+	if err != nil {
+		return err
+	}
+	version := cfg.fileVersion()
+	if cfg, err = migrateConfig(path, old, cfg); err != nil || cfg.migratedFrom == 0 {
+		return err
+	}
+	cfg.initMaps()
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if _, err := os.Stat(backup); os.IsNotExist(err) {
+		if err := writeFileAtomic(backup, old, 0644); err != nil {
+			return fmt.Errorf("cannot back up config: %w", err)
+		}
+	}
+	data, err := encodeConfig(path, cfg, old)
+	if err != nil {
+		return fmt.Errorf("cannot marshal config: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("cannot write config: %w", err)
+	}
+	return nil
+}
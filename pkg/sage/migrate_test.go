@@ -0,0 +1,117 @@
+package sage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// renameModelField is a version 1 to 2 migration for tests: profiles'
+// model_name becomes model.
+func renameModelField(settings map[string]any) error {
+	profiles, _ := settings["profiles"].(map[string]any)
+	for _, p := range profiles {
+		if p, ok := p.(map[string]any); ok {
+			if name, ok := p["model_name"]; ok {
+				p["model"] = name
+				delete(p, "model_name")
+			}
+		}
+	}
+	return nil
+}
+
+func withMigrations(t *testing.T, migrations ...func(map[string]any) error) {
+	old := configMigrations
+	configMigrations = migrations
+	t.Cleanup(func() { configMigrations = old })
+}
+
+func TestLoadConfig_Migrates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	withMigrations(t, renameModelField)
+
+	dir, _ := ConfigDir()
+	path := filepath.Join(dir, "config.yaml")
+	old := "# Local models\nprofiles:\n  local:\n    provider: ollama\n    model_name: llama3 # fast\n"
+	os.WriteFile(path, []byte(old), 0644)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if got := cfg.Profiles["local"].Model; got != "llama3" {
+		t.Errorf("migrated model = %q, want llama3", got)
+	}
+
+	backup, err := os.ReadFile(path + ".v1.bak")
+	if err != nil || string(backup) != old {
+		t.Errorf("backup = %q, %v, want the old file", backup, err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "version: 2") || !strings.Contains(string(data), "# Local models") ||
+		strings.Contains(string(data), "model_name") {
+		t.Errorf("config.yaml after upgrade = %q, want version 2 with comments kept", data)
+	}
+
+	// Upgraded files load as they are
+	if cfg, err := LoadConfig(); err != nil || cfg.Profiles["local"].Model != "llama3" {
+		t.Errorf("LoadConfig() after upgrade = %+v, %v", cfg.Profiles, err)
+	}
+}
+
+func TestLoadConfig_MigratesInMemoryWhenReadOnly(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SAGE_READ_ONLY", "1")
+	withMigrations(t, renameModelField)
+
+	path, _ := ConfigPath()
+	old := `{"profiles": {"local": {"provider": "ollama", "model_name": "llama3"}}}`
+	os.WriteFile(path, []byte(old), 0644)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if got := cfg.Profiles["local"].Model; got != "llama3" {
+		t.Errorf("migrated model = %q, want llama3", got)
+	}
+	if data, _ := os.ReadFile(path); string(data) != old {
+		t.Errorf("read-only config.json rewritten: %q", data)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); err == nil {
+		t.Error("read-only config backed up")
+	}
+}
+
+func TestLoadConfig_NewerVersion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	path, _ := ConfigPath()
+	os.WriteFile(path, []byte(`{"version": 99}`), 0644)
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "upgrade sage") {
+		t.Errorf("LoadConfig() error = %v, want upgrade sage", err)
+	}
+}
+
+func TestConfig_SaveSetsVersion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	err := UpdateConfig(func(cfg *Config) error {
+		cfg.DefaultProfile = "fast"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateConfig() error = %v", err)
+	}
+	cfg, _ := LoadConfig()
+	path, _ := ConfigPath()
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"version": 1`) {
+		t.Errorf("config.json = %s, want version 1", data)
+	}
+	if cfg.DefaultProfile != "fast" {
+		t.Errorf("DefaultProfile = %q, want fast", cfg.DefaultProfile)
+	}
+}
@@ -0,0 +1,216 @@
+package sage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// DefaultCatalogURL is where 'sage catalog update' fetches the model catalog.
+const DefaultCatalogURL = "https://raw.githubusercontent.com/not-emily/sage/main/pkg/sage/providers/models.json"
+
+// maxRemapHops bounds successor chains in the model catalog.
+const maxRemapHops = 5
+
+// maxCatalogSize limits how much of a downloaded catalog is read.
+const maxCatalogSize = 4 << 20
+
+// catalogTimeout bounds a catalog download.
+const catalogTimeout = 30 * time.Second
+
+// CatalogPath returns the path to the downloaded model catalog (models.json).
+// When present, it replaces the catalog embedded in the binary.
+func CatalogPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "models.json"), nil
+}
+
+// loadCatalog activates the downloaded model catalog, if there is one and
+// it's newer than the embedded catalog. Otherwise the embedded catalog is
+// used, so an old download can't shadow a newer binary's data. The error
+// reports a catalog that couldn't be used; the embedded one is active then.
+func loadCatalog() error {
+	providers.SetCatalog(nil)
+
+	path, err := CatalogPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read model catalog: %w", err)
+	}
+
+	catalog, err := providers.ParseCatalog(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if catalog.Version > providers.EmbeddedCatalog().Version {
+		providers.SetCatalog(catalog)
+	}
+	return nil
+}
+
+// UpdateCatalog downloads a model catalog from url (DefaultCatalogURL if
+// empty), saves it to CatalogPath, and makes it the active catalog. The
+// download goes through the client's HTTP client (see SetHTTPClient) and
+// gives up after 30 seconds, or when ctx is done.
+func (c *Client) UpdateCatalog(ctx context.Context, url string) (*providers.Catalog, error) {
+	if c.inMemory {
+		return nil, errors.New("client has no config directory: created with NewClientWith")
+	}
+	if c.config.IsReadOnly() {
+		return nil, fmt.Errorf("%w: cannot update model catalog", ErrReadOnly)
+	}
+	if url == "" {
+		url = DefaultCatalogURL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, catalogTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog URL: %w", err)
+	}
+	c.mu.Lock()
+	hc := c.httpClient
+	c.mu.Unlock()
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("catalog download failed: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCatalogSize))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read catalog: %w", err)
+	}
+
+	// Validate before replacing the current catalog
+	catalog, err := providers.ParseCatalog(data)
+	if err != nil {
+		return nil, err
+	}
+	if embedded := providers.EmbeddedCatalog(); catalog.Version < embedded.Version {
+		return nil, fmt.Errorf("catalog version %q is older than the built-in %s", catalog.Version, embedded.Version)
+	}
+
+	path, err := CatalogPath()
+	if err != nil {
+		return nil, err
+	}
+	// Written atomically: a partial models.json would stop every client
+	// from loading
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("cannot write model catalog: %w", err)
+	}
+
+	providers.SetCatalog(catalog)
+	return catalog, nil
+}
+
+// ModelDeprecation describes a deprecated or retired model used by a profile.
+type ModelDeprecation struct {
+	Profile   string
+	Model     string
+	Status    string // "deprecated" or "retired"
+	Successor string
+	Remapped  bool // Requests are sent to Successor instead
+	Override  bool // Model overrides the profile's model, e.g. from --model
+}
+
+func (d *ModelDeprecation) String() string {
+	msg := fmt.Sprintf("profile %q uses %s model %s", d.Profile, d.Status, d.Model)
+	if d.Override {
+		msg = fmt.Sprintf("%s model %s", d.Status, d.Model)
+	}
+	switch {
+	case d.Remapped:
+		msg += fmt.Sprintf("; using %s instead", d.Successor)
+	case d.Successor != "":
+		msg += fmt.Sprintf("; consider %s (or set remap_deprecated)", d.Successor)
+	}
+	return msg
+}
+
+// CheckModel reports whether the model a profile's requests use is
+// deprecated or retired according to the model catalog. model overrides the
+// profile's model, as Request.Model does; pass "" to check the profile's own.
+// Returns nil if the model is current.
+func (c *Client) CheckModel(profileName, model string) (*ModelDeprecation, error) {
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	override := model != ""
+	if !override {
+		model = profile.Model
+	}
+
+	spec, ok := providers.LookupModel(model)
+	if !ok || spec.Status == "" {
+		return nil, nil
+	}
+
+	dep := &ModelDeprecation{
+		Profile:   profile.Name,
+		Model:     model,
+		Status:    spec.Status,
+		Successor: resolveModel(model),
+		Override:  override,
+	}
+	if dep.Successor == model {
+		dep.Successor = ""
+	}
+	dep.Remapped = profile.RemapDeprecated && dep.Successor != ""
+	return dep, nil
+}
+
+// EstimateCost returns the USD cost of usage on model from catalog prices.
+// The second return value is false if the catalog has no pricing for model.
+func EstimateCost(model string, usage Usage) (float64, bool) {
+	spec, ok := providers.LookupModel(model)
+	if !ok || (spec.InputPrice == 0 && spec.OutputPrice == 0) {
+		return 0, false
+	}
+	return spec.Cost(providers.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	}), true
+}
+
+// resolveModel follows the catalog's successor chain for a deprecated model
+// and returns the current replacement, or model itself if there is none.
+func resolveModel(model string) string {
+	for i := 0; i < maxRemapHops; i++ {
+		spec, ok := providers.LookupModel(model)
+		if !ok || spec.Status == "" || spec.Successor == "" {
+			break
+		}
+		model = spec.Successor
+	}
+	return model
+}
@@ -0,0 +1,144 @@
+package sage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+func TestClient_UpdateCatalog(t *testing.T) {
+	client := setupTestClient(t)
+	defer providers.SetCatalog(nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version": "2099-01-01", "models": [{"prefix": "new-model", "context_window": 4096}]}`))
+	}))
+	defer server.Close()
+
+	// The download goes through the client's HTTP client
+	transport := &countingTransport{}
+	client.SetHTTPClient(&http.Client{Transport: transport})
+	catalog, err := client.UpdateCatalog(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("UpdateCatalog() error = %v", err)
+	}
+	if catalog.Version != "2099-01-01" {
+		t.Errorf("Version = %q, want %q", catalog.Version, "2099-01-01")
+	}
+	if transport.requests != 1 {
+		t.Errorf("requests through the client's HTTP client = %d, want 1", transport.requests)
+	}
+
+	if window, ok := ContextWindow("new-model-1"); !ok || window != 4096 {
+		t.Errorf("ContextWindow() = %d, %v; want 4096 from updated catalog", window, ok)
+	}
+
+	// Saved catalog is picked up on load
+	providers.SetCatalog(nil)
+	if err := loadCatalog(); err != nil {
+		t.Fatalf("loadCatalog() error = %v", err)
+	}
+	if providers.ActiveCatalog().Version != "2099-01-01" {
+		t.Errorf("loaded catalog version = %q, want %q", providers.ActiveCatalog().Version, "2099-01-01")
+	}
+}
+
+func TestLoadCatalog_Fallback(t *testing.T) {
+	setupTestClient(t)
+	defer providers.SetCatalog(nil)
+	embedded := providers.EmbeddedCatalog().Version
+
+	// An older download doesn't shadow the embedded catalog
+	path, _ := CatalogPath()
+	os.WriteFile(path, []byte(`{"version": "2000-01-01", "models": [{"prefix": "old-model"}]}`), 0644)
+	if err := loadCatalog(); err != nil {
+		t.Fatalf("loadCatalog() error = %v", err)
+	}
+	if got := providers.ActiveCatalog().Version; got != embedded {
+		t.Errorf("active catalog version = %q, want embedded %q", got, embedded)
+	}
+
+	// A corrupt download is reported, not fatal
+	os.WriteFile(path, []byte(`{"version": `), 0644)
+	client, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() with corrupt catalog error = %v", err)
+	}
+	if client.CatalogError() == nil {
+		t.Error("CatalogError() = nil, want the parse error")
+	}
+	if got := providers.ActiveCatalog().Version; got != embedded {
+		t.Errorf("active catalog version = %q, want embedded %q", got, embedded)
+	}
+}
+
+func TestClient_UpdateCatalog_Invalid(t *testing.T) {
+	client := setupTestClient(t)
+	defer providers.SetCatalog(nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html>not a catalog</html>`))
+	}))
+	defer server.Close()
+
+	if _, err := client.UpdateCatalog(context.Background(), server.URL); err == nil {
+		t.Fatal("UpdateCatalog() with invalid data should error")
+	}
+
+	// Nor an older catalog than the built-in one
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version": "2000-01-01", "models": []}`))
+	}))
+	defer old.Close()
+	if _, err := client.UpdateCatalog(context.Background(), old.URL); err == nil {
+		t.Fatal("UpdateCatalog() with an older catalog should error")
+	}
+
+	// Nothing is written on failure
+	path, _ := CatalogPath()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("invalid catalog was written to disk")
+	}
+
+	// Nor in a read-only config, or when the download is cancelled
+	configPath, _ := ConfigPath()
+	os.WriteFile(configPath, []byte(`{"read_only": true}`), 0644)
+	readOnly, _ := NewClient()
+	if _, err := readOnly.UpdateCatalog(context.Background(), server.URL); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("UpdateCatalog() with read_only config error = %v, want ErrReadOnly", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.UpdateCatalog(ctx, server.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("UpdateCatalog() with cancelled context error = %v", err)
+	}
+}
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestEstimateCost(t *testing.T) {
+	cost, ok := EstimateCost("gpt-4o", Usage{PromptTokens: 1000000, CompletionTokens: 100000})
+	if !ok {
+		t.Fatal("EstimateCost(gpt-4o) should have pricing")
+	}
+	if cost != 3.5 {
+		t.Errorf("EstimateCost(gpt-4o) = %v, want 3.5", cost)
+	}
+
+	if _, ok := EstimateCost("llama3.2", Usage{PromptTokens: 10}); ok {
+		t.Error("EstimateCost(llama3.2) should have no pricing")
+	}
+}
@@ -0,0 +1,152 @@
+package sage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// ErrFlagged is returned, wrapped with the flagged categories, when a
+// moderation hook rejects a request.
+var ErrFlagged = errors.New("input flagged by moderation")
+
+// ModerationRequest is the input for a moderation call.
+type ModerationRequest struct {
+	Input []string // Texts to classify, one result each
+}
+
+// ModerationResponse is the result of a moderation call.
+type ModerationResponse struct {
+	Results []ModerationResult // One per input, in input order
+	Model   string
+}
+
+// ModerationResult classifies one input.
+type ModerationResult struct {
+	Flagged    bool
+	Categories []string           // Flagged categories, sorted, e.g. "harassment"
+	Scores     map[string]float64 // Every category's score, 0 to 1
+}
+
+// Moderate classifies req.Input as safe or unsafe using the profile's
+// provider and model, which must be a moderation model such as
+// omni-moderation-latest. If profileName is empty, the default profile is
+// used. Failed requests are retried according to the profile's RetryPolicy.
+func (c *Client) Moderate(profileName string, req ModerationRequest) (*ModerationResponse, error) {
+	if len(req.Input) == 0 {
+		return nil, fmt.Errorf("no input to moderate")
+	}
+
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return nil, err
+	}
+	moderator, ok := provider.(providers.Moderator)
+	if !ok || !providers.Supports(provider, providers.CapModeration) {
+		return nil, errIncapable(profile, providers.CapModeration)
+	}
+
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return nil, err
+	}
+	modReq := providers.ModerationRequest{
+		Model:    profile.Model,
+		Input:    req.Input,
+		Endpoint: endpoint,
+	}
+
+	var providerResp *providers.ModerationResponse
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &modReq.APIKey, func() error {
+			providerResp, err = moderator.Moderate(modReq)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ModerationResponse{Model: providerResp.Model}
+	for _, r := range providerResp.Results {
+		result := ModerationResult{Flagged: r.Flagged, Scores: r.Scores}
+		for category, flagged := range r.Categories {
+			if flagged {
+				result.Categories = append(result.Categories, category)
+			}
+		}
+		sort.Strings(result.Categories)
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+// PreSendHook inspects a request before it's sent. Returning an error stops
+// the request, and the error is returned to the caller.
+type PreSendHook func(profileName string, req Request) error
+
+// SetPreSendHook installs a hook that Complete, CompleteStream and
+// BatchRunner call before sending each request, once it has passed the
+// client's own checks. Pass nil to remove it. Set it before sending
+// requests.
+func (c *Client) SetPreSendHook(hook PreSendHook) {
+	c.preSend = hook
+}
+
+// ModerationHook returns a PreSendHook that moderates a request's user
+// input, its Prompt and user Messages, with moderationProfile. Flagged
+// requests fail with an error wrapping ErrFlagged, before any tokens are
+// spent on the completion.
+func (c *Client) ModerationHook(moderationProfile string) PreSendHook {
+	return func(profileName string, req Request) error {
+		var input []string
+		for _, m := range req.Messages {
+			if m.Role == "user" && m.Content != "" {
+				input = append(input, m.Content)
+			}
+		}
+		if req.Prompt != "" {
+			input = append(input, req.Prompt)
+		}
+		if len(input) == 0 {
+			return nil
+		}
+
+		resp, err := c.Moderate(moderationProfile, ModerationRequest{Input: input})
+		if err != nil {
+			return fmt.Errorf("moderation failed: %w", err)
+		}
+		flagged := false
+		var categories []string
+		for _, r := range resp.Results {
+			if !r.Flagged {
+				continue
+			}
+			flagged = true
+			for _, category := range r.Categories {
+				if !containsString(categories, category) {
+					categories = append(categories, category)
+				}
+			}
+		}
+		if !flagged {
+			return nil
+		}
+		if len(categories) == 0 {
+			return ErrFlagged
+		}
+		return fmt.Errorf("%w: %s", ErrFlagged, strings.Join(categories, ", "))
+	}
+}
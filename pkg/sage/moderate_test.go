@@ -0,0 +1,107 @@
+package sage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// moderationServer flags inputs containing "attack" and answers chat
+// completions, counting them.
+func moderationServer(t *testing.T, completions *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/moderations":
+			var body struct {
+				Input []string `json:"input"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			var results []string
+			for _, in := range body.Input {
+				flagged := strings.Contains(in, "attack")
+				results = append(results, `{"flagged": `+boolJSON(flagged)+`, "categories": {"violence": `+boolJSON(flagged)+`, "hate": false}, "category_scores": {"violence": 0.9}}`)
+			}
+			w.Write([]byte(`{"model": "omni-moderation-latest", "results": [` + strings.Join(results, ",") + `]}`))
+		default:
+			*completions++
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+		}
+	}))
+}
+
+func boolJSON(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func setupModerationClient(t *testing.T, serverURL string) *Client {
+	t.Helper()
+	client := setupTestClient(t)
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = serverURL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("chat", Profile{Provider: "openai", Account: "default", Model: "gpt-4o-mini"})
+	client.AddProfile("mod", Profile{Provider: "openai", Account: "default", Model: "omni-moderation-latest"})
+	return client
+}
+
+func TestClient_Moderate(t *testing.T) {
+	var completions int
+	server := moderationServer(t, &completions)
+	defer server.Close()
+	client := setupModerationClient(t, server.URL)
+
+	resp, err := client.Moderate("mod", ModerationRequest{Input: []string{"hello", "plan an attack"}})
+	if err != nil {
+		t.Fatalf("Moderate() error = %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Flagged || !resp.Results[1].Flagged {
+		t.Fatalf("Results = %+v", resp.Results)
+	}
+	if got := resp.Results[1].Categories; len(got) != 1 || got[0] != "violence" {
+		t.Errorf("Categories = %v, want only the flagged violence", got)
+	}
+}
+
+func TestClient_ModerationHook(t *testing.T) {
+	var completions int
+	server := moderationServer(t, &completions)
+	defer server.Close()
+	client := setupModerationClient(t, server.URL)
+	client.SetPreSendHook(client.ModerationHook("mod"))
+
+	_, err := client.Complete(context.Background(), "chat", Request{Prompt: "plan an attack"})
+	if !errors.Is(err, ErrFlagged) {
+		t.Fatalf("Complete() error = %v, want ErrFlagged", err)
+	}
+	if !strings.Contains(err.Error(), "violence") {
+		t.Errorf("error = %q, want the flagged category", err)
+	}
+	if completions != 0 {
+		t.Errorf("sent %d completions, want none for flagged input", completions)
+	}
+
+	if _, err := client.Complete(context.Background(), "chat", Request{Prompt: "hello"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if completions != 1 {
+		t.Errorf("sent %d completions, want 1", completions)
+	}
+
+	// Earlier user turns are moderated too
+	_, err = client.CompleteStream(context.Background(), "chat", Request{
+		Messages: []Message{{Role: "user", Content: "plan an attack"}, {Role: "assistant", Content: "no"}},
+		Prompt:   "please",
+	})
+	if !errors.Is(err, ErrFlagged) {
+		t.Errorf("CompleteStream() error = %v, want ErrFlagged", err)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build !windows
+
+package sage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// userConfigBase returns the directory the sage config directory lives in:
+// $XDG_CONFIG_HOME if set, or else ~/.config, except on macOS, where it's
+// ~/Library/Application Support unless an existing ~/.config/sage is kept.
+func userConfigBase() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return xdg, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	base := filepath.Join(home, ".config")
+	if runtime.GOOS == "darwin" {
+		if _, err := os.Stat(filepath.Join(base, "sage")); os.IsNotExist(err) {
+			return filepath.Join(home, "Library", "Application Support"), nil
+		}
+	}
+	return base, nil
+}
+
+// userDataBase returns the directory the sage data directory lives in:
+// $XDG_DATA_HOME if set, or else ~/.local/share.
+func userDataBase() (string, error) {
+	if xdg := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(xdg) {
+		return xdg, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share"), nil
+}
+
+// checkKeyPermissions rejects a master key readable by group or others.
+func checkKeyPermissions(path string, info fs.FileInfo) error {
+	mode := info.Mode().Perm()
+	if mode&0077 != 0 {
+		return fmt.Errorf("master key has insecure permissions %o (should be 600)", mode)
+	}
+	return nil
+}
+
+// shellCommand returns a command that runs command with sh.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,136 @@
+//go:build windows
+
+package sage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// userConfigBase returns the directory the sage config directory lives in
+// (%AppData% on Windows).
+func userConfigBase() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine config directory: %w", err)
+	}
+	return dir, nil
+}
+
+// userDataBase returns the directory the sage data directory lives in:
+// %LocalAppData%.
+func userDataBase() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine data directory: %w", err)
+	}
+	return dir, nil
+}
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procGetNamedSecurityInfoW = advapi32.NewProc("GetNamedSecurityInfoW")
+	procGetAce                = advapi32.NewProc("GetAce")
+
+	kernel32       = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx = kernel32.NewProc("LockFileEx")
+)
+
+const (
+	seFileObject            = 1
+	daclSecurityInformation = 0x4
+	accessAllowedAceType    = 0
+
+	// Access rights that let a trustee read the key
+	fileReadData = 0x1
+	genericAll   = 0x10000000
+	genericRead  = 0x80000000
+)
+
+// broadSIDs are groups that include other users of the machine.
+var broadSIDs = map[string]string{
+	"S-1-1-0":      "Everyone",
+	"S-1-5-11":     "Authenticated Users",
+	"S-1-5-32-545": "Users",
+}
+
+type aclHeader struct {
+	AclRevision byte
+	Sbz1        byte
+	AclSize     uint16
+	AceCount    uint16
+	Sbz2        uint16
+}
+
+type accessAllowedAce struct {
+	AceType  byte
+	AceFlags byte
+	AceSize  uint16
+	Mask     uint32
+	SidStart uint32 // First 4 bytes of the trustee's SID
+}
+
+// checkKeyPermissions rejects a master key whose ACL lets broad groups read
+// it. Windows file modes don't reflect ACLs, so the Unix check can't be used.
+func checkKeyPermissions(path string, info fs.FileInfo) error {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	var dacl *aclHeader
+	var sd syscall.Handle
+	r, _, _ := procGetNamedSecurityInfoW.Call(
+		uintptr(unsafe.Pointer(p)), seFileObject, daclSecurityInformation,
+		0, 0, uintptr(unsafe.Pointer(&dacl)), 0, uintptr(unsafe.Pointer(&sd)))
+	if r != 0 {
+		return fmt.Errorf("cannot read master key permissions: %w", syscall.Errno(r))
+	}
+	defer syscall.LocalFree(sd)
+
+	if dacl == nil {
+		return errors.New("master key has no access control list, so anyone can read it")
+	}
+
+	for i := 0; i < int(dacl.AceCount); i++ {
+		var ace *accessAllowedAce
+		if ok, _, _ := procGetAce.Call(uintptr(unsafe.Pointer(dacl)), uintptr(i), uintptr(unsafe.Pointer(&ace))); ok == 0 {
+			continue
+		}
+		if ace.AceType != accessAllowedAceType || ace.Mask&(fileReadData|genericRead|genericAll) == 0 {
+			continue
+		}
+		sid, err := (*syscall.SID)(unsafe.Pointer(&ace.SidStart)).String()
+		if err != nil {
+			continue
+		}
+		if group, ok := broadSIDs[sid]; ok {
+			return fmt.Errorf("master key is readable by %s (restrict it to your user account)", group)
+		}
+	}
+	return nil
+}
+
+// shellCommand returns a command that runs command with cmd.exe.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
+
+const lockfileExclusiveLock = 0x2
+
+// lockFile blocks until it holds an exclusive lock on f, which is released
+// when f is closed.
+func lockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package sage
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// projectConfigNames are the project config files ProjectConfigPath looks
+// for in each directory.
+var projectConfigNames = []string{".sage.json", ".sage.yaml", ".sage.yml", ".sage.toml"}
+
+// ProjectConfigPath returns the project config file that applies in the
+// current directory: the nearest .sage.json, .sage.yaml, .sage.yml or
+// .sage.toml in it or its ancestors, or "" if there is none.
+// SAGE_PROJECT_CONFIG overrides the search with a path, or "off" to ignore
+// project config files.
+func ProjectConfigPath() (string, error) {
+	switch env := os.Getenv("SAGE_PROJECT_CONFIG"); env {
+	case "":
+	case "off":
+		return "", nil
+	default:
+		return filepath.Abs(env)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", nil
+	}
+	for {
+		var found []string
+		for _, name := range projectConfigNames {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+				found = append(found, name)
+			}
+		}
+		switch len(found) {
+		case 0:
+		case 1:
+			return filepath.Join(dir, found[0]), nil
+		default:
+			return "", fmt.Errorf("found %s in %s: remove all but one", strings.Join(found, " and "), dir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readProjectConfig reads a project config file. Only its profiles and
+// default profile are used, so a repository can't point the user's API keys
+// at another endpoint. system_file paths must be relative and stay inside
+// the file's directory, so a repository can't have the user's files sent
+// to a provider as a system prompt.
+func readProjectConfig(path string) (*Config, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("cannot read project config: %w", err)
+	}
+	file, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &Config{
+		Providers:      make(map[string]ProviderConfig),
+		Profiles:       file.Profiles,
+		DefaultProfile: file.DefaultProfile,
+	}
+	for name, p := range project.Profiles {
+		if p.SystemFile == "" {
+			continue
+		}
+		file, err := projectFile(filepath.Dir(path), p.SystemFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %w", path, name, err)
+		}
+		p.SystemFile = file
+		p.projectDir = filepath.Dir(path)
+		project.Profiles[name] = p
+	}
+	return project, nil
+}
+
+// projectFile resolves a path from a project config against dir, refusing
+// paths that lead outside it, including through symlinks. A symlink can be
+// added or changed later, so containedFile checks again on every read.
+func projectFile(dir, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "~") || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("system_file %q must be a relative path inside the project", name)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Lstat(path); err != nil {
+		return path, nil
+	}
+	if _, err := containedFile(dir, path); err != nil {
+		return "", fmt.Errorf("system_file %q: %w", name, err)
+	}
+	return path, nil
+}
+
+// containedFile returns the real path of file, which must exist and,
+// following symlinks, be inside dir.
+func containedFile(dir, file string) (string, error) {
+	real, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return "", err
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realDir, real); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s links outside the project", file)
+	}
+	return real, nil
+}
+
+// withProject layers the project config at path over c.
+func (c *Config) withProject(path string, project *Config) *Config {
+	cfg := mergeConfig(c, project)
+	cfg.system = c.system
+	cfg.project = project
+	cfg.projectPath = path
+	cfg.belowProject = c
+	return cfg
+}
+
+// withoutProject returns c with the profiles and default profile it has
+// unchanged from the project config replaced by those beneath it, so that
+// saving doesn't copy project settings into the user's config.
+func (c *Config) withoutProject() *Config {
+	if c.project == nil {
+		return c
+	}
+
+	cfg := *c
+	cfg.Profiles = maps.Clone(c.Profiles)
+	for name, p := range c.project.Profiles {
+		if cur, ok := cfg.Profiles[name]; !ok || !reflect.DeepEqual(cur, p) {
+			continue
+		}
+		if below, ok := c.belowProject.Profiles[name]; ok {
+			cfg.Profiles[name] = below
+		} else {
+			delete(cfg.Profiles, name)
+		}
+	}
+	if c.project.DefaultProfile != "" && c.DefaultProfile == c.project.DefaultProfile {
+		cfg.DefaultProfile = c.belowProject.DefaultProfile
+	}
+	return &cfg
+}
+
+// ProjectFile returns the path of the project config layered over c, or ""
+// if there is none.
+func (c *Config) ProjectFile() string {
+	return c.projectPath
+}
+
+// IsProjectProfile reports whether the profile name is set by the project
+// config.
+func (c *Config) IsProjectProfile(name string) bool {
+	if c.project == nil {
+		return false
+	}
+	_, ok := c.project.Profiles[name]
+	return ok
+}
+
+// checkNotProject refuses changes to a profile, or with an empty name to
+// the default profile, that the project config sets: saved to the user's
+// config, they would stay hidden behind it.
+func (c *Config) checkNotProject(name string) error {
+	switch {
+	case name != "" && c.IsProjectProfile(name):
+		return fmt.Errorf("profile %s is set by project config %s: edit that file instead", name, c.projectPath)
+	case name == "" && c.project != nil && c.project.DefaultProfile != "":
+		return fmt.Errorf("default profile is set by project config %s: edit that file instead", c.projectPath)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package sage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectConfigPath(t *testing.T) {
+	project := t.TempDir()
+	sub := filepath.Join(project, "src", "pkg")
+	os.MkdirAll(sub, 0755)
+	os.WriteFile(filepath.Join(project, ".sage.yaml"), []byte("default_profile: local\n"), 0644)
+
+	wd, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(wd) })
+	os.Chdir(sub)
+	t.Setenv("SAGE_PROJECT_CONFIG", "")
+
+	path, err := ProjectConfigPath()
+	if err != nil {
+		t.Fatalf("ProjectConfigPath() error = %v", err)
+	}
+	// The working directory may be reached through a symlink, e.g. on macOS
+	got, _ := filepath.EvalSymlinks(path)
+	want, _ := filepath.EvalSymlinks(filepath.Join(project, ".sage.yaml"))
+	if got != want {
+		t.Errorf("ProjectConfigPath() = %q, want %q", path, want)
+	}
+
+	os.WriteFile(filepath.Join(project, ".sage.json"), []byte("{}"), 0644)
+	if _, err := ProjectConfigPath(); err == nil {
+		t.Error("ProjectConfigPath() with two project files should error")
+	}
+
+	t.Setenv("SAGE_PROJECT_CONFIG", "off")
+	if path, _ := ProjectConfigPath(); path != "" {
+		t.Errorf("ProjectConfigPath() with SAGE_PROJECT_CONFIG=off = %q, want none", path)
+	}
+}
+
+func TestLoadConfig_Project(t *testing.T) {
+	client := setupTestClient(t)
+	client.AddProviderAccount("openai", "default", "sk-test")
+	client.AddProfile("mine", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+	client.SetDefaultProfile("mine")
+
+	project := t.TempDir()
+	path := filepath.Join(project, ".sage.json")
+	os.WriteFile(path, []byte(`{
+		"providers": {"openai": {"accounts": ["default"], "base_url": "https://attacker.example"}},
+		"profiles": {"local": {"provider": "ollama", "account": "default", "model": "llama3", "system_file": "prompts/review.md"}},
+		"default_profile": "local"
+	}`), 0644)
+	t.Setenv("SAGE_PROJECT_CONFIG", path)
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if client.GetDefaultProfile() != "local" || client.ProjectConfigFile() != path {
+		t.Errorf("default = %q from %q, want local from the project", client.GetDefaultProfile(), client.ProjectConfigFile())
+	}
+	local, err := client.GetProfile("local")
+	if err != nil || local.SystemFile != filepath.Join(project, "prompts", "review.md") {
+		t.Errorf("GetProfile(local) = %+v, %v; want system_file in the project", local, err)
+	}
+	if _, err := client.GetProfile("mine"); err != nil {
+		t.Errorf("user profile hidden by project: %v", err)
+	}
+	if client.config.Providers["openai"].BaseURL != "" {
+		t.Error("project config changed a provider's base_url")
+	}
+
+	// Project profiles can't be changed from the CLI, and aren't saved
+	if err := client.AddProfile("local", Profile{Provider: "openai", Model: "gpt-4o"}); err == nil {
+		t.Error("AddProfile() over a project profile should error")
+	}
+	if err := client.SetDefaultProfile("mine"); err == nil {
+		t.Error("SetDefaultProfile() with a project default should error")
+	}
+	if err := client.AddProfile("other", Profile{Provider: "openai", Account: "default", Model: "gpt-4o-mini"}); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+
+	configPath, _ := ConfigPath()
+	data, _ := os.ReadFile(configPath)
+	var saved Config
+	json.Unmarshal(data, &saved)
+	if _, ok := saved.Profiles["local"]; ok || saved.DefaultProfile != "mine" || len(saved.Profiles) != 2 {
+		t.Errorf("saved config = %s, want only the user's profiles and default", data)
+	}
+}
+
+func TestLoadConfig_ProjectSystemFileOutside(t *testing.T) {
+	setupTestClient(t)
+
+	outside := filepath.Join(t.TempDir(), "id_ed25519")
+	os.WriteFile(outside, []byte("secret"), 0600)
+
+	project := t.TempDir()
+	os.Symlink(outside, filepath.Join(project, "prompt.md"))
+	path := filepath.Join(project, ".sage.json")
+	t.Setenv("SAGE_PROJECT_CONFIG", path)
+
+	for _, file := range []string{outside, "~/.ssh/id_ed25519", "../id_ed25519", "prompts/../../x", "prompt.md"} {
+		data, _ := json.Marshal(map[string]any{
+			"profiles": map[string]any{"p": map[string]any{"provider": "ollama", "model": "llama3", "system_file": file}},
+		})
+		os.WriteFile(path, data, 0644)
+		if _, err := NewClient(); err == nil {
+			t.Errorf("NewClient() with project system_file %q should error", file)
+		}
+	}
+}
+
+func TestProfile_ProjectSystemFileLinkedLater(t *testing.T) {
+	setupTestClient(t)
+
+	outside := filepath.Join(t.TempDir(), "id_ed25519")
+	os.WriteFile(outside, []byte("secret"), 0600)
+
+	project := t.TempDir()
+	path := filepath.Join(project, ".sage.json")
+	os.WriteFile(path, []byte(`{"profiles": {"p": {"provider": "ollama", "model": "llama3", "system_file": "prompt.md"}}}`), 0644)
+	t.Setenv("SAGE_PROJECT_CONFIG", path)
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	profile, _ := client.GetProfile("p")
+	if _, err := profile.SystemPrompt(); err == nil {
+		t.Error("SystemPrompt() with a missing file should error")
+	}
+
+	os.WriteFile(filepath.Join(project, "prompt.md"), []byte("Review this"), 0644)
+	if got, err := profile.SystemPrompt(); err != nil || got != "Review this" {
+		t.Errorf("SystemPrompt() = %q, %v; want the project's prompt", got, err)
+	}
+
+	// The file is replaced by a link out of the project after loading
+	os.Remove(filepath.Join(project, "prompt.md"))
+	os.Symlink(outside, filepath.Join(project, "prompt.md"))
+	if got, err := profile.SystemPrompt(); err == nil {
+		t.Errorf("SystemPrompt() through a link outside the project = %q, want an error", got)
+	}
+}
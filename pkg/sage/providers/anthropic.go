@@ -3,10 +3,14 @@ package providers
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -19,7 +23,9 @@ func init() {
 	Register("anthropic", NewAnthropic)
 }
 
-type anthropic struct{}
+type anthropic struct {
+	httpClient
+}
 
 // NewAnthropic creates a new Anthropic provider.
 func NewAnthropic() Provider {
@@ -38,22 +44,110 @@ type anthropicRequest struct {
 	System    string             `json:"system,omitempty"`
 	Messages  []anthropicMessage `json:"messages"`
 	Stream    bool               `json:"stream,omitempty"`
+	Metadata  *anthropicMetadata `json:"metadata,omitempty"`
+
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
 }
 
 type anthropicMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+
+	// Blocks replaces Content in a turn with images
+	Blocks []anthropicBlock `json:"-"`
+}
+
+type anthropicBlock struct {
+	Type   string           `json:"type"`
+	Text   string           `json:"text,omitempty"`
+	Source *anthropicSource `json:"source,omitempty"`
+	Title  string           `json:"title,omitempty"` // Document blocks only
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"` // "base64", "url", or "text" for plain text documents
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// MarshalJSON sends the message's content as a list of blocks when it has
+// any, and as a plain string otherwise.
+func (m anthropicMessage) MarshalJSON() ([]byte, error) {
+	if m.Blocks == nil {
+		type plain anthropicMessage
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		Role    string           `json:"role"`
+		Content []anthropicBlock `json:"content"`
+	}{m.Role, m.Blocks})
 }
 
 type anthropicResponse struct {
-	Content []anthropicContent `json:"content"`
-	Usage   anthropicUsage     `json:"usage"`
-	Error   *anthropicError    `json:"error,omitempty"`
+	Content    []anthropicContent `json:"content"`
+	Usage      anthropicUsage     `json:"usage"`
+	StopReason string             `json:"stop_reason"`
+	Error      *anthropicError    `json:"error,omitempty"`
+}
+
+// anthropicFinishReason normalizes a Messages API stop_reason. Structured
+// output arrives as a forced tool call, which ends the answer rather than
+// asking for a tool.
+func anthropicFinishReason(reason string, req Request) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return FinishStop
+	case "max_tokens":
+		return FinishLength
+	case "tool_use":
+		if req.ResponseFormat != nil {
+			return FinishStop
+		}
+		return FinishToolUse
+	case "refusal":
+		return FinishContentFilter
+	}
+	return reason
+}
+
+// text returns the first text content block, or the input of a tool_use
+// block, which is how forced structured output arrives.
+func (r *anthropicResponse) text() string {
+	for _, c := range r.Content {
+		switch c.Type {
+		case "text":
+			return c.Text
+		case "tool_use":
+			return string(c.Input)
+		}
+	}
+	return ""
 }
 
 type anthropicContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	Input json.RawMessage `json:"input,omitempty"` // tool_use blocks
 }
 
 type anthropicUsage struct {
@@ -68,31 +162,103 @@ type anthropicError struct {
 
 // Streaming types
 type anthropicStreamEvent struct {
-	Type  string                `json:"type"`
-	Delta *anthropicStreamDelta `json:"delta,omitempty"`
+	Type    string                `json:"type"`
+	Delta   *anthropicStreamDelta `json:"delta,omitempty"`
+	Message *anthropicResponse    `json:"message,omitempty"` // message_start
+	Usage   *anthropicUsage       `json:"usage,omitempty"`   // message_delta
+}
+
+// streamUsage tracks token usage across a Messages API event stream.
+// message_start reports the input tokens, and each message_delta the
+// output tokens so far.
+type streamUsage struct {
+	usage Usage
+	seen  bool
+}
+
+// add records the usage an event reports, if any.
+func (s *streamUsage) add(event *anthropicStreamEvent) {
+	switch {
+	case event.Type == "message_start" && event.Message != nil:
+		s.usage.PromptTokens = event.Message.Usage.InputTokens
+		s.usage.CompletionTokens = event.Message.Usage.OutputTokens
+		s.seen = true
+	case event.Type == "message_delta" && event.Usage != nil:
+		s.usage.CompletionTokens = event.Usage.OutputTokens
+		s.seen = true
+	}
+}
+
+// result returns the usage reported, or nil if none was.
+func (s *streamUsage) result() *Usage {
+	if !s.seen {
+		return nil
+	}
+	u := s.usage
+	return &u
 }
 
 type anthropicStreamDelta struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	PartialJSON string `json:"partial_json"` // input_json_delta
+	StopReason  string `json:"stop_reason"`  // message_delta
+}
+
+// content returns the text a delta adds: response text, or a piece of the
+// JSON input of a forced structured-output tool call.
+func (d *anthropicStreamDelta) content() string {
+	switch d.Type {
+	case "text_delta":
+		return d.Text
+	case "input_json_delta":
+		return d.PartialJSON
+	}
+	return ""
 }
 
-func (a *anthropic) Complete(req Request) (*Response, error) {
+func (a *anthropic) Complete(ctx context.Context, req Request) (*Response, error) {
+	if err := requireBasicSampling(req, "anthropic"); err != nil {
+		return nil, err
+	}
+	if err := requireNoLogprobs(req, "anthropic"); err != nil {
+		return nil, err
+	}
+	if err := requireNoReasoningEffort(req, "anthropic"); err != nil {
+		return nil, err
+	}
+	if req.Platform != "" {
+		platform, platformReq, err := a.viaPlatform(req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := platform.Complete(ctx, platformReq)
+		if err != nil {
+			return nil, err
+		}
+		resp.Model = req.Model
+		return resp, nil
+	}
+
 	body := a.buildRequest(req, false)
 
-	jsonBody, err := json.Marshal(body)
+	jsonBody, err := marshalBody(body, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", a.endpoint(req), bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", a.endpoint(req), bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	a.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+	if len(req.Betas) > 0 {
+		httpReq.Header.Set("anthropic-beta", strings.Join(req.Betas, ","))
+	}
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := a.client().Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -111,41 +277,55 @@ func (a *anthropic) Complete(req Request) (*Response, error) {
 		return nil, fmt.Errorf("no content in response")
 	}
 
-	// Extract text from first text content block
-	var content string
-	for _, c := range anthropicResp.Content {
-		if c.Type == "text" {
-			content = c.Text
-			break
-		}
-	}
-
 	return &Response{
-		Content: content,
+		Content: anthropicResp.text(),
 		Model:   req.Model,
 		Usage: Usage{
 			PromptTokens:     anthropicResp.Usage.InputTokens,
 			CompletionTokens: anthropicResp.Usage.OutputTokens,
 		},
+		RequestID:    resp.Header.Get("request-id"),
+		FinishReason: anthropicFinishReason(anthropicResp.StopReason, req),
 	}, nil
 }
 
-func (a *anthropic) CompleteStream(req Request) (<-chan Chunk, error) {
+func (a *anthropic) CompleteStream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	if err := requireBasicSampling(req, "anthropic"); err != nil {
+		return nil, err
+	}
+	if err := requireNoLogprobs(req, "anthropic"); err != nil {
+		return nil, err
+	}
+	if err := requireNoReasoningEffort(req, "anthropic"); err != nil {
+		return nil, err
+	}
+	if req.Platform != "" {
+		platform, platformReq, err := a.viaPlatform(req)
+		if err != nil {
+			return nil, err
+		}
+		return platform.CompleteStream(ctx, platformReq)
+	}
+
 	body := a.buildRequest(req, true)
 
-	jsonBody, err := json.Marshal(body)
+	jsonBody, err := marshalBody(body, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", a.endpoint(req), bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", a.endpoint(req), bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	a.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+	if len(req.Betas) > 0 {
+		httpReq.Header.Set("anthropic-beta", strings.Join(req.Betas, ","))
+	}
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := a.client().Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -155,82 +335,231 @@ func (a *anthropic) CompleteStream(req Request) (<-chan Chunk, error) {
 		return nil, a.handleError(resp)
 	}
 
+	return readAnthropicStream(ctx, resp, req), nil
+}
+
+// readAnthropicStream converts a Messages API event stream into chunks.
+// Vertex serves Claude with the same stream, so it shares this.
+func readAnthropicStream(ctx context.Context, resp *http.Response, req Request) <-chan Chunk {
 	ch := make(chan Chunk)
 
 	go func() {
 		defer close(ch)
 		defer resp.Body.Close()
 
+		idle := newIdleTimer(req.IdleTimeout, resp.Body)
+		defer idle.stop()
+
 		scanner := bufio.NewScanner(resp.Body)
-		var currentEvent string
+		var currentEvent, finish string
+		var usage streamUsage
 
 		for scanner.Scan() {
-			line := scanner.Text()
-
-			// Track event type
-			if strings.HasPrefix(line, "event: ") {
-				currentEvent = strings.TrimPrefix(line, "event: ")
+			idle.reset()
+			field, value := sseField(scanner.Text())
+
+			// Track event type; ping events are keep-alives
+			if field == "event" {
+				currentEvent = value
+				if currentEvent == "ping" && req.OnHeartbeat != nil {
+					req.OnHeartbeat()
+				}
 				continue
 			}
 
-			// Skip empty lines
-			if line == "" {
+			// Keep-alive comments
+			if field == ":" {
+				if req.OnHeartbeat != nil {
+					req.OnHeartbeat()
+				}
 				continue
 			}
 
-			// Parse data line
-			if !strings.HasPrefix(line, "data: ") {
+			// Skip empty lines and other SSE fields
+			if field != "data" {
 				continue
 			}
 
-			data := strings.TrimPrefix(line, "data: ")
-
 			// Handle message_stop event
 			if currentEvent == "message_stop" {
-				ch <- Chunk{Done: true}
+				sendChunk(ctx, ch, Chunk{Done: true, Usage: usage.result(), FinishReason: finish})
 				return
 			}
 
-			// Only process content_block_delta events
-			if currentEvent != "content_block_delta" {
+			// Only process content and usage events
+			switch currentEvent {
+			case "content_block_delta", "message_start", "message_delta":
+			default:
 				continue
 			}
 
 			var event anthropicStreamEvent
-			if err := json.Unmarshal([]byte(data), &event); err != nil {
-				ch <- Chunk{Error: fmt.Errorf("failed to parse stream data: %w", err)}
+			if err := json.Unmarshal([]byte(value), &event); err != nil {
+				sendChunk(ctx, ch, Chunk{Error: idle.streamParseError(scanner, err)})
 				return
 			}
+			usage.add(&event)
+			if currentEvent == "message_delta" && event.Delta != nil && event.Delta.StopReason != "" {
+				finish = anthropicFinishReason(event.Delta.StopReason, req)
+			}
 
-			if event.Delta != nil && event.Delta.Type == "text_delta" && event.Delta.Text != "" {
-				ch <- Chunk{Content: event.Delta.Text}
+			if currentEvent == "content_block_delta" && event.Delta != nil && event.Delta.content() != "" {
+				if !sendChunk(ctx, ch, Chunk{Content: event.Delta.content()}) {
+					return
+				}
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
-			ch <- Chunk{Error: fmt.Errorf("stream read error: %w", err)}
+			sendChunk(ctx, ch, Chunk{Error: idle.readError(err)})
 		}
 	}()
 
-	return ch, nil
+	return ch
 }
 
 func (a *anthropic) buildRequest(req Request, stream bool) anthropicRequest {
-	messages := []anthropicMessage{
-		{Role: "user", Content: req.Prompt},
+	r := anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: anthropicMaxTokens(req),
+		System:    anthropicSystem(req), // Separate field, not in messages
+		Messages:  anthropicMessages(req),
+		Stream:    stream,
+
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
 	}
 
-	maxTokens := req.MaxTokens
-	if maxTokens == 0 {
-		maxTokens = 1024 // Anthropic requires max_tokens
+	if req.User != "" {
+		r.Metadata = &anthropicMetadata{UserID: req.User}
 	}
+	r.Tools, r.ToolChoice = anthropicOutputTool(req)
 
-	return anthropicRequest{
-		Model:     req.Model,
-		MaxTokens: maxTokens,
-		System:    req.System, // Separate field, not in messages
-		Messages:  messages,
-		Stream:    stream,
+	return r
+}
+
+// anthropicOutputTool returns a tool whose input is the requested JSON, and
+// a tool choice forcing Claude to call it, since Claude has no JSON mode.
+func anthropicOutputTool(req Request) ([]anthropicTool, *anthropicToolChoice) {
+	f := req.ResponseFormat
+	if f == nil {
+		return nil, nil
+	}
+	schema := f.Schema
+	if f.Type != "json_schema" {
+		schema = json.RawMessage(`{"type": "object"}`)
+	}
+	tool := anthropicTool{
+		Name:        f.name(),
+		Description: "Respond with JSON matching this schema.",
+		InputSchema: schema,
+	}
+	return []anthropicTool{tool}, &anthropicToolChoice{Type: "tool", Name: tool.Name}
+}
+
+// anthropicSystem returns the system prompt for a Claude request. Claude
+// takes no system turns, so any in the conversation are appended to it.
+func anthropicSystem(req Request) string {
+	parts := []string{}
+	if req.System != "" {
+		parts = append(parts, req.System)
+	}
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			parts = append(parts, m.Content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// anthropicMessages builds the conversation for a Claude request.
+func anthropicMessages(req Request) []anthropicMessage {
+	messages := []anthropicMessage{}
+	for _, m := range conversation(req) {
+		if m.Role != "system" {
+			messages = append(messages, anthropicTurn(m))
+		}
+	}
+
+	// A trailing assistant message is prefill: Claude continues it. It
+	// mustn't end in whitespace.
+	if prefill := strings.TrimRight(req.Continue, " \t\r\n"); prefill != "" {
+		messages = append(messages, anthropicMessage{Role: "assistant", Content: prefill})
+	}
+
+	return messages
+}
+
+// anthropicTurn converts a conversation turn, putting its images ahead of
+// the text as Anthropic recommends.
+func anthropicTurn(m Message) anthropicMessage {
+	msg := anthropicMessage{Role: m.Role, Content: m.Content}
+	if len(m.Images) == 0 && len(m.Documents) == 0 {
+		return msg
+	}
+	for _, doc := range m.Documents {
+		source := &anthropicSource{
+			Type:      "base64",
+			MediaType: doc.MediaType,
+			Data:      base64.StdEncoding.EncodeToString(doc.Data),
+		}
+		if doc.isText() {
+			source = &anthropicSource{Type: "text", MediaType: doc.MediaType, Data: string(doc.Data)}
+		}
+		msg.Blocks = append(msg.Blocks, anthropicBlock{Type: "document", Source: source, Title: doc.Name})
+	}
+	for _, img := range m.Images {
+		source := &anthropicSource{Type: "url", URL: img.URL}
+		if img.URL == "" {
+			source = &anthropicSource{
+				Type:      "base64",
+				MediaType: img.MediaType,
+				Data:      base64.StdEncoding.EncodeToString(img.Data),
+			}
+		}
+		msg.Blocks = append(msg.Blocks, anthropicBlock{Type: "image", Source: source})
+	}
+	msg.Blocks = append(msg.Blocks, anthropicBlock{Type: "text", Text: m.Content})
+	return msg
+}
+
+func anthropicMaxTokens(req Request) int {
+	if req.MaxTokens == 0 {
+		return 1024 // Anthropic requires max_tokens
+	}
+	return req.MaxTokens
+}
+
+// anthropicPlatformRequest is the Messages API body as cloud platforms take
+// it: the model is in the URL and the API version in the body.
+type anthropicPlatformRequest struct {
+	AnthropicVersion string             `json:"anthropic_version"`
+	MaxTokens        int                `json:"max_tokens"`
+	System           string             `json:"system,omitempty"`
+	Messages         []anthropicMessage `json:"messages"`
+	Stream           bool               `json:"stream,omitempty"`
+	AnthropicBeta    []string           `json:"anthropic_beta,omitempty"` // The anthropic-beta header, in the body
+
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+func buildPlatformRequest(req Request, version string, stream bool) anthropicPlatformRequest {
+	tools, toolChoice := anthropicOutputTool(req)
+	return anthropicPlatformRequest{
+		AnthropicVersion: version,
+		MaxTokens:        anthropicMaxTokens(req),
+		System:           anthropicSystem(req),
+		Messages:         anthropicMessages(req),
+		Stream:           stream,
+		AnthropicBeta:    req.Betas,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		Tools:            tools,
+		ToolChoice:       toolChoice,
 	}
 }
 
@@ -254,28 +583,217 @@ func (a *anthropic) handleError(resp *http.Response) error {
 		Error *anthropicError `json:"error"`
 	}
 	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
-		switch resp.StatusCode {
-		case http.StatusUnauthorized:
-			return fmt.Errorf("invalid API key: %s", errResp.Error.Message)
-		case http.StatusTooManyRequests:
-			return fmt.Errorf("rate limited: %s", errResp.Error.Message)
-		default:
-			return fmt.Errorf("API error (%d): %s", resp.StatusCode, errResp.Error.Message)
-		}
+		return classifyStatus(resp, errResp.Error.Type, errResp.Error.Message)
+	}
+	return classifyStatus(resp, "", string(body))
+}
+
+// anthropicCountRequest is the body of a count_tokens request: the parts of
+// a Messages request that take up input tokens.
+type anthropicCountRequest struct {
+	Model      string               `json:"model"`
+	System     string               `json:"system,omitempty"`
+	Messages   []anthropicMessage   `json:"messages"`
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// CountTokens counts a request's input tokens with the count_tokens
+// endpoint, which is free and doesn't run the model.
+func (a *anthropic) CountTokens(req Request) (int, error) {
+	if req.Platform != "" {
+		return 0, errUnsupported("anthropic on "+req.Platform, "token counting")
+	}
+
+	body := anthropicCountRequest{
+		Model:    req.Model,
+		System:   anthropicSystem(req),
+		Messages: anthropicMessages(req),
+	}
+	body.Tools, body.ToolChoice = anthropicOutputTool(req)
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", a.endpoint(req)+"/count_tokens", bytes.NewReader(jsonBody))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	a.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+	if len(req.Betas) > 0 {
+		httpReq.Header.Set("anthropic-beta", strings.Join(req.Betas, ","))
+	}
+
+	resp, err := a.client().Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, a.handleError(resp)
+	}
+
+	var countResp struct {
+		InputTokens int `json:"input_tokens"`
 	}
+	if err := json.NewDecoder(resp.Body).Decode(&countResp); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return countResp.InputTokens, nil
+}
 
-	return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
+// Ping lists models without ListModels' offline fallback, so an unreachable
+// endpoint or rejected key is reported.
+func (a *anthropic) Ping(apiKey, baseURL string) error {
+	_, err := a.fetchModels(apiKey, baseURL)
+	return err
 }
 
-// ListModels returns available Claude models.
-// Anthropic doesn't have a models endpoint, so we return a hardcoded list.
+// ListModels returns available Claude models from the models endpoint.
+// Without an API key, or if the API can't be reached, it falls back on a
+// built-in list of current models.
 func (a *anthropic) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
-	// Hardcoded list of current Claude models
+	if apiKey == "" {
+		return anthropicKnownModels(), nil
+	}
+	models, err := a.fetchModels(apiKey, baseURL)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return anthropicKnownModels(), nil
+	}
+	return models, err
+}
+
+type anthropicModelsResponse struct {
+	Data []struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+	} `json:"data"`
+	HasMore bool   `json:"has_more"`
+	LastID  string `json:"last_id"`
+}
+
+// fetchModels lists models from the models endpoint, following pages.
+func (a *anthropic) fetchModels(apiKey, baseURL string) ([]ModelInfo, error) {
+	endpoint := "https://api.anthropic.com/v1/models"
+	if baseURL != "" {
+		endpoint = strings.TrimSuffix(baseURL, "/") + "/v1/models"
+	}
+
+	var models []ModelInfo
+	afterID := ""
+	for {
+		query := url.Values{"limit": {"1000"}}
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+		req, err := http.NewRequest("GET", endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		a.setHeaders(req, apiKey)
+
+		resp, err := a.client().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return nil, a.handleError(resp)
+		}
+
+		var page anthropicModelsResponse
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		for _, m := range page.Data {
+			models = append(models, ModelInfo{ID: m.ID, Name: m.DisplayName})
+		}
+		if !page.HasMore || page.LastID == "" {
+			return models, nil
+		}
+		afterID = page.LastID
+	}
+}
+
+// anthropicKnownModels is the offline fallback for ListModels.
+func anthropicKnownModels() []ModelInfo {
 	return []ModelInfo{
 		{ID: "claude-opus-4-20250514", Name: "Claude Opus 4", Description: "Most capable model for complex tasks"},
 		{ID: "claude-sonnet-4-20250514", Name: "Claude Sonnet 4", Description: "Balanced performance and speed"},
 		{ID: "claude-3-5-haiku-latest", Name: "Claude 3.5 Haiku", Description: "Fast and efficient for simple tasks"},
 		{ID: "claude-3-5-sonnet-latest", Name: "Claude 3.5 Sonnet", Description: "Previous generation balanced model"},
 		{ID: "claude-3-opus-latest", Name: "Claude 3 Opus", Description: "Previous generation top model"},
-	}, nil
+	}
+}
+
+// viaPlatform returns the cloud platform provider for a request routed
+// through one, and the request with its model translated to the platform's
+// ID for the same Claude model.
+func (a *anthropic) viaPlatform(req Request) (Provider, Request, error) {
+	var platform Provider
+	var model string
+	var err error
+	switch req.Platform {
+	case "bedrock":
+		platform = NewBedrock()
+		model, err = bedrockClaudeModel(req.Model)
+	case "vertex":
+		platform = NewVertex()
+		model, err = vertexClaudeModel(req.Model)
+	default:
+		return nil, req, fmt.Errorf("unknown anthropic platform %q (want bedrock or vertex)", req.Platform)
+	}
+	if err != nil {
+		return nil, req, err
+	}
+
+	req.Model = model
+	return platform, req, nil
+}
+
+// bedrockClaudeVersions lists Bedrock model versions other than v1:0.
+var bedrockClaudeVersions = map[string]string{
+	"claude-3-5-sonnet-20241022": "v2:0",
+}
+
+// bedrockClaudeModel maps an Anthropic model ID to its Bedrock ID, e.g.
+// claude-sonnet-4-20250514 to anthropic.claude-sonnet-4-20250514-v1:0.
+// Bedrock IDs and inference profiles pass through.
+func bedrockClaudeModel(model string) (string, error) {
+	if strings.Contains(model, "anthropic.") {
+		return model, nil
+	}
+	if strings.HasSuffix(model, "-latest") {
+		return "", fmt.Errorf("model alias %s is not available on bedrock; use a dated model ID", model)
+	}
+	version := bedrockClaudeVersions[model]
+	if version == "" {
+		version = "v1:0"
+	}
+	return "anthropic." + model + "-" + version, nil
+}
+
+// vertexClaudeModel maps an Anthropic model ID to its Vertex ID, e.g.
+// claude-sonnet-4-20250514 to claude-sonnet-4@20250514. Vertex IDs pass
+// through.
+func vertexClaudeModel(model string) (string, error) {
+	if strings.Contains(model, "@") {
+		return model, nil
+	}
+	if strings.HasSuffix(model, "-latest") {
+		return "", fmt.Errorf("model alias %s is not available on vertex; use a dated model ID", model)
+	}
+	i := strings.LastIndex(model, "-")
+	if i < 0 || len(model)-i-1 != 8 {
+		return model, nil
+	}
+	return model[:i] + "@" + model[i+1:], nil
 }
@@ -1,7 +1,11 @@
 package providers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -121,3 +125,295 @@ func TestAnthropic_SetHeaders(t *testing.T) {
 		t.Errorf("Content-Type = %q, want %q", got, "application/json")
 	}
 }
+
+func TestAnthropic_BuildRequest_User(t *testing.T) {
+	a := &anthropic{}
+
+	built := a.buildRequest(Request{Model: "claude-sonnet-4-20250514", Prompt: "Hi"}, false)
+	if built.Metadata != nil {
+		t.Errorf("Metadata = %+v, want nil without a user", built.Metadata)
+	}
+
+	built = a.buildRequest(Request{Model: "claude-sonnet-4-20250514", Prompt: "Hi", User: "user-42"}, false)
+	if built.Metadata == nil || built.Metadata.UserID != "user-42" {
+		t.Errorf("Metadata = %+v, want user_id user-42", built.Metadata)
+	}
+}
+
+func TestAnthropic_BuildRequest_Continue(t *testing.T) {
+	a := &anthropic{}
+
+	built := a.buildRequest(Request{Model: "claude-sonnet-4-20250514", Prompt: "Hi", Continue: "Hello, "}, true)
+	if len(built.Messages) != 2 {
+		t.Fatalf("len(Messages) = %d, want 2", len(built.Messages))
+	}
+	// Prefill can't end in whitespace
+	if last := built.Messages[1]; last.Role != "assistant" || last.Content != "Hello," {
+		t.Errorf("prefill = %+v, want assistant %q", last, "Hello,")
+	}
+}
+
+func TestAnthropic_BuildRequest_Messages(t *testing.T) {
+	a := &anthropic{}
+
+	built := a.buildRequest(Request{
+		Model:  "claude-sonnet-4-20250514",
+		System: "Be brief",
+		Messages: []Message{
+			{Role: "system", Content: "Answer in French"},
+			{Role: "user", Content: "Hi"},
+			{Role: "assistant", Content: "Bonjour"},
+			{Role: "user", Content: "How are you?"},
+		},
+	}, false)
+
+	// System turns move to the system field, and the empty prompt is dropped
+	if built.System != "Be brief\n\nAnswer in French" {
+		t.Errorf("System = %q", built.System)
+	}
+	if len(built.Messages) != 3 || built.Messages[2].Content != "How are you?" {
+		t.Errorf("Messages = %+v, want the three conversation turns", built.Messages)
+	}
+}
+
+func TestAnthropic_BuildRequest_Images(t *testing.T) {
+	a := &anthropic{}
+
+	built := a.buildRequest(Request{
+		Model:  "claude-sonnet-4-20250514",
+		Prompt: "What's this?",
+		Images: []Image{{Data: []byte("png"), MediaType: "image/png"}},
+	}, false)
+
+	data, _ := json.Marshal(built.Messages[0])
+	want := `{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"cG5n"}},` +
+		`{"type":"text","text":"What's this?"}]}`
+	if string(data) != want {
+		t.Errorf("message = %s, want %s", data, want)
+	}
+}
+
+func TestAnthropic_BuildRequest_Documents(t *testing.T) {
+	a := &anthropic{}
+
+	built := a.buildRequest(Request{
+		Model:  "claude-sonnet-4-20250514",
+		Prompt: "Summarize these",
+		Documents: []Document{
+			{Data: []byte("pdf"), MediaType: "application/pdf", Name: "report.pdf"},
+			{Data: []byte("notes"), MediaType: "text/plain"},
+		},
+	}, false)
+
+	data, _ := json.Marshal(built.Messages[0])
+	want := `{"role":"user","content":[` +
+		`{"type":"document","source":{"type":"base64","media_type":"application/pdf","data":"cGRm"},"title":"report.pdf"},` +
+		`{"type":"document","source":{"type":"text","media_type":"text/plain","data":"notes"}},` +
+		`{"type":"text","text":"Summarize these"}]}`
+	if string(data) != want {
+		t.Errorf("message = %s, want %s", data, want)
+	}
+}
+
+func TestAnthropic_Sampling(t *testing.T) {
+	a := &anthropic{}
+
+	temp := 0.5
+	built := a.buildRequest(Request{Model: "claude-sonnet-4-20250514", Prompt: "hi", Temperature: &temp}, false)
+	if built.Temperature == nil || *built.Temperature != 0.5 {
+		t.Errorf("Temperature = %v, want 0.5", built.Temperature)
+	}
+
+	// Claude has no penalties or seed
+	_, err := a.Complete(context.Background(), Request{Model: "claude-sonnet-4-20250514", Prompt: "hi", PresencePenalty: &temp})
+	if err == nil {
+		t.Error("Complete() should reject presence_penalty")
+	}
+	seed := 1
+	if _, err := a.Complete(context.Background(), Request{Model: "claude-sonnet-4-20250514", Prompt: "hi", Seed: &seed}); err == nil {
+		t.Error("Complete() should reject seed")
+	}
+	if _, err := a.Complete(context.Background(), Request{Model: "claude-sonnet-4-20250514", Prompt: "hi", ReasoningEffort: "low"}); err == nil {
+		t.Error("Complete() should reject reasoning effort")
+	}
+}
+
+func TestAnthropic_BuildRequest_ResponseFormat(t *testing.T) {
+	a := &anthropic{}
+
+	schema := json.RawMessage(`{"type": "object", "properties": {"name": {"type": "string"}}}`)
+	built := a.buildRequest(Request{
+		Model:          "claude-sonnet-4-20250514",
+		Prompt:         "hi",
+		ResponseFormat: &ResponseFormat{Type: "json_schema", Name: "person", Schema: schema},
+	}, false)
+
+	// Claude is forced to call a tool whose input is the schema
+	if len(built.Tools) != 1 || built.Tools[0].Name != "person" || string(built.Tools[0].InputSchema) != string(schema) {
+		t.Errorf("Tools = %+v", built.Tools)
+	}
+	if built.ToolChoice == nil || built.ToolChoice.Type != "tool" || built.ToolChoice.Name != "person" {
+		t.Errorf("ToolChoice = %+v", built.ToolChoice)
+	}
+}
+
+func TestAnthropic_StructuredOutput(t *testing.T) {
+	var resp anthropicResponse
+	json.Unmarshal([]byte(`{"content": [{"type": "tool_use", "name": "person", "input": {"name": "Ada"}}]}`), &resp)
+	if got := resp.text(); got != `{"name": "Ada"}` {
+		t.Errorf("text() = %q, want the tool input", got)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: content_block_delta\n" + `data: {"type": "content_block_delta", "delta": {"type": "input_json_delta", "partial_json": "{\"name\": "}}` + "\n\n"))
+		w.Write([]byte("event: content_block_delta\n" + `data: {"type": "content_block_delta", "delta": {"type": "input_json_delta", "partial_json": "\"Ada\"}"}}` + "\n\n"))
+		w.Write([]byte("event: message_stop\ndata: {\"type\": \"message_stop\"}\n\n"))
+	}))
+	defer server.Close()
+
+	ch, err := NewAnthropic().CompleteStream(context.Background(), Request{
+		Model:          "claude-sonnet-4-20250514",
+		Prompt:         "hi",
+		Endpoint:       Endpoint{BaseURL: server.URL},
+		ResponseFormat: &ResponseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var content string
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		content += chunk.Content
+	}
+	if content != `{"name": "Ada"}` {
+		t.Errorf("content = %q", content)
+	}
+}
+
+func TestPlatformClaudeModels(t *testing.T) {
+	tests := []struct {
+		model, bedrock, vertex string
+	}{
+		{"claude-sonnet-4-20250514", "anthropic.claude-sonnet-4-20250514-v1:0", "claude-sonnet-4@20250514"},
+		{"claude-3-5-sonnet-20241022", "anthropic.claude-3-5-sonnet-20241022-v2:0", "claude-3-5-sonnet@20241022"},
+		{"us.anthropic.claude-3-5-haiku-20241022-v1:0", "us.anthropic.claude-3-5-haiku-20241022-v1:0", ""},
+		{"claude-opus-4@20250514", "", "claude-opus-4@20250514"},
+	}
+
+	for _, tt := range tests {
+		if tt.bedrock != "" {
+			if got, err := bedrockClaudeModel(tt.model); err != nil || got != tt.bedrock {
+				t.Errorf("bedrockClaudeModel(%q) = %q, %v; want %q", tt.model, got, err, tt.bedrock)
+			}
+		}
+		if tt.vertex != "" {
+			if got, err := vertexClaudeModel(tt.model); err != nil || got != tt.vertex {
+				t.Errorf("vertexClaudeModel(%q) = %q, %v; want %q", tt.model, got, err, tt.vertex)
+			}
+		}
+	}
+
+	if _, err := bedrockClaudeModel("claude-3-5-haiku-latest"); err == nil {
+		t.Error("bedrockClaudeModel(alias) should fail")
+	}
+}
+
+func TestAnthropic_CompleteViaBedrock(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"content": [{"type": "text", "text": "Hello"}], "usage": {"input_tokens": 5, "output_tokens": 2}}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewAnthropic().Complete(context.Background(), Request{
+		Model:    "claude-sonnet-4-20250514",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "AKID:secret", BaseURL: server.URL},
+		Betas:    []string{"context-1m-2025-08-07"},
+		Platform: "bedrock",
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if resp.Content != "Hello" || resp.Model != "claude-sonnet-4-20250514" {
+		t.Errorf("response = %+v", resp)
+	}
+	if gotPath != "/model/anthropic.claude-sonnet-4-20250514-v1%3A0/invoke" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if betas, _ := gotBody["anthropic_beta"].([]any); len(betas) != 1 {
+		t.Errorf("anthropic_beta = %v, want the profile's betas", gotBody["anthropic_beta"])
+	}
+}
+
+func TestAnthropic_UnknownPlatform(t *testing.T) {
+	_, err := NewAnthropic().Complete(context.Background(), Request{Model: "claude-sonnet-4-20250514", Prompt: "hi", Platform: "azure"})
+	if err == nil {
+		t.Error("Complete() should fail for an unknown platform")
+	}
+}
+
+func TestAnthropic_ListModels(t *testing.T) {
+	var gotKey, gotVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("x-api-key")
+		gotVersion = r.Header.Get("anthropic-version")
+		if r.URL.Query().Get("after_id") == "" {
+			w.Write([]byte(`{"data": [{"id": "claude-opus-4-1-20250805", "display_name": "Claude Opus 4.1"}],
+				"has_more": true, "last_id": "claude-opus-4-1-20250805"}`))
+			return
+		}
+		w.Write([]byte(`{"data": [{"id": "claude-sonnet-4-20250514", "display_name": "Claude Sonnet 4"}], "has_more": false}`))
+	}))
+	defer server.Close()
+
+	models, err := NewAnthropic().ListModels("sk-ant", server.URL)
+	if err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+
+	if len(models) != 2 || models[0].ID != "claude-opus-4-1-20250805" || models[1].Name != "Claude Sonnet 4" {
+		t.Errorf("models = %+v, want both pages", models)
+	}
+	if gotKey != "sk-ant" || gotVersion != anthropicVersion {
+		t.Errorf("x-api-key = %q, anthropic-version = %q", gotKey, gotVersion)
+	}
+}
+
+func TestAnthropic_ListModels_Offline(t *testing.T) {
+	// A closed server stands in for an unreachable API
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	a := NewAnthropic()
+	models, err := a.ListModels("sk-ant", server.URL)
+	if err != nil || len(models) == 0 {
+		t.Errorf("ListModels() = %d models, %v; want the built-in list", len(models), err)
+	}
+
+	// Health checks still see the failure
+	if err := a.(Pinger).Ping("sk-ant", server.URL); err == nil {
+		t.Error("Ping() should fail when the API is unreachable")
+	}
+}
+
+func TestAnthropic_ListModels_Unauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewAnthropic().ListModels("sk-bad", server.URL)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("ListModels() error = %v, want ErrUnauthorized", err)
+	}
+}
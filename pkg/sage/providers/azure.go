@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// azureDefaultAPIVersion is used when the provider config doesn't set one.
+const azureDefaultAPIVersion = "2024-10-21"
+
+func init() {
+	Register("azure-openai", NewAzureOpenAI)
+}
+
+// NewAzureOpenAI creates an Azure OpenAI provider. Requests go to the
+// resource's base URL (e.g. https://myres.openai.azure.com), and a profile's
+// model is the name of the deployment to call.
+func NewAzureOpenAI() Provider {
+	return &openai{
+		name:      "azure-openai",
+		chatURL:   azureChatURL,
+		modelsURL: azureModelsURL,
+		embedURL:  azureEmbedURL,
+		auth:      azureAuth,
+	}
+}
+
+func azureChatURL(req Request) string {
+	return azureDeploymentURL(req.BaseURL, req.Model, "chat/completions", req.APIVersion)
+}
+
+func azureEmbedURL(req EmbedRequest) string {
+	return azureDeploymentURL(req.BaseURL, req.Model, "embeddings", req.APIVersion)
+}
+
+func azureDeploymentURL(baseURL, deployment, operation, version string) string {
+	if version == "" {
+		version = azureDefaultAPIVersion
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/openai/deployments/" + url.PathEscape(deployment) +
+		"/" + operation + "?api-version=" + url.QueryEscape(version)
+}
+
+// azureModelsURL lists the models available to the resource. Azure has no
+// data-plane endpoint for listing deployments.
+func azureModelsURL(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/openai/models?api-version=" + azureDefaultAPIVersion
+}
+
+func azureAuth(req *http.Request, apiKey string) {
+	req.Header.Set("api-key", apiKey)
+}
@@ -0,0 +1,87 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureOpenAI_Registered(t *testing.T) {
+	p, err := Get("azure-openai")
+	if err != nil {
+		t.Fatalf("Get(azure-openai) error = %v", err)
+	}
+	if p.Name() != "azure-openai" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "azure-openai")
+	}
+}
+
+func TestAzureOpenAI_ChatURL(t *testing.T) {
+	req := Request{Model: "my gpt4o", Endpoint: Endpoint{BaseURL: "https://res.openai.azure.com/"}}
+
+	want := "https://res.openai.azure.com/openai/deployments/my%20gpt4o/chat/completions?api-version=" + azureDefaultAPIVersion
+	if got := azureChatURL(req); got != want {
+		t.Errorf("azureChatURL() = %q, want %q", got, want)
+	}
+
+	req.APIVersion = "2025-01-01-preview"
+	want = "https://res.openai.azure.com/openai/deployments/my%20gpt4o/chat/completions?api-version=2025-01-01-preview"
+	if got := azureChatURL(req); got != want {
+		t.Errorf("azureChatURL() = %q, want %q", got, want)
+	}
+}
+
+func TestAzureOpenAI_Complete(t *testing.T) {
+	var gotPath, gotVersion, gotKey, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotKey = r.Header.Get("api-key")
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewAzureOpenAI().Complete(context.Background(), Request{
+		Model:    "prod-gpt4o",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "az-key", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "ok" || resp.Model != "prod-gpt4o" {
+		t.Errorf("response = %+v, want content ok from prod-gpt4o", resp)
+	}
+	if gotPath != "/openai/deployments/prod-gpt4o/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotVersion != azureDefaultAPIVersion {
+		t.Errorf("api-version = %q, want %q", gotVersion, azureDefaultAPIVersion)
+	}
+	if gotKey != "az-key" || gotAuth != "" {
+		t.Errorf("api-key = %q, Authorization = %q; want api-key only", gotKey, gotAuth)
+	}
+}
+
+func TestAzureOpenAI_Embed(t *testing.T) {
+	var gotURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		w.Write([]byte(`{"data": [{"index": 0, "embedding": [0.5]}]}`))
+	}))
+	defer server.Close()
+
+	_, err := NewAzureOpenAI().(Embedder).Embed(EmbedRequest{
+		Model: "my-embeddings", Input: []string{"a"}, Endpoint: Endpoint{APIKey: "az-key", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	want := "/openai/deployments/my-embeddings/embeddings?api-version=" + azureDefaultAPIVersion
+	if gotURL != want {
+		t.Errorf("URL = %q, want %q", gotURL, want)
+	}
+}
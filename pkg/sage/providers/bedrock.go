@@ -0,0 +1,505 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	bedrockDefaultRegion    = "us-east-1"
+	bedrockAnthropicVersion = "bedrock-2023-05-31"
+)
+
+func init() {
+	Register("bedrock", NewBedrock)
+}
+
+// bedrock implements AWS Bedrock Runtime for Claude and Llama models.
+// Requests are signed with SigV4. The account's API key holds
+// "ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]"; without one the
+// standard AWS_* environment variables are used. The region comes from the
+// base URL (https://bedrock-runtime.<region>.amazonaws.com), then
+// AWS_REGION or AWS_DEFAULT_REGION.
+type bedrock struct {
+	httpClient
+}
+
+// NewBedrock creates a new AWS Bedrock provider.
+func NewBedrock() Provider {
+	return &bedrock{}
+}
+
+func (b *bedrock) Name() string {
+	return "bedrock"
+}
+
+// Bedrock request/response types. Claude bodies are anthropicPlatformRequest;
+// Llama bodies take a formatted prompt.
+
+type bedrockLlamaRequest struct {
+	Prompt      string   `json:"prompt"`
+	MaxGenLen   int      `json:"max_gen_len,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+}
+
+type bedrockLlamaResponse struct {
+	Generation           string  `json:"generation"`
+	PromptTokenCount     int     `json:"prompt_token_count"`
+	GenerationTokenCount int     `json:"generation_token_count"`
+	StopReason           *string `json:"stop_reason"`
+}
+
+// bedrockChunk is the payload of a streamed "chunk" event: one model
+// response event, base64-encoded.
+type bedrockChunk struct {
+	Bytes string `json:"bytes"`
+}
+
+type bedrockError struct {
+	Message string `json:"message"`
+}
+
+// bedrockFamily returns the request format for a model ID. Cross-region
+// inference profile IDs (e.g. "us.anthropic.claude-...") are matched too.
+func bedrockFamily(model string) (string, error) {
+	switch {
+	case strings.Contains(model, "anthropic.claude"):
+		return "claude", nil
+	case strings.Contains(model, "meta.llama"):
+		return "llama", nil
+	}
+	return "", fmt.Errorf("unsupported bedrock model %q (Claude and Llama models are supported)", model)
+}
+
+func (b *bedrock) Complete(ctx context.Context, req Request) (*Response, error) {
+	family, err := bedrockFamily(req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.invoke(ctx, req, family, "invoke")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Response{
+		Model:     req.Model,
+		RequestID: resp.Header.Get("x-amzn-RequestId"),
+	}
+
+	if family == "claude" {
+		var claudeResp anthropicResponse
+		if err := json.NewDecoder(resp.Body).Decode(&claudeResp); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		result.Content = claudeResp.text()
+		result.Usage = Usage{
+			PromptTokens:     claudeResp.Usage.InputTokens,
+			CompletionTokens: claudeResp.Usage.OutputTokens,
+		}
+		result.FinishReason = anthropicFinishReason(claudeResp.StopReason, req)
+		return result, nil
+	}
+
+	var llamaResp bedrockLlamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&llamaResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	result.Content = llamaResp.Generation
+	result.Usage = Usage{
+		PromptTokens:     llamaResp.PromptTokenCount,
+		CompletionTokens: llamaResp.GenerationTokenCount,
+	}
+	if llamaResp.StopReason != nil {
+		result.FinishReason = *llamaResp.StopReason // "stop" or "length"
+	}
+	return result, nil
+}
+
+func (b *bedrock) CompleteStream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	family, err := bedrockFamily(req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.invoke(ctx, req, family, "invoke-with-response-stream")
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Chunk)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		idle := newIdleTimer(req.IdleTimeout, resp.Body)
+		defer idle.stop()
+
+		var usage *Usage
+		var finish string
+		for {
+			msg, err := readEventStreamMessage(resp.Body)
+			if err == io.EOF {
+				sendChunk(ctx, ch, Chunk{Done: true, Usage: usage, FinishReason: finish})
+				return
+			}
+			if err != nil {
+				sendChunk(ctx, ch, Chunk{Error: idle.readError(err)})
+				return
+			}
+			idle.reset()
+
+			if msg.Headers[":message-type"] != "event" {
+				sendChunk(ctx, ch, Chunk{Error: bedrockStreamError(msg)})
+				return
+			}
+			if msg.Headers[":event-type"] != "chunk" {
+				continue
+			}
+
+			var chunk bedrockChunk
+			if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+				sendChunk(ctx, ch, Chunk{Error: fmt.Errorf("failed to parse stream data: %w", err)})
+				return
+			}
+			data, err := base64.StdEncoding.DecodeString(chunk.Bytes)
+			if err != nil {
+				sendChunk(ctx, ch, Chunk{Error: fmt.Errorf("failed to parse stream data: %w", err)})
+				return
+			}
+
+			content, done, err := bedrockStreamContent(family, data)
+			if err != nil {
+				sendChunk(ctx, ch, Chunk{Error: fmt.Errorf("failed to parse stream data: %w", err)})
+				return
+			}
+			if u := bedrockStreamUsage(data); u != nil {
+				usage = u
+			}
+			if f := bedrockStreamFinish(family, data, req); f != "" {
+				finish = f
+			}
+			if content != "" {
+				if !sendChunk(ctx, ch, Chunk{Content: content}) {
+					return
+				}
+			}
+			if done {
+				sendChunk(ctx, ch, Chunk{Done: true, Usage: usage, FinishReason: finish})
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// bedrockStreamUsage returns the token counts Bedrock adds to the last event
+// of a stream, for every model family, or nil if the event has none.
+func bedrockStreamUsage(data []byte) *Usage {
+	var event struct {
+		Metrics *struct {
+			InputTokenCount  int `json:"inputTokenCount"`
+			OutputTokenCount int `json:"outputTokenCount"`
+		} `json:"amazon-bedrock-invocationMetrics"`
+	}
+	if json.Unmarshal(data, &event) != nil || event.Metrics == nil {
+		return nil
+	}
+	return &Usage{PromptTokens: event.Metrics.InputTokenCount, CompletionTokens: event.Metrics.OutputTokenCount}
+}
+
+// bedrockStreamFinish returns the finish reason a decoded stream event
+// reports: Claude's message_delta, or Llama's last event. It returns "" for
+// other events.
+func bedrockStreamFinish(family string, data []byte, req Request) string {
+	if family == "claude" {
+		var event anthropicStreamEvent
+		if json.Unmarshal(data, &event) != nil || event.Type != "message_delta" || event.Delta == nil {
+			return ""
+		}
+		return anthropicFinishReason(event.Delta.StopReason, req)
+	}
+
+	var event bedrockLlamaResponse
+	if json.Unmarshal(data, &event) != nil || event.StopReason == nil {
+		return ""
+	}
+	return *event.StopReason
+}
+
+// bedrockStreamContent extracts the text from one decoded stream event and
+// reports whether it ends the response.
+func bedrockStreamContent(family string, data []byte) (content string, done bool, err error) {
+	if family == "claude" {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return "", false, err
+		}
+		if event.Type == "message_stop" {
+			return "", true, nil
+		}
+		if event.Type == "content_block_delta" && event.Delta != nil {
+			return event.Delta.content(), false, nil
+		}
+		return "", false, nil
+	}
+
+	var event bedrockLlamaResponse
+	if err := json.Unmarshal(data, &event); err != nil {
+		return "", false, err
+	}
+	return event.Generation, event.StopReason != nil, nil
+}
+
+// bedrockStreamError converts an exception message received mid-stream.
+func bedrockStreamError(msg *eventStreamMessage) error {
+	kind := msg.Headers[":exception-type"]
+	if kind == "" {
+		kind = msg.Headers[":error-code"]
+	}
+
+	var errResp bedrockError
+	message := string(msg.Payload)
+	if err := json.Unmarshal(msg.Payload, &errResp); err == nil && errResp.Message != "" {
+		message = errResp.Message
+	}
+
+	switch kind {
+	case "throttlingException":
+		return fmt.Errorf("%w: %s", ErrRateLimited, message)
+	case "internalServerException", "serviceUnavailableException", "modelStreamErrorException":
+		return fmt.Errorf("%w: %s", ErrServerError, message)
+	}
+	if classified := requestError(0, kind, message); classified != nil {
+		return fmt.Errorf("%w: %s", classified, message)
+	}
+	return fmt.Errorf("bedrock stream error (%s): %s", kind, message)
+}
+
+// invoke signs and sends a request to the given Bedrock Runtime action,
+// returning the response only if it succeeded.
+func (b *bedrock) invoke(ctx context.Context, req Request, family, action string) (*http.Response, error) {
+	if err := requireImageData(req, "bedrock"); err != nil {
+		return nil, err
+	}
+	if err := requireBasicSampling(req, "bedrock"); err != nil {
+		return nil, err
+	}
+	if err := requireNoLogprobs(req, "bedrock"); err != nil {
+		return nil, err
+	}
+	if err := requireNoReasoningEffort(req, "bedrock"); err != nil {
+		return nil, err
+	}
+
+	creds, err := bedrockCredentials(req.APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var body any
+	if family == "claude" {
+		body = buildPlatformRequest(req, bedrockAnthropicVersion, false)
+	} else if req.ResponseFormat != nil {
+		return nil, errUnsupported("bedrock "+family, "structured output")
+	} else if len(req.Images) > 0 {
+		return nil, errUnsupported("bedrock "+family, "images")
+	} else {
+		body = b.buildLlamaRequest(req)
+	}
+
+	jsonBody, err := marshalBody(body, req.ExtraBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Model IDs contain ":", which AWS expects escaped in the path
+	region := bedrockRegion(req.BaseURL)
+	endpoint := b.endpoint(req.BaseURL, region) + "/model/" + awsURIEscape(req.Model) + "/" + action
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	if req.RequestID != "" {
+		httpReq.Header.Set("X-Amzn-Client-Request-Id", req.RequestID)
+	}
+	setExtraHeaders(httpReq, req.Headers)
+	signV4(httpReq, jsonBody, creds, region, "bedrock", time.Now())
+
+	resp, err := b.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, b.handleError(resp)
+	}
+	return resp, nil
+}
+
+// buildLlamaRequest formats the prompt with the Llama 3 chat template.
+// The partial response to continue, if any, is left open after the
+// assistant header so the model carries on from it.
+func (b *bedrock) buildLlamaRequest(req Request) bedrockLlamaRequest {
+	var p strings.Builder
+	p.WriteString("<|begin_of_text|>")
+	if req.System != "" {
+		p.WriteString("<|start_header_id|>system<|end_header_id|>\n\n" + req.System + "<|eot_id|>")
+	}
+	for _, m := range conversation(req) {
+		p.WriteString("<|start_header_id|>" + m.Role + "<|end_header_id|>\n\n" + m.Content + "<|eot_id|>")
+	}
+	p.WriteString("<|start_header_id|>assistant<|end_header_id|>\n\n" + req.Continue)
+
+	return bedrockLlamaRequest{
+		Prompt:      p.String(),
+		MaxGenLen:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+	}
+}
+
+func (b *bedrock) endpoint(baseURL, region string) string {
+	if baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return "https://bedrock-runtime." + region + ".amazonaws.com"
+}
+
+// bedrockRegion returns the AWS region to sign for.
+func bedrockRegion(baseURL string) string {
+	if baseURL != "" {
+		if u, err := url.Parse(baseURL); err == nil {
+			parts := strings.Split(u.Hostname(), ".")
+			if len(parts) >= 4 && strings.HasPrefix(parts[0], "bedrock") && parts[2] == "amazonaws" {
+				return parts[1]
+			}
+		}
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	if region := os.Getenv("AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+	return bedrockDefaultRegion
+}
+
+// bedrockCredentials parses credentials stored as the account's API key,
+// falling back to the AWS_* environment variables.
+func bedrockCredentials(apiKey string) (awsCredentials, error) {
+	if apiKey != "" {
+		parts := strings.SplitN(apiKey, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return awsCredentials{}, errors.New("bedrock credentials must be ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]")
+		}
+		creds := awsCredentials{AccessKeyID: parts[0], SecretAccessKey: parts[1]}
+		if len(parts) == 3 {
+			creds.SessionToken = parts[2]
+		}
+		return creds, nil
+	}
+
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("%w: no AWS credentials (set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)", ErrUnauthorized)
+	}
+	return creds, nil
+}
+
+func (b *bedrock) handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	msg := string(body)
+	var errResp bedrockError
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Message != "" {
+		msg = errResp.Message
+	}
+	// AWS rejects bad signatures and unknown keys with 403. The error type
+	// header reads e.g. "ResourceNotFoundException:http://..."
+	return classifyStatus(resp, resp.Header.Get("X-Amzn-ErrorType"), msg)
+}
+
+// ListModels returns the Claude and Llama text models available in the
+// region, from the Bedrock control plane. A base URL replaces the control
+// plane endpoint.
+func (b *bedrock) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
+	creds, err := bedrockCredentials(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	region := bedrockRegion(baseURL)
+	endpoint := "https://bedrock." + region + ".amazonaws.com"
+	if baseURL != "" {
+		endpoint = strings.TrimSuffix(baseURL, "/")
+	}
+
+	req, err := http.NewRequest("GET", endpoint+"/foundation-models?byOutputModality=TEXT", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	signV4(req, nil, creds, region, "bedrock", time.Now())
+
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, b.handleError(resp)
+	}
+
+	var result bedrockModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	models := make([]ModelInfo, 0, len(result.ModelSummaries))
+	for _, m := range result.ModelSummaries {
+		if _, err := bedrockFamily(m.ModelID); err != nil {
+			continue
+		}
+		models = append(models, ModelInfo{
+			ID:          m.ModelID,
+			Name:        m.ModelName,
+			Description: m.ProviderName,
+		})
+	}
+
+	return models, nil
+}
+
+type bedrockModelsResponse struct {
+	ModelSummaries []bedrockModelSummary `json:"modelSummaries"`
+}
+
+type bedrockModelSummary struct {
+	ModelID      string `json:"modelId"`
+	ModelName    string `json:"modelName"`
+	ProviderName string `json:"providerName"`
+}
@@ -0,0 +1,378 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// encodeEventStreamMessage builds an event stream message with string headers.
+func encodeEventStreamMessage(headers map[string]string, payload []byte) []byte {
+	var h bytes.Buffer
+	for name, value := range headers {
+		h.WriteByte(byte(len(name)))
+		h.WriteString(name)
+		h.WriteByte(7)
+		binary.Write(&h, binary.BigEndian, uint16(len(value)))
+		h.WriteString(value)
+	}
+
+	total := 12 + h.Len() + len(payload) + 4
+	var m bytes.Buffer
+	binary.Write(&m, binary.BigEndian, uint32(total))
+	binary.Write(&m, binary.BigEndian, uint32(h.Len()))
+	binary.Write(&m, binary.BigEndian, crc32.ChecksumIEEE(m.Bytes()))
+	m.Write(h.Bytes())
+	m.Write(payload)
+	binary.Write(&m, binary.BigEndian, crc32.ChecksumIEEE(m.Bytes()))
+	return m.Bytes()
+}
+
+// bedrockChunkMessage wraps a model event the way Bedrock streams it.
+func bedrockChunkMessage(event string) []byte {
+	payload, _ := json.Marshal(bedrockChunk{Bytes: base64.StdEncoding.EncodeToString([]byte(event))})
+	return encodeEventStreamMessage(map[string]string{
+		":message-type": "event",
+		":event-type":   "chunk",
+		":content-type": "application/json",
+	}, payload)
+}
+
+func TestSignV4(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %q, want %q", got, "20150830T123600Z")
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://bedrock-runtime.us-east-1.amazonaws.com/model/anthropic.claude-v2%3A1/invoke", nil)
+
+	want := "/model/anthropic.claude-v2%253A1/invoke"
+	if got := canonicalURI(req); got != want {
+		t.Errorf("canonicalURI() = %q, want %q", got, want)
+	}
+}
+
+func TestReadEventStreamMessage(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(encodeEventStreamMessage(map[string]string{":event-type": "chunk"}, []byte("one")))
+	stream.Write(encodeEventStreamMessage(nil, []byte("two")))
+
+	msg, err := readEventStreamMessage(&stream)
+	if err != nil {
+		t.Fatalf("readEventStreamMessage() error = %v", err)
+	}
+	if msg.Headers[":event-type"] != "chunk" || string(msg.Payload) != "one" {
+		t.Errorf("message = %+v, want chunk event with payload one", msg)
+	}
+
+	msg, err = readEventStreamMessage(&stream)
+	if err != nil {
+		t.Fatalf("readEventStreamMessage() error = %v", err)
+	}
+	if string(msg.Payload) != "two" {
+		t.Errorf("Payload = %q, want %q", msg.Payload, "two")
+	}
+
+	if _, err := readEventStreamMessage(&stream); err != io.EOF {
+		t.Errorf("error at end = %v, want io.EOF", err)
+	}
+}
+
+func TestReadEventStreamMessage_Corrupt(t *testing.T) {
+	msg := encodeEventStreamMessage(nil, []byte("payload"))
+	msg[len(msg)-6] ^= 0xff
+
+	if _, err := readEventStreamMessage(bytes.NewReader(msg)); err == nil {
+		t.Error("expected checksum error")
+	}
+
+	truncated := encodeEventStreamMessage(nil, []byte("payload"))
+	_, err := readEventStreamMessage(bytes.NewReader(truncated[:len(truncated)-3]))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestBedrockCredentials(t *testing.T) {
+	creds, err := bedrockCredentials("AKID:secret:token")
+	if err != nil {
+		t.Fatalf("bedrockCredentials() error = %v", err)
+	}
+	if creds.AccessKeyID != "AKID" || creds.SecretAccessKey != "secret" || creds.SessionToken != "token" {
+		t.Errorf("credentials = %+v", creds)
+	}
+
+	if _, err := bedrockCredentials("just-a-key"); err == nil {
+		t.Error("expected error for malformed credentials")
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	creds, err = bedrockCredentials("")
+	if err != nil {
+		t.Fatalf("bedrockCredentials() error = %v", err)
+	}
+	if creds.AccessKeyID != "ENVKEY" || creds.SecretAccessKey != "envsecret" {
+		t.Errorf("credentials = %+v, want environment credentials", creds)
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	if _, err := bedrockCredentials(""); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestBedrockRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	if got := bedrockRegion("https://bedrock-runtime.eu-west-1.amazonaws.com"); got != "eu-west-1" {
+		t.Errorf("region from URL = %q, want %q", got, "eu-west-1")
+	}
+	if got := bedrockRegion(""); got != bedrockDefaultRegion {
+		t.Errorf("default region = %q, want %q", got, bedrockDefaultRegion)
+	}
+
+	t.Setenv("AWS_REGION", "ap-southeast-2")
+	if got := bedrockRegion("http://127.0.0.1:8080"); got != "ap-southeast-2" {
+		t.Errorf("region from env = %q, want %q", got, "ap-southeast-2")
+	}
+}
+
+func TestBedrock_CompleteClaude(t *testing.T) {
+	var gotPath, gotAuth, gotToken string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		gotToken = r.Header.Get("X-Amz-Security-Token")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("x-amzn-RequestId", "req-123")
+		w.Write([]byte(`{"content": [{"type": "text", "text": "Hello"}], "usage": {"input_tokens": 5, "output_tokens": 2}, "stop_reason": "end_turn"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_REGION", "us-west-2")
+	resp, err := NewBedrock().Complete(context.Background(), Request{
+		Model:    "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		System:   "Be brief",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "AKID:secret:token", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if resp.Content != "Hello" || resp.Usage.PromptTokens != 5 || resp.Usage.CompletionTokens != 2 || resp.FinishReason != FinishStop {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.RequestID != "req-123" {
+		t.Errorf("RequestID = %q, want %q", resp.RequestID, "req-123")
+	}
+	if gotPath != "/model/anthropic.claude-3-5-sonnet-20240620-v1%3A0/invoke" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(gotAuth, "/us-west-2/bedrock/aws4_request") {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if gotToken != "token" {
+		t.Errorf("X-Amz-Security-Token = %q, want %q", gotToken, "token")
+	}
+	if gotBody["anthropic_version"] != bedrockAnthropicVersion || gotBody["system"] != "Be brief" {
+		t.Errorf("body = %v", gotBody)
+	}
+	if _, ok := gotBody["model"]; ok {
+		t.Error("body should not include model")
+	}
+}
+
+func TestBedrock_CompleteLlama(t *testing.T) {
+	var gotBody bedrockLlamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"generation": "Hi there", "prompt_token_count": 12, "generation_token_count": 3, "stop_reason": "stop"}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewBedrock().Complete(context.Background(), Request{
+		Model:     "meta.llama3-8b-instruct-v1:0",
+		Prompt:    "hello",
+		MaxTokens: 50,
+		Endpoint:  Endpoint{APIKey: "AKID:secret", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if resp.Content != "Hi there" || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 3 || resp.FinishReason != FinishStop {
+		t.Errorf("response = %+v", resp)
+	}
+	if !strings.Contains(gotBody.Prompt, "<|start_header_id|>user<|end_header_id|>\n\nhello<|eot_id|>") {
+		t.Errorf("prompt = %q", gotBody.Prompt)
+	}
+	if !strings.HasSuffix(gotBody.Prompt, "<|start_header_id|>assistant<|end_header_id|>\n\n") {
+		t.Errorf("prompt should end with the assistant header: %q", gotBody.Prompt)
+	}
+	if gotBody.MaxGenLen != 50 {
+		t.Errorf("max_gen_len = %d, want 50", gotBody.MaxGenLen)
+	}
+}
+
+func TestBedrock_UnsupportedModel(t *testing.T) {
+	_, err := NewBedrock().Complete(context.Background(), Request{Model: "amazon.titan-text-express-v1", Endpoint: Endpoint{APIKey: "AKID:secret"}})
+	if err == nil || !strings.Contains(err.Error(), "unsupported bedrock model") {
+		t.Errorf("error = %v, want unsupported model error", err)
+	}
+}
+
+func TestBedrock_StreamClaude(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(bedrockChunkMessage(`{"type": "message_start"}`))
+		w.Write(bedrockChunkMessage(`{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Hel"}}`))
+		w.Write(bedrockChunkMessage(`{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "lo"}}`))
+		w.Write(bedrockChunkMessage(`{"type": "message_delta", "delta": {"stop_reason": "max_tokens"}}`))
+		w.Write(bedrockChunkMessage(`{"type": "message_stop"}`))
+	}))
+	defer server.Close()
+
+	ch, err := NewBedrock().CompleteStream(context.Background(), Request{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "AKID:secret", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var content, finish string
+	var done bool
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		content += chunk.Content
+		done = done || chunk.Done
+		if chunk.Done {
+			finish = chunk.FinishReason
+		}
+	}
+
+	if content != "Hello" || !done || finish != FinishLength {
+		t.Errorf("content = %q, done = %v, finish = %q; want Hello, true, length", content, done, finish)
+	}
+	if gotPath != "/model/anthropic.claude-3-haiku-20240307-v1%3A0/invoke-with-response-stream" {
+		t.Errorf("path = %q", gotPath)
+	}
+}
+
+func TestBedrock_StreamLlama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bedrockChunkMessage(`{"generation": "Hi", "stop_reason": null}`))
+		w.Write(bedrockChunkMessage(`{"generation": " there", "stop_reason": "stop",
+			"amazon-bedrock-invocationMetrics": {"inputTokenCount": 5, "outputTokenCount": 2}}`))
+	}))
+	defer server.Close()
+
+	ch, err := NewBedrock().CompleteStream(context.Background(), Request{
+		Model:    "meta.llama3-8b-instruct-v1:0",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "AKID:secret", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var content string
+	var usage *Usage
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		content += chunk.Content
+		if chunk.Done {
+			usage = chunk.Usage
+		}
+	}
+	if content != "Hi there" {
+		t.Errorf("content = %q, want %q", content, "Hi there")
+	}
+	if usage == nil || usage.PromptTokens != 5 || usage.CompletionTokens != 2 {
+		t.Errorf("Usage = %+v, want 5 prompt + 2 completion", usage)
+	}
+}
+
+func TestBedrock_StreamException(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bedrockChunkMessage(`{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Hel"}}`))
+		w.Write(encodeEventStreamMessage(map[string]string{
+			":message-type":   "exception",
+			":exception-type": "throttlingException",
+		}, []byte(`{"message": "Too many requests"}`)))
+	}))
+	defer server.Close()
+
+	ch, err := NewBedrock().CompleteStream(context.Background(), Request{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "AKID:secret", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var lastErr error
+	for chunk := range ch {
+		if chunk.Error != nil {
+			lastErr = chunk.Error
+		}
+	}
+	if !errors.Is(lastErr, ErrRateLimited) {
+		t.Errorf("error = %v, want ErrRateLimited", lastErr)
+	}
+}
+
+func TestBedrock_HandleError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "The security token included in the request is invalid."}`))
+	}))
+	defer server.Close()
+
+	_, err := NewBedrock().Complete(context.Background(), Request{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "AKID:secret", BaseURL: server.URL},
+	})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("error = %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,97 @@
+package providers
+
+// Capabilities are request features a provider can send, named like the
+// model catalog's capabilities where they overlap.
+const (
+	CapStreaming       = "streaming"
+	CapVision          = "vision"     // Image inputs
+	CapImageURLs       = "image_urls" // Images given by URL rather than data
+	CapDocuments       = "documents"  // PDF and plain text document inputs
+	CapJSON            = "json"       // Structured JSON output
+	CapEmbeddings      = "embeddings"
+	CapTranscription   = "transcription" // Speech to text
+	CapSpeech          = "speech"        // Text to speech
+	CapImageGeneration = "image_generation"
+	CapModeration      = "moderation"
+	CapBatch           = "batch" // Asynchronous batch jobs
+	CapFineTuning      = "fine_tuning"
+	CapFiles           = "files" // Uploaded file storage
+	CapLogprobs        = "logprobs"
+	CapSeed            = "seed"
+	CapPenalties       = "penalties" // Frequency and presence penalties
+	CapReasoningEffort = "reasoning_effort"
+)
+
+// Capable is implemented by providers that report their capabilities.
+// Providers without it are assumed to support everything, leaving the
+// provider's API to reject what it can't do.
+type Capable interface {
+	// Capabilities lists the features the provider supports. Individual
+	// models may support less.
+	Capabilities() []string
+}
+
+// Supports reports whether a provider supports a capability.
+func Supports(p Provider, capability string) bool {
+	c, ok := p.(Capable)
+	if !ok {
+		return true
+	}
+	for _, have := range c.Capabilities() {
+		if have == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities reports the features every OpenAI-compatible provider can
+// send. Embeddings, transcription, speech and image generation depend on the
+// provider having the endpoint, and only OpenAI itself takes file inputs,
+// moderates, stores files, runs batches and fine-tunes.
+func (o *openai) Capabilities() []string {
+	caps := []string{CapStreaming, CapVision, CapImageURLs, CapJSON, CapLogprobs, CapSeed, CapPenalties, CapReasoningEffort}
+	if o.name == "" || o.embedURL != nil {
+		caps = append(caps, CapEmbeddings)
+	}
+	if o.name == "" || o.transcribeURL != nil {
+		caps = append(caps, CapTranscription)
+	}
+	if o.name == "" || o.speechURL != nil {
+		caps = append(caps, CapSpeech)
+	}
+	if o.name == "" || o.imageURL != nil {
+		caps = append(caps, CapImageGeneration)
+	}
+	if o.name == "" {
+		caps = append(caps, CapDocuments, CapModeration, CapFiles, CapBatch, CapFineTuning)
+	}
+	return caps
+}
+
+// Capabilities reports Claude's features, wherever it's served from.
+func (a *anthropic) Capabilities() []string {
+	return []string{CapStreaming, CapVision, CapImageURLs, CapDocuments, CapJSON}
+}
+
+// Capabilities reports Ollama's features. Images must be sent as data.
+func (o *ollama) Capabilities() []string {
+	return []string{CapStreaming, CapVision, CapJSON, CapEmbeddings, CapSeed, CapPenalties}
+}
+
+// Capabilities reports Bedrock's features. Only Claude models take images
+// and JSON output.
+func (b *bedrock) Capabilities() []string {
+	return []string{CapStreaming, CapVision, CapJSON}
+}
+
+// Capabilities reports Vertex AI's features. Claude models on Vertex take
+// neither seed nor penalties.
+func (v *vertex) Capabilities() []string {
+	return []string{CapStreaming, CapVision, CapJSON, CapSeed, CapPenalties}
+}
+
+// Capabilities reports Replicate's features.
+func (r *replicate) Capabilities() []string {
+	return []string{CapStreaming, CapSeed, CapPenalties}
+}
@@ -0,0 +1,50 @@
+package providers
+
+import "testing"
+
+func TestSupports(t *testing.T) {
+	tests := []struct {
+		provider   string
+		capability string
+		want       bool
+	}{
+		{"openai", CapEmbeddings, true},
+		{"openai", CapLogprobs, true},
+		{"xai", CapEmbeddings, false},
+		{"openai", CapTranscription, true},
+		{"groq", CapTranscription, true},
+		{"xai", CapTranscription, false},
+		{"groq", CapSpeech, true},
+		{"xai", CapImageGeneration, true},
+		{"groq", CapImageGeneration, false},
+		{"openai", CapModeration, true},
+		{"azure-openai", CapModeration, false},
+		{"openai", CapBatch, true},
+		{"groq", CapBatch, false},
+		{"openai", CapFineTuning, true},
+		{"openai", CapFiles, true},
+		{"xai", CapFiles, false},
+		{"azure-openai", CapEmbeddings, true},
+		{"anthropic", CapVision, true},
+		{"anthropic", CapSeed, false},
+		{"ollama", CapVision, true},
+		{"ollama", CapImageURLs, false},
+		{"replicate", CapVision, false},
+	}
+	for _, tt := range tests {
+		p, err := Get(tt.provider)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", tt.provider, err)
+		}
+		if got := Supports(p, tt.capability); got != tt.want {
+			t.Errorf("Supports(%s, %s) = %v, want %v", tt.provider, tt.capability, got, tt.want)
+		}
+	}
+}
+
+func TestSupports_Unreported(t *testing.T) {
+	// Providers that don't report capabilities aren't second-guessed
+	if !Supports(&mockProvider{}, CapVision) {
+		t.Error("Supports() = false for a provider without Capabilities")
+	}
+}
@@ -0,0 +1,136 @@
+package providers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+//go:embed models.json
+var embeddedCatalog []byte
+
+// ModelSpec describes per-model quirks that affect how requests are built.
+type ModelSpec struct {
+	// Prefix matches model IDs; the longest matching prefix wins. Unless
+	// it ends in "-", it only matches up to a "-", ":" or "@" separator, so
+	// "gpt-4" matches "gpt-4-0613" but not "gpt-4.5-preview".
+	Prefix string `json:"prefix"`
+
+	// ContextWindow is the total context size in tokens (0 if unknown).
+	ContextWindow int `json:"context_window,omitempty"`
+
+	// Capabilities lists features such as "vision", "tools", "json", "reasoning".
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// MaxTokensParam is the request field for the output token limit.
+	// Empty means the provider's default ("max_tokens").
+	MaxTokensParam string `json:"max_tokens_param,omitempty"`
+
+	// NoTemperature is set for models that reject sampling parameters.
+	NoTemperature bool `json:"no_temperature,omitempty"`
+
+	// Status is "deprecated" or "retired" for models being phased out.
+	Status string `json:"status,omitempty"`
+
+	// Successor is the recommended replacement for a deprecated model.
+	Successor string `json:"successor,omitempty"`
+
+	// InputPrice and OutputPrice are USD per million tokens (0 if unknown).
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
+}
+
+// Cost returns the estimated USD cost of a request with the given usage.
+func (m ModelSpec) Cost(u Usage) float64 {
+	return (float64(u.PromptTokens)*m.InputPrice + float64(u.CompletionTokens)*m.OutputPrice) / 1e6
+}
+
+// Model lifecycle states used in ModelSpec.Status.
+const (
+	ModelDeprecated = "deprecated"
+	ModelRetired    = "retired"
+)
+
+// Catalog is a table of model specs. Version is a release date
+// (YYYY-MM-DD), so later catalogs sort after earlier ones.
+type Catalog struct {
+	Version string      `json:"version"`
+	Models  []ModelSpec `json:"models"`
+}
+
+// catalog is the active model catalog, initially the embedded models.json.
+// It's swapped atomically, so lookups can run while it's replaced.
+var catalog atomic.Pointer[Catalog]
+
+func init() {
+	catalog.Store(mustParseCatalog(embeddedCatalog))
+}
+
+// EmbeddedCatalog returns the catalog built into the binary.
+func EmbeddedCatalog() *Catalog {
+	return mustParseCatalog(embeddedCatalog)
+}
+
+// ActiveCatalog returns the catalog used for model lookups.
+func ActiveCatalog() *Catalog {
+	return catalog.Load()
+}
+
+// SetCatalog replaces the active catalog, e.g. with a newer downloaded one.
+// Passing nil restores the embedded catalog. It's safe to call while
+// models are being looked up.
+func SetCatalog(c *Catalog) {
+	if c == nil {
+		c = EmbeddedCatalog()
+	}
+	catalog.Store(c)
+}
+
+func mustParseCatalog(data []byte) *Catalog {
+	c, err := ParseCatalog(data)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ParseCatalog parses a model catalog from JSON.
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid model catalog: %w", err)
+	}
+	for _, m := range c.Models {
+		if m.Prefix == "" {
+			return nil, fmt.Errorf("invalid model catalog: entry with empty prefix")
+		}
+	}
+	return &c, nil
+}
+
+// LookupModel returns the catalog entry for a model ID.
+// The second return value is false if no entry matches.
+func LookupModel(model string) (ModelSpec, bool) {
+	var best ModelSpec
+	found := false
+	for _, m := range catalog.Load().Models {
+		if matchesPrefix(model, m.Prefix) && len(m.Prefix) > len(best.Prefix) {
+			best = m
+			found = true
+		}
+	}
+	return best, found
+}
+
+// matchesPrefix reports whether model belongs to the family named by prefix.
+func matchesPrefix(model, prefix string) bool {
+	if !strings.HasPrefix(model, prefix) {
+		return false
+	}
+	if len(model) == len(prefix) || strings.HasSuffix(prefix, "-") {
+		return true
+	}
+	return strings.ContainsRune("-:@", rune(model[len(prefix)]))
+}
@@ -0,0 +1,115 @@
+package providers
+
+import (
+	"testing"
+)
+
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		model  string
+		prefix string
+		found  bool
+	}{
+		{"gpt-4o-mini", "gpt-4o-mini", true},
+		{"gpt-4o-2024-08-06", "gpt-4o", true},
+		{"gpt-4-turbo-2024-04-09", "gpt-4-turbo", true},
+		{"gpt-4", "gpt-4", true},
+		{"o1-mini", "o1-mini", true},
+		{"o4-mini-2025-04-16", "o4-mini", true},
+		{"claude-3-5-haiku-latest", "claude-3-5-haiku", true},
+		{"claude-instant-1.2", "claude-", true},
+		{"claude-3-5-sonnet@20240620", "claude-3-5-sonnet", true},
+		{"gpt-4-0613", "gpt-4", true},
+		{"gpt-4-0125-preview", "gpt-4-0125-preview", true},
+		{"gpt-4.5-preview", "gpt-4.5-preview", true},
+		{"gpt-4.2", "", false},
+		{"o10", "", false},
+		{"llama3.2", "", false},
+	}
+
+	for _, tt := range tests {
+		spec, ok := LookupModel(tt.model)
+		if ok != tt.found || spec.Prefix != tt.prefix {
+			t.Errorf("LookupModel(%q) = %q, %v; want %q, %v", tt.model, spec.Prefix, ok, tt.prefix, tt.found)
+		}
+	}
+}
+
+func TestParseCatalog_Invalid(t *testing.T) {
+	if _, err := ParseCatalog([]byte(`{"models": [{"context_window": 1}]}`)); err == nil {
+		t.Error("ParseCatalog() with empty prefix should error")
+	}
+	if _, err := ParseCatalog([]byte(`not json`)); err == nil {
+		t.Error("ParseCatalog() with invalid JSON should error")
+	}
+}
+
+func TestOpenAI_UsesMaxCompletionTokens(t *testing.T) {
+	o := &openai{}
+
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"gpt-4o-mini", true},
+		{"gpt-4.1-nano", true},
+		{"o4-mini", true},
+		{"gpt-5", true},
+		{"gpt-4", false},
+		{"gpt-3.5-turbo", false},
+		{"some-compatible-model", false},
+		{"ft:gpt-4o-mini-2024-07-18:acme::abc123", true},
+		{"ft:gpt-3.5-turbo-0125:acme::abc123", false},
+		{"openai/gpt-5-mini", true},
+		{"openai/gpt-4", false},
+		{"prod-gpt-4o-deployment", false},
+	}
+
+	for _, tt := range tests {
+		if got := o.usesMaxCompletionTokens(tt.model); got != tt.want {
+			t.Errorf("usesMaxCompletionTokens(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestModelSpec_Cost(t *testing.T) {
+	spec := ModelSpec{InputPrice: 2.5, OutputPrice: 10}
+	got := spec.Cost(Usage{PromptTokens: 1000000, CompletionTokens: 500000})
+	if got != 7.5 {
+		t.Errorf("Cost() = %v, want 7.5", got)
+	}
+}
+
+func TestSetCatalog(t *testing.T) {
+	defer SetCatalog(nil)
+
+	SetCatalog(&Catalog{Version: "test", Models: []ModelSpec{{Prefix: "custom-", ContextWindow: 42}}})
+	if spec, ok := LookupModel("custom-model"); !ok || spec.ContextWindow != 42 {
+		t.Errorf("LookupModel(custom-model) = %+v, %v; want entry from new catalog", spec, ok)
+	}
+	if _, ok := LookupModel("gpt-4o"); ok {
+		t.Error("LookupModel(gpt-4o) should miss after replacing catalog")
+	}
+
+	SetCatalog(nil)
+	if _, ok := LookupModel("gpt-4o"); !ok {
+		t.Error("SetCatalog(nil) should restore the embedded catalog")
+	}
+}
+
+func TestSetCatalog_Concurrent(t *testing.T) {
+	defer SetCatalog(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetCatalog(&Catalog{Models: []ModelSpec{{Prefix: "custom-"}}})
+			SetCatalog(nil)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		LookupModel("gpt-4o")
+	}
+	<-done
+}
@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// eventStreamMessage is one message of the AWS event stream encoding
+// (application/vnd.amazon.eventstream) used by Bedrock streaming responses.
+type eventStreamMessage struct {
+	Headers map[string]string // String-valued headers; others are skipped
+	Payload []byte
+}
+
+// maxEventStreamMessage bounds a single message so a corrupt length can't
+// cause a huge allocation.
+const maxEventStreamMessage = 16 << 20
+
+// readEventStreamMessage reads the next message from r. It returns io.EOF
+// when the stream ends cleanly between messages.
+//
+// Layout: total length (4), headers length (4), prelude CRC (4), headers,
+// payload, message CRC (4). All integers are big-endian.
+func readEventStreamMessage(r io.Reader) (*eventStreamMessage, error) {
+	var prelude [12]byte
+	if _, err := io.ReadFull(r, prelude[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, errors.New("event stream: prelude checksum mismatch")
+	}
+	if totalLen < 16+headersLen || totalLen > maxEventStreamMessage {
+		return nil, fmt.Errorf("event stream: invalid message length %d", totalLen)
+	}
+
+	rest := make([]byte, totalLen-12)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write(prelude[:])
+	crc.Write(rest[:len(rest)-4])
+	if crc.Sum32() != binary.BigEndian.Uint32(rest[len(rest)-4:]) {
+		return nil, errors.New("event stream: message checksum mismatch")
+	}
+
+	headers, err := parseEventStreamHeaders(rest[:headersLen])
+	if err != nil {
+		return nil, err
+	}
+	return &eventStreamMessage{
+		Headers: headers,
+		Payload: rest[headersLen : len(rest)-4],
+	}, nil
+}
+
+// eventStreamValueSizes gives the fixed size of each header value type;
+// -1 marks types prefixed with a 2-byte length (byte array, string).
+var eventStreamValueSizes = [...]int{0, 0, 1, 2, 4, 8, -1, -1, 8, 16}
+
+func parseEventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, errors.New("event stream: truncated header")
+		}
+		name := string(b[1 : 1+nameLen])
+		valueType := int(b[1+nameLen])
+		b = b[2+nameLen:]
+
+		if valueType >= len(eventStreamValueSizes) {
+			return nil, fmt.Errorf("event stream: unknown header type %d", valueType)
+		}
+		size := eventStreamValueSizes[valueType]
+		if size == -1 {
+			if len(b) < 2 {
+				return nil, errors.New("event stream: truncated header")
+			}
+			size = int(binary.BigEndian.Uint16(b))
+			b = b[2:]
+		}
+		if len(b) < size {
+			return nil, errors.New("event stream: truncated header")
+		}
+		if valueType == 7 { // String
+			headers[name] = string(b[:size])
+		}
+		b = b[size:]
+	}
+	return headers, nil
+}
@@ -0,0 +1,60 @@
+package providers
+
+import "strings"
+
+const (
+	fireworksDefaultURL   = "https://api.fireworks.ai/inference"
+	fireworksModelsPrefix = "accounts/fireworks/models/"
+)
+
+func init() {
+	Register("fireworks", NewFireworks)
+}
+
+// NewFireworks creates a Fireworks AI provider. Fireworks serves an
+// OpenAI-compatible API; its model IDs are account-scoped paths such as
+// "accounts/fireworks/models/llama-v3p1-8b-instruct".
+func NewFireworks() Provider {
+	return &openai{
+		name:      "fireworks",
+		chatURL:   fireworksChatURL,
+		modelsURL: fireworksModelsURL,
+		embedURL:  fireworksEmbedURL,
+		chatModel: fireworksChatModel,
+		modelID:   fireworksModelID,
+	}
+}
+
+func fireworksChatURL(req Request) string {
+	return fireworksBaseURL(req.BaseURL) + "/v1/chat/completions"
+}
+
+func fireworksEmbedURL(req EmbedRequest) string {
+	return fireworksBaseURL(req.BaseURL) + "/v1/embeddings"
+}
+
+func fireworksModelsURL(baseURL string) string {
+	return fireworksBaseURL(baseURL) + "/v1/models"
+}
+
+func fireworksBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return fireworksDefaultURL
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// fireworksModelID expands a bare model name to Fireworks' public model
+// namespace, so profiles can use "llama-v3p1-8b-instruct". Full IDs,
+// including models under other accounts, are sent unchanged.
+func fireworksModelID(model string) string {
+	if strings.HasPrefix(model, "accounts/") {
+		return model
+	}
+	return fireworksModelsPrefix + model
+}
+
+// fireworksChatModel skips embedding models.
+func fireworksChatModel(id string) bool {
+	return !strings.Contains(id, "embed")
+}
@@ -0,0 +1,55 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFireworksModelID(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"llama-v3p1-8b-instruct", "accounts/fireworks/models/llama-v3p1-8b-instruct"},
+		{"accounts/fireworks/models/mixtral-8x7b-instruct", "accounts/fireworks/models/mixtral-8x7b-instruct"},
+		{"accounts/acme/models/my-fine-tune", "accounts/acme/models/my-fine-tune"},
+	}
+
+	for _, tt := range tests {
+		if got := fireworksModelID(tt.model); got != tt.want {
+			t.Errorf("fireworksModelID(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestFireworks_Complete(t *testing.T) {
+	var gotPath string
+	var gotBody openaiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewFireworks().Complete(context.Background(), Request{
+		Model:    "llama-v3p1-8b-instruct",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "fw-key", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Model != "llama-v3p1-8b-instruct" {
+		t.Errorf("Model = %q, want the profile's model", resp.Model)
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotBody.Model != "accounts/fireworks/models/llama-v3p1-8b-instruct" {
+		t.Errorf("request model = %q", gotBody.Model)
+	}
+}
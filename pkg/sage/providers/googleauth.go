@@ -0,0 +1,275 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	googleTokenURL   = "https://oauth2.googleapis.com/token"
+	googleCloudScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// googleCredentials is a Google credentials JSON file: a service-account key
+// or the authorized_user file written by
+// "gcloud auth application-default login".
+type googleCredentials struct {
+	Type string `json:"type"`
+
+	// service_account
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+
+	// authorized_user
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+	QuotaProjectID string `json:"quota_project_id"`
+}
+
+// loadGoogleCredentials reads credentials from a path, or inline JSON, or
+// Application Default Credentials when source is empty:
+// GOOGLE_APPLICATION_CREDENTIALS, then gcloud's well-known file.
+func loadGoogleCredentials(source string) (*googleCredentials, error) {
+	var data []byte
+	if strings.HasPrefix(strings.TrimSpace(source), "{") {
+		data = []byte(source)
+	} else {
+		path := source
+		if path == "" {
+			path = googleADCPath()
+		}
+		if path == "" {
+			return nil, fmt.Errorf("%w: no Google credentials (set GOOGLE_APPLICATION_CREDENTIALS or run gcloud auth application-default login)", ErrUnauthorized)
+		}
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read Google credentials: %w", err)
+		}
+	}
+
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("invalid Google credentials: %w", err)
+	}
+	if creds.Type != "service_account" && creds.Type != "authorized_user" {
+		return nil, fmt.Errorf("unsupported Google credentials type %q (want service_account or authorized_user)", creds.Type)
+	}
+	return &creds, nil
+}
+
+// googleADCPath returns the Application Default Credentials file, or "" if
+// there is none.
+func googleADCPath() string {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		return path
+	}
+
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if runtime.GOOS == "windows" {
+			dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gcloud")
+		}
+	}
+	path := filepath.Join(dir, "application_default_credentials.json")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// project returns the credentials' project, if they name one.
+func (c *googleCredentials) project() string {
+	if c.ProjectID != "" {
+		return c.ProjectID
+	}
+	return c.QuotaProjectID
+}
+
+func (c *googleCredentials) tokenURL() string {
+	if c.TokenURI != "" {
+		return c.TokenURI
+	}
+	return googleTokenURL
+}
+
+// googleTokens caches access tokens by credential, since each is valid for
+// an hour and fetching one costs a round trip. The lock isn't held while a
+// token is fetched; concurrent requests for the same credential wait for one
+// fetch instead.
+var googleTokens = struct {
+	sync.Mutex
+	m       map[string]googleToken
+	pending map[string]*googleFetch
+}{m: make(map[string]googleToken), pending: make(map[string]*googleFetch)}
+
+type googleToken struct {
+	value  string
+	expiry time.Time
+}
+
+// googleFetch is a token fetch in progress; done is closed when it ends.
+type googleFetch struct {
+	done  chan struct{}
+	token googleToken
+	err   error
+}
+
+// accessToken returns a cached OAuth access token, fetching a new one when
+// it is missing or about to expire.
+func (c *googleCredentials) accessToken(ctx context.Context, client *http.Client) (string, error) {
+	key := c.ClientEmail + "|" + c.PrivateKeyID + "|" + c.ClientID + "|" + c.RefreshToken
+
+	for {
+		googleTokens.Lock()
+		if t, ok := googleTokens.m[key]; ok && time.Until(t.expiry) > time.Minute {
+			googleTokens.Unlock()
+			return t.value, nil
+		}
+
+		f, fetching := googleTokens.pending[key]
+		if !fetching {
+			f = &googleFetch{done: make(chan struct{})}
+			googleTokens.pending[key] = f
+			googleTokens.Unlock()
+
+			f.token, f.err = c.fetchToken(ctx, client)
+
+			googleTokens.Lock()
+			delete(googleTokens.pending, key)
+			if f.err == nil {
+				googleTokens.m[key] = f.token
+			}
+			googleTokens.Unlock()
+			close(f.done)
+			return f.token.value, f.err
+		}
+		googleTokens.Unlock()
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		// A fetch cancelled by its own caller's context says nothing about
+		// this request's, so try again
+		if errors.Is(f.err, context.Canceled) || errors.Is(f.err, context.DeadlineExceeded) {
+			continue
+		}
+		return f.token.value, f.err
+	}
+}
+
+func (c *googleCredentials) fetchToken(ctx context.Context, client *http.Client) (googleToken, error) {
+	form := url.Values{}
+	if c.Type == "service_account" {
+		assertion, err := c.jwtAssertion(time.Now())
+		if err != nil {
+			return googleToken{}, err
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	} else {
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", c.ClientID)
+		form.Set("client_secret", c.ClientSecret)
+		form.Set("refresh_token", c.RefreshToken)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return googleToken{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return googleToken{}, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return googleToken{}, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return googleToken{}, fmt.Errorf("%w: Google token request failed (%d): %s %s",
+			ErrUnauthorized, resp.StatusCode, result.Error, result.ErrorDescription)
+	}
+
+	return googleToken{
+		value:  result.AccessToken,
+		expiry: time.Now().Add(time.Duration(result.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// jwtAssertion signs the RS256 JWT a service account exchanges for a token.
+func (c *googleCredentials) jwtAssertion(now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(c.PrivateKey))
+	if block == nil {
+		return "", errors.New("invalid service account private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid service account private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("service account private key is not RSA")
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": c.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   c.ClientEmail,
+		"scope": googleCloudScope,
+		"aud":   c.tokenURL(),
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
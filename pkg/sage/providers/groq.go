@@ -0,0 +1,51 @@
+package providers
+
+import "strings"
+
+const groqDefaultURL = "https://api.groq.com/openai"
+
+func init() {
+	Register("groq", NewGroq)
+}
+
+// NewGroq creates a Groq provider. Groq serves an OpenAI-compatible API,
+// including Whisper transcription and PlayAI speech, under
+// api.groq.com/openai.
+func NewGroq() Provider {
+	return &openai{
+		name:          "groq",
+		chatURL:       groqChatURL,
+		modelsURL:     groqModelsURL,
+		transcribeURL: groqTranscribeURL,
+		speechURL:     groqSpeechURL,
+		chatModel:     groqChatModel,
+	}
+}
+
+func groqChatURL(req Request) string {
+	return groqBaseURL(req.BaseURL) + "/v1/chat/completions"
+}
+
+func groqModelsURL(baseURL string) string {
+	return groqBaseURL(baseURL) + "/v1/models"
+}
+
+func groqTranscribeURL(req TranscribeRequest) string {
+	return groqBaseURL(req.BaseURL) + "/v1/audio/transcriptions"
+}
+
+func groqSpeechURL(req SpeechRequest) string {
+	return groqBaseURL(req.BaseURL) + "/v1/audio/speech"
+}
+
+func groqBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return groqDefaultURL
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// groqChatModel skips speech models.
+func groqChatModel(id string) bool {
+	return !strings.Contains(id, "whisper") && !strings.Contains(id, "tts")
+}
@@ -0,0 +1,71 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroq_Complete(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	p := NewGroq()
+	if p.Name() != "groq" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "groq")
+	}
+
+	resp, err := p.Complete(context.Background(), Request{Model: "llama-3.3-70b-versatile", Prompt: "hi", Endpoint: Endpoint{APIKey: "gsk-key", BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q, want %q", resp.Content, "ok")
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotAuth != "Bearer gsk-key" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+}
+
+func TestGroq_Transcribe(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"text": "hello"}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewGroq().(Transcriber).Transcribe(TranscribeRequest{
+		Model: "whisper-large-v3-turbo", Audio: []byte("wav"), Filename: "a.wav", Endpoint: Endpoint{APIKey: "gsk-key", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	if gotPath != "/v1/audio/transcriptions" || resp.Text != "hello" {
+		t.Errorf("path = %q, Text = %q", gotPath, resp.Text)
+	}
+}
+
+func TestGroq_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"id": "llama-3.3-70b-versatile"}, {"id": "whisper-large-v3"}, {"id": "playai-tts"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewGroq().ListModels("gsk-key", server.URL)
+	if err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+	if len(models) != 1 || models[0].ID != "llama-3.3-70b-versatile" {
+		t.Errorf("models = %+v, want only llama-3.3-70b-versatile", models)
+	}
+}
@@ -0,0 +1,57 @@
+package providers
+
+import (
+	"net/http"
+	"strings"
+)
+
+const lmstudioDefaultURL = "http://localhost:1234/v1"
+
+func init() {
+	Register("lmstudio", NewLMStudio)
+}
+
+// NewLMStudio creates a provider for LM Studio's local OpenAI-compatible
+// server. The base URL includes the /v1 prefix, as LM Studio displays it.
+// No API key is needed unless the server is set up to require one.
+func NewLMStudio() Provider {
+	return &openai{
+		name:      "lmstudio",
+		chatURL:   lmstudioChatURL,
+		modelsURL: lmstudioModelsURL,
+		embedURL:  lmstudioEmbedURL,
+		auth:      lmstudioAuth,
+		chatModel: lmstudioChatModel,
+	}
+}
+
+func lmstudioChatURL(req Request) string {
+	return lmstudioBaseURL(req.BaseURL) + "/chat/completions"
+}
+
+func lmstudioEmbedURL(req EmbedRequest) string {
+	return lmstudioBaseURL(req.BaseURL) + "/embeddings"
+}
+
+func lmstudioModelsURL(baseURL string) string {
+	return lmstudioBaseURL(baseURL) + "/models"
+}
+
+func lmstudioBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return lmstudioDefaultURL
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// lmstudioAuth only sets auth if an API key was provided.
+func lmstudioAuth(req *http.Request, apiKey string) {
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// lmstudioChatModel skips loaded embedding models.
+func lmstudioChatModel(id string) bool {
+	return !strings.Contains(id, "embed")
+}
@@ -0,0 +1,49 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLMStudio_Complete(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	_, err := NewLMStudio().Complete(context.Background(), Request{Model: "qwen2.5-7b-instruct", Prompt: "hi", Endpoint: Endpoint{BaseURL: server.URL + "/v1/"}})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotAuth != "" {
+		t.Errorf("Authorization = %q, want none without an API key", gotAuth)
+	}
+}
+
+func TestLMStudio_ListModels(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"data": [{"id": "qwen2.5-7b-instruct"}, {"id": "text-embedding-nomic-embed-text-v1.5"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewLMStudio().ListModels("", server.URL+"/v1")
+	if err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+	if gotPath != "/v1/models" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if len(models) != 1 || models[0].ID != "qwen2.5-7b-instruct" {
+		t.Errorf("models = %+v, want only the chat model", models)
+	}
+}
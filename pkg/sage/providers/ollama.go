@@ -3,6 +3,7 @@ package providers
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,7 +17,9 @@ func init() {
 	Register("ollama", NewOllama)
 }
 
-type ollama struct{}
+type ollama struct {
+	httpClient
+}
 
 // NewOllama creates a new Ollama provider.
 func NewOllama() Provider {
@@ -33,37 +36,59 @@ type ollamaRequest struct {
 	Model    string          `json:"model"`
 	Messages []ollamaMessage `json:"messages"`
 	Stream   bool            `json:"stream"`
+	Format   json.RawMessage `json:"format,omitempty"` // "json" or a JSON Schema
+	Options  *ollamaOptions  `json:"options,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
 }
 
 type ollamaMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  [][]byte `json:"images,omitempty"` // Encoded as base64
 }
 
 type ollamaResponse struct {
 	Message         ollamaMessage `json:"message"`
 	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"` // "stop" or "length"
 	PromptEvalCount int           `json:"prompt_eval_count"`
 	EvalCount       int           `json:"eval_count"`
 	Error           string        `json:"error,omitempty"`
 }
 
-func (o *ollama) Complete(req Request) (*Response, error) {
+func (o *ollama) Complete(ctx context.Context, req Request) (*Response, error) {
+	if err := requireImageData(req, "ollama"); err != nil {
+		return nil, err
+	}
+	if err := requireNoLogprobs(req, "ollama"); err != nil {
+		return nil, err
+	}
+	if err := requireNoReasoningEffort(req, "ollama"); err != nil {
+		return nil, err
+	}
 	body := o.buildRequest(req, false)
 
-	jsonBody, err := json.Marshal(body)
+	jsonBody, err := marshalBody(body, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", o.endpoint(req), bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.endpoint(req), bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := o.client().Do(httpReq)
 	if err != nil {
 		if strings.Contains(err.Error(), "connection refused") {
 			return nil, fmt.Errorf("ollama not running (is Ollama installed and started?)")
@@ -92,25 +117,36 @@ func (o *ollama) Complete(req Request) (*Response, error) {
 			PromptTokens:     ollamaResp.PromptEvalCount,
 			CompletionTokens: ollamaResp.EvalCount,
 		},
+		FinishReason: ollamaResp.DoneReason,
 	}, nil
 }
 
-func (o *ollama) CompleteStream(req Request) (<-chan Chunk, error) {
+func (o *ollama) CompleteStream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	if err := requireImageData(req, "ollama"); err != nil {
+		return nil, err
+	}
+	if err := requireNoLogprobs(req, "ollama"); err != nil {
+		return nil, err
+	}
+	if err := requireNoReasoningEffort(req, "ollama"); err != nil {
+		return nil, err
+	}
 	body := o.buildRequest(req, true)
 
-	jsonBody, err := json.Marshal(body)
+	jsonBody, err := marshalBody(body, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", o.endpoint(req), bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.endpoint(req), bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := o.client().Do(httpReq)
 	if err != nil {
 		if strings.Contains(err.Error(), "connection refused") {
 			return nil, fmt.Errorf("ollama not running (is Ollama installed and started?)")
@@ -129,8 +165,12 @@ func (o *ollama) CompleteStream(req Request) (<-chan Chunk, error) {
 		defer close(ch)
 		defer resp.Body.Close()
 
+		idle := newIdleTimer(req.IdleTimeout, resp.Body)
+		defer idle.stop()
+
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
+			idle.reset()
 			line := scanner.Text()
 
 			// Skip empty lines
@@ -140,29 +180,34 @@ func (o *ollama) CompleteStream(req Request) (<-chan Chunk, error) {
 
 			var streamResp ollamaResponse
 			if err := json.Unmarshal([]byte(line), &streamResp); err != nil {
-				ch <- Chunk{Error: fmt.Errorf("failed to parse stream data: %w", err)}
+				sendChunk(ctx, ch, Chunk{Error: idle.streamParseError(scanner, err)})
 				return
 			}
 
 			if streamResp.Error != "" {
-				ch <- Chunk{Error: fmt.Errorf("ollama error: %s", streamResp.Error)}
+				sendChunk(ctx, ch, Chunk{Error: fmt.Errorf("ollama error: %s", streamResp.Error)})
 				return
 			}
 
 			// Send content chunk
 			if streamResp.Message.Content != "" {
-				ch <- Chunk{Content: streamResp.Message.Content}
+				if !sendChunk(ctx, ch, Chunk{Content: streamResp.Message.Content}) {
+					return
+				}
 			}
 
-			// Check for completion
+			// Check for completion; the final chunk carries the token counts
 			if streamResp.Done {
-				ch <- Chunk{Done: true}
+				sendChunk(ctx, ch, Chunk{Done: true, FinishReason: streamResp.DoneReason, Usage: &Usage{
+					PromptTokens:     streamResp.PromptEvalCount,
+					CompletionTokens: streamResp.EvalCount,
+				}})
 				return
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
-			ch <- Chunk{Error: fmt.Errorf("stream read error: %w", err)}
+			sendChunk(ctx, ch, Chunk{Error: idle.readError(err)})
 		}
 	}()
 
@@ -179,16 +224,41 @@ func (o *ollama) buildRequest(req Request, stream bool) ollamaRequest {
 		})
 	}
 
-	messages = append(messages, ollamaMessage{
-		Role:    "user",
-		Content: req.Prompt,
-	})
+	for _, m := range conversation(req) {
+		msg := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, img := range m.Images {
+			msg.Images = append(msg.Images, img.Data)
+		}
+		messages = append(messages, msg)
+	}
 
-	return ollamaRequest{
+	// Ollama continues a trailing assistant message
+	if req.Continue != "" {
+		messages = append(messages, ollamaMessage{Role: "assistant", Content: req.Continue})
+	}
+
+	r := ollamaRequest{
 		Model:    req.Model,
 		Messages: messages,
 		Stream:   stream,
 	}
+	if req.Temperature != nil || req.TopP != nil || req.FrequencyPenalty != nil || req.PresencePenalty != nil || req.Seed != nil {
+		r.Options = &ollamaOptions{
+			Temperature:      req.Temperature,
+			TopP:             req.TopP,
+			FrequencyPenalty: req.FrequencyPenalty,
+			PresencePenalty:  req.PresencePenalty,
+			Seed:             req.Seed,
+		}
+	}
+	if f := req.ResponseFormat; f != nil {
+		r.Format = json.RawMessage(`"json"`)
+		if f.Type == "json_schema" {
+			r.Format = f.Schema
+		}
+	}
+
+	return r
 }
 
 func (o *ollama) endpoint(req Request) string {
@@ -211,12 +281,75 @@ func (o *ollama) setHeaders(req *http.Request, apiKey string) {
 func (o *ollama) handleError(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
 
+	msg := string(body)
 	var errResp ollamaResponse
 	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
-		return fmt.Errorf("ollama error (%d): %s", resp.StatusCode, errResp.Error)
+		msg = errResp.Error
 	}
+	return fmt.Errorf("ollama %w", classifyStatus(resp, "", msg))
+}
+
+type ollamaEmbedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
 
-	return fmt.Errorf("ollama error (%d): %s", resp.StatusCode, string(body))
+type ollamaEmbedResponse struct {
+	Embeddings      [][]float64 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// Embed calls Ollama's /api/embed endpoint.
+func (o *ollama) Embed(req EmbedRequest) (*EmbedResponse, error) {
+	baseURL := req.BaseURL
+	if baseURL == "" {
+		baseURL = ollamaDefaultURL
+	}
+	endpoint := strings.TrimSuffix(baseURL, "/") + "/api/embed"
+
+	jsonBody, err := json.Marshal(ollamaEmbedRequest{Model: req.Model, Input: req.Input, Dimensions: req.Dimensions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		if strings.Contains(err.Error(), "connection refused") {
+			return nil, fmt.Errorf("ollama not running (is Ollama installed and started?)")
+		}
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+
+	var embedResp ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if embedResp.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", embedResp.Error)
+	}
+	if len(embedResp.Embeddings) != len(req.Input) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(embedResp.Embeddings), len(req.Input))
+	}
+
+	return &EmbedResponse{
+		Embeddings: embedResp.Embeddings,
+		Model:      req.Model,
+		Usage:      Usage{PromptTokens: embedResp.PromptEvalCount},
+	}, nil
 }
 
 // ListModels returns available models from the local Ollama instance.
@@ -235,19 +368,18 @@ func (o *ollama) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
 		req.Header.Set("Authorization", "Bearer "+apiKey)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := o.client().Do(req)
 	if err != nil {
 		// Check for connection refused (Ollama not running)
 		if strings.Contains(err.Error(), "connection refused") {
-			return nil, fmt.Errorf("ollama not running at %s (is Ollama installed and started?)", endpoint)
+			return nil, fmt.Errorf("ollama not running at %s (is Ollama installed and started?): %w", endpoint, err)
 		}
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
+		return nil, o.handleError(resp)
 	}
 
 	var result ollamaTagsResponse
@@ -1,7 +1,10 @@
 package providers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -76,6 +79,65 @@ func TestOllama_BuildRequest_NoSystem(t *testing.T) {
 	}
 }
 
+func TestOllama_BuildRequest_Messages(t *testing.T) {
+	o := &ollama{}
+
+	built := o.buildRequest(Request{
+		Model:    "llama3.2",
+		Messages: []Message{{Role: "user", Content: "Hi"}, {Role: "assistant", Content: "Hello"}},
+		Prompt:   "Again",
+	}, false)
+
+	if len(built.Messages) != 3 || built.Messages[1].Role != "assistant" || built.Messages[2].Content != "Again" {
+		t.Errorf("Messages = %+v", built.Messages)
+	}
+}
+
+func TestOllama_Images(t *testing.T) {
+	o := &ollama{}
+
+	built := o.buildRequest(Request{Model: "llava", Prompt: "What's this?", Images: []Image{{Data: []byte("png"), MediaType: "image/png"}}}, false)
+	data, _ := json.Marshal(built.Messages[0])
+	if string(data) != `{"role":"user","content":"What's this?","images":["cG5n"]}` {
+		t.Errorf("message = %s", data)
+	}
+
+	// Ollama can't fetch URLs
+	_, err := o.Complete(context.Background(), Request{Model: "llava", Prompt: "hi", Images: []Image{{URL: "https://example.com/cat.png"}}})
+	if err == nil {
+		t.Error("Complete() should reject image URLs")
+	}
+}
+
+func TestOllama_BuildRequest_ResponseFormat(t *testing.T) {
+	o := &ollama{}
+
+	built := o.buildRequest(Request{Model: "llama3.2", Prompt: "hi", ResponseFormat: &ResponseFormat{Type: "json_object"}}, false)
+	if string(built.Format) != `"json"` {
+		t.Errorf("Format = %s, want \"json\"", built.Format)
+	}
+
+	schema := json.RawMessage(`{"type": "object"}`)
+	built = o.buildRequest(Request{Model: "llama3.2", Prompt: "hi", ResponseFormat: &ResponseFormat{Type: "json_schema", Schema: schema}}, false)
+	if string(built.Format) != string(schema) {
+		t.Errorf("Format = %s, want the schema", built.Format)
+	}
+}
+
+func TestOllama_BuildRequest_Sampling(t *testing.T) {
+	o := &ollama{}
+
+	if built := o.buildRequest(Request{Model: "llama3.2", Prompt: "hi"}, false); built.Options != nil {
+		t.Errorf("Options = %+v, want nil without sampling parameters", built.Options)
+	}
+
+	temp := 0.2
+	built := o.buildRequest(Request{Model: "llama3.2", Prompt: "hi", Temperature: &temp}, false)
+	if built.Options == nil || built.Options.Temperature == nil || *built.Options.Temperature != 0.2 {
+		t.Errorf("Options = %+v, want temperature 0.2", built.Options)
+	}
+}
+
 func TestOllama_Endpoint(t *testing.T) {
 	o := &ollama{}
 
@@ -126,3 +188,31 @@ func TestOllama_SetHeaders_WithAuth(t *testing.T) {
 		t.Errorf("Authorization = %q, want %q", got, "Bearer test-api-key")
 	}
 }
+
+func TestOllama_Embed(t *testing.T) {
+	var gotPath string
+	var gotBody ollamaEmbedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"embeddings": [[0.1, 0.2], [0.3, 0.4]], "prompt_eval_count": 6}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOllama().(Embedder).Embed(EmbedRequest{
+		Model: "nomic-embed-text", Input: []string{"a", "b"}, Endpoint: Endpoint{BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	if gotPath != "/api/embed" {
+		t.Errorf("path = %q, want %q", gotPath, "/api/embed")
+	}
+	if len(gotBody.Input) != 2 {
+		t.Errorf("input = %v, want 2 texts", gotBody.Input)
+	}
+	if len(resp.Embeddings) != 2 || resp.Usage.PromptTokens != 6 {
+		t.Errorf("resp = %+v", resp)
+	}
+}
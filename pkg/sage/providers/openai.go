@@ -3,9 +3,12 @@ package providers
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"strings"
 )
@@ -16,7 +19,23 @@ func init() {
 	Register("openai", NewOpenAI)
 }
 
-type openai struct{}
+// openai implements the OpenAI chat completions API. OpenAI-compatible
+// providers reuse it, overriding the name, URLs, and auth header.
+type openai struct {
+	httpClient
+	name string // Registered name (default "openai")
+
+	// Hooks for compatible providers; nil fields use OpenAI's behaviour.
+	chatURL       func(req Request) string
+	modelsURL     func(baseURL string) string
+	embedURL      func(req EmbedRequest) string      // Nil if a compatible provider has no embeddings
+	transcribeURL func(req TranscribeRequest) string // Nil if a compatible provider has no transcriptions
+	speechURL     func(req SpeechRequest) string     // Nil if a compatible provider has no text-to-speech
+	imageURL      func(req ImageRequest) string      // Nil if a compatible provider has no image generation
+	auth          func(r *http.Request, apiKey string)
+	chatModel     func(id string) bool      // Filters ListModels to chat models
+	modelID       func(model string) string // Maps a profile's model to the API's ID
+}
 
 // NewOpenAI creates a new OpenAI provider.
 func NewOpenAI() Provider {
@@ -24,6 +43,9 @@ func NewOpenAI() Provider {
 }
 
 func (o *openai) Name() string {
+	if o.name != "" {
+		return o.name
+	}
 	return "openai"
 }
 
@@ -35,22 +57,178 @@ type openaiRequest struct {
 	MaxTokens           int             `json:"max_tokens,omitempty"`
 	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
 	Stream              bool            `json:"stream,omitempty"`
+	User                string          `json:"user,omitempty"`
+
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+
+	Logprobs    bool `json:"logprobs,omitempty"`
+	TopLogprobs int  `json:"top_logprobs,omitempty"`
+
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+
+	ResponseFormat *openaiResponseFormat `json:"response_format,omitempty"`
+	StreamOptions  *openaiStreamOptions  `json:"stream_options,omitempty"`
+}
+
+// openaiStreamOptions asks for a final stream chunk carrying token usage.
+type openaiStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openaiResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openaiJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openaiJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
 }
 
 type openaiMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+
+	// Parts replaces Content in a request turn with images
+	Parts []openaiContentPart `json:"-"`
+}
+
+type openaiContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openaiImageURL `json:"image_url,omitempty"`
+	File     *openaiFile     `json:"file,omitempty"`
+}
+
+type openaiImageURL struct {
+	URL string `json:"url"`
+}
+
+type openaiFile struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data"` // base64 data: URL
+}
+
+// MarshalJSON sends the message's content as a list of parts when it has
+// any, and as a plain string otherwise.
+func (m openaiMessage) MarshalJSON() ([]byte, error) {
+	if m.Parts == nil {
+		type plain openaiMessage
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		Role    string              `json:"role"`
+		Content []openaiContentPart `json:"content"`
+	}{m.Role, m.Parts})
+}
+
+// openaiTurn converts a conversation turn, attaching its images as
+// image_url parts and its documents as file parts. Plain text documents are
+// sent as text parts, since file inputs only take PDFs.
+func openaiTurn(m Message) openaiMessage {
+	msg := openaiMessage{Role: m.Role, Content: m.Content}
+	if len(m.Images) == 0 && len(m.Documents) == 0 {
+		return msg
+	}
+	msg.Parts = []openaiContentPart{{Type: "text", Text: m.Content}}
+	for _, img := range m.Images {
+		msg.Parts = append(msg.Parts, openaiContentPart{Type: "image_url", ImageURL: &openaiImageURL{URL: img.dataURL()}})
+	}
+	for _, doc := range m.Documents {
+		if doc.isText() {
+			msg.Parts = append(msg.Parts, openaiContentPart{Type: "text", Text: string(doc.Data)})
+			continue
+		}
+		msg.Parts = append(msg.Parts, openaiContentPart{Type: "file", File: &openaiFile{Filename: doc.Name, FileData: doc.dataURL()}})
+	}
+	return msg
 }
 
 type openaiResponse struct {
+	Model   string         `json:"model,omitempty"`
 	Choices []openaiChoice `json:"choices"`
 	Usage   openaiUsage    `json:"usage"`
 	Error   *openaiError   `json:"error,omitempty"`
+
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+
+	// Perplexity extensions
+	Citations     []string             `json:"citations,omitempty"`
+	SearchResults []openaiSearchResult `json:"search_results,omitempty"`
+}
+
+type openaiSearchResult struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// citations returns the sources a response cited. Search results carry
+// titles; the older citations field has only URLs.
+func (r *openaiResponse) citations() []Citation {
+	var cs []Citation
+	if len(r.SearchResults) > 0 {
+		for _, s := range r.SearchResults {
+			cs = append(cs, Citation{URL: s.URL, Title: s.Title})
+		}
+		return cs
+	}
+	for _, url := range r.Citations {
+		cs = append(cs, Citation{URL: url})
+	}
+	return cs
 }
 
 type openaiChoice struct {
-	Message openaiMessage `json:"message"`
-	Delta   openaiMessage `json:"delta"`
+	Message      openaiMessage   `json:"message"`
+	Delta        openaiMessage   `json:"delta"`
+	Logprobs     *openaiLogprobs `json:"logprobs"`
+	FinishReason string          `json:"finish_reason"`
+}
+
+// openaiFinishReason normalizes a chat completion's finish_reason.
+func openaiFinishReason(reason string) string {
+	switch reason {
+	case "tool_calls", "function_call":
+		return FinishToolUse
+	}
+	return reason // stop, length and content_filter already match
+}
+
+type openaiLogprobs struct {
+	Content []openaiTokenLogprob `json:"content"`
+}
+
+type openaiTokenLogprob struct {
+	Token       string               `json:"token"`
+	Logprob     float64              `json:"logprob"`
+	TopLogprobs []openaiTokenLogprob `json:"top_logprobs"`
+}
+
+// tokens converts a choice's logprobs, if it has any.
+func (c *openaiChoice) tokens() []TokenLogprob {
+	if c.Logprobs == nil {
+		return nil
+	}
+	return convertOpenAILogprobs(c.Logprobs.Content)
+}
+
+func convertOpenAILogprobs(lps []openaiTokenLogprob) []TokenLogprob {
+	if len(lps) == 0 {
+		return nil
+	}
+	tokens := make([]TokenLogprob, len(lps))
+	for i, lp := range lps {
+		tokens[i] = TokenLogprob{
+			Token:       lp.Token,
+			Logprob:     lp.Logprob,
+			TopLogprobs: convertOpenAILogprobs(lp.TopLogprobs),
+		}
+	}
+	return tokens
 }
 
 type openaiUsage struct {
@@ -64,22 +242,29 @@ type openaiError struct {
 	Code    string `json:"code"`
 }
 
-func (o *openai) Complete(req Request) (*Response, error) {
+func (o *openai) Complete(ctx context.Context, req Request) (*Response, error) {
 	body := o.buildRequest(req, false)
 
-	jsonBody, err := json.Marshal(body)
+	jsonBody, err := marshalBody(body, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", o.endpoint(req), bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.endpoint(req), bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+	if req.RequestID != "" {
+		httpReq.Header.Set("X-Client-Request-Id", req.RequestID)
+	}
+	if req.IdempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.IdempotencyKey)
+	}
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := o.client().Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -105,25 +290,38 @@ func (o *openai) Complete(req Request) (*Response, error) {
 			PromptTokens:     openaiResp.Usage.PromptTokens,
 			CompletionTokens: openaiResp.Usage.CompletionTokens,
 		},
+		RequestID: resp.Header.Get("x-request-id"),
+		Citations: openaiResp.citations(),
+
+		FinishReason:      openaiFinishReason(openaiResp.Choices[0].FinishReason),
+		SystemFingerprint: openaiResp.SystemFingerprint,
+		Logprobs:          openaiResp.Choices[0].tokens(),
 	}, nil
 }
 
-func (o *openai) CompleteStream(req Request) (<-chan Chunk, error) {
+func (o *openai) CompleteStream(ctx context.Context, req Request) (<-chan Chunk, error) {
 	body := o.buildRequest(req, true)
 
-	jsonBody, err := json.Marshal(body)
+	jsonBody, err := marshalBody(body, req.ExtraBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", o.endpoint(req), bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.endpoint(req), bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+	if req.RequestID != "" {
+		httpReq.Header.Set("X-Client-Request-Id", req.RequestID)
+	}
+	if req.IdempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.IdempotencyKey)
+	}
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := o.client().Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -139,44 +337,71 @@ func (o *openai) CompleteStream(req Request) (<-chan Chunk, error) {
 		defer close(ch)
 		defer resp.Body.Close()
 
+		idle := newIdleTimer(req.IdleTimeout, resp.Body)
+		defer idle.stop()
+
+		// Citations, the fingerprint and the finish reason arrive with the
+		// content chunks, and usage in a final chunk without choices; keep
+		// the latest
+		var citations []Citation
+		var fingerprint, finish string
+		var usage *Usage
+
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
-			line := scanner.Text()
+			idle.reset()
+			field, data := sseField(scanner.Text())
 
-			// Skip empty lines
-			if line == "" {
+			// Keep-alive comments
+			if field == ":" {
+				if req.OnHeartbeat != nil {
+					req.OnHeartbeat()
+				}
 				continue
 			}
 
-			// Check for end of stream
-			if line == "data: [DONE]" {
-				ch <- Chunk{Done: true}
-				return
-			}
-
-			// Parse SSE data line
-			if !strings.HasPrefix(line, "data: ") {
+			// Skip empty lines and other SSE fields
+			if field != "data" {
 				continue
 			}
 
-			data := strings.TrimPrefix(line, "data: ")
+			// Check for end of stream
+			if data == "[DONE]" {
+				sendChunk(ctx, ch, Chunk{Done: true, Citations: citations, SystemFingerprint: fingerprint, Usage: usage, FinishReason: finish})
+				return
+			}
 
 			var streamResp openaiResponse
 			if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
-				ch <- Chunk{Error: fmt.Errorf("failed to parse stream data: %w", err)}
+				sendChunk(ctx, ch, Chunk{Error: idle.streamParseError(scanner, err)})
 				return
 			}
 
+			if cs := streamResp.citations(); cs != nil {
+				citations = cs
+			}
+			if streamResp.SystemFingerprint != "" {
+				fingerprint = streamResp.SystemFingerprint
+			}
+			if u := streamResp.Usage; u.PromptTokens > 0 || u.CompletionTokens > 0 {
+				usage = &Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+			}
+
 			if len(streamResp.Choices) > 0 {
-				content := streamResp.Choices[0].Delta.Content
-				if content != "" {
-					ch <- Chunk{Content: content}
+				choice := &streamResp.Choices[0]
+				if choice.FinishReason != "" {
+					finish = openaiFinishReason(choice.FinishReason)
+				}
+				if choice.Delta.Content != "" {
+					if !sendChunk(ctx, ch, Chunk{Content: choice.Delta.Content, Logprobs: choice.tokens()}) {
+						return
+					}
 				}
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
-			ch <- Chunk{Error: fmt.Errorf("stream read error: %w", err)}
+			sendChunk(ctx, ch, Chunk{Error: idle.readError(err)})
 		}
 	}()
 
@@ -193,20 +418,61 @@ func (o *openai) buildRequest(req Request, stream bool) openaiRequest {
 		})
 	}
 
-	messages = append(messages, openaiMessage{
-		Role:    "user",
-		Content: req.Prompt,
-	})
+	for _, m := range conversation(req) {
+		messages = append(messages, openaiTurn(m))
+	}
+
+	// Chat completions can't prefill, so ask for the rest explicitly
+	if req.Continue != "" {
+		messages = append(messages,
+			openaiMessage{Role: "assistant", Content: req.Continue},
+			openaiMessage{Role: "user", Content: continuePrompt},
+		)
+	}
+
+	model := req.Model
+	if o.modelID != nil {
+		model = o.modelID(model)
+	}
 
 	r := openaiRequest{
-		Model:    req.Model,
+		Model:    model,
 		Messages: messages,
 		Stream:   stream,
+		User:     req.User,
+
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Seed:             req.Seed,
+
+		Logprobs:    req.Logprobs,
+		TopLogprobs: req.TopLogprobs,
+
+		ReasoningEffort: req.ReasoningEffort,
+	}
+
+	// Reasoning models reject sampling parameters with a 400 (see models.json)
+	if spec, ok := lookupOpenAIModel(req.catalogModel()); ok && spec.NoTemperature {
+		r.Temperature = nil
+		r.TopP = nil
+	}
+
+	if stream {
+		r.StreamOptions = &openaiStreamOptions{IncludeUsage: true}
 	}
 
-	// Newer models (o1, o3, gpt-4o) use max_completion_tokens instead of max_tokens
+	if f := req.ResponseFormat; f != nil {
+		r.ResponseFormat = &openaiResponseFormat{Type: f.Type}
+		if f.Type == "json_schema" {
+			r.ResponseFormat.JSONSchema = &openaiJSONSchema{Name: f.name(), Schema: f.Schema}
+		}
+	}
+
+	// Newer models use max_completion_tokens instead of max_tokens (see models.json)
 	if req.MaxTokens > 0 {
-		if o.usesMaxCompletionTokens(req.Model) {
+		if o.usesMaxCompletionTokens(req.catalogModel()) {
 			r.MaxCompletionTokens = req.MaxTokens
 		} else {
 			r.MaxTokens = req.MaxTokens
@@ -218,16 +484,31 @@ func (o *openai) buildRequest(req Request, stream bool) openaiRequest {
 
 // usesMaxCompletionTokens returns true for models that require max_completion_tokens.
 func (o *openai) usesMaxCompletionTokens(model string) bool {
-	// Newer models use max_completion_tokens instead of max_tokens:
-	// - o1, o3 reasoning models
-	// - gpt-4o and newer (gpt-5, etc.)
-	return strings.HasPrefix(model, "o1") ||
-		strings.HasPrefix(model, "o3") ||
-		strings.Contains(model, "gpt-4o") ||
-		strings.Contains(model, "gpt-5")
+	spec, ok := lookupOpenAIModel(model)
+	return ok && spec.MaxTokensParam == "max_completion_tokens"
+}
+
+// lookupOpenAIModel looks up a model in the catalog, also matching
+// fine-tuned IDs ("ft:gpt-4o-mini:org::id") and vendor-qualified names
+// ("openai/gpt-5") by their base model.
+func lookupOpenAIModel(model string) (ModelSpec, bool) {
+	if spec, ok := LookupModel(model); ok {
+		return spec, true
+	}
+	base := strings.TrimPrefix(model, "ft:")
+	if i := strings.LastIndex(base, "/"); i >= 0 {
+		base = base[i+1:]
+	}
+	if base == model {
+		return ModelSpec{}, false
+	}
+	return LookupModel(base)
 }
 
 func (o *openai) endpoint(req Request) string {
+	if o.chatURL != nil {
+		return o.chatURL(req)
+	}
 	if req.BaseURL != "" {
 		return strings.TrimSuffix(req.BaseURL, "/") + "/v1/chat/completions"
 	}
@@ -236,6 +517,14 @@ func (o *openai) endpoint(req Request) string {
 
 func (o *openai) setHeaders(req *http.Request, apiKey string) {
 	req.Header.Set("Content-Type", "application/json")
+	o.setAuth(req, apiKey)
+}
+
+func (o *openai) setAuth(req *http.Request, apiKey string) {
+	if o.auth != nil {
+		o.auth(req, apiKey)
+		return
+	}
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 }
 
@@ -244,23 +533,376 @@ func (o *openai) handleError(resp *http.Response) error {
 
 	var errResp openaiResponse
 	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
-		switch resp.StatusCode {
-		case http.StatusUnauthorized:
-			return fmt.Errorf("invalid API key: %s", errResp.Error.Message)
-		case http.StatusTooManyRequests:
-			return fmt.Errorf("rate limited: %s", errResp.Error.Message)
+		return classifyStatus(resp, errResp.Error.Code, errResp.Error.Message)
+	}
+	return classifyStatus(resp, "", string(body))
+}
+
+type openaiEmbedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openaiEmbedResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Model string      `json:"model"`
+	Usage openaiUsage `json:"usage"`
+}
+
+// Embed calls the embeddings endpoint.
+func (o *openai) Embed(req EmbedRequest) (*EmbedResponse, error) {
+	endpoint := "https://api.openai.com/v1/embeddings"
+	if o.embedURL != nil {
+		endpoint = o.embedURL(req)
+	} else if o.name != "" {
+		return nil, errUnsupported(o.name, "embeddings")
+	} else if req.BaseURL != "" {
+		endpoint = strings.TrimSuffix(req.BaseURL, "/") + "/v1/embeddings"
+	}
+
+	model := req.Model
+	if o.modelID != nil {
+		model = o.modelID(model)
+	}
+	jsonBody, err := json.Marshal(openaiEmbedRequest{Model: model, Input: req.Input, Dimensions: req.Dimensions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+
+	var embedResp openaiEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Place each vector by its index rather than trusting the order
+	embeddings := make([][]float64, len(req.Input))
+	for _, d := range embedResp.Data {
+		if d.Index < 0 || d.Index >= len(embeddings) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+
+	return &EmbedResponse{
+		Embeddings: embeddings,
+		Model:      req.Model,
+		Usage:      Usage{PromptTokens: embedResp.Usage.PromptTokens},
+	}, nil
+}
+
+type openaiTranscribeResponse struct {
+	Text string `json:"text"`
+}
+
+// Transcribe uploads audio to the transcriptions endpoint.
+func (o *openai) Transcribe(req TranscribeRequest) (*TranscribeResponse, error) {
+	endpoint := "https://api.openai.com/v1/audio/transcriptions"
+	if o.transcribeURL != nil {
+		endpoint = o.transcribeURL(req)
+	} else if o.name != "" {
+		return nil, errUnsupported(o.name, "transcription")
+	} else if req.BaseURL != "" {
+		endpoint = strings.TrimSuffix(req.BaseURL, "/") + "/v1/audio/transcriptions"
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := [][2]string{
+		{"model", req.Model},
+		{"language", req.Language},
+		{"prompt", req.Prompt},
+		{"response_format", "json"},
+	}
+	for _, f := range fields {
+		if f[1] != "" {
+			form.WriteField(f[0], f[1])
+		}
+	}
+	file, err := form.CreateFormFile("file", req.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	file.Write(req.Audio)
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", endpoint, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setAuth(httpReq, req.APIKey)
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+	setExtraHeaders(httpReq, req.Headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+
+	var transcribeResp openaiTranscribeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&transcribeResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &TranscribeResponse{Text: transcribeResp.Text, Model: req.Model}, nil
+}
+
+type openaiSpeechRequest struct {
+	Model          string   `json:"model"`
+	Input          string   `json:"input"`
+	Voice          string   `json:"voice"`
+	ResponseFormat string   `json:"response_format,omitempty"`
+	Speed          *float64 `json:"speed,omitempty"`
+}
+
+// Speak calls the speech endpoint, which responds with the audio itself.
+func (o *openai) Speak(req SpeechRequest) (*SpeechResponse, error) {
+	endpoint := "https://api.openai.com/v1/audio/speech"
+	if o.speechURL != nil {
+		endpoint = o.speechURL(req)
+	} else if o.name != "" {
+		return nil, errUnsupported(o.name, "text-to-speech")
+	} else if req.BaseURL != "" {
+		endpoint = strings.TrimSuffix(req.BaseURL, "/") + "/v1/audio/speech"
+	}
+
+	jsonBody, err := json.Marshal(openaiSpeechRequest{
+		Model:          req.Model,
+		Input:          req.Input,
+		Voice:          req.Voice,
+		ResponseFormat: req.Format,
+		Speed:          req.Speed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &SpeechResponse{Audio: audio, MediaType: resp.Header.Get("Content-Type"), Model: req.Model}, nil
+}
+
+type openaiImageRequest struct {
+	Model   string `json:"model"`
+	Prompt  string `json:"prompt"`
+	N       int    `json:"n,omitempty"`
+	Size    string `json:"size,omitempty"`
+	Quality string `json:"quality,omitempty"`
+}
+
+type openaiImageResponse struct {
+	Data []struct {
+		B64JSON       string `json:"b64_json"`
+		URL           string `json:"url"`
+		RevisedPrompt string `json:"revised_prompt"`
+	} `json:"data"`
+}
+
+// GenerateImages calls the image generations endpoint. Models return images
+// as base64 data or, like dall-e-3 by default, as URLs to download.
+func (o *openai) GenerateImages(req ImageRequest) (*ImageResponse, error) {
+	endpoint := "https://api.openai.com/v1/images/generations"
+	if o.imageURL != nil {
+		endpoint = o.imageURL(req)
+	} else if o.name != "" {
+		return nil, errUnsupported(o.name, "image generation")
+	} else if req.BaseURL != "" {
+		endpoint = strings.TrimSuffix(req.BaseURL, "/") + "/v1/images/generations"
+	}
+
+	jsonBody, err := json.Marshal(openaiImageRequest{
+		Model:   req.Model,
+		Prompt:  req.Prompt,
+		N:       req.N,
+		Size:    req.Size,
+		Quality: req.Quality,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+
+	var imageResp openaiImageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&imageResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	result := &ImageResponse{Model: req.Model}
+	for i, d := range imageResp.Data {
+		var data []byte
+		switch {
+		case d.B64JSON != "":
+			data, err = base64.StdEncoding.DecodeString(d.B64JSON)
+			if err != nil {
+				return nil, fmt.Errorf("image %d: invalid base64: %w", i, err)
+			}
+		case d.URL != "":
+			data, err = downloadImage(o.client(), d.URL)
+			if err != nil {
+				return nil, fmt.Errorf("image %d: %w", i, err)
+			}
 		default:
-			return fmt.Errorf("API error (%d): %s", resp.StatusCode, errResp.Error.Message)
+			return nil, fmt.Errorf("image %d: response has no data or URL", i)
 		}
+		result.Images = append(result.Images, GeneratedImage{
+			Data:          data,
+			MediaType:     http.DetectContentType(data),
+			RevisedPrompt: d.RevisedPrompt,
+		})
 	}
+	return result, nil
+}
 
-	return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
+// downloadImage fetches a generated image from the URL a provider returned.
+func downloadImage(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	return data, nil
+}
+
+type openaiModerationRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type openaiModerationResponse struct {
+	Model   string `json:"model"`
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		Categories     map[string]bool    `json:"categories"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
+
+// Moderate calls the moderations endpoint, which only OpenAI serves.
+func (o *openai) Moderate(req ModerationRequest) (*ModerationResponse, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "moderation")
+	}
+	endpoint := "https://api.openai.com/v1/moderations"
+	if req.BaseURL != "" {
+		endpoint = strings.TrimSuffix(req.BaseURL, "/") + "/v1/moderations"
+	}
+
+	jsonBody, err := json.Marshal(openaiModerationRequest{Model: req.Model, Input: req.Input})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setHeaders(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+
+	var modResp openaiModerationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(modResp.Results) != len(req.Input) {
+		return nil, fmt.Errorf("got %d moderation results for %d inputs", len(modResp.Results), len(req.Input))
+	}
+
+	result := &ModerationResponse{Model: modResp.Model}
+	for _, r := range modResp.Results {
+		result.Results = append(result.Results, ModerationResult{
+			Flagged:    r.Flagged,
+			Categories: r.Categories,
+			Scores:     r.CategoryScores,
+		})
+	}
+	return result, nil
 }
 
 // ListModels returns available models from OpenAI.
 func (o *openai) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
 	endpoint := "https://api.openai.com/v1/models"
-	if baseURL != "" {
+	if o.modelsURL != nil {
+		endpoint = o.modelsURL(baseURL)
+	} else if baseURL != "" {
 		endpoint = strings.TrimSuffix(baseURL, "/") + "/v1/models"
 	}
 
@@ -269,17 +911,16 @@ func (o *openai) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	o.setAuth(req, apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := o.client().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
+		return nil, o.handleError(resp)
 	}
 
 	var result openaiModelsResponse
@@ -287,10 +928,15 @@ func (o *openai) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	isChat := o.chatModel
+	if isChat == nil {
+		isChat = openaiChatModel
+	}
+
 	models := make([]ModelInfo, 0, len(result.Data))
 	for _, m := range result.Data {
 		// Filter to chat models (skip embeddings, audio, etc.)
-		if strings.Contains(m.ID, "gpt") || strings.Contains(m.ID, "o1") || strings.Contains(m.ID, "o3") {
+		if isChat(m.ID) {
 			models = append(models, ModelInfo{
 				ID:   m.ID,
 				Name: m.ID,
@@ -301,6 +947,10 @@ func (o *openai) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
 	return models, nil
 }
 
+func openaiChatModel(id string) bool {
+	return strings.Contains(id, "gpt") || strings.Contains(id, "o1") || strings.Contains(id, "o3")
+}
+
 type openaiModelsResponse struct {
 	Data []openaiModel `json:"data"`
 }
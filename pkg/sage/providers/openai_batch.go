@@ -0,0 +1,246 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// openaiBatch is a batch object from the Batch API.
+type openaiBatch struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	CreatedAt     int64  `json:"created_at"`
+	OutputFileID  string `json:"output_file_id"`
+	ErrorFileID   string `json:"error_file_id"`
+	RequestCounts struct {
+		Total     int `json:"total"`
+		Completed int `json:"completed"`
+		Failed    int `json:"failed"`
+	} `json:"request_counts"`
+	Errors *struct {
+		Data []struct {
+			Message string `json:"message"`
+			Line    *int   `json:"line"`
+		} `json:"data"`
+	} `json:"errors"`
+}
+
+// openaiBatchLine is one line of a batch input file.
+type openaiBatchLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// openaiBatchOutput is one line of a batch output or error file.
+type openaiBatchOutput struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int            `json:"status_code"`
+		Body       openaiResponse `json:"body"`
+	} `json:"response"`
+	Error *openaiError `json:"error"`
+}
+
+func (b *openaiBatch) job() *BatchJob {
+	job := &BatchJob{
+		ID:        b.ID,
+		Status:    b.Status,
+		Total:     b.RequestCounts.Total,
+		Completed: b.RequestCounts.Completed,
+		Failed:    b.RequestCounts.Failed,
+		CreatedAt: time.Unix(b.CreatedAt, 0),
+	}
+	switch b.Status {
+	case "completed", "failed", "expired", "cancelled":
+		job.Done = true
+	}
+	if b.Errors != nil {
+		for _, e := range b.Errors.Data {
+			if e.Line != nil {
+				job.Errors = append(job.Errors, fmt.Sprintf("line %d: %s", *e.Line, e.Message))
+			} else {
+				job.Errors = append(job.Errors, e.Message)
+			}
+		}
+	}
+	return job
+}
+
+// openaiAPIURL returns the URL of an OpenAI API path such as "/batches".
+func openaiAPIURL(baseURL, path string) string {
+	if baseURL == "" {
+		return "https://api.openai.com/v1" + path
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/v1" + path
+}
+
+// SubmitBatch uploads the items as a JSONL file and creates a batch job
+// against the chat completions endpoint, to finish within 24 hours.
+func (o *openai) SubmitBatch(req BatchRequest) (*BatchJob, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "batches")
+	}
+
+	var input bytes.Buffer
+	for _, item := range req.Items {
+		body, err := marshalBody(o.buildRequest(item.Request, false), item.Request.ExtraBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request %s: %w", item.ID, err)
+		}
+		line, err := json.Marshal(openaiBatchLine{CustomID: item.ID, Method: "POST", URL: "/v1/chat/completions", Body: body})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request %s: %w", item.ID, err)
+		}
+		input.Write(line)
+		input.WriteByte('\n')
+	}
+
+	file, err := o.uploadFile(req.APIKey, req.BaseURL, req.Headers, "batch", "batch.jsonl", input.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	jsonBody, err := json.Marshal(map[string]string{
+		"input_file_id":     file.ID,
+		"endpoint":          "/v1/chat/completions",
+		"completion_window": "24h",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	var batch openaiBatch
+	if err := o.apiCall("POST", openaiAPIURL(req.BaseURL, "/batches"), req.APIKey, req.Headers, jsonBody, &batch); err != nil {
+		return nil, err
+	}
+	return batch.job(), nil
+}
+
+// GetBatch returns a batch job's status and request counts.
+func (o *openai) GetBatch(req BatchJobRequest) (*BatchJob, error) {
+	batch, err := o.getBatch(req)
+	if err != nil {
+		return nil, err
+	}
+	return batch.job(), nil
+}
+
+func (o *openai) getBatch(req BatchJobRequest) (*openaiBatch, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "batches")
+	}
+	var batch openaiBatch
+	if err := o.apiCall("GET", openaiAPIURL(req.BaseURL, "/batches/"+req.ID), req.APIKey, req.Headers, nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// BatchResults downloads a finished job's output and error files.
+func (o *openai) BatchResults(req BatchJobRequest) ([]BatchItemResult, error) {
+	batch, err := o.getBatch(req)
+	if err != nil {
+		return nil, err
+	}
+	if job := batch.job(); !job.Done {
+		return nil, fmt.Errorf("batch %s is still %s", req.ID, job.Status)
+	}
+
+	var results []BatchItemResult
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		content, err := o.fileContent(req.APIKey, req.BaseURL, req.Headers, fileID)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseOpenAIBatchOutput(content)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, parsed...)
+	}
+	return results, nil
+}
+
+// parseOpenAIBatchOutput converts the lines of an output or error file.
+func parseOpenAIBatchOutput(content []byte) ([]BatchItemResult, error) {
+	var results []BatchItemResult
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line openaiBatchOutput
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("failed to decode batch result: %w", err)
+		}
+
+		result := BatchItemResult{ID: line.CustomID}
+		switch {
+		case line.Error != nil:
+			result.Error = line.Error.Message
+		case line.Response == nil:
+			result.Error = "no response"
+		case line.Response.Body.Error != nil:
+			result.Error = fmt.Sprintf("API error (%d): %s", line.Response.StatusCode, line.Response.Body.Error.Message)
+		case len(line.Response.Body.Choices) == 0:
+			result.Error = "no choices in response"
+		default:
+			body := line.Response.Body
+			result.Response = &Response{
+				Content: body.Choices[0].Message.Content,
+				Model:   body.Model,
+				Usage: Usage{
+					PromptTokens:     body.Usage.PromptTokens,
+					CompletionTokens: body.Usage.CompletionTokens,
+				},
+				FinishReason:      openaiFinishReason(body.Choices[0].FinishReason),
+				SystemFingerprint: body.SystemFingerprint,
+			}
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch results: %w", err)
+	}
+	return results, nil
+}
+
+// apiCall sends a JSON request, or none if body is nil, and decodes the
+// JSON response into out.
+func (o *openai) apiCall(method, url, apiKey string, headers map[string]string, body []byte, out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setHeaders(httpReq, apiKey)
+	setExtraHeaders(httpReq, headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return o.handleError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package providers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// batchServer fakes the Files and Batch APIs, recording the uploaded input.
+func batchServer(t *testing.T, status string, uploaded *string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/files":
+			if r.FormValue("purpose") != "batch" {
+				t.Errorf("purpose = %q, want batch", r.FormValue("purpose"))
+			}
+			file, _, _ := r.FormFile("file")
+			data, _ := io.ReadAll(file)
+			*uploaded = string(data)
+			w.Write([]byte(`{"id": "file-in"}`))
+		case r.Method == "POST" && r.URL.Path == "/v1/batches":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["input_file_id"] != "file-in" || body["endpoint"] != "/v1/chat/completions" {
+				t.Errorf("batch body = %v", body)
+			}
+			w.Write([]byte(`{"id": "batch_1", "status": "validating", "created_at": 1700000000, "request_counts": {"total": 0}}`))
+		case r.URL.Path == "/v1/batches/batch_1":
+			w.Write([]byte(`{"id": "batch_1", "status": "` + status + `", "output_file_id": "file-out", "error_file_id": "file-err",
+				"request_counts": {"total": 3, "completed": 2, "failed": 1}}`))
+		case r.URL.Path == "/v1/files/file-out/content":
+			w.Write([]byte(`{"custom_id": "1", "response": {"status_code": 200, "body": {"model": "gpt-4o-mini", "choices": [{"message": {"content": "two"}}], "usage": {"prompt_tokens": 5, "completion_tokens": 1}}}}
+{"custom_id": "0", "response": {"status_code": 200, "body": {"model": "gpt-4o-mini", "choices": [{"message": {"content": "one"}}]}}}
+`))
+		case r.URL.Path == "/v1/files/file-err/content":
+			w.Write([]byte(`{"custom_id": "2", "response": {"status_code": 400, "body": {"error": {"message": "bad request"}}}}` + "\n"))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestOpenAI_SubmitBatch(t *testing.T) {
+	var uploaded string
+	server := batchServer(t, "completed", &uploaded)
+	defer server.Close()
+
+	job, err := NewOpenAI().(Batcher).SubmitBatch(BatchRequest{
+		Items: []BatchItem{
+			{ID: "0", Request: Request{Model: "gpt-4o-mini", Prompt: "one"}},
+			{ID: "1", Request: Request{Model: "gpt-4o-mini", Prompt: "two"}},
+		},
+		Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("SubmitBatch() error = %v", err)
+	}
+	if job.ID != "batch_1" || job.Status != "validating" || job.Done {
+		t.Errorf("job = %+v", job)
+	}
+
+	lines := strings.Split(strings.TrimSpace(uploaded), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("uploaded %d lines, want 2", len(lines))
+	}
+	var line struct {
+		CustomID string        `json:"custom_id"`
+		URL      string        `json:"url"`
+		Body     openaiRequest `json:"body"`
+	}
+	json.Unmarshal([]byte(lines[1]), &line)
+	if line.CustomID != "1" || line.URL != "/v1/chat/completions" || line.Body.Model != "gpt-4o-mini" || line.Body.Stream {
+		t.Errorf("line = %+v", line)
+	}
+}
+
+func TestOpenAI_BatchResults(t *testing.T) {
+	var uploaded string
+	server := batchServer(t, "completed", &uploaded)
+	defer server.Close()
+
+	batcher := NewOpenAI().(Batcher)
+	job, err := batcher.GetBatch(BatchJobRequest{ID: "batch_1", Endpoint: Endpoint{BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("GetBatch() error = %v", err)
+	}
+	if !job.Done || job.Total != 3 || job.Failed != 1 {
+		t.Errorf("job = %+v", job)
+	}
+
+	results, err := batcher.BatchResults(BatchJobRequest{ID: "batch_1", Endpoint: Endpoint{BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("BatchResults() error = %v", err)
+	}
+	byID := map[string]BatchItemResult{}
+	for _, r := range results {
+		byID[r.ID] = r
+	}
+	if len(byID) != 3 {
+		t.Fatalf("results = %+v, want 3", results)
+	}
+	if r := byID["1"]; r.Response == nil || r.Response.Content != "two" || r.Response.Usage.PromptTokens != 5 {
+		t.Errorf("result 1 = %+v", r)
+	}
+	if r := byID["2"]; r.Response != nil || !strings.Contains(r.Error, "bad request") {
+		t.Errorf("result 2 = %+v, want the item's error", r)
+	}
+}
+
+func TestOpenAI_BatchResults_NotDone(t *testing.T) {
+	var uploaded string
+	server := batchServer(t, "in_progress", &uploaded)
+	defer server.Close()
+
+	_, err := NewOpenAI().(Batcher).BatchResults(BatchJobRequest{ID: "batch_1", Endpoint: Endpoint{BaseURL: server.URL}})
+	if err == nil || !strings.Contains(err.Error(), "in_progress") {
+		t.Errorf("BatchResults() error = %v, want the job still in progress", err)
+	}
+}
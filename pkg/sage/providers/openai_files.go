@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// openaiFileObject is a file object from the Files API.
+type openaiFileObject struct {
+	ID        string `json:"id"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (f *openaiFileObject) file() *File {
+	return &File{
+		ID:        f.ID,
+		Filename:  f.Filename,
+		Purpose:   f.Purpose,
+		Bytes:     f.Bytes,
+		CreatedAt: time.Unix(f.CreatedAt, 0),
+	}
+}
+
+// UploadFile stores a file for batch, fine-tuning or other requests.
+func (o *openai) UploadFile(req FileUploadRequest) (*File, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "files")
+	}
+	return o.uploadFile(req.APIKey, req.BaseURL, req.Headers, req.Purpose, req.Filename, req.Data)
+}
+
+// ListFiles returns the account's files, newest first.
+func (o *openai) ListFiles(req FileRequest) ([]File, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "files")
+	}
+	path := "/files"
+	if req.Purpose != "" {
+		path += "?purpose=" + url.QueryEscape(req.Purpose)
+	}
+	var list struct {
+		Data []openaiFileObject `json:"data"`
+	}
+	if err := o.apiCall("GET", openaiAPIURL(req.BaseURL, path), req.APIKey, req.Headers, nil, &list); err != nil {
+		return nil, err
+	}
+	files := make([]File, len(list.Data))
+	for i, f := range list.Data {
+		files[i] = *f.file()
+	}
+	return files, nil
+}
+
+// DeleteFile deletes a stored file.
+func (o *openai) DeleteFile(req FileRequest) error {
+	if o.name != "" {
+		return errUnsupported(o.name, "files")
+	}
+	var deleted struct {
+		Deleted bool `json:"deleted"`
+	}
+	if err := o.apiCall("DELETE", openaiAPIURL(req.BaseURL, "/files/"+req.ID), req.APIKey, req.Headers, nil, &deleted); err != nil {
+		return err
+	}
+	if !deleted.Deleted {
+		return fmt.Errorf("file %s was not deleted", req.ID)
+	}
+	return nil
+}
+
+// uploadFile uploads data to the Files API.
+func (o *openai) uploadFile(apiKey, baseURL string, headers map[string]string, purpose, filename string, data []byte) (*File, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("purpose", purpose)
+	file, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build upload: %w", err)
+	}
+	file.Write(data)
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build upload: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", openaiAPIURL(baseURL, "/files"), &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setAuth(httpReq, apiKey)
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+	setExtraHeaders(httpReq, headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+
+	var uploaded openaiFileObject
+	if err := json.NewDecoder(resp.Body).Decode(&uploaded); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return uploaded.file(), nil
+}
+
+// fileContent downloads a file from the Files API.
+func (o *openai) fileContent(apiKey, baseURL string, headers map[string]string, fileID string) ([]byte, error) {
+	httpReq, err := http.NewRequest("GET", openaiAPIURL(baseURL, "/files/"+fileID+"/content"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	o.setAuth(httpReq, apiKey)
+	setExtraHeaders(httpReq, headers)
+
+	resp, err := o.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	return data, nil
+}
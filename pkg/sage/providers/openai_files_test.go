@@ -0,0 +1,49 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAI_Files(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/files":
+			_, header, _ := r.FormFile("file")
+			if header.Filename != "train.jsonl" || r.FormValue("purpose") != "fine-tune" {
+				t.Errorf("upload = %s for %q", header.Filename, r.FormValue("purpose"))
+			}
+			w.Write([]byte(`{"id": "file-1", "filename": "train.jsonl", "purpose": "fine-tune", "bytes": 3, "created_at": 1700000000}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/files":
+			if r.URL.Query().Get("purpose") != "fine-tune" {
+				t.Errorf("purpose = %q, want fine-tune", r.URL.Query().Get("purpose"))
+			}
+			w.Write([]byte(`{"data": [{"id": "file-1", "filename": "train.jsonl", "purpose": "fine-tune", "bytes": 3}]}`))
+		case r.Method == "DELETE" && r.URL.Path == "/v1/files/file-1":
+			w.Write([]byte(`{"id": "file-1", "deleted": true}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	store := NewOpenAI().(FileStore)
+	file, err := store.UploadFile(FileUploadRequest{Data: []byte("{}\n"), Filename: "train.jsonl", Purpose: "fine-tune", Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	if file.ID != "file-1" || file.Bytes != 3 || file.CreatedAt.Unix() != 1700000000 {
+		t.Errorf("file = %+v", file)
+	}
+
+	files, err := store.ListFiles(FileRequest{Purpose: "fine-tune", Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL}})
+	if err != nil || len(files) != 1 || files[0].Filename != "train.jsonl" {
+		t.Errorf("ListFiles() = %+v, %v", files, err)
+	}
+
+	if err := store.DeleteFile(FileRequest{ID: "file-1", Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL}}); err != nil {
+		t.Errorf("DeleteFile() error = %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// openaiFineTuneJob is a job object from the fine-tuning API.
+type openaiFineTuneJob struct {
+	ID             string `json:"id"`
+	Model          string `json:"model"`
+	FineTunedModel string `json:"fine_tuned_model"`
+	Status         string `json:"status"`
+	CreatedAt      int64  `json:"created_at"`
+	FinishedAt     int64  `json:"finished_at"`
+	TrainedTokens  int    `json:"trained_tokens"`
+	Error          *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type openaiFineTuneCreate struct {
+	Model           string                     `json:"model"`
+	TrainingFile    string                     `json:"training_file"`
+	ValidationFile  string                     `json:"validation_file,omitempty"`
+	Suffix          string                     `json:"suffix,omitempty"`
+	Hyperparameters *openaiFineTuneHyperparams `json:"hyperparameters,omitempty"`
+}
+
+type openaiFineTuneHyperparams struct {
+	Epochs int `json:"n_epochs"`
+}
+
+func (j *openaiFineTuneJob) job() *FineTuneJob {
+	job := &FineTuneJob{
+		ID:             j.ID,
+		Model:          j.Model,
+		FineTunedModel: j.FineTunedModel,
+		Status:         j.Status,
+		CreatedAt:      time.Unix(j.CreatedAt, 0),
+		TrainedTokens:  j.TrainedTokens,
+	}
+	switch j.Status {
+	case "succeeded", "failed", "cancelled":
+		job.Done = true
+	}
+	if j.FinishedAt > 0 {
+		job.FinishedAt = time.Unix(j.FinishedAt, 0)
+	}
+	if j.Error != nil {
+		job.Error = j.Error.Message
+	}
+	return job
+}
+
+// CreateFineTune uploads the training and validation data, unless they're
+// already uploaded, and starts a fine-tuning job on them.
+func (o *openai) CreateFineTune(req FineTuneRequest) (*FineTuneJob, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "fine-tuning")
+	}
+
+	body := openaiFineTuneCreate{
+		Model:          req.Model,
+		TrainingFile:   req.TrainingFileID,
+		ValidationFile: req.ValidationFileID,
+		Suffix:         req.Suffix,
+	}
+	if body.TrainingFile == "" {
+		file, err := o.uploadFile(req.APIKey, req.BaseURL, req.Headers, "fine-tune", req.TrainingFilename, req.TrainingData)
+		if err != nil {
+			return nil, err
+		}
+		body.TrainingFile = file.ID
+	}
+	if body.ValidationFile == "" && len(req.ValidationData) > 0 {
+		file, err := o.uploadFile(req.APIKey, req.BaseURL, req.Headers, "fine-tune", "validation.jsonl", req.ValidationData)
+		if err != nil {
+			return nil, err
+		}
+		body.ValidationFile = file.ID
+	}
+	if req.Epochs > 0 {
+		body.Hyperparameters = &openaiFineTuneHyperparams{Epochs: req.Epochs}
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	var job openaiFineTuneJob
+	if err := o.apiCall("POST", openaiAPIURL(req.BaseURL, "/fine_tuning/jobs"), req.APIKey, req.Headers, jsonBody, &job); err != nil {
+		return nil, err
+	}
+	return job.job(), nil
+}
+
+// ListFineTunes returns the most recent fine-tuning jobs.
+func (o *openai) ListFineTunes(req FineTuneJobRequest) ([]FineTuneJob, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "fine-tuning")
+	}
+	var list struct {
+		Data []openaiFineTuneJob `json:"data"`
+	}
+	if err := o.apiCall("GET", openaiAPIURL(req.BaseURL, "/fine_tuning/jobs?limit=100"), req.APIKey, req.Headers, nil, &list); err != nil {
+		return nil, err
+	}
+	jobs := make([]FineTuneJob, len(list.Data))
+	for i, j := range list.Data {
+		jobs[i] = *j.job()
+	}
+	return jobs, nil
+}
+
+// GetFineTune returns a fine-tuning job's state.
+func (o *openai) GetFineTune(req FineTuneJobRequest) (*FineTuneJob, error) {
+	return o.fineTuneCall("GET", req, "")
+}
+
+// CancelFineTune cancels a fine-tuning job.
+func (o *openai) CancelFineTune(req FineTuneJobRequest) (*FineTuneJob, error) {
+	return o.fineTuneCall("POST", req, "/cancel")
+}
+
+func (o *openai) fineTuneCall(method string, req FineTuneJobRequest, action string) (*FineTuneJob, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "fine-tuning")
+	}
+	var job openaiFineTuneJob
+	if err := o.apiCall(method, openaiAPIURL(req.BaseURL, "/fine_tuning/jobs/"+req.ID+action), req.APIKey, req.Headers, nil, &job); err != nil {
+		return nil, err
+	}
+	return job.job(), nil
+}
+
+// FineTuneEvents returns a job's latest events. The API lists them newest
+// first; they're returned oldest first.
+func (o *openai) FineTuneEvents(req FineTuneJobRequest) ([]FineTuneEvent, error) {
+	if o.name != "" {
+		return nil, errUnsupported(o.name, "fine-tuning")
+	}
+	var list struct {
+		Data []struct {
+			ID        string `json:"id"`
+			CreatedAt int64  `json:"created_at"`
+			Level     string `json:"level"`
+			Message   string `json:"message"`
+		} `json:"data"`
+	}
+	if err := o.apiCall("GET", openaiAPIURL(req.BaseURL, "/fine_tuning/jobs/"+req.ID+"/events?limit=100"), req.APIKey, req.Headers, nil, &list); err != nil {
+		return nil, err
+	}
+	events := make([]FineTuneEvent, len(list.Data))
+	for i, e := range list.Data {
+		events[len(events)-1-i] = FineTuneEvent{
+			ID:        e.ID,
+			CreatedAt: time.Unix(e.CreatedAt, 0),
+			Level:     e.Level,
+			Message:   e.Message,
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAI_CreateFineTune(t *testing.T) {
+	var uploads []string
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/files":
+			_, header, _ := r.FormFile("file")
+			uploads = append(uploads, header.Filename)
+			w.Write([]byte(`{"id": "file-` + header.Filename + `"}`))
+		case "/v1/fine_tuning/jobs":
+			json.NewDecoder(r.Body).Decode(&body)
+			w.Write([]byte(`{"id": "ftjob-1", "model": "gpt-4o-mini", "status": "validating_files", "created_at": 1700000000}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	job, err := NewOpenAI().(FineTuner).CreateFineTune(FineTuneRequest{
+		Model:            "gpt-4o-mini",
+		TrainingData:     []byte("{}\n"),
+		TrainingFilename: "train.jsonl",
+		ValidationData:   []byte("{}\n"),
+		Epochs:           2,
+		Endpoint:         Endpoint{APIKey: "sk-test", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("CreateFineTune() error = %v", err)
+	}
+	if job.ID != "ftjob-1" || job.Done || job.CreatedAt.Unix() != 1700000000 {
+		t.Errorf("job = %+v", job)
+	}
+	if len(uploads) != 2 {
+		t.Fatalf("uploads = %v, want training and validation files", uploads)
+	}
+	if body["training_file"] != "file-train.jsonl" || body["validation_file"] != "file-validation.jsonl" {
+		t.Errorf("body = %v", body)
+	}
+	if hp, _ := body["hyperparameters"].(map[string]any); hp["n_epochs"] != float64(2) {
+		t.Errorf("hyperparameters = %v, want n_epochs 2", body["hyperparameters"])
+	}
+}
+
+func TestOpenAI_CancelFineTune(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/fine_tuning/jobs/ftjob-1/cancel" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"id": "ftjob-1", "status": "cancelled", "error": {"message": "cancelled by user"}}`))
+	}))
+	defer server.Close()
+
+	job, err := NewOpenAI().(FineTuner).CancelFineTune(FineTuneJobRequest{ID: "ftjob-1", Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("CancelFineTune() error = %v", err)
+	}
+	if !job.Done || job.Error != "cancelled by user" {
+		t.Errorf("job = %+v", job)
+	}
+}
+
+func TestOpenAI_FineTune_Unsupported(t *testing.T) {
+	if _, err := NewXAI().(FineTuner).ListFineTunes(FineTuneJobRequest{}); err == nil {
+		t.Error("ListFineTunes() should fail for an OpenAI-compatible provider")
+	}
+}
@@ -1,6 +1,13 @@
 package providers
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -98,3 +105,466 @@ func TestOpenAI_Endpoint(t *testing.T) {
 		t.Errorf("endpoint() = %q, want %q", got, expected)
 	}
 }
+
+func TestOpenAI_BuildRequest_User(t *testing.T) {
+	o := &openai{}
+
+	built := o.buildRequest(Request{Model: "gpt-4o", Prompt: "Hi", User: "user-42"}, false)
+	if built.User != "user-42" {
+		t.Errorf("User = %q, want %q", built.User, "user-42")
+	}
+}
+
+func TestOpenAI_BuildRequest_Continue(t *testing.T) {
+	o := &openai{}
+
+	built := o.buildRequest(Request{Model: "gpt-4o", Prompt: "Hi", Continue: "Hello"}, true)
+	if len(built.Messages) != 3 {
+		t.Fatalf("len(Messages) = %d, want 3", len(built.Messages))
+	}
+	if m := built.Messages[1]; m.Role != "assistant" || m.Content != "Hello" {
+		t.Errorf("Messages[1] = %+v, want the partial response", m)
+	}
+	if m := built.Messages[2]; m.Role != "user" || m.Content != continuePrompt {
+		t.Errorf("Messages[2] = %+v, want the continue prompt", m)
+	}
+}
+
+func TestOpenAI_BuildRequest_Messages(t *testing.T) {
+	o := &openai{}
+
+	built := o.buildRequest(Request{
+		Model:  "gpt-4o",
+		System: "Be brief",
+		Messages: []Message{
+			{Role: "user", Content: "My name is Ada"},
+			{Role: "assistant", Content: "Hi Ada"},
+		},
+		Prompt: "What's my name?",
+	}, false)
+
+	roles := []string{}
+	for _, m := range built.Messages {
+		roles = append(roles, m.Role)
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,user" {
+		t.Errorf("roles = %s, want system,user,assistant,user", got)
+	}
+	if last := built.Messages[3]; last.Content != "What's my name?" {
+		t.Errorf("last message = %+v, want the prompt", last)
+	}
+}
+
+func TestOpenAI_BuildRequest_Images(t *testing.T) {
+	o := &openai{}
+
+	built := o.buildRequest(Request{
+		Model:  "gpt-4o",
+		Prompt: "What's this?",
+		Images: []Image{
+			{URL: "https://example.com/cat.png"},
+			{Data: []byte("png"), MediaType: "image/png"},
+		},
+	}, false)
+
+	data, _ := json.Marshal(built.Messages[0])
+	want := `{"role":"user","content":[{"type":"text","text":"What's this?"},` +
+		`{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}},` +
+		`{"type":"image_url","image_url":{"url":"data:image/png;base64,cG5n"}}]}`
+	if string(data) != want {
+		t.Errorf("message = %s, want %s", data, want)
+	}
+
+	// Turns without images keep plain string content
+	data, _ = json.Marshal(openaiMessage{Role: "user", Content: "hi"})
+	if string(data) != `{"role":"user","content":"hi"}` {
+		t.Errorf("message = %s", data)
+	}
+}
+
+func TestOpenAI_BuildRequest_Documents(t *testing.T) {
+	o := &openai{}
+
+	built := o.buildRequest(Request{
+		Model:  "gpt-4o",
+		Prompt: "Summarize these",
+		Documents: []Document{
+			{Data: []byte("pdf"), MediaType: "application/pdf", Name: "report.pdf"},
+			{Data: []byte("notes"), MediaType: "text/plain"},
+		},
+	}, false)
+
+	data, _ := json.Marshal(built.Messages[0])
+	want := `{"role":"user","content":[{"type":"text","text":"Summarize these"},` +
+		`{"type":"file","file":{"filename":"report.pdf","file_data":"data:application/pdf;base64,cGRm"}},` +
+		`{"type":"text","text":"notes"}]}`
+	if string(data) != want {
+		t.Errorf("message = %s, want %s", data, want)
+	}
+}
+
+func TestOpenAI_BuildRequest_Sampling(t *testing.T) {
+	o := &openai{}
+
+	zero, topP := 0.0, 0.9
+	built := o.buildRequest(Request{Model: "gpt-4o", Prompt: "hi", Temperature: &zero, TopP: &topP}, false)
+
+	data, _ := json.Marshal(built)
+	var body map[string]any
+	json.Unmarshal(data, &body)
+
+	// A temperature of 0 is sent, not omitted
+	if body["temperature"] != 0.0 || body["top_p"] != 0.9 {
+		t.Errorf("body = %s, want temperature 0 and top_p 0.9", data)
+	}
+	if _, ok := body["frequency_penalty"]; ok {
+		t.Errorf("body = %s, want unset penalties omitted", data)
+	}
+}
+
+func TestOpenAI_BuildRequest_NoTemperature(t *testing.T) {
+	o := &openai{}
+
+	temp, topP := 0.7, 0.9
+	for _, model := range []string{"o3-mini", "gpt-5", "openai/gpt-5-mini"} {
+		built := o.buildRequest(Request{Model: model, Prompt: "hi", Temperature: &temp, TopP: &topP}, false)
+
+		data, _ := json.Marshal(built)
+		if strings.Contains(string(data), "temperature") || strings.Contains(string(data), "top_p") {
+			t.Errorf("%s: body = %s, want sampling parameters omitted", model, data)
+		}
+	}
+}
+
+func TestOpenAI_BuildRequest_ModelAlias(t *testing.T) {
+	o := &openai{}
+
+	temp := 0.7
+	built := o.buildRequest(Request{Model: "prod-reasoner", ModelAlias: "o3-mini", Prompt: "hi", MaxTokens: 500, Temperature: &temp}, false)
+	if built.Model != "prod-reasoner" {
+		t.Errorf("Model = %q, want the deployment name sent as-is", built.Model)
+	}
+	if built.MaxCompletionTokens != 500 || built.MaxTokens != 0 || built.Temperature != nil {
+		t.Errorf("built = %+v, want the aliased model's quirks applied", built)
+	}
+
+	// Without an alias, an unknown name gets the defaults
+	built = o.buildRequest(Request{Model: "prod-reasoner", Prompt: "hi", MaxTokens: 500}, false)
+	if built.MaxTokens != 500 || built.MaxCompletionTokens != 0 {
+		t.Errorf("built = %+v, want max_tokens", built)
+	}
+}
+
+func TestOpenAI_BuildRequest_ReasoningEffort(t *testing.T) {
+	o := &openai{}
+
+	built := o.buildRequest(Request{Model: "o3-mini", Prompt: "hi", MaxTokens: 500, ReasoningEffort: "low"}, false)
+
+	data, _ := json.Marshal(built)
+	var body map[string]any
+	json.Unmarshal(data, &body)
+
+	if body["reasoning_effort"] != "low" {
+		t.Errorf("body = %s, want reasoning_effort low", data)
+	}
+
+	built = o.buildRequest(Request{Model: "gpt-4o", Prompt: "hi"}, false)
+	data, _ = json.Marshal(built)
+	if strings.Contains(string(data), "reasoning_effort") {
+		t.Errorf("body = %s, want unset reasoning_effort omitted", data)
+	}
+}
+
+func TestOpenAI_Seed(t *testing.T) {
+	var gotSeed any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		gotSeed = body["seed"]
+		if body["stream"] == true {
+			w.Write([]byte(`data: {"system_fingerprint": "fp_abc", "choices": [{"delta": {"content": "4"}}]}` + "\n\n"))
+			w.Write([]byte("data: [DONE]\n\n"))
+			return
+		}
+		w.Write([]byte(`{"system_fingerprint": "fp_abc", "choices": [{"message": {"role": "assistant", "content": "4"}}]}`))
+	}))
+	defer server.Close()
+
+	seed := 42
+	req := Request{Model: "gpt-4o", Prompt: "hi", Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL}, Seed: &seed}
+
+	resp, err := NewOpenAI().Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if gotSeed != 42.0 {
+		t.Errorf("seed = %v, want 42", gotSeed)
+	}
+	if resp.SystemFingerprint != "fp_abc" {
+		t.Errorf("SystemFingerprint = %q, want %q", resp.SystemFingerprint, "fp_abc")
+	}
+
+	ch, err := NewOpenAI().CompleteStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+	var fingerprint string
+	for chunk := range ch {
+		if chunk.Done {
+			fingerprint = chunk.SystemFingerprint
+		}
+	}
+	if fingerprint != "fp_abc" {
+		t.Errorf("Done chunk SystemFingerprint = %q, want %q", fingerprint, "fp_abc")
+	}
+}
+
+func TestOpenAI_Logprobs(t *testing.T) {
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Yes"},
+			"logprobs": {"content": [{"token": "Yes", "logprob": -0.01, "top_logprobs": [
+				{"token": "Yes", "logprob": -0.01}, {"token": "No", "logprob": -4.6}]}]}}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAI().Complete(context.Background(), Request{
+		Model: "gpt-4o", Prompt: "hi", Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL},
+		Logprobs: true, TopLogprobs: 2,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if gotBody["logprobs"] != true || gotBody["top_logprobs"] != 2.0 {
+		t.Errorf("body = %v, want logprobs and top_logprobs", gotBody)
+	}
+	if len(resp.Logprobs) != 1 || resp.Logprobs[0].Token != "Yes" || len(resp.Logprobs[0].TopLogprobs) != 2 {
+		t.Fatalf("Logprobs = %+v", resp.Logprobs)
+	}
+	if alt := resp.Logprobs[0].TopLogprobs[1]; alt.Token != "No" || alt.Logprob != -4.6 {
+		t.Errorf("alternative = %+v", alt)
+	}
+}
+
+func TestOpenAI_BuildRequest_ResponseFormat(t *testing.T) {
+	o := &openai{}
+
+	schema := json.RawMessage(`{"type": "object"}`)
+	built := o.buildRequest(Request{
+		Model:          "gpt-4o",
+		Prompt:         "hi",
+		ResponseFormat: &ResponseFormat{Type: "json_schema", Schema: schema},
+	}, false)
+
+	f := built.ResponseFormat
+	if f == nil || f.Type != "json_schema" || f.JSONSchema == nil {
+		t.Fatalf("ResponseFormat = %+v, want json_schema", f)
+	}
+	if f.JSONSchema.Name != defaultSchemaName || string(f.JSONSchema.Schema) != string(schema) {
+		t.Errorf("JSONSchema = %+v", f.JSONSchema)
+	}
+
+	built = o.buildRequest(Request{Model: "gpt-4o", Prompt: "hi", ResponseFormat: &ResponseFormat{Type: "json_object"}}, false)
+	if built.ResponseFormat.Type != "json_object" || built.ResponseFormat.JSONSchema != nil {
+		t.Errorf("ResponseFormat = %+v, want json_object", built.ResponseFormat)
+	}
+}
+
+func TestOpenAI_Embed(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		// Out of order, to check vectors are placed by index
+		w.Write([]byte(`{"data": [{"index": 1, "embedding": [0.3, 0.4]}, {"index": 0, "embedding": [0.1, 0.2]}],
+			"model": "text-embedding-3-small", "usage": {"prompt_tokens": 4}}`))
+	}))
+	defer server.Close()
+
+	embedder := NewOpenAI().(Embedder)
+	resp, err := embedder.Embed(EmbedRequest{
+		Model: "text-embedding-3-small", Input: []string{"a", "b"}, Dimensions: 2,
+		Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	if gotPath != "/v1/embeddings" {
+		t.Errorf("path = %q, want %q", gotPath, "/v1/embeddings")
+	}
+	if gotBody["dimensions"] != 2.0 {
+		t.Errorf("dimensions = %v, want 2", gotBody["dimensions"])
+	}
+	if len(resp.Embeddings) != 2 || resp.Embeddings[0][0] != 0.1 || resp.Embeddings[1][0] != 0.3 {
+		t.Errorf("Embeddings = %v, want input order", resp.Embeddings)
+	}
+	if resp.Usage.PromptTokens != 4 {
+		t.Errorf("PromptTokens = %d, want 4", resp.Usage.PromptTokens)
+	}
+}
+
+func TestOpenAI_Transcribe(t *testing.T) {
+	var gotPath, gotModel, gotLanguage, gotFilename, gotAudio string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotModel = r.FormValue("model")
+		gotLanguage = r.FormValue("language")
+		if file, header, err := r.FormFile("file"); err == nil {
+			data, _ := io.ReadAll(file)
+			gotFilename, gotAudio = header.Filename, string(data)
+		}
+		w.Write([]byte(`{"text": "Hello there."}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAI().(Transcriber).Transcribe(TranscribeRequest{
+		Model: "whisper-1", Audio: []byte("mp3 data"), Filename: "memo.mp3", Language: "en",
+		Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+
+	if gotPath != "/v1/audio/transcriptions" {
+		t.Errorf("path = %q, want %q", gotPath, "/v1/audio/transcriptions")
+	}
+	if gotModel != "whisper-1" || gotLanguage != "en" {
+		t.Errorf("model = %q, language = %q", gotModel, gotLanguage)
+	}
+	if gotFilename != "memo.mp3" || gotAudio != "mp3 data" {
+		t.Errorf("file = %q with %q", gotFilename, gotAudio)
+	}
+	if resp.Text != "Hello there." {
+		t.Errorf("Text = %q, want %q", resp.Text, "Hello there.")
+	}
+}
+
+func TestOpenAI_Speak(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Write([]byte("RIFF"))
+	}))
+	defer server.Close()
+
+	speed := 1.5
+	resp, err := NewOpenAI().(Speaker).Speak(SpeechRequest{
+		Model: "gpt-4o-mini-tts", Input: "Hello", Voice: "alloy", Format: "wav", Speed: &speed,
+		Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Speak() error = %v", err)
+	}
+
+	if gotPath != "/v1/audio/speech" {
+		t.Errorf("path = %q, want %q", gotPath, "/v1/audio/speech")
+	}
+	if gotBody["voice"] != "alloy" || gotBody["response_format"] != "wav" || gotBody["speed"] != 1.5 {
+		t.Errorf("body = %v", gotBody)
+	}
+	if string(resp.Audio) != "RIFF" || resp.MediaType != "audio/wav" {
+		t.Errorf("Audio = %q, MediaType = %q", resp.Audio, resp.MediaType)
+	}
+}
+
+func TestOpenAI_Speak_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "Invalid voice"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAI().(Speaker).Speak(SpeechRequest{Model: "tts-1", Input: "Hello", Voice: "nobody", Endpoint: Endpoint{BaseURL: server.URL}})
+	if err == nil || !strings.Contains(err.Error(), "Invalid voice") {
+		t.Errorf("Speak() error = %v, want the API's message", err)
+	}
+}
+
+func TestOpenAI_GenerateImages(t *testing.T) {
+	var gotBody map[string]any
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/images/generations":
+			json.NewDecoder(r.Body).Decode(&gotBody)
+			// One inline image and one by URL, as dall-e-3 returns them
+			fmt.Fprintf(w, `{"data": [{"b64_json": "iVBORw0KGgo="}, {"url": "%s/files/cat.png", "revised_prompt": "A tabby cat"}]}`, server.URL)
+		case "/files/cat.png":
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAI().(ImageGenerator).GenerateImages(ImageRequest{
+		Model: "dall-e-3", Prompt: "a cat", N: 2, Size: "1024x1024", Quality: "hd",
+		Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("GenerateImages() error = %v", err)
+	}
+
+	if gotBody["n"] != 2.0 || gotBody["size"] != "1024x1024" || gotBody["quality"] != "hd" {
+		t.Errorf("body = %v", gotBody)
+	}
+	if len(resp.Images) != 2 {
+		t.Fatalf("got %d images, want 2", len(resp.Images))
+	}
+	for i, img := range resp.Images {
+		if img.MediaType != "image/png" {
+			t.Errorf("image %d MediaType = %q, want image/png", i, img.MediaType)
+		}
+	}
+	if resp.Images[1].RevisedPrompt != "A tabby cat" {
+		t.Errorf("RevisedPrompt = %q", resp.Images[1].RevisedPrompt)
+	}
+}
+
+func TestOpenAI_Moderate(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"model": "omni-moderation-2024-09-26", "results": [
+			{"flagged": false, "categories": {"violence": false}, "category_scores": {"violence": 0.01}},
+			{"flagged": true, "categories": {"violence": true}, "category_scores": {"violence": 0.93}}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAI().(Moderator).Moderate(ModerationRequest{
+		Model: "omni-moderation-latest", Input: []string{"hello", "something violent"},
+		Endpoint: Endpoint{APIKey: "sk-test", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Moderate() error = %v", err)
+	}
+
+	if gotPath != "/v1/moderations" {
+		t.Errorf("path = %q, want %q", gotPath, "/v1/moderations")
+	}
+	if input, _ := gotBody["input"].([]any); len(input) != 2 {
+		t.Errorf("input = %v, want both texts", gotBody["input"])
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Flagged || !resp.Results[1].Flagged {
+		t.Fatalf("Results = %+v", resp.Results)
+	}
+	if !resp.Results[1].Categories["violence"] || resp.Results[1].Scores["violence"] != 0.93 {
+		t.Errorf("Results[1] = %+v", resp.Results[1])
+	}
+}
+
+func TestOpenAICompatible_EmbedUnsupported(t *testing.T) {
+	_, err := NewXAI().(Embedder).Embed(EmbedRequest{Model: "grok-2", Input: []string{"a"}})
+	if err == nil {
+		t.Error("Embed() should fail for a provider without embeddings")
+	}
+}
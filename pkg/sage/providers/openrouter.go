@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const openrouterDefaultURL = "https://openrouter.ai/api"
+
+func init() {
+	Register("openrouter", NewOpenRouter)
+}
+
+// openrouter speaks OpenRouter's OpenAI-compatible API. Model IDs are
+// namespaced by vendor (e.g. "anthropic/claude-3.5-sonnet"). OpenRouter
+// attributes requests to an app via the HTTP-Referer and X-Title headers,
+// which come from the provider's configured headers.
+type openrouter struct {
+	*openai
+}
+
+// NewOpenRouter creates an OpenRouter provider.
+func NewOpenRouter() Provider {
+	return &openrouter{&openai{
+		name:    "openrouter",
+		chatURL: openrouterChatURL,
+	}}
+}
+
+func openrouterChatURL(req Request) string {
+	return openrouterBaseURL(req.BaseURL) + "/v1/chat/completions"
+}
+
+func openrouterBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return openrouterDefaultURL
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+type openrouterModelsResponse struct {
+	Data []openrouterModel `json:"data"`
+}
+
+type openrouterModel struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	ContextLength int               `json:"context_length"`
+	Pricing       openrouterPricing `json:"pricing"`
+}
+
+// openrouterPricing holds USD per token as decimal strings.
+type openrouterPricing struct {
+	Prompt     string `json:"prompt"`
+	Completion string `json:"completion"`
+}
+
+// ListModels returns OpenRouter's model catalogue with context lengths and
+// prices. The models endpoint doesn't require an API key.
+func (o *openrouter) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
+	req, err := http.NewRequest("GET", openrouterBaseURL(baseURL)+"/v1/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if apiKey != "" {
+		o.setAuth(req, apiKey)
+	}
+
+	resp, err := o.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.handleError(resp)
+	}
+
+	var result openrouterModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	models := make([]ModelInfo, 0, len(result.Data))
+	for _, m := range result.Data {
+		models = append(models, ModelInfo{
+			ID:            m.ID,
+			Name:          m.Name,
+			ContextWindow: m.ContextLength,
+			InputPrice:    perMillion(m.Pricing.Prompt),
+			OutputPrice:   perMillion(m.Pricing.Completion),
+		})
+	}
+
+	return models, nil
+}
+
+// perMillion converts a per-token price string to USD per million tokens.
+// Unparseable prices (OpenRouter uses "-1" for variable pricing) are 0.
+func perMillion(perToken string) float64 {
+	p, err := strconv.ParseFloat(perToken, 64)
+	if err != nil || p < 0 {
+		return 0
+	}
+	return p * 1e6
+}
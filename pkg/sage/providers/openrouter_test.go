@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenRouter_CompleteHeaders(t *testing.T) {
+	var gotPath, gotReferer, gotTitle string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotReferer = r.Header.Get("HTTP-Referer")
+		gotTitle = r.Header.Get("X-Title")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOpenRouter().Complete(context.Background(), Request{
+		Model:  "anthropic/claude-3.5-sonnet",
+		Prompt: "hi",
+		Endpoint: Endpoint{
+			APIKey:  "or-key",
+			BaseURL: server.URL,
+			Headers: map[string]string{"HTTP-Referer": "https://myapp.example", "X-Title": "My App"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotReferer != "https://myapp.example" || gotTitle != "My App" {
+		t.Errorf("HTTP-Referer = %q, X-Title = %q", gotReferer, gotTitle)
+	}
+}
+
+func TestOpenRouter_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [
+			{"id": "anthropic/claude-3.5-sonnet", "name": "Claude 3.5 Sonnet", "context_length": 200000,
+			 "pricing": {"prompt": "0.000003", "completion": "0.000015"}},
+			{"id": "openrouter/auto", "name": "Auto Router", "context_length": 2000000,
+			 "pricing": {"prompt": "-1", "completion": "-1"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewOpenRouter().ListModels("", server.URL)
+	if err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+	if len(models) != 2 {
+		t.Fatalf("got %d models, want 2", len(models))
+	}
+
+	m := models[0]
+	if m.ID != "anthropic/claude-3.5-sonnet" || m.ContextWindow != 200000 {
+		t.Errorf("model = %+v", m)
+	}
+	if m.InputPrice < 2.999 || m.InputPrice > 3.001 || m.OutputPrice < 14.999 || m.OutputPrice > 15.001 {
+		t.Errorf("prices = %v/%v, want 3/15", m.InputPrice, m.OutputPrice)
+	}
+	if models[1].InputPrice != 0 {
+		t.Errorf("variable price = %v, want 0", models[1].InputPrice)
+	}
+}
@@ -0,0 +1,55 @@
+package providers
+
+import (
+	"context"
+	"strings"
+)
+
+const perplexityDefaultURL = "https://api.perplexity.ai"
+
+func init() {
+	Register("perplexity", NewPerplexity)
+}
+
+// perplexity serves the Sonar search-backed models through an
+// OpenAI-compatible API. Responses carry the sources they cite, which the
+// openai parser returns as Citations.
+type perplexity struct {
+	*openai
+}
+
+// NewPerplexity creates a Perplexity provider.
+func NewPerplexity() Provider {
+	return &perplexity{&openai{
+		name:    "perplexity",
+		chatURL: perplexityChatURL,
+	}}
+}
+
+// perplexityChatURL has no /v1 prefix, unlike OpenAI's.
+func perplexityChatURL(req Request) string {
+	base := perplexityDefaultURL
+	if req.BaseURL != "" {
+		base = strings.TrimSuffix(req.BaseURL, "/")
+	}
+	return base + "/chat/completions"
+}
+
+// Ping sends a 1-token completion, since the hardcoded model list can't
+// tell whether the API key is accepted.
+func (p *perplexity) Ping(apiKey, baseURL string) error {
+	_, err := p.Complete(context.Background(), Request{Model: "sonar", Prompt: "hi", MaxTokens: 1, Endpoint: Endpoint{APIKey: apiKey, BaseURL: baseURL}})
+	return err
+}
+
+// ListModels returns the Sonar models.
+// Perplexity doesn't have a models endpoint, so we return a hardcoded list.
+func (p *perplexity) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
+	return []ModelInfo{
+		{ID: "sonar", Name: "Sonar", Description: "Lightweight search-grounded model"},
+		{ID: "sonar-pro", Name: "Sonar Pro", Description: "Advanced search with more sources"},
+		{ID: "sonar-reasoning", Name: "Sonar Reasoning", Description: "Search with step-by-step reasoning"},
+		{ID: "sonar-reasoning-pro", Name: "Sonar Reasoning Pro", Description: "Most capable search reasoning model"},
+		{ID: "sonar-deep-research", Name: "Sonar Deep Research", Description: "Exhaustive multi-step research reports"},
+	}, nil
+}
@@ -0,0 +1,106 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPerplexity_CompleteCitations(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "Go 1.22 added range over int [1]."}}],
+			"citations": ["https://go.dev/doc/go1.22"],
+			"search_results": [{"title": "Go 1.22 Release Notes", "url": "https://go.dev/doc/go1.22"}]
+		}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewPerplexity().Complete(context.Background(), Request{Model: "sonar", Prompt: "hi", Endpoint: Endpoint{APIKey: "pplx", BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if gotPath != "/chat/completions" {
+		t.Errorf("path = %q, want /chat/completions", gotPath)
+	}
+	want := Citation{URL: "https://go.dev/doc/go1.22", Title: "Go 1.22 Release Notes"}
+	if len(resp.Citations) != 1 || resp.Citations[0] != want {
+		t.Errorf("Citations = %+v, want [%+v]", resp.Citations, want)
+	}
+}
+
+func TestPerplexity_StreamCitations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`data: {"choices": [{"delta": {"content": "Hi"}}], "citations": ["https://a.example"]}` + "\n\n"))
+		w.Write([]byte(`data: {"choices": [{"delta": {"content": " [1]"}}], "citations": ["https://a.example", "https://b.example"]}` + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	ch, err := NewPerplexity().CompleteStream(context.Background(), Request{Model: "sonar", Prompt: "hi", Endpoint: Endpoint{APIKey: "pplx", BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var citations []Citation
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		if chunk.Done {
+			citations = chunk.Citations
+		}
+	}
+
+	if len(citations) != 2 || citations[1].URL != "https://b.example" {
+		t.Errorf("Citations = %+v, want the final two URLs", citations)
+	}
+}
+
+func TestOpenAI_NoCitations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewOpenAI().Complete(context.Background(), Request{Model: "gpt-4o", Prompt: "hi", Endpoint: Endpoint{APIKey: "sk", BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Citations != nil {
+		t.Errorf("Citations = %+v, want nil", resp.Citations)
+	}
+}
+
+func TestPerplexity_Ping(t *testing.T) {
+	var gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		if r.Header.Get("Authorization") != "Bearer pplx-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Invalid API key"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Hi"}}]}`))
+	}))
+	defer server.Close()
+
+	p := NewPerplexity().(Pinger)
+	if err := p.Ping("pplx-good", server.URL); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if !strings.Contains(gotBody, `"max_tokens":1`) {
+		t.Errorf("request body = %s, want a 1-token completion", gotBody)
+	}
+	if err := p.Ping("pplx-bad", server.URL); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Ping() with a bad key error = %v, want ErrUnauthorized", err)
+	}
+}
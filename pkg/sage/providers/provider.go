@@ -2,47 +2,705 @@
 package providers
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrRateLimited is wrapped by provider errors for HTTP 429 responses.
+var ErrRateLimited = errors.New("rate limited")
+
+// ErrServerError is wrapped by provider errors for HTTP 5xx responses.
+var ErrServerError = errors.New("server error")
+
+// ErrUnauthorized is wrapped by provider errors for HTTP 401 and 403 responses.
+var ErrUnauthorized = errors.New("invalid API key")
+
+// ErrContextLengthExceeded is wrapped by provider errors for requests whose
+// prompt and max tokens don't fit the model's context window.
+var ErrContextLengthExceeded = errors.New("context length exceeded")
+
+// ErrModelNotFound is wrapped by provider errors for requests naming a model
+// the provider doesn't serve, or the account can't use.
+var ErrModelNotFound = errors.New("model not found")
+
+// ErrContentFiltered is wrapped by provider errors for requests rejected by
+// the provider's content filter or safety policy.
+var ErrContentFiltered = errors.New("content filtered")
+
+// requestError classifies a rejected request that isn't an auth, rate limit
+// or server failure, from the provider's error code (or type) and message.
+// It returns ErrContextLengthExceeded, ErrModelNotFound, ErrContentFiltered,
+// or nil if the failure is none of those. Providers don't agree on codes, so
+// well-known message wording is matched too.
+func requestError(status int, code, msg string) error {
+	code, msg = strings.ToLower(code), strings.ToLower(msg)
+	switch {
+	case code == "context_length_exceeded" || containsAny(msg,
+		"context length", "context window", "maximum context", "prompt is too long",
+		"input is too long", "too many input tokens", "exceeds the maximum number of tokens"):
+		return ErrContextLengthExceeded
+	case code == "model_not_found" ||
+		(status == http.StatusNotFound || code == "not_found_error" || code == "not_found" ||
+			strings.HasPrefix(code, "resourcenotfoundexception")) && strings.Contains(msg, "model"):
+		return ErrModelNotFound
+	case code == "content_filter" || code == "content_policy_violation" || containsAny(msg,
+		"content filter", "content management policy", "content policy", "safety system"):
+		return ErrContentFiltered
+	}
+	return nil
+}
+
+// containsAny reports whether s contains any of substrs.
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryAfterError wraps a provider error whose response said how long to
+// wait before retrying, in a Retry-After header.
+type RetryAfterError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfter returns the delay the provider asked for before retrying the
+// request that failed with err, if it asked for one.
+func RetryAfter(err error) (time.Duration, bool) {
+	var ra *RetryAfterError
+	if errors.As(err, &ra) {
+		return ra.Delay, true
+	}
+	return 0, false
+}
+
+// classifyStatus returns the error for a failed response, given the message
+// and error code (or type) a provider's error body held, or the raw body if
+// it couldn't be parsed. 401 and 403 wrap ErrUnauthorized, 429
+// ErrRateLimited and 5xx ErrServerError; other rejections are classified by
+// requestError. Any Retry-After delay is attached.
+func classifyStatus(resp *http.Response, code, msg string) error {
+	var err error
+	switch status := resp.StatusCode; {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		err = fmt.Errorf("%w: %s", ErrUnauthorized, msg)
+	case status == http.StatusTooManyRequests:
+		err = fmt.Errorf("%w: %s", ErrRateLimited, msg)
+	case status >= 500:
+		err = fmt.Errorf("%w (%d): %s", ErrServerError, status, msg)
+	default:
+		if kind := requestError(status, code, msg); kind != nil {
+			err = fmt.Errorf("%w (%d): %s", kind, status, msg)
+		} else {
+			err = fmt.Errorf("API error (%d): %s", status, msg)
+		}
+	}
+	return withRetryAfter(resp, err)
+}
+
+// withRetryAfter wraps err with the delay in resp's retry headers, if any.
+// OpenAI sends retry-after-ms alongside the standard Retry-After, which is
+// either seconds or an HTTP date.
+func withRetryAfter(resp *http.Response, err error) error {
+	if ms, parseErr := strconv.ParseFloat(resp.Header.Get("Retry-After-Ms"), 64); parseErr == nil && ms >= 0 {
+		return &RetryAfterError{Err: err, Delay: time.Duration(ms * float64(time.Millisecond))}
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return err
+	}
+	if secs, parseErr := strconv.ParseFloat(value, 64); parseErr == nil && secs >= 0 {
+		return &RetryAfterError{Err: err, Delay: time.Duration(secs * float64(time.Second))}
+	}
+	if at, parseErr := http.ParseTime(value); parseErr == nil {
+		delay := time.Until(at)
+		if delay < 0 {
+			delay = 0
+		}
+		return &RetryAfterError{Err: err, Delay: delay}
+	}
+	return err
+}
+
 // Provider is implemented by each LLM provider.
 type Provider interface {
 	// Name returns the provider identifier (e.g., "openai", "anthropic").
 	Name() string
 
-	// Complete sends a request and returns the full response.
-	Complete(req Request) (*Response, error)
+	// Complete sends a request and returns the full response. Cancelling
+	// ctx aborts the request.
+	Complete(ctx context.Context, req Request) (*Response, error)
 
-	// CompleteStream sends a request and streams chunks.
-	CompleteStream(req Request) (<-chan Chunk, error)
+	// CompleteStream sends a request and streams chunks. Cancelling ctx
+	// aborts the stream; the channel is closed without a done chunk.
+	CompleteStream(ctx context.Context, req Request) (<-chan Chunk, error)
 
 	// ListModels returns available models from this provider.
 	ListModels(apiKey, baseURL string) ([]ModelInfo, error)
 }
 
-// ModelInfo describes an available model.
+// Pinger is implemented by providers that need a custom health check.
+// Providers without one are checked by listing their models.
+type Pinger interface {
+	// Ping makes the cheapest request that verifies the endpoint is
+	// reachable and the API key is accepted.
+	Ping(apiKey, baseURL string) error
+}
+
+// Embedder is implemented by providers that can embed text.
+type Embedder interface {
+	// Embed returns a vector for each input, in order.
+	Embed(req EmbedRequest) (*EmbedResponse, error)
+}
+
+// Transcriber is implemented by providers that can transcribe audio.
+type Transcriber interface {
+	// Transcribe returns the text spoken in req.Audio.
+	Transcribe(req TranscribeRequest) (*TranscribeResponse, error)
+}
+
+// Speaker is implemented by providers that can turn text into speech.
+type Speaker interface {
+	// Speak returns req.Input read aloud.
+	Speak(req SpeechRequest) (*SpeechResponse, error)
+}
+
+// ImageGenerator is implemented by providers that can generate images.
+type ImageGenerator interface {
+	// GenerateImages returns the images drawn for req.Prompt.
+	GenerateImages(req ImageRequest) (*ImageResponse, error)
+}
+
+// Moderator is implemented by providers that can classify text as unsafe.
+type Moderator interface {
+	// Moderate returns a result for each input, in order.
+	Moderate(req ModerationRequest) (*ModerationResponse, error)
+}
+
+// Batcher is implemented by providers with an asynchronous batch API, which
+// runs many requests offline at a discount.
+type Batcher interface {
+	// SubmitBatch uploads the items and starts a batch job.
+	SubmitBatch(req BatchRequest) (*BatchJob, error)
+	// GetBatch returns a job's current state.
+	GetBatch(req BatchJobRequest) (*BatchJob, error)
+	// BatchResults returns the results of a finished job, one per item in
+	// no particular order.
+	BatchResults(req BatchJobRequest) ([]BatchItemResult, error)
+}
+
+// FileStore is implemented by providers that keep uploaded files, such as
+// training data or batch input, for later requests to refer to by ID.
+type FileStore interface {
+	// UploadFile stores the data and returns the new file.
+	UploadFile(req FileUploadRequest) (*File, error)
+	// ListFiles returns stored files, filtered by req.Purpose if set.
+	ListFiles(req FileRequest) ([]File, error)
+	// DeleteFile deletes the file req.ID.
+	DeleteFile(req FileRequest) error
+}
+
+// FineTuner is implemented by providers that can fine-tune models.
+type FineTuner interface {
+	// CreateFineTune uploads the training data and starts a job.
+	CreateFineTune(req FineTuneRequest) (*FineTuneJob, error)
+	// ListFineTunes returns recent jobs, newest first. req.ID is unused.
+	ListFineTunes(req FineTuneJobRequest) ([]FineTuneJob, error)
+	// GetFineTune returns a job's current state.
+	GetFineTune(req FineTuneJobRequest) (*FineTuneJob, error)
+	// CancelFineTune stops a running job.
+	CancelFineTune(req FineTuneJobRequest) (*FineTuneJob, error)
+	// FineTuneEvents returns a job's recent events, oldest first.
+	FineTuneEvents(req FineTuneJobRequest) ([]FineTuneEvent, error)
+}
+
+// TokenCounter is implemented by providers that can count a request's input
+// tokens without running it.
+type TokenCounter interface {
+	// CountTokens returns the number of input tokens req would use.
+	CountTokens(req Request) (int, error)
+}
+
+// Endpoint is where a request is sent and how it's authenticated. The
+// client fills it in from the profile's provider config and account.
+type Endpoint struct {
+	APIKey  string // Decrypted, passed in by client
+	BaseURL string // Optional override
+
+	// Headers are extra HTTP headers sent with the request.
+	Headers map[string]string
+}
+
+// EmbedRequest is the normalized embedding request format for providers.
+type EmbedRequest struct {
+	Endpoint
+
+	Model      string
+	Input      []string
+	Dimensions int    // Shortened vector size, for models that support it; 0 for the default
+	APIVersion string // API version for providers that version by query (Azure)
+}
+
+// TranscribeRequest is the normalized transcription request format for
+// providers.
+type TranscribeRequest struct {
+	Endpoint
+
+	Model    string
+	Audio    []byte
+	Filename string // The API detects the audio format from its extension
+	Language string // ISO-639-1 code of the spoken language; empty to detect
+	Prompt   string // Text that guides spelling and style (optional)
+}
+
+// TranscribeResponse is the normalized transcription response from
+// providers.
+type TranscribeResponse struct {
+	Text  string
+	Model string
+}
+
+// SpeechRequest is the normalized text-to-speech request format for
+// providers.
+type SpeechRequest struct {
+	Endpoint
+
+	Model  string
+	Input  string
+	Voice  string
+	Format string   // Audio format, e.g. "mp3" or "wav"
+	Speed  *float64 // Playback speed multiplier; nil for the default
+}
+
+// SpeechResponse is the normalized text-to-speech response from providers.
+type SpeechResponse struct {
+	Audio     []byte
+	MediaType string // From the response, e.g. "audio/mpeg"
+	Model     string
+}
+
+// ImageRequest is the normalized image generation request format for
+// providers.
+type ImageRequest struct {
+	Endpoint
+
+	Model   string
+	Prompt  string
+	N       int    // Number of images; 0 for one
+	Size    string // e.g. "1024x1024"; empty for the model's default
+	Quality string // e.g. "high" or "hd"; empty for the model's default
+}
+
+// ImageResponse is the normalized image generation response from providers.
+type ImageResponse struct {
+	Images []GeneratedImage
+	Model  string
+}
+
+// GeneratedImage is one generated image. Images the API returns by URL
+// are downloaded, since the URLs expire.
+type GeneratedImage struct {
+	Data          []byte
+	MediaType     string // Detected from Data, e.g. "image/png"
+	RevisedPrompt string // The prompt the model actually drew, if it rewrote it
+}
+
+// ModerationRequest is the normalized moderation request format for
+// providers.
+type ModerationRequest struct {
+	Endpoint
+
+	Model string
+	Input []string
+}
+
+// ModerationResponse is the normalized moderation response from providers.
+type ModerationResponse struct {
+	Results []ModerationResult
+	Model   string
+}
+
+// ModerationResult classifies one input.
+type ModerationResult struct {
+	Flagged    bool
+	Categories map[string]bool    // Category name to whether it was flagged
+	Scores     map[string]float64 // Category name to the model's confidence, 0 to 1
+}
+
+// BatchRequest is the normalized batch submission format for providers.
+type BatchRequest struct {
+	Endpoint
+
+	Items []BatchItem
+}
+
+// BatchItem is one request in a batch. Its result carries the same ID.
+type BatchItem struct {
+	ID      string
+	Request Request
+}
+
+// BatchJobRequest identifies an existing batch job.
+type BatchJobRequest struct {
+	Endpoint
+
+	ID string
+}
+
+// BatchJob is the state of a batch job.
+type BatchJob struct {
+	ID        string
+	Status    string // As the provider reports it, e.g. "in_progress"
+	Done      bool   // Finished, whether completed, failed, expired or cancelled
+	Total     int
+	Completed int
+	Failed    int
+	CreatedAt time.Time
+	Errors    []string // Job-level errors, such as invalid input
+}
+
+// BatchItemResult is the outcome of one batch item.
+type BatchItemResult struct {
+	ID       string
+	Response *Response // Nil if the item failed
+	Error    string
+}
+
+// FileUploadRequest is a file to store with the provider.
+type FileUploadRequest struct {
+	Endpoint
+
+	Data     []byte
+	Filename string
+	Purpose  string // What the file is for, e.g. "batch" or "fine-tune"
+}
+
+// FileRequest identifies a stored file, or filters a listing of them.
+type FileRequest struct {
+	Endpoint
+
+	ID      string
+	Purpose string // Only for ListFiles (optional)
+}
+
+// File is a file stored with a provider.
+type File struct {
+	ID        string
+	Filename  string
+	Purpose   string
+	Bytes     int64
+	CreatedAt time.Time
+}
+
+// FineTuneRequest is the normalized fine-tuning job format for providers.
+// Training and validation data are either uploaded with the job or refer to
+// files already uploaded with a FileStore.
+type FineTuneRequest struct {
+	Endpoint
+
+	Model            string // Base model
+	TrainingData     []byte // JSONL of example conversations
+	TrainingFilename string
+	TrainingFileID   string // Instead of TrainingData
+	ValidationData   []byte // Optional, in the same format
+	ValidationFileID string // Instead of ValidationData
+	Suffix           string // Added to the fine-tuned model's name (optional)
+	Epochs           int    // 0 lets the provider choose
+}
+
+// FineTuneJobRequest identifies an existing fine-tuning job.
+type FineTuneJobRequest struct {
+	Endpoint
+
+	ID string
+}
+
+// FineTuneJob is the state of a fine-tuning job.
+type FineTuneJob struct {
+	ID             string
+	Model          string // Base model
+	FineTunedModel string // Set once the job succeeds
+	Status         string // As the provider reports it, e.g. "running"
+	Done           bool   // Finished, whether succeeded, failed or cancelled
+	CreatedAt      time.Time
+	FinishedAt     time.Time // Zero until done
+	TrainedTokens  int
+	Error          string // Why the job failed
+}
+
+// FineTuneEvent is a log message from a fine-tuning job.
+type FineTuneEvent struct {
+	ID        string
+	CreatedAt time.Time
+	Level     string // "info", "warn" or "error"
+	Message   string
+}
+
+// EmbedResponse is the normalized embedding response from providers.
+type EmbedResponse struct {
+	Embeddings [][]float64
+	Model      string
+	Usage      Usage // Only PromptTokens is set
+}
+
+// ModelInfo describes an available model. Context window and pricing are
+// set by providers that report them and are zero otherwise.
 type ModelInfo struct {
-	ID          string `json:"id"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
+	ID            string  `json:"id"`
+	Name          string  `json:"name,omitempty"`
+	Description   string  `json:"description,omitempty"`
+	ContextWindow int     `json:"context_window,omitempty"`
+	InputPrice    float64 `json:"input_price,omitempty"`  // USD per million tokens
+	OutputPrice   float64 `json:"output_price,omitempty"` // USD per million tokens
 }
 
 // Request is the normalized request format for providers.
 type Request struct {
-	Model     string
-	System    string
-	Prompt    string
-	MaxTokens int
-	APIKey    string // Decrypted, passed in by client
-	BaseURL   string // Optional override
+	Endpoint
+
+	Model      string
+	ModelAlias string // Catalog model that Model serves, for custom and deployment names
+	System     string
+	Prompt     string
+	Messages   []Message  // Earlier turns of a conversation, sent before Prompt
+	Images     []Image    // Images sent with the final user turn
+	Documents  []Document // Documents sent with the final user turn
+	MaxTokens  int
+	APIVersion string // API version for providers that version by query (Azure)
+	RequestID  string // Sent to providers that accept a client request ID
+	User       string // End-user ID for providers that track abuse/spend per user
+
+	// Sampling parameters; nil leaves the provider's default
+	Temperature      *float64
+	TopP             *float64
+	FrequencyPenalty *float64
+	PresencePenalty  *float64
+	Seed             *int // For reproducible sampling, where supported
+
+	// Logprobs asks for each output token's log probability, with the
+	// TopLogprobs most likely alternatives (OpenAI-compatible providers).
+	Logprobs    bool
+	TopLogprobs int
+
+	// ReasoningEffort is "low", "medium" or "high", limiting how much a
+	// reasoning model thinks before answering (OpenAI-compatible providers).
+	ReasoningEffort string
+
+	// IdempotencyKey is sent to providers that deduplicate retried requests.
+	IdempotencyKey string
+
+	// Betas lists beta features to opt into (Anthropic's anthropic-beta header).
+	Betas []string
+
+	// ExtraBody is merged into the provider's JSON request body, overriding
+	// fields sage sets itself. For parameters sage doesn't model yet.
+	ExtraBody map[string]any
+
+	// ResponseFormat, if set, asks for JSON output.
+	ResponseFormat *ResponseFormat
+
+	// Platform routes an Anthropic request through "bedrock" or "vertex".
+	// Endpoint is then the platform's.
+	Platform string
+
+	// IdleTimeout aborts a stream with ErrStreamIdle when nothing, not even
+	// a heartbeat, arrives for this long. Zero means no limit.
+	IdleTimeout time.Duration
+
+	// OnHeartbeat, if set, is called for each keep-alive a stream receives
+	// (SSE comments and Anthropic ping events).
+	OnHeartbeat func()
+
+	// Continue is a partial assistant response, received before a stream
+	// dropped, that the model should pick up from instead of starting over.
+	Continue string
+}
+
+// catalogModel returns the model to look up in the model catalog.
+func (r Request) catalogModel() string {
+	if r.ModelAlias != "" {
+		return r.ModelAlias
+	}
+	return r.Model
 }
 
+// ResponseFormat asks a model for JSON output, optionally matching a schema.
+type ResponseFormat struct {
+	Type   string          // "json_object" or "json_schema"
+	Name   string          // Schema name, for providers that require one
+	Schema json.RawMessage // JSON Schema, for json_schema
+}
+
+// defaultSchemaName names a schema that wasn't given a name.
+const defaultSchemaName = "response"
+
+// name returns the schema's name.
+func (f *ResponseFormat) name() string {
+	if f.Name == "" {
+		return defaultSchemaName
+	}
+	return f.Name
+}
+
+// errUnsupported reports a request feature a provider can't send.
+func errUnsupported(provider, feature string) error {
+	return fmt.Errorf("%s does not support %s", provider, feature)
+}
+
+// requireBasicSampling returns an error if the request sets sampling
+// parameters beyond temperature and top_p, for providers that take only
+// those.
+func requireBasicSampling(req Request, provider string) error {
+	if req.FrequencyPenalty != nil || req.PresencePenalty != nil {
+		return errUnsupported(provider, "frequency or presence penalties")
+	}
+	if req.Seed != nil {
+		return errUnsupported(provider, "seed")
+	}
+	return nil
+}
+
+// requireNoLogprobs returns an error if the request asks for logprobs, for
+// providers that can't return them.
+func requireNoLogprobs(req Request, provider string) error {
+	if req.Logprobs {
+		return errUnsupported(provider, "logprobs")
+	}
+	return nil
+}
+
+// requireNoReasoningEffort returns an error if the request sets a reasoning
+// effort, for providers that don't take one.
+func requireNoReasoningEffort(req Request, provider string) error {
+	if req.ReasoningEffort != "" {
+		return errUnsupported(provider, "reasoning effort")
+	}
+	return nil
+}
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role      string // "user", "assistant" or "system"
+	Content   string
+	Images    []Image    // Only set on the final user turn by conversation
+	Documents []Document // Likewise
+}
+
+// Document is a document input, such as a PDF, given as data.
+type Document struct {
+	Data      []byte
+	MediaType string // "application/pdf" or "text/plain"
+	Name      string // File name, for providers that want one
+}
+
+// isText reports whether the document is plain text.
+func (d Document) isText() bool {
+	return d.MediaType == "text/plain"
+}
+
+// dataURL returns the document encoded as a base64 data: URL.
+func (d Document) dataURL() string {
+	return "data:" + d.MediaType + ";base64," + base64.StdEncoding.EncodeToString(d.Data)
+}
+
+// Image is an image input, given by URL or as data.
+type Image struct {
+	URL       string // http(s) URL; empty when Data is set
+	Data      []byte
+	MediaType string // e.g. "image/png"; always set with Data
+}
+
+// dataURL returns the image's URL, encoding data as a base64 data: URL.
+func (i Image) dataURL() string {
+	if i.URL != "" {
+		return i.URL
+	}
+	return "data:" + i.MediaType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// requireImageData returns an error if any image is only a URL, for
+// providers that take images inline.
+func requireImageData(req Request, provider string) error {
+	for _, img := range req.Images {
+		if img.URL != "" {
+			return errUnsupported(provider, "image URLs; attach the image file instead")
+		}
+	}
+	return nil
+}
+
+// conversation returns a request's turns in order: Messages, then Prompt as
+// the final user turn, carrying the request's images and documents. An
+// empty Prompt is left out when Messages carries the whole conversation.
+func conversation(req Request) []Message {
+	turns := append([]Message(nil), req.Messages...)
+	if req.Prompt != "" || len(turns) == 0 {
+		turns = append(turns, Message{Role: "user", Content: req.Prompt})
+	}
+	if last := &turns[len(turns)-1]; last.Role == "user" {
+		last.Images = req.Images
+		last.Documents = req.Documents
+	}
+	return turns
+}
+
+// continuePrompt asks providers without assistant prefill to carry on from
+// a partial response.
+const continuePrompt = "Continue your previous response exactly where it stopped. Do not repeat any of it."
+
 // Response is the normalized response from providers.
 type Response struct {
-	Content string
-	Model   string
-	Usage   Usage
+	Content   string
+	Model     string
+	Usage     Usage
+	RequestID string     // Provider-assigned request ID, if returned
+	Citations []Citation // Sources cited by search-backed models
+
+	// FinishReason is why the model stopped: one of the Finish constants,
+	// the provider's own value if it has no equivalent, or empty if the
+	// provider doesn't say.
+	FinishReason string
+
+	// SystemFingerprint identifies the backend configuration that served
+	// the request (OpenAI), for telling seeded runs apart.
+	SystemFingerprint string
+
+	Logprobs []TokenLogprob // Set when requested
+}
+
+// Finish reasons, normalized from each provider's values.
+const (
+	FinishStop          = "stop"           // The model finished or hit a stop sequence
+	FinishLength        = "length"         // Cut off at MaxTokens
+	FinishToolUse       = "tool_use"       // Stopped to call a tool
+	FinishContentFilter = "content_filter" // Withheld or cut off by a safety filter
+)
+
+// TokenLogprob is an output token's log probability.
+type TokenLogprob struct {
+	Token       string
+	Logprob     float64
+	TopLogprobs []TokenLogprob // Most likely alternatives, if requested
+}
+
+// Citation is a source a response drew on.
+type Citation struct {
+	URL   string
+	Title string // Empty if the provider only returns URLs
 }
 
 // Usage contains token counts.
@@ -53,9 +711,83 @@ type Usage struct {
 
 // Chunk is a streaming response piece.
 type Chunk struct {
-	Content string
-	Done    bool
-	Error   error
+	Content   string
+	Done      bool
+	Error     error
+	RequestID string // Provider-assigned request ID, if known; on the Done chunk
+
+	Citations []Citation // Sources cited by the response; on the Done chunk
+
+	SystemFingerprint string // Backend configuration, if reported; on the Done chunk
+
+	Usage *Usage // Token counts, if the provider reports them; on the Done chunk
+
+	FinishReason string // Why the model stopped, if reported; on the Done chunk
+
+	Logprobs []TokenLogprob // The content's tokens, when requested
+}
+
+// marshalBody encodes a provider request body as JSON with extra fields
+// merged in. Objects are merged key by key, so an extra {"options":
+// {"num_ctx": 8192}} keeps the options sage set; anything else replaces the
+// body's value.
+func marshalBody(body any, extra map[string]any) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var merged map[string]any
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	mergeObject(merged, extra)
+	return json.Marshal(merged)
+}
+
+// mergeObject copies src into dst, merging nested objects present in both.
+func mergeObject(dst, src map[string]any) {
+	for k, v := range src {
+		sub, ok := v.(map[string]any)
+		existing, isObject := dst[k].(map[string]any)
+		if ok && isObject {
+			mergeObject(existing, sub)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// setExtraHeaders adds the request's configured headers to an HTTP request.
+func setExtraHeaders(r *http.Request, headers map[string]string) {
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+}
+
+// HTTPClientSetter is implemented by providers that can send their requests
+// through a caller's *http.Client, e.g. to add a proxy, TLS settings or
+// instrumentation. All built-in providers implement it.
+type HTTPClientSetter interface {
+	// SetHTTPClient replaces the client; nil restores http.DefaultClient.
+	SetHTTPClient(client *http.Client)
+}
+
+// httpClient is embedded by providers to implement HTTPClientSetter.
+type httpClient struct {
+	hc *http.Client
+}
+
+func (h *httpClient) SetHTTPClient(client *http.Client) {
+	h.hc = client
+}
+
+// client returns the HTTP client to send requests with.
+func (h *httpClient) client() *http.Client {
+	if h.hc != nil {
+		return h.hc
+	}
+	return http.DefaultClient
 }
 
 // Constructor is a function that creates a new Provider instance.
@@ -1,7 +1,15 @@
 package providers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 // mockProvider is a test provider implementation.
@@ -11,11 +19,11 @@ type mockProvider struct {
 
 func (m *mockProvider) Name() string { return m.name }
 
-func (m *mockProvider) Complete(req Request) (*Response, error) {
+func (m *mockProvider) Complete(ctx context.Context, req Request) (*Response, error) {
 	return &Response{Content: "mock response", Model: req.Model}, nil
 }
 
-func (m *mockProvider) CompleteStream(req Request) (<-chan Chunk, error) {
+func (m *mockProvider) CompleteStream(ctx context.Context, req Request) (<-chan Chunk, error) {
 	ch := make(chan Chunk, 1)
 	ch <- Chunk{Content: "mock", Done: true}
 	close(ch)
@@ -105,7 +113,7 @@ func TestProviderInterface(t *testing.T) {
 	p := &mockProvider{name: "test"}
 
 	// Test Complete
-	resp, err := p.Complete(Request{Model: "test-model", Prompt: "hello"})
+	resp, err := p.Complete(context.Background(), Request{Model: "test-model", Prompt: "hello"})
 	if err != nil {
 		t.Fatalf("Complete() error = %v", err)
 	}
@@ -114,7 +122,7 @@ func TestProviderInterface(t *testing.T) {
 	}
 
 	// Test CompleteStream
-	ch, err := p.CompleteStream(Request{Model: "test-model", Prompt: "hello"})
+	ch, err := p.CompleteStream(context.Background(), Request{Model: "test-model", Prompt: "hello"})
 	if err != nil {
 		t.Fatalf("CompleteStream() error = %v", err)
 	}
@@ -124,3 +132,197 @@ func TestProviderInterface(t *testing.T) {
 		t.Error("chunk.Done should be true")
 	}
 }
+
+func TestMarshalBody_ExtraBody(t *testing.T) {
+	body := openaiRequest{Model: "gpt-4o", MaxTokens: 10}
+
+	data, err := marshalBody(body, map[string]any{
+		"provider":   map[string]any{"order": []string{"openai"}},
+		"max_tokens": 20,
+	})
+	if err != nil {
+		t.Fatalf("marshalBody() error = %v", err)
+	}
+
+	var got map[string]any
+	json.Unmarshal(data, &got)
+
+	if got["model"] != "gpt-4o" {
+		t.Errorf("model = %v, want gpt-4o", got["model"])
+	}
+	if got["max_tokens"] != float64(20) {
+		t.Errorf("max_tokens = %v, want extra body to override it", got["max_tokens"])
+	}
+	if _, ok := got["provider"]; !ok {
+		t.Error("extra field missing from body")
+	}
+
+	// Nested objects are merged rather than replaced
+	temp := 0.5
+	data, err = marshalBody(ollamaRequest{Model: "llama3.2", Options: &ollamaOptions{Temperature: &temp}}, map[string]any{
+		"options": map[string]any{"num_ctx": 8192},
+	})
+	if err != nil {
+		t.Fatalf("marshalBody() error = %v", err)
+	}
+	var nested struct {
+		Options map[string]any `json:"options"`
+	}
+	json.Unmarshal(data, &nested)
+	if nested.Options["num_ctx"] != float64(8192) || nested.Options["temperature"] != 0.5 {
+		t.Errorf("options = %v, want num_ctx added alongside temperature", nested.Options)
+	}
+
+	// No extras leaves the body untouched
+	plain, _ := marshalBody(body, nil)
+	want, _ := json.Marshal(body)
+	if string(plain) != string(want) {
+		t.Errorf("marshalBody(nil) = %s, want %s", plain, want)
+	}
+}
+
+func TestWithRetryAfter(t *testing.T) {
+	tests := []struct {
+		headers map[string]string
+		want    time.Duration
+		ok      bool
+	}{
+		{map[string]string{"Retry-After": "3"}, 3 * time.Second, true},
+		{map[string]string{"Retry-After": "1", "Retry-After-Ms": "250"}, 250 * time.Millisecond, true},
+		{map[string]string{"Retry-After": time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)}, 0, true},
+		{map[string]string{"Retry-After": "soon"}, 0, false},
+		{map[string]string{}, 0, false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		for k, v := range tt.headers {
+			resp.Header.Set(k, v)
+		}
+		err := withRetryAfter(resp, ErrRateLimited)
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("withRetryAfter(%v) = %v, want it to wrap ErrRateLimited", tt.headers, err)
+		}
+		if got, ok := RetryAfter(err); got != tt.want || ok != tt.ok {
+			t.Errorf("RetryAfter() with %v = %v, %v; want %v, %v", tt.headers, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// countingTransport counts the requests it sends.
+type countingTransport struct{ n int }
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.n++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestSetHTTPClient(t *testing.T) {
+	for _, name := range List() {
+		p, _ := Get(name)
+		if _, ok := p.(HTTPClientSetter); !ok {
+			t.Errorf("%s doesn't implement HTTPClientSetter", name)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "hi"}}]}`))
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	p := NewOpenAI()
+	p.(HTTPClientSetter).SetHTTPClient(&http.Client{Transport: transport})
+	if _, err := p.Complete(context.Background(), Request{Model: "gpt-4o", Prompt: "hi", Endpoint: Endpoint{BaseURL: server.URL}}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if transport.n != 1 {
+		t.Errorf("custom client sent %d requests, want 1", transport.n)
+	}
+}
+
+func TestHandleError_RequestErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status func(*http.Response) error
+		code   int
+		header string // X-Amzn-ErrorType
+		body   string
+		want   error
+	}{
+		{"openai context", (&openai{}).handleError, 400, "",
+			`{"error": {"message": "This model's maximum context length is 128000 tokens.", "type": "invalid_request_error", "code": "context_length_exceeded"}}`,
+			ErrContextLengthExceeded},
+		{"openai model", (&openai{}).handleError, 404, "",
+			`{"error": {"message": "The model 'gpt-9' does not exist", "type": "invalid_request_error", "code": "model_not_found"}}`,
+			ErrModelNotFound},
+		{"azure filter", (&openai{}).handleError, 400, "",
+			`{"error": {"message": "The response was filtered due to the prompt triggering Azure OpenAI's content management policy.", "code": "content_filter"}}`,
+			ErrContentFiltered},
+		{"anthropic context", (&anthropic{}).handleError, 400, "",
+			`{"type": "error", "error": {"type": "invalid_request_error", "message": "prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			ErrContextLengthExceeded},
+		{"anthropic model", (&anthropic{}).handleError, 404, "",
+			`{"type": "error", "error": {"type": "not_found_error", "message": "model: claude-9"}}`,
+			ErrModelNotFound},
+		{"ollama model", (&ollama{}).handleError, 404, "",
+			`{"error": "model \"llama9\" not found, try pulling it first"}`,
+			ErrModelNotFound},
+		{"vertex model", (&vertex{}).handleError, 404, "",
+			`{"error": {"code": 404, "message": "Publisher Model gemini-9 was not found", "status": "NOT_FOUND"}}`,
+			ErrModelNotFound},
+		{"bedrock context", (&bedrock{}).handleError, 400, "ValidationException:http://internal.amazon.com/coral/com.amazon.bedrock/",
+			`{"message": "Input is too long for requested model."}`,
+			ErrContextLengthExceeded},
+		{"bedrock model", (&bedrock{}).handleError, 404, "ResourceNotFoundException:http://internal.amazon.com/coral/com.amazon.bedrock/",
+			`{"message": "Could not resolve the foundation model from the provided model identifier."}`,
+			ErrModelNotFound},
+		{"other bad request", (&replicate{}).handleError, 422, "",
+			`{"detail": "Invalid version or not permitted"}`,
+			nil},
+		{"unknown path", (&openai{}).handleError, 404, "", `404 page not found`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.code,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.header != "" {
+				resp.Header.Set("X-Amzn-ErrorType", tt.header)
+			}
+			err := tt.status(resp)
+			for _, sentinel := range []error{ErrContextLengthExceeded, ErrModelNotFound, ErrContentFiltered} {
+				if errors.Is(err, sentinel) != (sentinel == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v", err, sentinel, !(sentinel == tt.want))
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{401, ErrUnauthorized},
+		{403, ErrUnauthorized},
+		{429, ErrRateLimited},
+		{500, ErrServerError},
+		{503, ErrServerError},
+		{400, nil},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.code, Header: http.Header{"Retry-After": {"2"}}}
+		err := classifyStatus(resp, "", "nope")
+		for _, sentinel := range []error{ErrUnauthorized, ErrRateLimited, ErrServerError} {
+			if errors.Is(err, sentinel) != (sentinel == tt.want) {
+				t.Errorf("classifyStatus(%d) = %v, errors.Is(%v) = %v", tt.code, err, sentinel, !(sentinel == tt.want))
+			}
+		}
+		if delay, ok := RetryAfter(err); !ok || delay != 2*time.Second {
+			t.Errorf("RetryAfter(classifyStatus(%d)) = %v, %v, want 2s", tt.code, delay, ok)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const replicateDefaultURL = "https://api.replicate.com"
+
+// replicatePollInterval is how often Complete checks an unfinished
+// prediction. A variable so tests can shorten it.
+var replicatePollInterval = time.Second
+
+func init() {
+	Register("replicate", NewReplicate)
+}
+
+// replicate runs language models on Replicate. Each request creates a
+// prediction, which Complete polls until it finishes and CompleteStream
+// follows through the prediction's stream URL. A profile's model is
+// "owner/name" for the model's latest version or "owner/name:version" to pin
+// one. ExtraBody fields are merged into the prediction's input.
+type replicate struct {
+	httpClient
+}
+
+// NewReplicate creates a new Replicate provider.
+func NewReplicate() Provider {
+	return &replicate{}
+}
+
+func (r *replicate) Name() string {
+	return "replicate"
+}
+
+// Replicate API request/response types
+
+type replicateRequest struct {
+	Version string          `json:"version,omitempty"`
+	Input   json.RawMessage `json:"input"`
+	Stream  bool            `json:"stream,omitempty"`
+}
+
+type replicateInput struct {
+	Prompt       string `json:"prompt"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	MaxTokens    int    `json:"max_tokens,omitempty"`
+
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+}
+
+type replicatePrediction struct {
+	ID      string           `json:"id"`
+	Status  string           `json:"status"`
+	Output  json.RawMessage  `json:"output"`
+	Error   json.RawMessage  `json:"error"`
+	URLs    replicateURLs    `json:"urls"`
+	Metrics replicateMetrics `json:"metrics"`
+}
+
+type replicateURLs struct {
+	Get    string `json:"get"`
+	Stream string `json:"stream"`
+}
+
+type replicateMetrics struct {
+	InputTokenCount  int `json:"input_token_count"`
+	OutputTokenCount int `json:"output_token_count"`
+}
+
+type replicateError struct {
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+// done reports whether the prediction reached a terminal status.
+func (p *replicatePrediction) done() bool {
+	return p.Status == "succeeded" || p.Status == "failed" || p.Status == "canceled"
+}
+
+// text joins the prediction's output. Language models return a list of
+// tokens; some return a single string.
+func (p *replicatePrediction) text() string {
+	var tokens []string
+	if err := json.Unmarshal(p.Output, &tokens); err == nil {
+		return strings.Join(tokens, "")
+	}
+	var s string
+	json.Unmarshal(p.Output, &s)
+	return s
+}
+
+// failure returns the error for a prediction that didn't succeed.
+func (p *replicatePrediction) failure() error {
+	var msg string
+	if err := json.Unmarshal(p.Error, &msg); err != nil || msg == "" {
+		msg = string(p.Error)
+	}
+	if p.Status == "canceled" {
+		return fmt.Errorf("replicate prediction %s canceled", p.ID)
+	}
+	return fmt.Errorf("replicate prediction %s failed: %s", p.ID, msg)
+}
+
+func (r *replicate) Complete(ctx context.Context, req Request) (*Response, error) {
+	// Wait briefly on creation so short predictions need no polling
+	pred, err := r.createPrediction(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for !pred.done() {
+		if err := sleepContext(ctx, replicatePollInterval); err != nil {
+			return nil, err
+		}
+		if pred, err = r.getPrediction(ctx, req, pred); err != nil {
+			return nil, err
+		}
+	}
+
+	if pred.Status != "succeeded" {
+		return nil, pred.failure()
+	}
+
+	return &Response{
+		Content: pred.text(),
+		Model:   req.Model,
+		Usage: Usage{
+			PromptTokens:     pred.Metrics.InputTokenCount,
+			CompletionTokens: pred.Metrics.OutputTokenCount,
+		},
+		RequestID: pred.ID,
+	}, nil
+}
+
+func (r *replicate) CompleteStream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	pred, err := r.createPrediction(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	if pred.URLs.Stream == "" {
+		return nil, fmt.Errorf("replicate model %s does not support streaming", req.Model)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", pred.URLs.Stream, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Cache-Control", "no-store")
+	r.setAuth(httpReq, req.APIKey)
+
+	resp, err := r.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, r.handleError(resp)
+	}
+
+	ch := make(chan Chunk)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		idle := newIdleTimer(req.IdleTimeout, resp.Body)
+		defer idle.stop()
+
+		// Replicate's events can carry multi-line data, so collect an
+		// event's data lines until the blank line that ends it
+		scanner := bufio.NewScanner(resp.Body)
+		var event string
+		var data []string
+
+		for scanner.Scan() {
+			idle.reset()
+			field, value := sseField(scanner.Text())
+
+			switch field {
+			case ":":
+				if req.OnHeartbeat != nil {
+					req.OnHeartbeat()
+				}
+				continue
+			case "event":
+				event = value
+				continue
+			case "data":
+				data = append(data, value)
+				continue
+			case "":
+				// Blank line: dispatch below
+			default:
+				continue
+			}
+
+			text := strings.Join(data, "\n")
+			kind := event
+			event, data = "", nil
+
+			switch kind {
+			case "output":
+				if text != "" {
+					if !sendChunk(ctx, ch, Chunk{Content: text}) {
+						return
+					}
+				}
+			case "error":
+				sendChunk(ctx, ch, Chunk{Error: fmt.Errorf("replicate prediction %s failed: %s", pred.ID, text)})
+				return
+			case "done":
+				var reason struct {
+					Reason string `json:"reason"`
+				}
+				json.Unmarshal([]byte(text), &reason)
+				if reason.Reason == "canceled" {
+					sendChunk(ctx, ch, Chunk{Error: fmt.Errorf("replicate prediction %s canceled", pred.ID)})
+					return
+				}
+				sendChunk(ctx, ch, Chunk{Done: true, RequestID: pred.ID})
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			sendChunk(ctx, ch, Chunk{Error: idle.readError(err)})
+		}
+	}()
+
+	return ch, nil
+}
+
+// createPrediction starts a prediction. Without streaming it asks the API
+// to wait for the result, which returns early if the model is quick.
+func (r *replicate) createPrediction(ctx context.Context, req Request, stream bool) (*replicatePrediction, error) {
+	if req.ResponseFormat != nil {
+		return nil, errUnsupported("replicate", "structured output")
+	}
+	if len(req.Images) > 0 {
+		return nil, errUnsupported("replicate", "images")
+	}
+	if err := requireNoLogprobs(req, "replicate"); err != nil {
+		return nil, err
+	}
+	if err := requireNoReasoningEffort(req, "replicate"); err != nil {
+		return nil, err
+	}
+
+	input, err := marshalBody(r.buildInput(req), req.ExtraBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	body := replicateRequest{Input: input, Stream: stream}
+	endpoint := r.baseURL(req.BaseURL) + "/v1/models/" + req.Model + "/predictions"
+	if _, version, ok := strings.Cut(req.Model, ":"); ok {
+		body.Version = version
+		endpoint = r.baseURL(req.BaseURL) + "/v1/predictions"
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	r.setAuth(httpReq, req.APIKey)
+	setExtraHeaders(httpReq, req.Headers)
+	if !stream {
+		httpReq.Header.Set("Prefer", "wait")
+	}
+
+	return r.doPrediction(httpReq)
+}
+
+// getPrediction fetches the current state of a prediction.
+func (r *replicate) getPrediction(ctx context.Context, req Request, pred *replicatePrediction) (*replicatePrediction, error) {
+	endpoint := pred.URLs.Get
+	if endpoint == "" {
+		endpoint = r.baseURL(req.BaseURL) + "/v1/predictions/" + pred.ID
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	r.setAuth(httpReq, req.APIKey)
+
+	return r.doPrediction(httpReq)
+}
+
+func (r *replicate) doPrediction(httpReq *http.Request) (*replicatePrediction, error) {
+	resp, err := r.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, r.handleError(resp)
+	}
+
+	var pred replicatePrediction
+	if err := json.NewDecoder(resp.Body).Decode(&pred); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &pred, nil
+}
+
+func (r *replicate) buildInput(req Request) replicateInput {
+	prompt := req.Prompt
+
+	// Replicate models take a single prompt, so earlier turns are written
+	// out as a transcript ahead of it
+	if len(req.Messages) > 0 {
+		var b strings.Builder
+		for _, m := range conversation(req) {
+			fmt.Fprintf(&b, "%s: %s\n\n", replicateRoles[m.Role], m.Content)
+		}
+		prompt = b.String() + "Assistant:"
+	}
+
+	// Replicate models take a single prompt, so include the partial
+	// response and ask for the rest
+	if req.Continue != "" {
+		prompt += "\n\nYour response so far:\n" + req.Continue + "\n\n" + continuePrompt
+	}
+
+	return replicateInput{
+		Prompt:       prompt,
+		SystemPrompt: req.System,
+		MaxTokens:    req.MaxTokens,
+
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Seed:             req.Seed,
+	}
+}
+
+// replicateRoles labels conversation turns in a transcript prompt.
+var replicateRoles = map[string]string{
+	"system":    "System",
+	"user":      "User",
+	"assistant": "Assistant",
+}
+
+func (r *replicate) baseURL(baseURL string) string {
+	if baseURL == "" {
+		return replicateDefaultURL
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+func (r *replicate) setAuth(req *http.Request, apiKey string) {
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+}
+
+func (r *replicate) handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	msg := string(body)
+	var errResp replicateError
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Detail != "" {
+		msg = errResp.Detail
+	}
+	return classifyStatus(resp, "", msg)
+}
+
+// ListModels returns the models in Replicate's language-models collection.
+func (r *replicate) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
+	req, err := http.NewRequest("GET", r.baseURL(baseURL)+"/v1/collections/language-models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	r.setAuth(req, apiKey)
+
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, r.handleError(resp)
+	}
+
+	var result replicateCollection
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	models := make([]ModelInfo, 0, len(result.Models))
+	for _, m := range result.Models {
+		id := m.Owner + "/" + m.Name
+		models = append(models, ModelInfo{
+			ID:          id,
+			Name:        id,
+			Description: m.Description,
+		})
+	}
+
+	return models, nil
+}
+
+type replicateCollection struct {
+	Models []replicateModel `json:"models"`
+}
+
+type replicateModel struct {
+	Owner       string `json:"owner"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
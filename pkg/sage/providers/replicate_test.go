@@ -0,0 +1,167 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplicate_CompletePolls(t *testing.T) {
+	replicatePollInterval = time.Millisecond
+	defer func() { replicatePollInterval = time.Second }()
+
+	var server *httptest.Server
+	var gotPath, gotPrefer string
+	var gotInput map[string]any
+	polls := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		getURL := server.URL + "/v1/predictions/p1"
+		if r.Method == "POST" {
+			gotPath = r.URL.Path
+			gotPrefer = r.Header.Get("Prefer")
+			var body replicateRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			json.Unmarshal(body.Input, &gotInput)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "p1", "status": "starting", "urls": {"get": %q}}`, getURL)
+			return
+		}
+
+		polls++
+		if polls < 2 {
+			fmt.Fprintf(w, `{"id": "p1", "status": "processing", "urls": {"get": %q}}`, getURL)
+			return
+		}
+		w.Write([]byte(`{"id": "p1", "status": "succeeded", "output": ["Hel", "lo"],
+			"metrics": {"input_token_count": 4, "output_token_count": 2}}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewReplicate().Complete(context.Background(), Request{
+		Model:     "meta/meta-llama-3-8b-instruct",
+		System:    "Be brief",
+		Prompt:    "hi",
+		MaxTokens: 20,
+		Endpoint:  Endpoint{APIKey: "r8-key", BaseURL: server.URL},
+		ExtraBody: map[string]any{"temperature": 0.2},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if resp.Content != "Hello" || resp.RequestID != "p1" {
+		t.Errorf("response = %+v, want Hello from prediction p1", resp)
+	}
+	if resp.Usage.PromptTokens != 4 || resp.Usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+	if polls != 2 {
+		t.Errorf("polls = %d, want 2", polls)
+	}
+	if gotPath != "/v1/models/meta/meta-llama-3-8b-instruct/predictions" || gotPrefer != "wait" {
+		t.Errorf("path = %q, Prefer = %q", gotPath, gotPrefer)
+	}
+	if gotInput["prompt"] != "hi" || gotInput["system_prompt"] != "Be brief" || gotInput["temperature"] != 0.2 {
+		t.Errorf("input = %v", gotInput)
+	}
+}
+
+func TestReplicate_CompleteVersion(t *testing.T) {
+	var gotPath string
+	var gotBody replicateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"id": "p2", "status": "succeeded", "output": "ok"}`))
+	}))
+	defer server.Close()
+
+	resp, err := NewReplicate().Complete(context.Background(), Request{
+		Model:    "acme/my-model:abc123",
+		Prompt:   "hi",
+		Endpoint: Endpoint{APIKey: "r8-key", BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q, want %q", resp.Content, "ok")
+	}
+	if gotPath != "/v1/predictions" || gotBody.Version != "abc123" {
+		t.Errorf("path = %q, version = %q", gotPath, gotBody.Version)
+	}
+}
+
+func TestReplicate_CompleteFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "p3", "status": "failed", "error": "CUDA out of memory"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewReplicate().Complete(context.Background(), Request{Model: "a/b", Prompt: "hi", Endpoint: Endpoint{APIKey: "k", BaseURL: server.URL}})
+	if err == nil || !strings.Contains(err.Error(), "CUDA out of memory") {
+		t.Errorf("error = %v, want prediction failure", err)
+	}
+}
+
+func TestReplicate_Stream(t *testing.T) {
+	var server *httptest.Server
+	var gotStream bool
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			var body replicateRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			gotStream = body.Stream
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": "p4", "status": "starting", "urls": {"stream": %q}}`, server.URL+"/stream/p4")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": keep-alive\n\n"))
+		w.Write([]byte("event: output\ndata: Hello\n\n"))
+		w.Write([]byte("event: output\ndata: line one\ndata: line two\n\n"))
+		w.Write([]byte("event: done\ndata: {}\n\n"))
+	}))
+	defer server.Close()
+
+	heartbeats := 0
+	ch, err := NewReplicate().CompleteStream(context.Background(), Request{
+		Model:       "meta/meta-llama-3-8b-instruct",
+		Prompt:      "hi",
+		Endpoint:    Endpoint{APIKey: "r8-key", BaseURL: server.URL},
+		OnHeartbeat: func() { heartbeats++ },
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var content, requestID string
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		content += chunk.Content
+		if chunk.Done {
+			requestID = chunk.RequestID
+		}
+	}
+
+	if !gotStream {
+		t.Error("prediction was not created with stream: true")
+	}
+	if content != "Helloline one\nline two" {
+		t.Errorf("content = %q", content)
+	}
+	if requestID != "p4" {
+		t.Errorf("RequestID = %q, want %q", requestID, "p4")
+	}
+	if heartbeats != 1 {
+		t.Errorf("heartbeats = %d, want 1", heartbeats)
+	}
+}
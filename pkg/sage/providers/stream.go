@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStreamIdle is wrapped by stream errors when no data arrived within the
+// request's IdleTimeout.
+var ErrStreamIdle = errors.New("stream idle")
+
+// sendChunk delivers c unless ctx is done first, and reports whether it was
+// delivered. Stream goroutines return when it fails, rather than blocking on
+// a reader that has gone away.
+func sendChunk(ctx context.Context, ch chan<- Chunk, c Chunk) bool {
+	select {
+	case ch <- c:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sleepContext waits for d, or returns ctx's error if it's done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sseField splits a server-sent events line into its field name and value.
+// Comment lines, which providers and proxies send as keep-alives, return
+// field ":".
+func sseField(line string) (field, value string) {
+	if strings.HasPrefix(line, ":") {
+		return ":", strings.TrimSpace(line[1:])
+	}
+	field, value, _ = strings.Cut(line, ":")
+	return field, strings.TrimPrefix(value, " ")
+}
+
+// idleTimer closes a stream's body when no data arrives within the timeout,
+// so a read blocked on a stalled connection fails. A zero timeout disables it.
+type idleTimer struct {
+	timer   *time.Timer
+	timeout time.Duration
+	fired   atomic.Bool
+}
+
+func newIdleTimer(timeout time.Duration, body io.Closer) *idleTimer {
+	t := &idleTimer{timeout: timeout}
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, func() {
+			t.fired.Store(true)
+			body.Close()
+		})
+	}
+	return t
+}
+
+// reset restarts the timeout after data, including a heartbeat, arrived.
+func (t *idleTimer) reset() {
+	if t.timer != nil {
+		t.timer.Reset(t.timeout)
+	}
+}
+
+func (t *idleTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// readError returns the error to report for a failed stream read.
+func (t *idleTimer) readError(err error) error {
+	if t.fired.Load() {
+		return fmt.Errorf("%w: no data for %v", ErrStreamIdle, t.timeout)
+	}
+	return fmt.Errorf("stream read error: %w", err)
+}
+
+// streamParseError returns the error for a stream line that failed to parse.
+// bufio.Scanner hands back a truncated final line before reporting a read
+// error, so a dropped connection is reported as such rather than as bad data.
+func (t *idleTimer) streamParseError(scanner *bufio.Scanner, err error) error {
+	if !scanner.Scan() && scanner.Err() != nil {
+		return t.readError(scanner.Err())
+	}
+	return fmt.Errorf("failed to parse stream data: %w", err)
+}
@@ -0,0 +1,292 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSEField(t *testing.T) {
+	tests := []struct {
+		line, field, value string
+	}{
+		{"data: {\"a\": 1}", "data", "{\"a\": 1}"},
+		{"data:{\"a\": 1}", "data", "{\"a\": 1}"},
+		{"event: ping", "event", "ping"},
+		{": keep-alive", ":", "keep-alive"},
+		{":", ":", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		field, value := sseField(tt.line)
+		if field != tt.field || value != tt.value {
+			t.Errorf("sseField(%q) = %q, %q; want %q, %q", tt.line, field, value, tt.field, tt.value)
+		}
+	}
+}
+
+func TestOpenAI_CompleteStream_Heartbeats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			w.Write([]byte(": ping\n\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.Write([]byte("data:{\"choices\": [{\"delta\": {\"content\": \"hi\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	heartbeats := 0
+	ch, err := (&openai{}).CompleteStream(context.Background(), Request{
+		Model:       "gpt-4o",
+		Prompt:      "hi",
+		Endpoint:    Endpoint{BaseURL: server.URL},
+		IdleTimeout: 50 * time.Millisecond, // Longer than the gaps, shorter than the whole wait
+		OnHeartbeat: func() { heartbeats++ },
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var content string
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		content += chunk.Content
+	}
+	if content != "hi" {
+		t.Errorf("content = %q, want %q", content, "hi")
+	}
+	if heartbeats != 3 {
+		t.Errorf("heartbeats = %d, want 3", heartbeats)
+	}
+}
+
+func TestAnthropic_CompleteStream_IdleTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: ping\ndata: {\"type\": \"ping\"}\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // Stall until the client gives up
+	}))
+	defer server.Close()
+
+	ch, err := (&anthropic{}).CompleteStream(context.Background(), Request{
+		Model:       "claude-sonnet-4-20250514",
+		Prompt:      "hi",
+		Endpoint:    Endpoint{BaseURL: server.URL},
+		IdleTimeout: 30 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var streamErr error
+	for chunk := range ch {
+		if chunk.Error != nil {
+			streamErr = chunk.Error
+		}
+	}
+	if !errors.Is(streamErr, ErrStreamIdle) {
+		t.Errorf("stream error = %v, want ErrStreamIdle", streamErr)
+	}
+}
+
+func TestOpenAI_CompleteStream_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data:{\"choices\": [{\"delta\": {\"content\": \"hi\"}}]}\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // Stall until the client gives up
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := (&openai{}).CompleteStream(ctx, Request{Model: "gpt-4o", Prompt: "hi", Endpoint: Endpoint{BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+	if chunk := <-ch; chunk.Content != "hi" {
+		t.Fatalf("first chunk = %+v", chunk)
+	}
+
+	// The stalled connection is closed rather than waited on
+	cancel()
+	select {
+	case <-drained(ch):
+	case <-time.After(time.Second):
+		t.Fatal("stream still open a second after cancelling")
+	}
+}
+
+// drained reads ch in the background and reports when it's closed.
+func drained(ch <-chan Chunk) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+	return done
+}
+
+// doneUsage drains a stream and returns the usage on its Done chunk.
+func doneUsage(t *testing.T, ch <-chan Chunk) *Usage {
+	t.Helper()
+	return doneChunk(t, ch).Usage
+}
+
+// doneChunk drains a stream and returns its Done chunk.
+func doneChunk(t *testing.T, ch <-chan Chunk) Chunk {
+	t.Helper()
+	var done Chunk
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		if chunk.Done {
+			done = chunk
+		}
+	}
+	return done
+}
+
+func TestCompleteStream_FinishReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		model    string
+		format   *ResponseFormat
+		stream   string
+		want     string
+	}{
+		{"openai", NewOpenAI(), "gpt-4o", nil, `data: {"choices": [{"delta": {"content": "Hi"}}]}
+
+data: {"choices": [{"delta": {}, "finish_reason": "length"}]}
+
+data: [DONE]
+
+`, FinishLength},
+		{"openai tool", NewOpenAI(), "gpt-4o", nil, `data: {"choices": [{"delta": {}, "finish_reason": "tool_calls"}]}
+
+data: [DONE]
+
+`, FinishToolUse},
+		{"anthropic", NewAnthropic(), "claude-sonnet-4-20250514", nil, `event: message_delta
+data: {"type": "message_delta", "delta": {"stop_reason": "max_tokens"}, "usage": {"output_tokens": 4}}
+
+event: message_stop
+data: {"type": "message_stop"}
+
+`, FinishLength},
+		{"anthropic structured output", NewAnthropic(), "claude-sonnet-4-20250514", &ResponseFormat{Type: "json_object"}, `event: message_delta
+data: {"type": "message_delta", "delta": {"stop_reason": "tool_use"}}
+
+event: message_stop
+data: {"type": "message_stop"}
+
+`, FinishStop},
+		{"ollama", NewOllama(), "llama3.2", nil, `{"message": {"content": "Hi"}, "done": true, "done_reason": "length"}
+`, FinishLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.stream))
+			}))
+			defer server.Close()
+
+			ch, err := tt.provider.CompleteStream(context.Background(), Request{Model: tt.model, Prompt: "hi", ResponseFormat: tt.format, Endpoint: Endpoint{BaseURL: server.URL}})
+			if err != nil {
+				t.Fatalf("CompleteStream() error = %v", err)
+			}
+			if got := doneChunk(t, ch).FinishReason; got != tt.want {
+				t.Errorf("FinishReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAI_CompleteStream_Usage(t *testing.T) {
+	var gotOptions any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		gotOptions = body["stream_options"]
+		w.Write([]byte(`data: {"choices": [{"delta": {"content": "hi"}}]}
+
+data: {"choices": [], "usage": {"prompt_tokens": 9, "completion_tokens": 1}}
+
+data: [DONE]
+
+`))
+	}))
+	defer server.Close()
+
+	ch, err := NewOpenAI().CompleteStream(context.Background(), Request{Model: "gpt-4o", Prompt: "hi", Endpoint: Endpoint{BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	usage := doneUsage(t, ch)
+	if usage == nil || usage.PromptTokens != 9 || usage.CompletionTokens != 1 {
+		t.Errorf("Usage = %+v, want 9 prompt + 1 completion", usage)
+	}
+	if opts, _ := gotOptions.(map[string]any); opts["include_usage"] != true {
+		t.Errorf("stream_options = %v, want include_usage", gotOptions)
+	}
+}
+
+func TestAnthropic_CompleteStream_Usage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`event: message_start
+data: {"type": "message_start", "message": {"usage": {"input_tokens": 12, "output_tokens": 1}}}
+
+event: content_block_delta
+data: {"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Hi"}}
+
+event: message_delta
+data: {"type": "message_delta", "delta": {"stop_reason": "end_turn"}, "usage": {"output_tokens": 4}}
+
+event: message_stop
+data: {"type": "message_stop"}
+
+`))
+	}))
+	defer server.Close()
+
+	ch, err := NewAnthropic().CompleteStream(context.Background(), Request{Model: "claude-sonnet-4-20250514", Prompt: "hi", Endpoint: Endpoint{BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	usage := doneUsage(t, ch)
+	if usage == nil || usage.PromptTokens != 12 || usage.CompletionTokens != 4 {
+		t.Errorf("Usage = %+v, want 12 prompt + 4 completion", usage)
+	}
+}
+
+func TestOllama_CompleteStream_Usage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message": {"content": "Hi"}, "done": false}
+{"message": {"content": ""}, "done": true, "prompt_eval_count": 7, "eval_count": 2}
+`))
+	}))
+	defer server.Close()
+
+	ch, err := NewOllama().CompleteStream(context.Background(), Request{Model: "llama3.2", Prompt: "hi", Endpoint: Endpoint{BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	usage := doneUsage(t, ch)
+	if usage == nil || usage.PromptTokens != 7 || usage.CompletionTokens != 2 {
+		t.Errorf("Usage = %+v, want 7 prompt + 2 completion", usage)
+	}
+}
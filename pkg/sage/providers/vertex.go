@@ -0,0 +1,453 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	vertexDefaultRegion    = "us-central1"
+	vertexAnthropicVersion = "vertex-2023-10-16"
+)
+
+func init() {
+	Register("vertex", NewVertex)
+}
+
+// vertex implements Google Vertex AI for Gemini and, through Anthropic's
+// publisher endpoint, Claude. The account's API key is the path to a
+// service-account JSON key; without one, Application Default Credentials
+// are used. The project comes from GOOGLE_CLOUD_PROJECT or the credentials,
+// and the region from the base URL (https://<region>-aiplatform.googleapis.com),
+// then GOOGLE_CLOUD_LOCATION.
+type vertex struct {
+	httpClient
+}
+
+// NewVertex creates a new Vertex AI provider.
+func NewVertex() Provider {
+	return &vertex{}
+}
+
+func (v *vertex) Name() string {
+	return "vertex"
+}
+
+// Gemini request/response types
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *geminiInlineData `json:"inlineData,omitempty"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     []byte `json:"data"` // Encoded as base64
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens    int             `json:"maxOutputTokens,omitempty"`
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               *float64        `json:"topP,omitempty"`
+	FrequencyPenalty   *float64        `json:"frequencyPenalty,omitempty"`
+	PresencePenalty    *float64        `json:"presencePenalty,omitempty"`
+	Seed               *int            `json:"seed,omitempty"`
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates    []geminiCandidate   `json:"candidates"`
+	UsageMetadata geminiUsageMetadata `json:"usageMetadata"`
+	ResponseID    string              `json:"responseId"`
+}
+
+type geminiCandidate struct {
+	Content      geminiContent `json:"content"`
+	FinishReason string        `json:"finishReason"`
+}
+
+type geminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+}
+
+// geminiFinishReason normalizes a candidate's finishReason.
+func geminiFinishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "STOP":
+		return FinishStop
+	case "MAX_TOKENS":
+		return FinishLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return FinishContentFilter
+	}
+	return strings.ToLower(reason)
+}
+
+// text joins the first candidate's parts.
+func (r *geminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, p := range r.Candidates[0].Content.Parts {
+		b.WriteString(p.Text)
+	}
+	return b.String()
+}
+
+type googleError struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// vertexPublisher returns who publishes a model on Vertex.
+func vertexPublisher(model string) string {
+	if strings.HasPrefix(model, "claude") {
+		return "anthropic"
+	}
+	return "google"
+}
+
+func (v *vertex) Complete(ctx context.Context, req Request) (*Response, error) {
+	publisher := vertexPublisher(req.Model)
+	method := "generateContent"
+	if publisher == "anthropic" {
+		method = "rawPredict"
+	}
+
+	resp, err := v.invoke(ctx, req, publisher, method, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if publisher == "anthropic" {
+		var claudeResp anthropicResponse
+		if err := json.NewDecoder(resp.Body).Decode(&claudeResp); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return &Response{
+			Content: claudeResp.text(),
+			Model:   req.Model,
+			Usage: Usage{
+				PromptTokens:     claudeResp.Usage.InputTokens,
+				CompletionTokens: claudeResp.Usage.OutputTokens,
+			},
+			FinishReason: anthropicFinishReason(claudeResp.StopReason, req),
+		}, nil
+	}
+
+	var geminiResp geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(geminiResp.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidates in response")
+	}
+
+	return &Response{
+		Content: geminiResp.text(),
+		Model:   req.Model,
+		Usage: Usage{
+			PromptTokens:     geminiResp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+		},
+		RequestID:    geminiResp.ResponseID,
+		FinishReason: geminiFinishReason(geminiResp.Candidates[0].FinishReason),
+	}, nil
+}
+
+func (v *vertex) CompleteStream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	publisher := vertexPublisher(req.Model)
+	if publisher == "anthropic" {
+		resp, err := v.invoke(ctx, req, publisher, "streamRawPredict", true)
+		if err != nil {
+			return nil, err
+		}
+		return readAnthropicStream(ctx, resp, req), nil
+	}
+
+	resp, err := v.invoke(ctx, req, publisher, "streamGenerateContent?alt=sse", true)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Chunk)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		idle := newIdleTimer(req.IdleTimeout, resp.Body)
+		defer idle.stop()
+
+		// Each chunk reports the usage so far, and the last one the finish
+		// reason; keep the latest
+		var usage *Usage
+		var finish string
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			idle.reset()
+			field, data := sseField(scanner.Text())
+
+			// Keep-alive comments
+			if field == ":" {
+				if req.OnHeartbeat != nil {
+					req.OnHeartbeat()
+				}
+				continue
+			}
+
+			if field != "data" {
+				continue
+			}
+
+			var streamResp geminiResponse
+			if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+				sendChunk(ctx, ch, Chunk{Error: idle.streamParseError(scanner, err)})
+				return
+			}
+
+			if m := streamResp.UsageMetadata; m.PromptTokenCount > 0 || m.CandidatesTokenCount > 0 {
+				usage = &Usage{PromptTokens: m.PromptTokenCount, CompletionTokens: m.CandidatesTokenCount}
+			}
+
+			if len(streamResp.Candidates) > 0 && streamResp.Candidates[0].FinishReason != "" {
+				finish = geminiFinishReason(streamResp.Candidates[0].FinishReason)
+			}
+
+			if content := streamResp.text(); content != "" {
+				if !sendChunk(ctx, ch, Chunk{Content: content}) {
+					return
+				}
+			}
+		}
+
+		// Gemini ends the stream without a done marker
+		if err := scanner.Err(); err != nil {
+			sendChunk(ctx, ch, Chunk{Error: idle.readError(err)})
+			return
+		}
+		sendChunk(ctx, ch, Chunk{Done: true, Usage: usage, FinishReason: finish})
+	}()
+
+	return ch, nil
+}
+
+// invoke authenticates and sends a request to a model method, returning the
+// response only if it succeeded.
+func (v *vertex) invoke(ctx context.Context, req Request, publisher, method string, stream bool) (*http.Response, error) {
+	if err := requireImageData(req, "vertex"); err != nil {
+		return nil, err
+	}
+	if err := requireNoLogprobs(req, "vertex"); err != nil {
+		return nil, err
+	}
+	if err := requireNoReasoningEffort(req, "vertex"); err != nil {
+		return nil, err
+	}
+	if publisher == "anthropic" {
+		if err := requireBasicSampling(req, "claude on vertex"); err != nil {
+			return nil, err
+		}
+	}
+
+	creds, err := loadGoogleCredentials(req.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	token, err := creds.accessToken(ctx, v.client())
+	if err != nil {
+		return nil, err
+	}
+
+	project := vertexProject(creds)
+	if project == "" {
+		return nil, fmt.Errorf("no Google Cloud project (set GOOGLE_CLOUD_PROJECT)")
+	}
+
+	var body any
+	if publisher == "anthropic" {
+		body = buildPlatformRequest(req, vertexAnthropicVersion, stream)
+	} else {
+		body = v.buildGeminiRequest(req)
+	}
+
+	jsonBody, err := marshalBody(body, req.ExtraBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	region := vertexRegion(req.BaseURL)
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/%s/models/%s:%s",
+		v.baseURL(req.BaseURL, region), url.PathEscape(project), region, publisher, url.PathEscape(req.Model), method)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	setExtraHeaders(httpReq, req.Headers)
+
+	resp, err := v.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, v.handleError(resp)
+	}
+	return resp, nil
+}
+
+// buildGeminiRequest builds a generateContent body. Gemini calls the
+// assistant "model" and takes system turns as the system instruction. A
+// partial response to continue is sent as a trailing model turn, which
+// Gemini extends.
+func (v *vertex) buildGeminiRequest(req Request) geminiRequest {
+	var r geminiRequest
+	var system []geminiPart
+	if req.System != "" {
+		system = append(system, geminiPart{Text: req.System})
+	}
+	for _, m := range conversation(req) {
+		switch m.Role {
+		case "system":
+			system = append(system, geminiPart{Text: m.Content})
+		case "assistant":
+			r.Contents = append(r.Contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: m.Content}}})
+		default:
+			parts := []geminiPart{{Text: m.Content}}
+			for _, img := range m.Images {
+				parts = append(parts, geminiPart{InlineData: &geminiInlineData{MimeType: img.MediaType, Data: img.Data}})
+			}
+			r.Contents = append(r.Contents, geminiContent{Role: "user", Parts: parts})
+		}
+	}
+	if req.Continue != "" {
+		r.Contents = append(r.Contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: req.Continue}}})
+	}
+	if len(system) > 0 {
+		r.SystemInstruction = &geminiContent{Parts: system}
+	}
+	r.GenerationConfig = &geminiGenerationConfig{
+		MaxOutputTokens:  req.MaxTokens,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Seed:             req.Seed,
+	}
+	if f := req.ResponseFormat; f != nil {
+		r.GenerationConfig.ResponseMimeType = "application/json"
+		if f.Type == "json_schema" {
+			r.GenerationConfig.ResponseJSONSchema = f.Schema
+		}
+	}
+	return r
+}
+
+func (v *vertex) baseURL(baseURL, region string) string {
+	if baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	if region == "global" {
+		return "https://aiplatform.googleapis.com"
+	}
+	return "https://" + region + "-aiplatform.googleapis.com"
+}
+
+// vertexRegion returns the Vertex location to call.
+func vertexRegion(baseURL string) string {
+	if baseURL != "" {
+		if u, err := url.Parse(baseURL); err == nil {
+			if region, ok := strings.CutSuffix(u.Hostname(), "-aiplatform.googleapis.com"); ok {
+				return region
+			}
+		}
+	}
+	if region := os.Getenv("GOOGLE_CLOUD_LOCATION"); region != "" {
+		return region
+	}
+	return vertexDefaultRegion
+}
+
+// vertexProject returns the Google Cloud project to bill.
+func vertexProject(creds *googleCredentials) string {
+	if project := os.Getenv("GOOGLE_CLOUD_PROJECT"); project != "" {
+		return project
+	}
+	return creds.project()
+}
+
+func (v *vertex) handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	// Errors come in Google's format, or Anthropic's for Claude
+	msg, code := string(body), ""
+	var gErr googleError
+	var aErr struct {
+		Error *anthropicError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &gErr); err == nil && gErr.Error != nil && gErr.Error.Message != "" {
+		msg, code = gErr.Error.Message, gErr.Error.Status
+	} else if err := json.Unmarshal(body, &aErr); err == nil && aErr.Error != nil {
+		msg, code = aErr.Error.Message, aErr.Error.Type
+	}
+	return classifyStatus(resp, code, msg)
+}
+
+// Ping checks that the credentials can be exchanged for an access token.
+func (v *vertex) Ping(apiKey, baseURL string) error {
+	creds, err := loadGoogleCredentials(apiKey)
+	if err != nil {
+		return err
+	}
+	_, err = creds.accessToken(context.Background(), v.client())
+	return err
+}
+
+// ListModels returns common Gemini and Claude models on Vertex.
+// Vertex has no endpoint listing the publisher models a project can call,
+// so we return a hardcoded list.
+func (v *vertex) ListModels(apiKey, baseURL string) ([]ModelInfo, error) {
+	return []ModelInfo{
+		{ID: "gemini-2.5-pro", Name: "Gemini 2.5 Pro", Description: "Most capable Gemini model"},
+		{ID: "gemini-2.5-flash", Name: "Gemini 2.5 Flash", Description: "Fast, cost-efficient Gemini model"},
+		{ID: "gemini-2.0-flash", Name: "Gemini 2.0 Flash", Description: "Previous generation fast model"},
+		{ID: "claude-sonnet-4@20250514", Name: "Claude Sonnet 4", Description: "Anthropic, via Vertex"},
+		{ID: "claude-opus-4@20250514", Name: "Claude Opus 4", Description: "Anthropic, via Vertex"},
+		{ID: "claude-3-5-haiku@20241022", Name: "Claude 3.5 Haiku", Description: "Anthropic, via Vertex"},
+	}, nil
+}
@@ -0,0 +1,250 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// vertexTestServer serves Google's token endpoint and Vertex model methods.
+// It records the last model request and counts token requests.
+type vertexTestServer struct {
+	*httptest.Server
+	key        *rsa.PrivateKey
+	tokenCalls int
+	path       string
+	auth       string
+	body       map[string]any
+}
+
+func newVertexTestServer(t *testing.T, respond func(w http.ResponseWriter, r *http.Request)) *vertexTestServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &vertexTestServer{key: key}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			s.tokenCalls++
+			if err := verifyJWT(r.FormValue("assertion"), &key.PublicKey); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "invalid_grant", "error_description": "` + err.Error() + `"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token": "ya29.test", "expires_in": 3600}`))
+			return
+		}
+		s.path = r.URL.String()
+		s.auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&s.body)
+		respond(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// credentialsFile writes a service-account key for the test server and
+// returns its path.
+func (s *vertexTestServer) credentialsFile(t *testing.T) string {
+	t.Helper()
+	der, _ := x509.MarshalPKCS8PrivateKey(s.key)
+	creds, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "my-project",
+		"client_email":   "sage@my-project.iam.gserviceaccount.com",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"private_key_id": t.Name(),
+		"token_uri":      s.URL + "/token",
+	})
+	path := filepath.Join(t.TempDir(), "sa.json")
+	if err := os.WriteFile(path, creds, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func verifyJWT(token string, pub *rsa.PublicKey) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed JWT")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], sig)
+}
+
+func TestVertex_CompleteGemini(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	t.Setenv("GOOGLE_CLOUD_LOCATION", "europe-west4")
+
+	server := newVertexTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"candidates": [{"content": {"role": "model", "parts": [{"text": "Hel"}, {"text": "lo"}]}}],
+			"usageMetadata": {"promptTokenCount": 3, "candidatesTokenCount": 2}, "responseId": "resp-1"}`))
+	})
+	creds := server.credentialsFile(t)
+
+	req := Request{Model: "gemini-2.5-flash", System: "Be brief", Prompt: "hi", MaxTokens: 10, Endpoint: Endpoint{APIKey: creds, BaseURL: server.URL}}
+	resp, err := NewVertex().Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if resp.Content != "Hello" || resp.Usage.PromptTokens != 3 || resp.Usage.CompletionTokens != 2 || resp.RequestID != "resp-1" {
+		t.Errorf("response = %+v", resp)
+	}
+	wantPath := "/v1/projects/my-project/locations/europe-west4/publishers/google/models/gemini-2.5-flash:generateContent"
+	if server.path != wantPath {
+		t.Errorf("path = %q, want %q", server.path, wantPath)
+	}
+	if server.auth != "Bearer ya29.test" {
+		t.Errorf("Authorization = %q", server.auth)
+	}
+	if _, ok := server.body["systemInstruction"]; !ok {
+		t.Errorf("body = %v, want systemInstruction", server.body)
+	}
+
+	// The token is cached between requests
+	if _, err := NewVertex().Complete(context.Background(), req); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if server.tokenCalls != 1 {
+		t.Errorf("token requests = %d, want 1", server.tokenCalls)
+	}
+}
+
+func TestVertex_StreamGemini(t *testing.T) {
+	server := newVertexTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`data: {"candidates": [{"content": {"parts": [{"text": "Hel"}]}}]}` + "\n\n"))
+		w.Write([]byte(`data: {"candidates": [{"content": {"parts": [{"text": "lo"}]}, "finishReason": "STOP"}]}` + "\n\n"))
+	})
+
+	ch, err := NewVertex().CompleteStream(context.Background(), Request{Model: "gemini-2.5-flash", Prompt: "hi", Endpoint: Endpoint{APIKey: server.credentialsFile(t), BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("CompleteStream() error = %v", err)
+	}
+
+	var content, finish string
+	var done bool
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		content += chunk.Content
+		done = done || chunk.Done
+		if chunk.Done {
+			finish = chunk.FinishReason
+		}
+	}
+
+	if content != "Hello" || !done || finish != FinishStop {
+		t.Errorf("content = %q, done = %v, finish = %q; want Hello, true, stop", content, done, finish)
+	}
+	if !strings.HasSuffix(server.path, ":streamGenerateContent?alt=sse") {
+		t.Errorf("path = %q", server.path)
+	}
+}
+
+func TestGoogleAccessToken_Context(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token": "ya29.fast", "expires_in": 3600}`))
+	}))
+	defer fast.Close()
+
+	stuck := &googleCredentials{Type: "authorized_user", RefreshToken: t.Name() + "-slow", TokenURI: slow.URL}
+	ok := &googleCredentials{Type: "authorized_user", RefreshToken: t.Name() + "-fast", TokenURI: fast.URL}
+
+	// A stuck fetch gives up with its context...
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := stuck.accessToken(ctx, http.DefaultClient)
+		done <- err
+	}()
+
+	// ...and doesn't hold up tokens for other credentials meanwhile
+	<-started
+	token, err := ok.accessToken(context.Background(), http.DefaultClient)
+	if err != nil || token != "ya29.fast" {
+		t.Errorf("accessToken() = %q, %v; want ya29.fast", token, err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("accessToken() with cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestVertex_CompleteClaude(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "other-project")
+
+	server := newVertexTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content": [{"type": "text", "text": "ok"}], "usage": {"input_tokens": 4, "output_tokens": 1}}`))
+	})
+
+	resp, err := NewVertex().Complete(context.Background(), Request{Model: "claude-sonnet-4@20250514", Prompt: "hi", Endpoint: Endpoint{APIKey: server.credentialsFile(t), BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q, want %q", resp.Content, "ok")
+	}
+	if !strings.Contains(server.path, "/projects/other-project/") || !strings.HasSuffix(server.path, "/publishers/anthropic/models/claude-sonnet-4@20250514:rawPredict") {
+		t.Errorf("path = %q", server.path)
+	}
+	if server.body["anthropic_version"] != vertexAnthropicVersion {
+		t.Errorf("anthropic_version = %v", server.body["anthropic_version"])
+	}
+	if _, ok := server.body["model"]; ok {
+		t.Error("body should not include model")
+	}
+}
+
+func TestVertex_MissingCredentials(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+
+	_, err := NewVertex().Complete(context.Background(), Request{Model: "gemini-2.5-flash", Prompt: "hi"})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestVertexRegion(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_LOCATION", "")
+
+	if got := vertexRegion("https://us-east5-aiplatform.googleapis.com"); got != "us-east5" {
+		t.Errorf("region from URL = %q, want %q", got, "us-east5")
+	}
+	if got := vertexRegion(""); got != vertexDefaultRegion {
+		t.Errorf("default region = %q, want %q", got, vertexDefaultRegion)
+	}
+}
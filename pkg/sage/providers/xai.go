@@ -0,0 +1,45 @@
+package providers
+
+import "strings"
+
+const xaiDefaultURL = "https://api.x.ai"
+
+func init() {
+	Register("xai", NewXAI)
+}
+
+// NewXAI creates an xAI provider for Grok models. xAI serves an
+// OpenAI-compatible API at api.x.ai, including image generation.
+func NewXAI() Provider {
+	return &openai{
+		name:      "xai",
+		chatURL:   xaiChatURL,
+		modelsURL: xaiModelsURL,
+		imageURL:  xaiImageURL,
+		chatModel: xaiChatModel,
+	}
+}
+
+func xaiChatURL(req Request) string {
+	return xaiBaseURL(req.BaseURL) + "/v1/chat/completions"
+}
+
+func xaiModelsURL(baseURL string) string {
+	return xaiBaseURL(baseURL) + "/v1/models"
+}
+
+func xaiImageURL(req ImageRequest) string {
+	return xaiBaseURL(req.BaseURL) + "/v1/images/generations"
+}
+
+func xaiBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return xaiDefaultURL
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// xaiChatModel skips image generation models.
+func xaiChatModel(id string) bool {
+	return strings.HasPrefix(id, "grok") && !strings.Contains(id, "image")
+}
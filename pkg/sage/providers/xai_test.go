@@ -0,0 +1,52 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestXAI_Complete(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	p := NewXAI()
+	if p.Name() != "xai" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "xai")
+	}
+
+	resp, err := p.Complete(context.Background(), Request{Model: "grok-3", Prompt: "hi", Endpoint: Endpoint{APIKey: "xai-key", BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q, want %q", resp.Content, "ok")
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotAuth != "Bearer xai-key" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+}
+
+func TestXAI_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"id": "grok-3"}, {"id": "grok-3-mini"}, {"id": "grok-2-image-1212"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewXAI().ListModels("xai-key", server.URL)
+	if err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+	if len(models) != 2 || models[0].ID != "grok-3" || models[1].ID != "grok-3-mini" {
+		t.Errorf("models = %+v, want grok-3 and grok-3-mini", models)
+	}
+}
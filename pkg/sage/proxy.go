@@ -0,0 +1,98 @@
+package sage
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ProxyDirect is the proxy setting that sends a provider's requests
+// directly, ignoring HTTPS_PROXY and the other proxy environment variables.
+const ProxyDirect = "direct"
+
+// ValidateProxy checks a provider proxy setting: an http, https, socks5 or
+// socks5h URL, or ProxyDirect.
+func ValidateProxy(proxy string) error {
+	_, err := parseProxy(proxy)
+	return err
+}
+
+// parseProxy parses a proxy setting, returning nil for ProxyDirect.
+func parseProxy(proxy string) (*url.URL, error) {
+	if proxy == ProxyDirect {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: want a URL like http://host:port", redactProxy(proxy))
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q: use http, https, socks5 or socks5h", u.Scheme)
+}
+
+// redactProxy hides the password in a proxy URL for display.
+func redactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy
+	}
+	return u.Redacted()
+}
+
+// proxyEnv returns the environment variable that overrides a provider's
+// proxy setting, e.g. SAGE_AZURE_OPENAI_PROXY.
+func proxyEnv(providerName string) string {
+	return "SAGE_" + strings.ToUpper(strings.ReplaceAll(providerName, "-", "_")) + "_PROXY"
+}
+
+// providerHTTPClient returns the HTTP client for requests to a provider:
+// the client's own with the provider's proxy, if it has one, applied to
+// its transport. Nil means http.DefaultClient.
+func (c *Client) providerHTTPClient(providerName string) (*http.Client, error) {
+	proxy := os.Getenv(proxyEnv(providerName))
+	if proxy == "" {
+		proxy = c.config.Providers[providerName].Proxy
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if proxy == "" {
+		return c.httpClient, nil
+	}
+	if hc, ok := c.proxyClients[proxy]; ok {
+		return hc, nil
+	}
+
+	proxyURL, err := parseProxy(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", providerName, err)
+	}
+	base := http.DefaultClient
+	if c.httpClient != nil {
+		base = c.httpClient
+	}
+	rt := base.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("cannot use %s's proxy: the HTTP client's transport is a %T, not an *http.Transport", providerName, rt)
+	}
+	transport = transport.Clone()
+	transport.Proxy = http.ProxyURL(proxyURL) // Nil URL means no proxy
+
+	hc := *base
+	hc.Transport = transport
+	if c.proxyClients == nil {
+		c.proxyClients = make(map[string]*http.Client)
+	}
+	c.proxyClients[proxy] = &hc
+	return &hc, nil
+}
@@ -0,0 +1,223 @@
+package sage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// Defaults for RetryPolicy and retry settings that aren't configured.
+const (
+	defaultMaxRetries     = 2
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+	defaultJitter         = 0.2
+)
+
+// Error classes accepted in RetryConfig.RetryOn.
+const (
+	RetryRateLimit = "rate_limit" // HTTP 429
+	RetryServer    = "server"     // HTTP 5xx
+	RetryNetwork   = "network"    // Connection failures and timeouts
+)
+
+// defaultRetryOn lists the error classes retried when retry_on isn't set.
+var defaultRetryOn = []string{RetryRateLimit, RetryServer, RetryNetwork}
+
+// RetryPolicy controls how failed requests are retried.
+type RetryPolicy struct {
+	MaxRetries     int           // Retries after the first attempt (0 = no retries)
+	InitialBackoff time.Duration // Delay before the first retry (default 1s), doubled each time
+	MaxBackoff     time.Duration // Upper bound on the delay (default 30s)
+	Jitter         float64       // Fraction of each delay randomly taken off, 0-1 (0 = none)
+
+	// Retryable decides whether an error is worth retrying.
+	// If nil, every error is retried.
+	Retryable func(error) bool
+}
+
+// backoff returns the delay before the given retry (1-based).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	if d <= 0 {
+		d = defaultInitialBackoff
+	}
+	max := p.maxBackoff()
+	for i := 1; i < retry && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func (p RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return defaultMaxBackoff
+	}
+	return p.MaxBackoff
+}
+
+// delay returns how long to wait before the given retry (1-based) after
+// err. A delay the provider asked for with Retry-After is honored up to
+// MaxBackoff; otherwise it's the backoff less a random share of Jitter,
+// so clients that failed together don't all retry together.
+func (p RetryPolicy) delay(retry int, err error) time.Duration {
+	if d, ok := providers.RetryAfter(err); ok {
+		if max := p.maxBackoff(); d > max {
+			d = max
+		}
+		return d
+	}
+	d := p.backoff(retry)
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// shouldRetry reports whether err should be retried after attempt attempts.
+func (p RetryPolicy) shouldRetry(err error, attempt int) bool {
+	if attempt > p.MaxRetries {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// do calls fn until it succeeds or the policy gives up, returning the last error.
+func (p RetryPolicy) do(fn func() error) error {
+	return p.doContext(context.Background(), fn)
+}
+
+// doContext is do, giving up early when ctx is done. Errors caused by ctx
+// aren't retried.
+func (p RetryPolicy) doContext(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || ctx.Err() != nil || !p.shouldRetry(err, attempt) {
+			return err
+		}
+		if err := sleepContext(ctx, p.delay(attempt, err)); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext waits for d, or returns ctx's error if it's done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryConfig is the JSON form of a retry policy. It can be set globally
+// in config.json and per profile; unset fields fall back to the global
+// setting, then to the built-in defaults (2 retries, 1s backoff doubling
+// up to 30s with 20% jitter, retrying rate limits, server and network
+// errors).
+type RetryConfig struct {
+	MaxRetries     *int     `json:"max_retries,omitempty"`
+	InitialBackoff string   `json:"initial_backoff,omitempty"` // Duration, e.g. "500ms"
+	MaxBackoff     string   `json:"max_backoff,omitempty"`     // Duration, e.g. "1m"
+	Jitter         *float64 `json:"jitter,omitempty"`          // Fraction of each delay randomized, 0-1
+	RetryOn        []string `json:"retry_on,omitempty"`        // Error classes, e.g. ["rate_limit"]
+}
+
+// RetryPolicy returns the retry policy used by Complete for a profile.
+// If profileName is empty, the default profile is used.
+func (c *Client) RetryPolicy(profileName string) (RetryPolicy, error) {
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return RetryPolicy{}, err
+	}
+	return retryPolicy(c.config.Retry, profile.Retry)
+}
+
+// retryPolicy builds a policy from layered retry settings; later layers
+// override earlier ones field by field. Nil layers are skipped.
+func retryPolicy(layers ...*RetryConfig) (RetryPolicy, error) {
+	maxRetries := defaultMaxRetries
+	initial, max := "", ""
+	jitter := defaultJitter
+	retryOn := defaultRetryOn
+	for _, rc := range layers {
+		if rc == nil {
+			continue
+		}
+		if rc.MaxRetries != nil {
+			maxRetries = *rc.MaxRetries
+		}
+		if rc.InitialBackoff != "" {
+			initial = rc.InitialBackoff
+		}
+		if rc.MaxBackoff != "" {
+			max = rc.MaxBackoff
+		}
+		if rc.Jitter != nil {
+			jitter = *rc.Jitter
+		}
+		if rc.RetryOn != nil {
+			retryOn = rc.RetryOn
+		}
+	}
+
+	policy := RetryPolicy{MaxRetries: maxRetries, Jitter: jitter}
+	var err error
+	if policy.InitialBackoff, err = parseBackoff("initial_backoff", initial); err != nil {
+		return RetryPolicy{}, err
+	}
+	if policy.MaxBackoff, err = parseBackoff("max_backoff", max); err != nil {
+		return RetryPolicy{}, err
+	}
+	if jitter < 0 || jitter > 1 {
+		return RetryPolicy{}, fmt.Errorf("invalid retry jitter: %v (must be between 0 and 1)", jitter)
+	}
+	for _, class := range retryOn {
+		if class != RetryRateLimit && class != RetryServer && class != RetryNetwork {
+			return RetryPolicy{}, fmt.Errorf("unknown retry_on class: %s", class)
+		}
+	}
+	policy.Retryable = func(err error) bool {
+		return containsString(retryOn, errorClass(err))
+	}
+
+	return policy, nil
+}
+
+// parseBackoff parses a backoff duration setting; empty means the default.
+func parseBackoff(name, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid retry %s: %q", name, s)
+	}
+	return d, nil
+}
+
+// errorClass returns the retry_on class of a provider error, or "" if it
+// doesn't belong to one (e.g. an invalid API key).
+func errorClass(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, providers.ErrRateLimited):
+		return RetryRateLimit
+	case errors.Is(err, providers.ErrServerError):
+		return RetryServer
+	case errors.As(err, &netErr):
+		return RetryNetwork
+	}
+	return ""
+}
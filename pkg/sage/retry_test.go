@@ -0,0 +1,221 @@
+package sage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+func TestRetryPolicy_Layers(t *testing.T) {
+	client := setupTestClient(t)
+
+	global, override := 5, 0
+	client.config.Retry = &RetryConfig{MaxRetries: &global, InitialBackoff: "250ms"}
+	client.config.Profiles["batch"] = Profile{Provider: "openai", Retry: &RetryConfig{MaxBackoff: "2m"}}
+	client.config.Profiles["chat"] = Profile{Provider: "openai", Retry: &RetryConfig{MaxRetries: &override, RetryOn: []string{RetryRateLimit}}}
+
+	p, err := client.RetryPolicy("batch")
+	if err != nil {
+		t.Fatalf("RetryPolicy() error = %v", err)
+	}
+	if p.MaxRetries != 5 || p.InitialBackoff != 250*time.Millisecond || p.MaxBackoff != 2*time.Minute {
+		t.Errorf("batch policy = %+v, want 5 retries, 250ms, 2m", p)
+	}
+	if !p.Retryable(providers.ErrServerError) {
+		t.Error("batch policy should retry server errors by default")
+	}
+
+	p, _ = client.RetryPolicy("chat")
+	if p.MaxRetries != 0 {
+		t.Errorf("chat MaxRetries = %d, want 0", p.MaxRetries)
+	}
+	if p.Retryable(providers.ErrServerError) || !p.Retryable(providers.ErrRateLimited) {
+		t.Error("chat policy should only retry rate limits")
+	}
+}
+
+func TestRetryPolicy_Defaults(t *testing.T) {
+	p, err := retryPolicy()
+	if err != nil {
+		t.Fatalf("retryPolicy() error = %v", err)
+	}
+	if p.MaxRetries != defaultMaxRetries {
+		t.Errorf("MaxRetries = %d, want %d", p.MaxRetries, defaultMaxRetries)
+	}
+	if p.Retryable(errors.New("invalid API key: bad")) {
+		t.Error("auth errors should not be retried")
+	}
+}
+
+func TestRetryPolicy_Invalid(t *testing.T) {
+	jitter := 1.5
+	tests := []RetryConfig{
+		{InitialBackoff: "soon"},
+		{MaxBackoff: "-1s"},
+		{Jitter: &jitter},
+		{RetryOn: []string{"timeout"}},
+	}
+	for _, rc := range tests {
+		rc := rc
+		if _, err := retryPolicy(&rc); err == nil {
+			t.Errorf("retryPolicy(%+v) should error", rc)
+		}
+	}
+
+	client := setupTestClient(t)
+	err := client.AddProfile("bad", Profile{Provider: "openai", Retry: &RetryConfig{RetryOn: []string{"timeout"}}})
+	if err == nil {
+		t.Error("AddProfile() with invalid retry settings should error")
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: slow down", providers.ErrRateLimited), RetryRateLimit},
+		{fmt.Errorf("%w (503): overloaded", providers.ErrServerError), RetryServer},
+		{fmt.Errorf("request failed: %w", &url.Error{Op: "Post", URL: "http://x", Err: errors.New("connection refused")}), RetryNetwork},
+		{errors.New("API error (400): bad request"), ""},
+	}
+	for _, tt := range tests {
+		if got := errorClass(tt.err); got != tt.want {
+			t.Errorf("errorClass(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClient_Complete_RetriesServerErrors(t *testing.T) {
+	client := setupTestClient(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.Header.Get("Authorization") {
+		case "Bearer sk-bad":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "bad key"}}`))
+		default:
+			if calls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-1")
+	client.AddProviderAccount("openai", "bad", "sk-bad")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	fast := &RetryConfig{InitialBackoff: "1ms"}
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o", Retry: fast})
+	client.AddProfile("bad", Profile{Provider: "openai", Account: "bad", Model: "gpt-4o", Retry: fast})
+
+	resp, err := client.Complete(context.Background(), "test", Request{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if resp.Content != "ok" || calls != 2 {
+		t.Errorf("Content = %q after %d calls, want %q after 2", resp.Content, calls, "ok")
+	}
+
+	// Errors outside retry_on fail immediately
+	calls = 0
+	if _, err := client.Complete(context.Background(), "bad", Request{Prompt: "hi"}); err == nil {
+		t.Error("Complete() with bad key should error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 for a non-retryable error", calls)
+	}
+}
+
+func TestClient_Complete_CancelDuringBackoff(t *testing.T) {
+	client := setupTestClient(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-1")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o", Retry: &RetryConfig{InitialBackoff: "1m"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Complete(ctx, "test", Request{Prompt: "hi"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Complete() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Complete() took %v, want it to stop waiting when ctx expired", elapsed)
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 20; i++ {
+		if d := p.delay(2, providers.ErrServerError); d < 100*time.Millisecond || d > 200*time.Millisecond {
+			t.Fatalf("delay(2) = %v, want between 100ms and 200ms", d)
+		}
+	}
+
+	// Retry-After wins over the backoff, up to MaxBackoff
+	err := &providers.RetryAfterError{Err: providers.ErrRateLimited, Delay: 700 * time.Millisecond}
+	if d := p.delay(1, err); d != 700*time.Millisecond {
+		t.Errorf("delay() with Retry-After = %v, want 700ms", d)
+	}
+	err.Delay = time.Hour
+	if d := p.delay(1, err); d != time.Second {
+		t.Errorf("delay() with long Retry-After = %v, want MaxBackoff", d)
+	}
+}
+
+func TestClient_Complete_HonorsRetryAfter(t *testing.T) {
+	client := setupTestClient(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After-Ms", "50")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-1")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o", Retry: &RetryConfig{InitialBackoff: "1m"}})
+
+	start := time.Now()
+	if _, err := client.Complete(context.Background(), "test", Request{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("Complete() took %v, want the 50ms Retry-After rather than the 1m backoff", elapsed)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
@@ -8,13 +8,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 const (
 	keySize   = 32 // AES-256
 	nonceSize = 12 // GCM standard nonce size
+
+	backupSuffix = ".bak" // Previous master.key and secrets.enc during a rotation
 )
 
 // MasterKeyPath returns the path to master.key.
@@ -62,6 +67,9 @@ func InitSecrets() error {
 	return nil
 }
 
+// errNoMasterKey is returned by loadMasterKey before 'sage init' has run.
+var errNoMasterKey = errors.New("master key not found: run 'sage init' first")
+
 // loadMasterKey reads and validates the master key.
 func loadMasterKey() ([]byte, error) {
 	keyPath, err := MasterKeyPath()
@@ -72,16 +80,17 @@ func loadMasterKey() ([]byte, error) {
 	// Check key exists
 	info, err := os.Stat(keyPath)
 	if os.IsNotExist(err) {
-		return nil, errors.New("master key not found: run 'sage init' first")
+		return nil, errNoMasterKey
 	}
 	if err != nil {
 		return nil, fmt.Errorf("cannot stat master key: %w", err)
 	}
 
-	// Check permissions (should be 0600)
-	mode := info.Mode().Perm()
-	if mode&0077 != 0 {
-		return nil, fmt.Errorf("master key has insecure permissions %o (should be 600)", mode)
+	// Check the key isn't readable by other users
+	if !skipKeyPermissionCheck() {
+		if err := checkKeyPermissions(keyPath, info); err != nil {
+			return nil, fmt.Errorf("%w (set SAGE_SKIP_KEY_PERMISSION_CHECK=1 on filesystems without permissions)", err)
+		}
 	}
 
 	// Read key
@@ -97,6 +106,13 @@ func loadMasterKey() ([]byte, error) {
 	return key, nil
 }
 
+// skipKeyPermissionCheck reports whether SAGE_SKIP_KEY_PERMISSION_CHECK is set
+// to a true value, for mounts that can't represent file permissions.
+func skipKeyPermissionCheck() bool {
+	v, _ := strconv.ParseBool(os.Getenv("SAGE_SKIP_KEY_PERMISSION_CHECK"))
+	return v
+}
+
 // encrypt encrypts plaintext using AES-256-GCM.
 // Returns: nonce (12 bytes) || ciphertext
 func encrypt(key, plaintext []byte) ([]byte, error) {
@@ -150,29 +166,44 @@ func decrypt(key, data []byte) ([]byte, error) {
 }
 
 // LoadSecrets decrypts and returns the secrets map.
-// Returns empty map if secrets file doesn't exist.
+// Returns empty map if secrets file doesn't exist. Before 'sage init', when
+// neither it nor the master key exists, that lets clients run on API keys from
+// environment variables alone.
 func LoadSecrets() (map[string]string, error) {
-	key, err := loadMasterKey()
-	if err != nil {
-		return nil, err
-	}
-
 	secretsPath, err := SecretsPath()
 	if err != nil {
 		return nil, err
 	}
 
 	data, err := os.ReadFile(secretsPath)
-	if os.IsNotExist(err) {
-		return make(map[string]string), nil
-	}
-	if err != nil {
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("cannot read secrets file: %w", err)
 	}
 
-	plaintext, err := decrypt(key, data)
-	if err != nil {
-		return nil, fmt.Errorf("cannot decrypt secrets: %w", err)
+	var plaintext []byte
+	if exists && isAgeEncrypted(data) {
+		cfg, err := LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		if plaintext, err = ageDecrypt(cfg.Age, data); err != nil {
+			return nil, fmt.Errorf("cannot decrypt secrets: %w", err)
+		}
+	} else {
+		key, err := loadMasterKey()
+		if errors.Is(err, errNoMasterKey) && !exists {
+			return make(map[string]string), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return make(map[string]string), nil
+		}
+		if plaintext, err = decrypt(key, data); err != nil {
+			return nil, fmt.Errorf("cannot decrypt secrets: %w", err)
+		}
 	}
 
 	var secrets map[string]string
@@ -183,9 +214,78 @@ func LoadSecrets() (map[string]string, error) {
 	return secrets, nil
 }
 
-// SaveSecrets encrypts and saves the secrets map.
+// SaveSecrets encrypts and saves the secrets map, replacing the file's
+// contents. Fails with ErrReadOnly if SAGE_READ_ONLY is set.
 func SaveSecrets(secrets map[string]string) error {
-	key, err := loadMasterKey()
+	if readOnlyEnv() {
+		return fmt.Errorf("%w: cannot save secrets", ErrReadOnly)
+	}
+
+	unlock, err := lockConfigDir()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeSecrets(secrets)
+}
+
+// updateSecrets loads the secrets, lets update change them, and saves the
+// result, holding the config directory lock throughout so concurrent
+// processes can't lose each other's changes. It returns the saved secrets.
+func updateSecrets(update func(map[string]string)) (map[string]string, error) {
+	if readOnlyEnv() {
+		return nil, fmt.Errorf("%w: cannot save secrets", ErrReadOnly)
+	}
+
+	unlock, err := lockConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	secrets, err := LoadSecrets()
+	if err != nil {
+		return nil, err
+	}
+	before := maps.Clone(secrets)
+	update(secrets)
+	if err := writeSecrets(secrets); err != nil {
+		return nil, err
+	}
+	recordKeyChanges(before, secrets)
+	return secrets, nil
+}
+
+// lockConfigDir takes an exclusive lock on sage.lock in the config
+// directory, blocking until other sage processes release it, and returns a
+// function that releases it. It guards load-modify-save of config.json and
+// secrets.enc.
+func lockConfigDir() (func(), error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "sage.lock")
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return nil, fmt.Errorf("%w: cannot write %s", ErrReadOnly, path)
+		}
+		return nil, fmt.Errorf("cannot open lock file: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot lock config directory: %w", err)
+	}
+	return func() { f.Close() }, nil
+}
+
+// writeSecrets encrypts secrets, for the config's age recipients if it has
+// any and otherwise with the master key, and atomically replaces secrets.enc
+// with them. The caller holds the config directory lock.
+func writeSecrets(secrets map[string]string) error {
+	cfg, err := LoadConfig()
 	if err != nil {
 		return err
 	}
@@ -195,7 +295,16 @@ func SaveSecrets(secrets map[string]string) error {
 		return fmt.Errorf("cannot marshal secrets: %w", err)
 	}
 
-	ciphertext, err := encrypt(key, plaintext)
+	var ciphertext []byte
+	if cfg.Age != nil {
+		ciphertext, err = ageEncrypt(cfg.Age.Recipients, plaintext)
+	} else {
+		var key []byte
+		if key, err = loadMasterKey(); err != nil {
+			return err
+		}
+		ciphertext, err = encrypt(key, plaintext)
+	}
 	if err != nil {
 		return fmt.Errorf("cannot encrypt secrets: %w", err)
 	}
@@ -205,7 +314,10 @@ func SaveSecrets(secrets map[string]string) error {
 		return err
 	}
 
-	if err := os.WriteFile(secretsPath, ciphertext, 0600); err != nil {
+	if err := writeFileAtomic(secretsPath, ciphertext, 0600); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("%w: cannot write %s", ErrReadOnly, secretsPath)
+		}
 		return fmt.Errorf("cannot write secrets file: %w", err)
 	}
 
@@ -217,6 +329,12 @@ func secretKey(provider, account string) string {
 	return provider + ":" + account
 }
 
+// extraSecretKey returns the map key for an account's nth API key (n >= 2).
+// The first key is stored under secretKey.
+func extraSecretKey(provider, account string, n int) string {
+	return fmt.Sprintf("%s#%d", secretKey(provider, account), n)
+}
+
 // GetSecret returns a decrypted API key for the given provider and account.
 func GetSecret(provider, account string) (string, error) {
 	secrets, err := LoadSecrets()
@@ -235,29 +353,27 @@ func GetSecret(provider, account string) (string, error) {
 
 // SetSecret encrypts and stores an API key.
 func SetSecret(provider, account, apiKey string) error {
-	secrets, err := LoadSecrets()
-	if err != nil {
-		return err
-	}
-
-	secrets[secretKey(provider, account)] = apiKey
-	return SaveSecrets(secrets)
+	_, err := updateSecrets(func(secrets map[string]string) {
+		secrets[secretKey(provider, account)] = apiKey
+	})
+	return err
 }
 
 // DeleteSecret removes an API key.
 func DeleteSecret(provider, account string) error {
-	secrets, err := LoadSecrets()
+	key := secretKey(provider, account)
+	found := false
+	_, err := updateSecrets(func(secrets map[string]string) {
+		_, found = secrets[key]
+		delete(secrets, key)
+	})
 	if err != nil {
 		return err
 	}
-
-	key := secretKey(provider, account)
-	if _, ok := secrets[key]; !ok {
+	if !found {
 		return fmt.Errorf("no secret found for %s", key)
 	}
-
-	delete(secrets, key)
-	return SaveSecrets(secrets)
+	return nil
 }
 
 // HasSecret checks if a secret exists for the given provider and account.
@@ -270,3 +386,169 @@ func HasSecret(provider, account string) (bool, error) {
 	_, ok := secrets[secretKey(provider, account)]
 	return ok, nil
 }
+
+// RotateMasterKey replaces master.key with a new random key and re-encrypts
+// secrets.enc with it. Each new file is written beside the old one and
+// renamed over it, and the result is checked by decrypting it again; if that
+// fails, the previous files are put back.
+//
+// The previous key and secrets are kept as master.key.bak and
+// secrets.enc.bak until ConfirmKeyRotation removes them, so restoring both
+// undoes the rotation. Rotating again before confirming is refused.
+func RotateMasterKey() error {
+	if readOnlyEnv() {
+		return fmt.Errorf("%w: cannot rotate master key", ErrReadOnly)
+	}
+
+	keyPath, err := MasterKeyPath()
+	if err != nil {
+		return err
+	}
+	secretsPath, err := SecretsPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(keyPath + backupSuffix); err == nil {
+		return fmt.Errorf("a previous rotation is unconfirmed (%s exists): run 'sage secrets rotate --confirm' first", keyPath+backupSuffix)
+	}
+
+	unlock, err := lockConfigDir()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.IsReadOnly() {
+		return fmt.Errorf("%w: cannot rotate master key", ErrReadOnly)
+	}
+	if cfg.Age != nil {
+		return errors.New("secrets are encrypted for age recipients, not with master.key: change the recipients with 'sage secrets age' instead")
+	}
+
+	secrets, err := LoadSecrets()
+	if err != nil {
+		return err
+	}
+	oldKey, err := loadMasterKey()
+	if err != nil {
+		return err
+	}
+	oldSecrets, err := os.ReadFile(secretsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read secrets file: %w", err)
+	}
+
+	newKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, newKey); err != nil {
+		return fmt.Errorf("cannot generate random key: %w", err)
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("cannot marshal secrets: %w", err)
+	}
+	ciphertext, err := encrypt(newKey, plaintext)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt secrets: %w", err)
+	}
+
+	// Back up the current pair before replacing either file
+	if oldSecrets != nil {
+		if err := writeFileAtomic(secretsPath+backupSuffix, oldSecrets, 0600); err != nil {
+			return fmt.Errorf("cannot back up secrets file: %w", err)
+		}
+	}
+	if err := writeFileAtomic(keyPath+backupSuffix, oldKey, 0600); err != nil {
+		return fmt.Errorf("cannot back up master key: %w", err)
+	}
+
+	restore := func(cause error) error {
+		if err := writeFileAtomic(keyPath, oldKey, 0600); err != nil {
+			return fmt.Errorf("%w; restoring the previous key also failed (%v): restore %s and %s by hand",
+				cause, err, keyPath+backupSuffix, secretsPath+backupSuffix)
+		}
+		if oldSecrets != nil {
+			writeFileAtomic(secretsPath, oldSecrets, 0600)
+		}
+		os.Remove(keyPath + backupSuffix)
+		os.Remove(secretsPath + backupSuffix)
+		return cause
+	}
+
+	if err := writeFileAtomic(secretsPath, ciphertext, 0600); err != nil {
+		return restore(fmt.Errorf("cannot write secrets file: %w", err))
+	}
+	if err := writeFileAtomic(keyPath, newKey, 0600); err != nil {
+		return restore(fmt.Errorf("cannot write master key: %w", err))
+	}
+
+	got, err := LoadSecrets()
+	if err != nil {
+		return restore(fmt.Errorf("rotated secrets don't decrypt: %w", err))
+	}
+	if !maps.Equal(got, secrets) {
+		return restore(errors.New("rotated secrets don't match the originals"))
+	}
+	return nil
+}
+
+// ConfirmKeyRotation deletes the backups RotateMasterKey keeps, after
+// checking that the secrets decrypt with the current key.
+func ConfirmKeyRotation() error {
+	keyPath, err := MasterKeyPath()
+	if err != nil {
+		return err
+	}
+	secretsPath, err := SecretsPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(keyPath + backupSuffix); os.IsNotExist(err) {
+		return errors.New("no key rotation to confirm")
+	}
+
+	if _, err := LoadSecrets(); err != nil {
+		return fmt.Errorf("current key can't decrypt secrets, keeping backups: %w", err)
+	}
+	if err := os.Remove(secretsPath + backupSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove secrets backup: %w", err)
+	}
+	if err := os.Remove(keyPath + backupSuffix); err != nil {
+		return fmt.Errorf("cannot remove master key backup: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file in the
+// same directory and renaming it over path, so readers see either the old
+// content or the new, never a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // No-op once renamed
+
+	// os.CreateTemp creates the file with mode 0600
+	if perm != 0600 {
+		if err := f.Chmod(perm); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
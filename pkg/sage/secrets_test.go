@@ -2,8 +2,11 @@ package sage
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -204,14 +207,84 @@ func TestSetGetDeleteSecret(t *testing.T) {
 	}
 }
 
+func TestSetSecret_Concurrent(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	InitSecrets()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := SetSecret("openai", fmt.Sprintf("acct%d", i), "sk"); err != nil {
+				t.Errorf("SetSecret() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	secrets, err := LoadSecrets()
+	if err != nil {
+		t.Fatalf("LoadSecrets() error = %v", err)
+	}
+	if len(secrets) != 20 {
+		t.Errorf("got %d secrets, want 20: updates were lost", len(secrets))
+	}
+
+	// Writes go through a temporary file renamed into place
+	dir, _ := ConfigDir()
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "secrets.enc.tmp*")); len(tmps) != 0 {
+		t.Errorf("temporary files left behind: %v", tmps)
+	}
+}
+
+func TestClient_SaveSecrets_KeepsOtherClientsChanges(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	InitSecrets()
+	setup, _ := NewClient()
+	setup.AddProviderAccount("openai", "old", "sk-old")
+
+	// Two processes load the same secrets, then each changes them
+	a, _ := NewClient()
+	b, _ := NewClient()
+	a.AddProviderAccount("openai", "a", "sk-a")
+	b.AddProviderAccount("anthropic", "b", "sk-b")
+	b.RemoveProviderAccount("openai", "old")
+
+	secrets, _ := LoadSecrets()
+	if secrets["openai:a"] != "sk-a" || secrets["anthropic:b"] != "sk-b" {
+		t.Errorf("secrets = %v, want both clients' keys", secrets)
+	}
+	if _, ok := secrets["openai:old"]; ok {
+		t.Error("removed key was saved again")
+	}
+
+	cfg, _ := LoadConfig()
+	if accounts := cfg.Providers["openai"].Accounts; len(accounts) != 1 || accounts[0] != "a" {
+		t.Errorf("openai accounts = %v, want [a]", accounts)
+	}
+	if !containsString(cfg.Providers["anthropic"].Accounts, "b") {
+		t.Errorf("anthropic accounts = %v, want b kept", cfg.Providers["anthropic"].Accounts)
+	}
+}
+
 func TestLoadSecrets_NoMasterKey(t *testing.T) {
 	tmp := t.TempDir()
 	t.Setenv("HOME", tmp)
 
 	// Don't call InitSecrets - no master key
 
-	_, err := LoadSecrets()
-	if err == nil {
+	// With no secrets file either, there is nothing to decrypt
+	secrets, err := LoadSecrets()
+	if err != nil || len(secrets) != 0 {
+		t.Errorf("LoadSecrets() = %v, %v; want empty secrets before init", secrets, err)
+	}
+
+	path, _ := SecretsPath()
+	os.WriteFile(path, []byte("encrypted"), 0600)
+	if _, err := LoadSecrets(); err == nil {
 		t.Error("LoadSecrets() should error when master key doesn't exist")
 	}
 }
@@ -233,3 +306,87 @@ func TestLoadSecrets_InsecurePermissions(t *testing.T) {
 		t.Error("LoadSecrets() should error with insecure permissions")
 	}
 }
+
+func TestLoadSecrets_SkipPermissionCheck(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv("SAGE_SKIP_KEY_PERMISSION_CHECK", "1")
+
+	dir, _ := ConfigDir()
+	key := make([]byte, keySize)
+	os.WriteFile(filepath.Join(dir, "master.key"), key, 0644)
+
+	if _, err := LoadSecrets(); err != nil {
+		t.Errorf("LoadSecrets() error = %v, want permission check skipped", err)
+	}
+}
+
+func TestRotateMasterKey(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	InitSecrets()
+	SetSecret("openai", "default", "sk-test")
+	keyPath, _ := MasterKeyPath()
+	secretsPath, _ := SecretsPath()
+	oldKey, _ := os.ReadFile(keyPath)
+
+	if err := RotateMasterKey(); err != nil {
+		t.Fatalf("RotateMasterKey() error = %v", err)
+	}
+
+	newKey, _ := os.ReadFile(keyPath)
+	if bytes.Equal(oldKey, newKey) {
+		t.Error("master key unchanged after rotation")
+	}
+	if secret, err := GetSecret("openai", "default"); err != nil || secret != "sk-test" {
+		t.Errorf("GetSecret() = %q, %v; want sk-test with the new key", secret, err)
+	}
+	if backup, _ := os.ReadFile(keyPath + ".bak"); !bytes.Equal(backup, oldKey) {
+		t.Error("previous key not kept as master.key.bak")
+	}
+	if _, err := os.Stat(secretsPath + ".bak"); err != nil {
+		t.Errorf("previous secrets not kept: %v", err)
+	}
+
+	// Another rotation waits for the first to be confirmed
+	if err := RotateMasterKey(); err == nil {
+		t.Error("RotateMasterKey() before confirming should error")
+	}
+
+	if err := ConfirmKeyRotation(); err != nil {
+		t.Fatalf("ConfirmKeyRotation() error = %v", err)
+	}
+	for _, path := range []string{keyPath + ".bak", secretsPath + ".bak"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after confirming", path)
+		}
+	}
+	if err := ConfirmKeyRotation(); err == nil {
+		t.Error("ConfirmKeyRotation() with nothing to confirm should error")
+	}
+	if info, _ := os.Stat(keyPath); info.Mode().Perm() != 0600 {
+		t.Errorf("key permissions = %o, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestRotateMasterKey_ReadOnlyConfig(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+
+	InitSecrets()
+	keyPath, _ := MasterKeyPath()
+	oldKey, _ := os.ReadFile(keyPath)
+	configPath, _ := ConfigPath()
+	os.WriteFile(configPath, []byte(`{"read_only": true}`), 0644)
+
+	if err := RotateMasterKey(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("RotateMasterKey() with read_only config error = %v, want ErrReadOnly", err)
+	}
+	if key, _ := os.ReadFile(keyPath); !bytes.Equal(key, oldKey) {
+		t.Error("master key changed in a read-only config")
+	}
+	if _, err := os.Stat(keyPath + ".bak"); !os.IsNotExist(err) {
+		t.Error("backup written in a read-only config")
+	}
+}
@@ -0,0 +1,267 @@
+package sage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DataDir returns the sage data directory path, creating it if needed.
+// SAGE_DATA_DIR overrides it. Default: $XDG_DATA_HOME/sage/ or
+// ~/.local/share/sage/ (%LocalAppData%\sage\ on Windows)
+func DataDir() (string, error) {
+	dir := os.Getenv("SAGE_DATA_DIR")
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", fmt.Errorf("invalid SAGE_DATA_DIR: %w", err)
+		}
+		dir = abs
+	} else {
+		base, err := userDataBase()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "sage")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("cannot create data directory: %w", err)
+	}
+	return dir, nil
+}
+
+// Session is a saved chat conversation, kept as sessions/<id>.json in the
+// data directory so it can be resumed.
+type Session struct {
+	ID      string    `json:"id"`
+	Title   string    `json:"title,omitempty"` // Default: the start of the first message
+	Profile string    `json:"profile"`
+	Model   string    `json:"model,omitempty"`  // Overrides the profile's
+	System  string    `json:"system,omitempty"` // System prompt, as it was when the session began
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+
+	// Tokens used by the session's requests, as reported by the provider.
+	// Each request's prompt includes the conversation before it.
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+
+	Messages []Message `json:"messages"`
+}
+
+// NewSession starts a session with a new ID, which sorts by creation time.
+func NewSession(profile, model, system string) *Session {
+	b := make([]byte, 3)
+	rand.Read(b)
+	now := time.Now()
+	return &Session{
+		ID:      now.Format("20060102-150405-") + hex.EncodeToString(b),
+		Profile: profile,
+		Model:   model,
+		System:  system,
+		Created: now,
+		Updated: now,
+	}
+}
+
+// AddUsage adds a request's token counts to the session's totals. u may be
+// nil, for providers that don't report them.
+func (s *Session) AddUsage(u *Usage) {
+	if u != nil {
+		s.PromptTokens += u.PromptTokens
+		s.CompletionTokens += u.CompletionTokens
+	}
+}
+
+// setDefaultTitle titles an untitled session with the first line of its
+// first message, cut to a length that fits a listing.
+func (s *Session) setDefaultTitle() {
+	const maxLen = 60
+	if s.Title != "" {
+		return
+	}
+	for _, m := range s.Messages {
+		if m.Role != "user" {
+			continue
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(m.Content), "\n")
+		title := []rune(strings.Join(strings.Fields(line), " "))
+		if len(title) > maxLen {
+			title = append(title[:maxLen-3], []rune("...")...)
+		}
+		s.Title = string(title)
+		return
+	}
+}
+
+// sessionsDir returns the directory sessions are saved in.
+func sessionsDir() (string, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "sessions")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("cannot create sessions directory: %w", err)
+	}
+	return dir, nil
+}
+
+// sessionPath returns the path of the session with the given ID.
+func sessionPath(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+// Save writes the session, readable only by the user since conversations
+// may hold anything.
+func (s *Session) Save() error {
+	path, err := sessionPath(s.ID)
+	if err != nil {
+		return err
+	}
+	s.Updated = time.Now()
+	s.setDefaultTitle()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("cannot save session: %w", err)
+	}
+	return nil
+}
+
+// LoadSession reads the session with the given ID, or the most recently
+// updated session for "last".
+func LoadSession(id string) (*Session, error) {
+	if id == "last" {
+		sessions, err := ListSessions()
+		if err != nil {
+			return nil, err
+		}
+		if len(sessions) == 0 {
+			return nil, errors.New("no saved sessions")
+		}
+		return sessions[0], nil
+	}
+
+	path, err := sessionPath(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read session: %w", err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid session %s: %w", id, err)
+	}
+	s.setDefaultTitle()
+	return &s, nil
+}
+
+// ListSessions returns the saved sessions, most recently updated first.
+// Files that can't be read as sessions are skipped.
+func ListSessions() ([]*Session, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read sessions: %w", err)
+	}
+
+	var sessions []*Session
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if s, err := LoadSession(id); err == nil {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Updated.After(sessions[j].Updated)
+	})
+	return sessions, nil
+}
+
+// DeleteSession deletes the saved session with the given ID.
+func DeleteSession(id string) error {
+	path, err := sessionPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("session not found: %s", id)
+		}
+		return fmt.Errorf("cannot delete session: %w", err)
+	}
+	return nil
+}
+
+// Export formats the session as "json", as it's saved, or as "markdown": a
+// transcript with the title as a heading and a section for each message.
+func (s *Session) Export(format string) ([]byte, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case "markdown", "md":
+	default:
+		return nil, fmt.Errorf("unknown session format %q (want json or markdown)", format)
+	}
+
+	var b strings.Builder
+	title := s.Title
+	if title == "" {
+		title = "Session " + s.ID
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	model := ""
+	if s.Model != "" {
+		model = " (" + s.Model + ")"
+	}
+	fmt.Fprintf(&b, "- Profile: %s%s\n", s.Profile, model)
+	fmt.Fprintf(&b, "- Started: %s\n", s.Created.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "- Updated: %s\n", s.Updated.Local().Format("2006-01-02 15:04"))
+	if s.PromptTokens+s.CompletionTokens > 0 {
+		fmt.Fprintf(&b, "- Tokens: %d prompt, %d completion\n", s.PromptTokens, s.CompletionTokens)
+	}
+	if s.System != "" {
+		fmt.Fprintf(&b, "\n## System\n\n%s\n", strings.TrimSpace(s.System))
+	}
+	for _, m := range s.Messages {
+		role := m.Role
+		if role != "" {
+			role = strings.ToUpper(role[:1]) + role[1:]
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", role, strings.TrimSpace(m.Content))
+	}
+	return []byte(b.String()), nil
+}
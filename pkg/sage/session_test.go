@@ -0,0 +1,125 @@
+package sage
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSession_SaveLoad(t *testing.T) {
+	t.Setenv("SAGE_DATA_DIR", t.TempDir())
+
+	if _, err := LoadSession("last"); err == nil {
+		t.Error("LoadSession(last) with no sessions should error")
+	}
+
+	first := NewSession("claude", "", "Be brief.")
+	first.Messages = []Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "Hello."}}
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	second := NewSession("gpt", "gpt-4o-mini", "")
+	if err := second.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	got, err := LoadSession(first.ID)
+	if err != nil {
+		t.Fatalf("LoadSession() error = %v", err)
+	}
+	if got.Profile != "claude" || got.System != "Be brief." || len(got.Messages) != 2 || got.Messages[1].Content != "Hello." {
+		t.Errorf("LoadSession() = %+v, want the saved session", got)
+	}
+
+	if last, err := LoadSession("last"); err != nil || last.ID != second.ID {
+		t.Errorf("LoadSession(last) = %v, %v, want %s", last, err, second.ID)
+	}
+	sessions, err := ListSessions()
+	if err != nil || len(sessions) != 2 || sessions[0].ID != second.ID || sessions[1].ID != first.ID {
+		t.Errorf("ListSessions() = %v, %v, want newest first", sessions, err)
+	}
+
+	for _, id := range []string{"", "../config", "a/b", ".hidden", "missing"} {
+		if _, err := LoadSession(id); err == nil {
+			t.Errorf("LoadSession(%q) should error", id)
+		}
+	}
+}
+
+func TestDataDir(t *testing.T) {
+	dir := t.TempDir()
+	if runtime.GOOS != "windows" {
+		t.Setenv("XDG_DATA_HOME", dir)
+		if got, err := DataDir(); err != nil || got != filepath.Join(dir, "sage") {
+			t.Errorf("DataDir() = %q, %v, want under XDG_DATA_HOME", got, err)
+		}
+	}
+
+	override := filepath.Join(dir, "data")
+	t.Setenv("SAGE_DATA_DIR", override)
+	if got, err := DataDir(); err != nil || got != override {
+		t.Errorf("DataDir() = %q, %v, want SAGE_DATA_DIR", got, err)
+	}
+}
+
+func TestSession_TitleUsageExport(t *testing.T) {
+	t.Setenv("SAGE_DATA_DIR", t.TempDir())
+
+	s := NewSession("claude", "claude-haiku", "Be brief.")
+	s.Messages = []Message{
+		{Role: "user", Content: "  Why is the   sky blue?\nAsking for a friend."},
+		{Role: "assistant", Content: "Rayleigh scattering."},
+	}
+	s.AddUsage(&Usage{PromptTokens: 12, CompletionTokens: 4})
+	s.AddUsage(nil)
+	s.AddUsage(&Usage{PromptTokens: 30, CompletionTokens: 6})
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if s.Title != "Why is the sky blue?" {
+		t.Errorf("Title = %q, want the first line of the first message", s.Title)
+	}
+	if s.PromptTokens != 42 || s.CompletionTokens != 10 {
+		t.Errorf("tokens = %d, %d, want 42, 10", s.PromptTokens, s.CompletionTokens)
+	}
+
+	long := NewSession("claude", "", "")
+	long.Messages = []Message{{Role: "user", Content: strings.Repeat("word ", 30)}}
+	long.setDefaultTitle()
+	if n := len([]rune(long.Title)); n != 60 || !strings.HasSuffix(long.Title, "...") {
+		t.Errorf("long Title = %q (%d runes), want cut to 60", long.Title, n)
+	}
+
+	md, err := s.Export("markdown")
+	if err != nil {
+		t.Fatalf("Export(markdown) error = %v", err)
+	}
+	for _, want := range []string{"# Why is the sky blue?\n", "- Profile: claude (claude-haiku)\n", "- Tokens: 42 prompt, 10 completion\n",
+		"## System\n\nBe brief.\n", "## User\n\nWhy is the   sky blue?\nAsking for a friend.\n", "## Assistant\n\nRayleigh scattering.\n"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("Export(markdown) = %s\nwant it to contain %q", md, want)
+		}
+	}
+	data, err := s.Export("json")
+	var got Session
+	if err != nil || json.Unmarshal(data, &got) != nil || got.ID != s.ID || len(got.Messages) != 2 {
+		t.Errorf("Export(json) = %s, %v", data, err)
+	}
+	if _, err := s.Export("html"); err == nil {
+		t.Error("Export(html) should error")
+	}
+
+	if err := DeleteSession(s.ID); err != nil {
+		t.Fatalf("DeleteSession() error = %v", err)
+	}
+	if _, err := LoadSession(s.ID); err == nil {
+		t.Error("LoadSession() after DeleteSession() should error")
+	}
+	if err := DeleteSession(s.ID); err == nil {
+		t.Error("DeleteSession() of a missing session should error")
+	}
+}
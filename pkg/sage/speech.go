@@ -0,0 +1,94 @@
+package sage
+
+import (
+	"fmt"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// SpeechRequest is the input for a text-to-speech call.
+type SpeechRequest struct {
+	Text  string
+	Voice string // e.g. "alloy" for OpenAI or "Fritz-PlayAI" for Groq
+
+	// Format is the audio format: "mp3" (the default), "opus", "aac",
+	// "flac", "wav" or "pcm" for OpenAI. Providers may take others.
+	Format string
+
+	// Speed is a playback speed multiplier; OpenAI takes 0.25 to 4.
+	// Nil uses the provider's default.
+	Speed *float64
+}
+
+// SpeechResponse is the result of a text-to-speech call.
+type SpeechResponse struct {
+	Audio     []byte
+	Format    string // The format requested
+	MediaType string // As reported by the provider, e.g. "audio/mpeg"
+	Model     string
+}
+
+// Speak reads req.Text aloud using the profile's provider and model, which
+// must be a text-to-speech model such as gpt-4o-mini-tts. If profileName is
+// empty, the default profile is used. Failed requests are retried according
+// to the profile's RetryPolicy.
+func (c *Client) Speak(profileName string, req SpeechRequest) (*SpeechResponse, error) {
+	if req.Text == "" {
+		return nil, fmt.Errorf("no text to speak")
+	}
+	if req.Voice == "" {
+		return nil, fmt.Errorf("voice is required")
+	}
+	if req.Format == "" {
+		req.Format = "mp3"
+	}
+
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return nil, err
+	}
+	speaker, ok := provider.(providers.Speaker)
+	if !ok || !providers.Supports(provider, providers.CapSpeech) {
+		return nil, errIncapable(profile, providers.CapSpeech)
+	}
+
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return nil, err
+	}
+	speechReq := providers.SpeechRequest{
+		Model:    profile.Model,
+		Input:    req.Text,
+		Voice:    req.Voice,
+		Format:   req.Format,
+		Speed:    req.Speed,
+		Endpoint: endpoint,
+	}
+
+	var providerResp *providers.SpeechResponse
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &speechReq.APIKey, func() error {
+			providerResp, err = speaker.Speak(speechReq)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SpeechResponse{
+		Audio:     providerResp.Audio,
+		Format:    req.Format,
+		MediaType: providerResp.MediaType,
+		Model:     providerResp.Model,
+	}, nil
+}
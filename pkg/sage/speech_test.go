@@ -0,0 +1,52 @@
+package sage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Speak(t *testing.T) {
+	client := setupTestClient(t)
+
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("ID3"))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("tts", Profile{Provider: "openai", Account: "default", Model: "gpt-4o-mini-tts"})
+
+	resp, err := client.Speak("tts", SpeechRequest{Text: "Hello", Voice: "alloy"})
+	if err != nil {
+		t.Fatalf("Speak() error = %v", err)
+	}
+	if string(resp.Audio) != "ID3" || resp.Format != "mp3" {
+		t.Errorf("Audio = %q, Format = %q", resp.Audio, resp.Format)
+	}
+	if gotBody["model"] != "gpt-4o-mini-tts" || gotBody["response_format"] != "mp3" {
+		t.Errorf("body = %v, want the profile's model and mp3 by default", gotBody)
+	}
+
+	if _, err := client.Speak("tts", SpeechRequest{Text: "Hello"}); err == nil {
+		t.Error("Speak() should require a voice")
+	}
+}
+
+func TestClient_Speak_Unsupported(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-5-haiku-latest"})
+
+	if _, err := client.Speak("claude", SpeechRequest{Text: "Hello", Voice: "alloy"}); err == nil {
+		t.Error("Speak() should fail for a provider without text-to-speech")
+	}
+}
@@ -0,0 +1,41 @@
+//go:build go1.23
+
+package sage
+
+import (
+	"context"
+	"iter"
+)
+
+// Stream is CompleteStream as an iterator, for use with range:
+//
+//	for chunk, err := range client.Stream(ctx, "", req) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Print(chunk.Content)
+//	}
+//
+// An error opening the stream or reading it is yielded once, last, with its
+// chunk. Breaking out of the loop cancels the request.
+func (c *Client) Stream(ctx context.Context, profileName string, req Request) iter.Seq2[Chunk, error] {
+	return func(yield func(Chunk, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ch, err := c.CompleteStream(ctx, profileName, req)
+		if err != nil {
+			yield(Chunk{}, err)
+			return
+		}
+		for chunk := range ch {
+			if chunk.Error != nil {
+				yield(chunk, chunk.Error)
+				return
+			}
+			if !yield(chunk, nil) {
+				return
+			}
+		}
+	}
+}
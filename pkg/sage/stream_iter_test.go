@@ -0,0 +1,68 @@
+//go:build go1.23
+
+package sage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Stream(t *testing.T) {
+	client := setupTestClient(t)
+
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"a\"}}]}\n\n"))
+		if !strings.Contains(string(body), "stall") {
+			w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"b\"}}]}\n\ndata: [DONE]\n\n"))
+			return
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // Stall until the client gives up
+		close(cancelled)
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("test", Profile{Provider: "openai", Account: "default", Model: "gpt-4o"})
+
+	var content string
+	for chunk, err := range client.Stream(context.Background(), "test", Request{Prompt: "hi"}) {
+		if err != nil {
+			t.Fatalf("Stream() error = %v", err)
+		}
+		content += chunk.Content
+	}
+	if content != "ab" {
+		t.Errorf("content = %q, want %q", content, "ab")
+	}
+
+	// Breaking out of the loop closes the stalled connection
+	for chunk, err := range client.Stream(context.Background(), "test", Request{Prompt: "stall"}) {
+		if err != nil || chunk.Content != "a" {
+			t.Fatalf("first chunk = %+v, %v; want a", chunk, err)
+		}
+		break
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("request still open a second after breaking out of the loop")
+	}
+
+	for _, err := range client.Stream(context.Background(), "missing", Request{Prompt: "hi"}) {
+		if err == nil {
+			t.Error("Stream() with an unknown profile should yield an error")
+		}
+	}
+}
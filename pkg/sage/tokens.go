@@ -0,0 +1,206 @@
+package sage
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// charsPerToken is a rough average for English text across common tokenizers.
+const charsPerToken = 4
+
+// EstimateTokens returns a rough token count for text.
+// It's an approximation for sanity checks, not an exact tokenizer.
+func EstimateTokens(text string) int {
+	n := utf8.RuneCountInString(text)
+	return (n + charsPerToken - 1) / charsPerToken
+}
+
+// TokenCount is the result of CountTokens.
+type TokenCount struct {
+	Tokens    int
+	Model     string
+	Estimated bool // Counted locally rather than by the provider
+}
+
+// CountTokens counts the tokens text takes up as a prompt to the profile's
+// model, without sending a completion. Anthropic counts them exactly with its
+// count_tokens endpoint; for other providers they're estimated locally. If
+// profileName is empty, the default profile is used.
+func (c *Client) CountTokens(profileName, text string) (*TokenCount, error) {
+	providerReq, err := c.buildProviderRequest(profileName, Request{Prompt: text})
+	if err != nil {
+		return nil, err
+	}
+	providerReq.System = "" // Count only text, not the profile's system prompt
+
+	tokens, ok, err := c.countTokens(profileName, providerReq)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &TokenCount{Tokens: bpeTokens(text), Model: providerReq.Model, Estimated: true}, nil
+	}
+	return &TokenCount{Tokens: tokens, Model: providerReq.Model}, nil
+}
+
+// countTokens counts providerReq's input tokens with the provider's API. ok
+// is false if the provider can't count them.
+func (c *Client) countTokens(profileName string, providerReq providers.Request) (tokens int, ok bool, err error) {
+	profile, _ := c.config.GetProfile(profileName)
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return 0, false, err
+	}
+
+	counter, ok := provider.(providers.TokenCounter)
+	if !ok || providerReq.Platform != "" {
+		return 0, false, nil
+	}
+
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return 0, false, err
+	}
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &providerReq.APIKey, func() error {
+			tokens, err = counter.CountTokens(providerReq)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return tokens, true, nil
+}
+
+// bpeTokens approximates the token count of BPE tokenizers such as OpenAI's
+// tiktoken. Text is split roughly the way they pre-tokenize it: words with
+// their leading space, digits in groups of three, punctuation, and runs of
+// whitespace. Long words count as several tokens, runs of punctuation as one
+// per pair, and other characters outside ASCII as one each.
+func bpeTokens(text string) int {
+	runes := []rune(text)
+	tokens := 0
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case r == ' ' && j < len(runes) && unicode.IsLetter(runes[j]):
+			// A single space joins the following word
+			i = j
+			continue
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			for j < len(runes) && runes[j] < utf8.RuneSelf && unicode.IsLetter(runes[j]) {
+				j++
+			}
+			tokens += (j - i + 7) / 8
+		case unicode.IsDigit(r):
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens += (j - i + 2) / 3
+		case unicode.IsSpace(r):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+			tokens++
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			// Common pairs such as "()" and ".\n" merge
+			for j < len(runes) && (unicode.IsPunct(runes[j]) || unicode.IsSymbol(runes[j])) {
+				j++
+			}
+			tokens += (j - i + 1) / 2
+		default:
+			tokens++
+		}
+		i = j
+	}
+	return tokens
+}
+
+// ContextWindow returns the context window size of a model in tokens.
+// The second return value is false if the model is unknown.
+func ContextWindow(model string) (int, bool) {
+	spec, ok := providers.LookupModel(model)
+	if !ok || spec.ContextWindow == 0 {
+		return 0, false
+	}
+	return spec.ContextWindow, true
+}
+
+// PromptSizeError reports a request that won't fit in the model's context window.
+type PromptSizeError struct {
+	Model         string
+	PromptTokens  int
+	MaxTokens     int
+	ContextWindow int
+
+	// Estimated is set when PromptTokens is a local estimate, which can be
+	// off by several percent, rather than counted by the provider.
+	Estimated bool
+}
+
+func (e *PromptSizeError) Error() string {
+	approx := ""
+	if e.Estimated {
+		approx = "~"
+	}
+	return fmt.Sprintf("prompt too large for %s: %s%d prompt + %d max tokens exceeds %d token context window",
+		e.Model, approx, e.PromptTokens, e.MaxTokens, e.ContextWindow)
+}
+
+// Unwrap lets errors.Is match a PromptSizeError against
+// providers.ErrContextLengthExceeded, like a provider's rejection would.
+func (e *PromptSizeError) Unwrap() error { return providers.ErrContextLengthExceeded }
+
+// CheckPromptSize checks whether req fits in the context window of the
+// profile's model. It returns a *PromptSizeError if it doesn't, and nil if
+// it fits or the model's context window is unknown.
+//
+// The prompt is estimated at about 4 characters per token. If the estimate
+// doesn't fit and the provider can count tokens (Anthropic), they're
+// counted exactly before deciding; otherwise, or if counting fails, the
+// error is marked Estimated.
+func (c *Client) CheckPromptSize(profileName string, req Request) error {
+	// Peek at the key so the check doesn't use up a turn in key rotation
+	providerReq, err := c.buildRequestWithKey(profileName, req, c.peekAPIKey)
+	if err != nil {
+		return err
+	}
+
+	model := providerReq.Model
+	if providerReq.ModelAlias != "" {
+		model = providerReq.ModelAlias
+	}
+	window, ok := ContextWindow(model)
+	if !ok {
+		return nil
+	}
+
+	promptTokens := EstimateTokens(providerReq.System) + EstimateTokens(providerReq.Prompt)
+	for _, m := range providerReq.Messages {
+		promptTokens += EstimateTokens(m.Content)
+	}
+	if promptTokens+providerReq.MaxTokens <= window {
+		return nil
+	}
+
+	estimated := true
+	if tokens, ok, err := c.countTokens(profileName, providerReq); err == nil && ok {
+		promptTokens, estimated = tokens, false
+		if promptTokens+providerReq.MaxTokens <= window {
+			return nil
+		}
+	}
+
+	return &PromptSizeError{
+		Model:         providerReq.Model,
+		PromptTokens:  promptTokens,
+		MaxTokens:     providerReq.MaxTokens,
+		ContextWindow: window,
+		Estimated:     estimated,
+	}
+}
@@ -0,0 +1,200 @@
+package sage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{strings.Repeat("a", 400), 100},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%d chars) = %d, want %d", len(tt.text), got, tt.want)
+		}
+	}
+}
+
+func TestBPETokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"1234567", 3},
+		{"こんにちは", 5},
+	}
+
+	for _, tt := range tests {
+		if got := bpeTokens(tt.text); got != tt.want {
+			t.Errorf("bpeTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestClient_CountTokens(t *testing.T) {
+	client := setupTestClient(t)
+
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"input_tokens": 14}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	client.AddProviderAccount("ollama", "default", "")
+	cfg := client.config.Providers["anthropic"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["anthropic"] = cfg
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-sonnet-4-20250514", System: "Be brief."})
+	client.AddProfile("local", Profile{Provider: "ollama", Account: "default", Model: "llama3.2"})
+
+	count, err := client.CountTokens("claude", "Hello, world!")
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.Tokens != 14 || count.Estimated {
+		t.Errorf("CountTokens() = %+v, want 14 counted by the API", count)
+	}
+	if _, ok := gotBody["system"]; ok {
+		t.Errorf("body = %v, want the profile's system prompt left out", gotBody)
+	}
+
+	count, err = client.CountTokens("local", "Hello, world!")
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.Tokens != 4 || !count.Estimated {
+		t.Errorf("CountTokens() = %+v, want 4 estimated", count)
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+		ok    bool
+	}{
+		{"gpt-4o-mini", 128000, true},
+		{"gpt-4-turbo-preview", 128000, true},
+		{"gpt-4", 8192, true},
+		{"claude-sonnet-4-20250514", 200000, true},
+		{"llama3.2", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ContextWindow(tt.model)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ContextWindow(%q) = %d, %v; want %d, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestClient_CheckPromptSize(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProfile("small", Profile{Provider: "openai", Account: "default", Model: "gpt-4"})
+	client.AddProfile("local", Profile{Provider: "ollama", Account: "default", Model: "llama3.2"})
+
+	// Fits
+	if err := client.CheckPromptSize("small", Request{Prompt: "Hello", MaxTokens: 100}); err != nil {
+		t.Errorf("CheckPromptSize() error = %v, want nil", err)
+	}
+
+	// Too large: ~8000 prompt tokens + 500 max tokens > 8192
+	req := Request{Prompt: strings.Repeat("a", 32000), MaxTokens: 500}
+	err := client.CheckPromptSize("small", req)
+	var sizeErr *PromptSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("CheckPromptSize() error = %v, want *PromptSizeError", err)
+	}
+	if sizeErr.ContextWindow != 8192 {
+		t.Errorf("ContextWindow = %d, want 8192", sizeErr.ContextWindow)
+	}
+	if !errors.Is(err, providers.ErrContextLengthExceeded) {
+		t.Errorf("CheckPromptSize() error = %v, want it to match ErrContextLengthExceeded", err)
+	}
+	if !sizeErr.Estimated {
+		t.Errorf("Estimated = false, want true for a provider that can't count tokens")
+	}
+
+	// Unknown model is never rejected
+	if err := client.CheckPromptSize("local", req); err != nil {
+		t.Errorf("CheckPromptSize(unknown model) error = %v, want nil", err)
+	}
+}
+
+func TestClient_CheckPromptSize_KeepsRotation(t *testing.T) {
+	client := setupTestClient(t)
+	client.AddProviderAccount("openai", "default", "sk-1")
+	client.AddProviderKey("openai", "default", "sk-2")
+	cfg := client.config.Providers["openai"]
+	cfg.RotateKeys = true
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("small", Profile{Provider: "openai", Account: "default", Model: "gpt-4"})
+
+	// Checking before each request doesn't skip a key
+	var got []string
+	for i := 0; i < 2; i++ {
+		client.CheckPromptSize("small", Request{Prompt: "Hello"})
+		key, _ := client.selectAPIKey("openai", "default")
+		got = append(got, key)
+	}
+	if got[0] != "sk-1" || got[1] != "sk-2" {
+		t.Errorf("keys after size checks = %v, want [sk-1 sk-2]", got)
+	}
+}
+
+func TestClient_CheckPromptSize_Counted(t *testing.T) {
+	client := setupTestClient(t)
+
+	counted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf(`{"input_tokens": %d}`, counted)))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	cfg := client.config.Providers["anthropic"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["anthropic"] = cfg
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-sonnet-4-20250514"})
+
+	// ~205000 estimated tokens are over the 200000 token window, but the
+	// count decides
+	req := Request{Prompt: strings.Repeat("a", 820000), MaxTokens: 1000}
+	counted = 190000
+	if err := client.CheckPromptSize("claude", req); err != nil {
+		t.Errorf("CheckPromptSize() error = %v, want nil when the count fits", err)
+	}
+
+	counted = 210000
+	var sizeErr *PromptSizeError
+	if err := client.CheckPromptSize("claude", req); !errors.As(err, &sizeErr) {
+		t.Fatalf("CheckPromptSize() error = %v, want *PromptSizeError", err)
+	}
+	if sizeErr.PromptTokens != 210000 || sizeErr.Estimated {
+		t.Errorf("PromptSizeError = %+v, want 210000 counted tokens", sizeErr)
+	}
+}
@@ -0,0 +1,723 @@
+package sage
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The TOML support covers what config files use: tables, dotted keys,
+// strings of every kind, numbers, booleans, arrays, inline tables and
+// comments. Arrays of tables ([[x]]) aren't supported; dates are read as
+// strings.
+
+// tomlParser parses TOML.
+type tomlParser struct {
+	src      string
+	pos      int
+	line     int
+	root     *configNode
+	table    []string // Path of the current [table]
+	defined  map[string]bool
+	comments *configComments
+	pending  []string
+}
+
+// parseTOML parses a TOML config file into a tree and its comments.
+func parseTOML(src string) (*configNode, *configComments, error) {
+	p := &tomlParser{
+		src:      strings.ReplaceAll(src, "\r\n", "\n"),
+		line:     1,
+		root:     newMapNode(),
+		defined:  make(map[string]bool),
+		comments: newConfigComments(),
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		switch c := p.src[p.pos]; {
+		case c == '\n':
+			if p.lineStart() {
+				addPending(&p.pending, "")
+			}
+			p.newline()
+		case c == '#':
+			addPending(&p.pending, p.comment())
+			if err := p.endLine(); err != nil {
+				return nil, nil, err
+			}
+		case c == '[':
+			if err := p.parseTable(); err != nil {
+				return nil, nil, err
+			}
+		default:
+			if err := p.parseKeyValue(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	p.comments.finish(p.pending)
+	return p.root, p.comments, nil
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) newline() {
+	p.pos++
+	p.line++
+}
+
+// lineStart reports whether only whitespace precedes pos on its line.
+func (p *tomlParser) lineStart() bool {
+	i := strings.LastIndexByte(p.src[:p.pos], '\n')
+	return strings.TrimSpace(p.src[i+1:p.pos]) == ""
+}
+
+// comment consumes a comment, returning it.
+func (p *tomlParser) comment() string {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		end = len(p.src) - p.pos
+	}
+	c := strings.TrimRight(p.src[p.pos:p.pos+end], " \t\r")
+	p.pos += end
+	return c
+}
+
+// endLineComment consumes the rest of a line, an optional comment and the
+// newline, and returns the comment.
+func (p *tomlParser) endLineComment() (string, error) {
+	p.skipSpace()
+	var c string
+	if p.pos < len(p.src) && p.src[p.pos] == '#' {
+		c = p.comment()
+	}
+	if p.pos < len(p.src) {
+		if p.src[p.pos] != '\n' {
+			return "", p.errorf("unexpected %q at end of line", p.restOfLine())
+		}
+		p.newline()
+	}
+	return c, nil
+}
+
+// endLine is endLineComment, for lines whose comment was already taken.
+func (p *tomlParser) endLine() error {
+	_, err := p.endLineComment()
+	return err
+}
+
+func (p *tomlParser) restOfLine() string {
+	rest, _, _ := strings.Cut(p.src[p.pos:], "\n")
+	return rest
+}
+
+// parseTable parses a [table] header and makes it the current table.
+func (p *tomlParser) parseTable() error {
+	if strings.HasPrefix(p.src[p.pos:], "[[") {
+		return p.errorf("arrays of tables aren't supported")
+	}
+	p.pos++
+	p.skipSpace()
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != ']' {
+		return p.errorf("expected ] after table name")
+	}
+	p.pos++
+
+	id := strings.Join(keys, "\x00")
+	if p.defined[id] {
+		return p.errorf("table [%s] defined twice", strings.Join(keys, "."))
+	}
+	p.defined[id] = true
+	if _, err := p.lookupTable(keys); err != nil {
+		return err
+	}
+	p.table = keys
+
+	p.comments.take(id, &p.pending)
+	comment, err := p.endLineComment()
+	if comment != "" {
+		p.comments.inline[id] = comment
+	}
+	return err
+}
+
+// lookupTable returns the table at path from the root, creating it and the
+// tables above it as needed.
+func (p *tomlParser) lookupTable(path []string) (*configNode, error) {
+	n := p.root
+	for i, key := range path {
+		next, ok := n.fields[key]
+		if !ok {
+			next = newMapNode()
+			n.set(key, next)
+		}
+		if next.kind != mapNode {
+			return nil, p.errorf("%s is not a table", strings.Join(path[:i+1], "."))
+		}
+		n = next
+	}
+	return n, nil
+}
+
+// parseKeyValue parses "key = value" into the current table.
+func (p *tomlParser) parseKeyValue() error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+		return p.errorf("expected = after key %s", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpace()
+
+	path := append(p.table[:len(p.table):len(p.table)], keys...)
+	id := strings.Join(path, "\x00")
+	p.comments.take(id, &p.pending)
+
+	value, err := p.parseValue(path)
+	if err != nil {
+		return err
+	}
+	table, err := p.lookupTable(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	if err := table.set(path[len(path)-1], value); err != nil {
+		return p.errorf("%v", err)
+	}
+
+	comment, err := p.endLineComment()
+	if comment != "" {
+		p.comments.inline[id] = comment
+	}
+	return err
+}
+
+// parseKey parses a possibly dotted key: a.b."c.d".
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("expected a key")
+		}
+		var key string
+		switch p.src[p.pos] {
+		case '"', '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = s.text
+		default:
+			start := p.pos
+			for p.pos < len(p.src) && isBareKeyChar(p.src[p.pos]) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key, found %q", p.restOfLine())
+			}
+			key = p.src[start:p.pos]
+		}
+		keys = append(keys, key)
+
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue parses a value, the one at path for the comments within it.
+func (p *tomlParser) parseValue(path []string) (*configNode, error) {
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("expected a value")
+	}
+	switch p.src[p.pos] {
+	case '"', '\'':
+		return p.parseString()
+	case '[':
+		return p.parseArray(path)
+	case '{':
+		return p.parseInlineTable(path)
+	}
+
+	// Booleans, numbers and dates
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n#,]}", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	text := strings.TrimRight(p.src[start:p.pos], "\r")
+	switch {
+	case text == "":
+		return nil, p.errorf("expected a value, found %q", p.restOfLine())
+	case text == "true" || text == "false":
+		return &configNode{text: text}, nil
+	}
+	if number := strings.ReplaceAll(strings.TrimPrefix(text, "+"), "_", ""); jsonNumber.MatchString(number) {
+		return &configNode{text: number}, nil
+	}
+	if n, err := strconv.ParseInt(strings.ReplaceAll(text, "_", ""), 0, 64); err == nil {
+		return &configNode{text: strconv.FormatInt(n, 10)}, nil
+	}
+	if text[0] >= '0' && text[0] <= '9' {
+		// A date or time, which the config only has as strings
+		return &configNode{text: text, quoted: true}, nil
+	}
+	return nil, p.errorf("invalid value %q (strings must be quoted)", text)
+}
+
+// parseString parses a basic ("...") or literal ('...') string, or their
+// multi-line forms with three quotes.
+func (p *tomlParser) parseString() (*configNode, error) {
+	quote := p.src[p.pos]
+	delim := string(quote)
+	multiline := strings.HasPrefix(p.src[p.pos:], strings.Repeat(delim, 3))
+	if multiline {
+		delim = strings.Repeat(delim, 3)
+		p.pos += 3
+		// A newline right after the opening quotes is trimmed
+		if strings.HasPrefix(p.src[p.pos:], "\n") {
+			p.newline()
+		}
+	} else {
+		p.pos++
+	}
+
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated string")
+		}
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += len(delim)
+			// Up to two more quotes end the content of a multi-line string
+			for extra := 0; multiline && extra < 2 && p.pos < len(p.src) && p.src[p.pos] == quote; extra++ {
+				b.WriteByte(quote)
+				p.pos++
+			}
+			return &configNode{text: b.String(), quoted: true}, nil
+		}
+
+		c := p.src[p.pos]
+		switch {
+		case c == '\n':
+			if !multiline {
+				return nil, p.errorf("unterminated string")
+			}
+			b.WriteByte('\n')
+			p.newline()
+		case c == '\\' && quote == '"':
+			p.pos++
+			if multiline && p.lineEndingBackslash() {
+				continue
+			}
+			if p.pos >= len(p.src) {
+				return nil, p.errorf("unterminated string")
+			}
+			r, n, err := unescapeTOML(p.src[p.pos:])
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			b.WriteString(r)
+			p.pos += n
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// lineEndingBackslash consumes the whitespace and newlines after a
+// backslash that ends a line of a multi-line basic string, reporting
+// whether there was one.
+func (p *tomlParser) lineEndingBackslash() bool {
+	rest := strings.TrimLeft(p.src[p.pos:], " \t\r")
+	if !strings.HasPrefix(rest, "\n") {
+		return false
+	}
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		if p.src[p.pos] == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+	return true
+}
+
+// unescapeTOML decodes the escape sequence that starts s, after its
+// backslash, returning it and its length.
+func unescapeTOML(s string) (string, int, error) {
+	switch s[0] {
+	case 'b':
+		return "\b", 1, nil
+	case 't':
+		return "\t", 1, nil
+	case 'n':
+		return "\n", 1, nil
+	case 'f':
+		return "\f", 1, nil
+	case 'r':
+		return "\r", 1, nil
+	case 'e':
+		return "\x1b", 1, nil
+	case '"':
+		return "\"", 1, nil
+	case '\\':
+		return "\\", 1, nil
+	case 'u', 'U':
+		return unescapeYAML(s)
+	}
+	return "", 0, fmt.Errorf("invalid escape \\%c", s[0])
+}
+
+// skipArraySpace skips whitespace, newlines and comments inside the array
+// at path, which has items items so far. A comment after an item on its
+// line is the item's; others wait for the next item or the closing ].
+func (p *tomlParser) skipArraySpace(path []string, items int) {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			if p.lineStart() {
+				addPending(&p.pending, "")
+			}
+			p.newline()
+		case '#':
+			if items > 0 && !p.lineStart() {
+				p.comments.inline[commentPath(path, strconv.Itoa(items-1))] = p.comment()
+			} else {
+				addPending(&p.pending, p.comment())
+			}
+		default:
+			return
+		}
+	}
+}
+
+// parseArray parses [a, b, c], which may span lines. The comments above an
+// item are kept by its index under path, and those above the closing ]
+// under "]".
+func (p *tomlParser) parseArray(path []string) (*configNode, error) {
+	start := p.line
+	p.pos++
+	n := &configNode{kind: listNode}
+	for {
+		p.skipArraySpace(path, len(n.items))
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.src[p.pos] == ']' {
+			p.comments.take(commentPath(path, "]"), &p.pending)
+			p.pos++
+			n.multiline = p.line > start
+			return n, nil
+		}
+		ipath := append(path[:len(path):len(path)], strconv.Itoa(len(n.items)))
+		p.comments.take(strings.Join(ipath, "\x00"), &p.pending)
+		item, err := p.parseValue(ipath)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+
+		p.skipArraySpace(path, len(n.items))
+		switch {
+		case p.pos >= len(p.src):
+			return nil, p.errorf("unterminated array")
+		case p.src[p.pos] == ',':
+			p.pos++
+		case p.src[p.pos] != ']':
+			return nil, p.errorf("expected , or ] in array, found %q", p.restOfLine())
+		}
+	}
+}
+
+// parseInlineTable parses {a = 1, b = "x"}, on one line.
+func (p *tomlParser) parseInlineTable(path []string) (*configNode, error) {
+	p.pos++
+	n := newMapNode()
+	n.flow = true
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			return nil, p.errorf("unterminated inline table")
+		}
+		if p.src[p.pos] == '}' && len(n.keys) == 0 {
+			p.pos++
+			return n, nil
+		}
+		keys, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+			return nil, p.errorf("expected = after key %s", strings.Join(keys, "."))
+		}
+		p.pos++
+		p.skipSpace()
+		value, err := p.parseValue(append(path[:len(path):len(path)], keys...))
+		if err != nil {
+			return nil, err
+		}
+		table := n
+		for _, key := range keys[:len(keys)-1] {
+			next, ok := table.fields[key]
+			if !ok {
+				next = newMapNode()
+				table.set(key, next)
+			}
+			if next.kind != mapNode {
+				return nil, p.errorf("%s is not a table", key)
+			}
+			table = next
+		}
+		if err := table.set(keys[len(keys)-1], value); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+
+		p.skipSpace()
+		switch {
+		case p.pos >= len(p.src):
+			return nil, p.errorf("unterminated inline table")
+		case p.src[p.pos] == ',':
+			p.pos++
+		case p.src[p.pos] == '}':
+			p.pos++
+			return n, nil
+		default:
+			return nil, p.errorf("expected , or } in inline table, found %q", p.restOfLine())
+		}
+	}
+}
+
+// emitTOML writes root as a TOML config file with comments.
+func emitTOML(buf *bytes.Buffer, root *configNode, comments *configComments) {
+	emitTOMLTable(buf, root, comments, nil)
+	for _, line := range comments.footer {
+		buf.WriteString(line + "\n")
+	}
+}
+
+// emitTOMLTable writes a table's values, then its subtables under their
+// own [headers]. The root and tables holding only subtables get no header
+// unless comments are attached to it.
+func emitTOMLTable(buf *bytes.Buffer, n *configNode, comments *configComments, path []string) {
+	var subtables []string
+	for _, key := range n.keys {
+		value := n.fields[key]
+		switch {
+		case value.isNull():
+			// TOML has no null; an absent key reads back the same
+		case value.kind == mapNode && len(value.keys) > 0 && !value.flow:
+			subtables = append(subtables, key)
+		default:
+			cpath := commentPath(path, key)
+			writeComments(buf, comments.before[cpath], "")
+			buf.WriteString(tomlKey(key) + " = ")
+			if value.kind == listNode && value.multiline {
+				emitTOMLArray(buf, value, comments, append(path[:len(path):len(path)], key), "")
+			} else {
+				buf.WriteString(tomlValue(value))
+			}
+			writeInlineComment(buf, comments.inline[cpath])
+		}
+	}
+
+	for _, key := range subtables {
+		sub := n.fields[key]
+		spath := append(path[:len(path):len(path)], key)
+		id := strings.Join(spath, "\x00")
+
+		hasValues := false
+		for _, k := range sub.keys {
+			if v := sub.fields[k]; !v.isNull() && (v.kind != mapNode || len(v.keys) == 0 || v.flow) {
+				hasValues = true
+			}
+		}
+		if hasValues || len(comments.before[id]) > 0 || comments.inline[id] != "" {
+			if buf.Len() > 0 && len(comments.before[id]) == 0 {
+				buf.WriteString("\n")
+			}
+			writeComments(buf, comments.before[id], "")
+			names := make([]string, len(spath))
+			for i, k := range spath {
+				names[i] = tomlKey(k)
+			}
+			buf.WriteString("[" + strings.Join(names, ".") + "]")
+			writeInlineComment(buf, comments.inline[id])
+		}
+		emitTOMLTable(buf, sub, comments, spath)
+	}
+}
+
+// emitTOMLArray writes an array that was written over several lines the
+// same way, an item per line with its comments, indented by pad. It leaves
+// the line of the closing ] to be ended.
+func emitTOMLArray(buf *bytes.Buffer, n *configNode, comments *configComments, path []string, pad string) {
+	buf.WriteString("[\n")
+	for i, item := range n.items {
+		if item.isNull() {
+			continue
+		}
+		ipath := append(path[:len(path):len(path)], strconv.Itoa(i))
+		id := strings.Join(ipath, "\x00")
+		writeComments(buf, comments.before[id], pad+"  ")
+		buf.WriteString(pad + "  ")
+		if item.kind == listNode && item.multiline {
+			emitTOMLArray(buf, item, comments, ipath, pad+"  ")
+		} else {
+			buf.WriteString(tomlValue(item))
+		}
+		buf.WriteString(",")
+		writeInlineComment(buf, comments.inline[id])
+	}
+	writeComments(buf, comments.before[commentPath(path, "]")], pad+"  ")
+	buf.WriteString(pad + "]")
+}
+
+// writeInlineComment ends a line with its comment, if any.
+func writeInlineComment(buf *bytes.Buffer, comment string) {
+	if comment != "" {
+		buf.WriteString(" " + comment)
+	}
+	buf.WriteString("\n")
+}
+
+// tomlKey returns key bare if it can be, or else quoted.
+func tomlKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		if !isBareKeyChar(key[i]) {
+			return tomlString(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+// tomlValue formats a value on one line, except multi-line strings.
+func tomlValue(n *configNode) string {
+	switch n.kind {
+	case mapNode:
+		parts := make([]string, 0, len(n.keys))
+		for _, k := range n.keys {
+			if v := n.fields[k]; !v.isNull() {
+				parts = append(parts, tomlKey(k)+" = "+tomlValue(v))
+			}
+		}
+		if len(parts) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case listNode:
+		parts := make([]string, 0, len(n.items))
+		for _, item := range n.items {
+			if !item.isNull() {
+				parts = append(parts, tomlValue(item))
+			}
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	if !n.quoted {
+		return n.text
+	}
+	if strings.Contains(n.text, "\n") {
+		return tomlMultilineString(n.text)
+	}
+	return tomlString(n.text)
+}
+
+// tomlString quotes s as a basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case isControl(r):
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlMultilineString quotes s as a multi-line basic string, which keeps
+// prompts readable.
+func tomlMultilineString(s string) string {
+	var b strings.Builder
+	b.WriteString("\"\"\"\n")
+	quotes := 0
+	for _, r := range s {
+		if r == '"' {
+			// Escape every third quote in a row, so none closes the string
+			quotes++
+			if quotes == 3 {
+				b.WriteString(`\"`)
+				quotes = 0
+				continue
+			}
+			b.WriteRune(r)
+			continue
+		}
+		quotes = 0
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r != '\n' && r != '\t' && isControl(r):
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if quotes > 0 {
+		// A quote right before the closing ones would be read as content
+		// of an ambiguous run
+		str := b.String()
+		b.Reset()
+		b.WriteString(str[:len(str)-quotes])
+		b.WriteString(strings.Repeat(`\"`, quotes))
+	}
+	b.WriteString(`"""`)
+	return b.String()
+}
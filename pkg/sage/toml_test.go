@@ -0,0 +1,141 @@
+package sage
+
+import (
+	"strings"
+	"testing"
+)
+
+const testTOMLConfig = `# sage config
+default_profile = "local" # used by "sage complete"
+
+# Local models
+[providers.ollama]
+accounts = [
+  "default", # the only one
+]
+
+[providers.openai]
+accounts = ["default", "work"]
+api_key_env = { work = "WORK_OPENAI_KEY" }
+
+[profiles.local]
+provider = "ollama"
+account = 'default'
+model = "llama3"
+system = """
+Be "terse".
+Answer in English.\
+"""
+retry.max_retries = 1_000
+`
+
+func TestDecodeConfig_TOML(t *testing.T) {
+	cfg, err := decodeConfig("config.toml", []byte(testTOMLConfig))
+	if err != nil {
+		t.Fatalf("decodeConfig() error = %v", err)
+	}
+
+	if cfg.DefaultProfile != "local" {
+		t.Errorf("DefaultProfile = %q, want local", cfg.DefaultProfile)
+	}
+	openai := cfg.Providers["openai"]
+	if len(openai.Accounts) != 2 || openai.APIKeyEnv["work"] != "WORK_OPENAI_KEY" {
+		t.Errorf("openai = %+v", openai)
+	}
+	local := cfg.Profiles["local"]
+	if local.Account != "default" || local.System != "Be \"terse\".\nAnswer in English." {
+		t.Errorf("local = %+v", local)
+	}
+	if local.Retry == nil || local.Retry.MaxRetries == nil || *local.Retry.MaxRetries != 1000 {
+		t.Errorf("local retry = %+v, want max_retries 1000", local.Retry)
+	}
+}
+
+func TestDecodeConfig_TOMLErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a = 1\na = 2\n", "line 2: duplicate key"},
+		{"[a]\n[a]\n", "line 2: table [a] defined twice"},
+		{"a = gpt-4o\n", "strings must be quoted"},
+		{"a = \"open\n", "unterminated string"},
+		{"[[a]]\n", "arrays of tables"},
+		{"a = 1 b = 2\n", "at end of line"},
+	}
+	for _, tt := range tests {
+		if _, err := decodeConfig("config.toml", []byte(tt.src)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("decodeConfig(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestEncodeConfig_TOMLKeepsComments(t *testing.T) {
+	cfg, _ := decodeConfig("config.toml", []byte(testTOMLConfig))
+	cfg.DefaultProfile = "fast"
+	cfg.Profiles["fast"] = Profile{Provider: "openai", Account: "work", Model: "gpt-4o-mini", System: `Say """hi"""`}
+
+	data, err := encodeConfig("config.toml", cfg, []byte(testTOMLConfig))
+	if err != nil {
+		t.Fatalf("encodeConfig() error = %v", err)
+	}
+	out := string(data)
+
+	for _, want := range []string{
+		"# sage config\ndefault_profile = \"fast\" # used by \"sage complete\"\n",
+		"# Local models\n[providers.ollama]\naccounts = [\n  \"default\", # the only one\n]\n",
+		"api_key_env = { work = \"WORK_OPENAI_KEY\" }\n",
+		"[profiles.local.retry]\nmax_retries = 1000\n",
+		"[profiles.fast]\nprovider = \"openai\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("encoded config missing %q:\n%s", want, out)
+		}
+	}
+
+	again, err := decodeConfig("config.toml", data)
+	if err != nil {
+		t.Fatalf("decodeConfig() of encoded config error = %v\n%s", err, out)
+	}
+	for _, name := range []string{"local", "fast"} {
+		if got, want := again.Profiles[name].System, cfg.Profiles[name].System; got != want {
+			t.Errorf("%s system after round trip = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestEncodeConfig_TOMLMultilineArray(t *testing.T) {
+	src := `[providers.openai]
+accounts = [
+  # Personal
+  "default", # first
+  "work",
+
+  # Retired keys go here
+] # all of them
+`
+	cfg, err := decodeConfig("config.toml", []byte(src))
+	if err != nil {
+		t.Fatalf("decodeConfig() error = %v", err)
+	}
+	if accounts := cfg.Providers["openai"].Accounts; len(accounts) != 2 || accounts[1] != "work" {
+		t.Fatalf("accounts = %v, want [default work]", accounts)
+	}
+
+	data, err := encodeConfig("config.toml", cfg, []byte(src))
+	if err != nil {
+		t.Fatalf("encodeConfig() error = %v", err)
+	}
+	if string(data) != src {
+		t.Errorf("encoded config = %q, want it unchanged: %q", data, src)
+	}
+
+	// A new item goes on its own line, and the comments before ] stay there
+	openai := cfg.Providers["openai"]
+	openai.Accounts = append(openai.Accounts, "team")
+	cfg.Providers["openai"] = openai
+	data, _ = encodeConfig("config.toml", cfg, []byte(src))
+	if want := "  \"work\",\n  \"team\",\n\n  # Retired keys go here\n] # all of them\n"; !strings.HasSuffix(string(data), want) {
+		t.Errorf("encoded config = %q, want it to end %q", data, want)
+	}
+}
@@ -0,0 +1,79 @@
+package sage
+
+import (
+	"fmt"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// TranscribeRequest is the input for a transcription call.
+type TranscribeRequest struct {
+	Audio    []byte // Audio data, e.g. mp3, wav, m4a or webm
+	Filename string // File name; its extension tells the provider the format
+
+	Language string // ISO-639-1 code of the spoken language, e.g. "en"; empty to detect
+	Prompt   string // Text that guides spelling and style (optional)
+}
+
+// TranscribeResponse is the result of a transcription call.
+type TranscribeResponse struct {
+	Text  string
+	Model string
+}
+
+// Transcribe returns the text spoken in req.Audio using the profile's
+// provider and model, which must be a speech-to-text model such as
+// whisper-1. If profileName is empty, the default profile is used. Failed
+// requests are retried according to the profile's RetryPolicy.
+func (c *Client) Transcribe(profileName string, req TranscribeRequest) (*TranscribeResponse, error) {
+	if len(req.Audio) == 0 {
+		return nil, fmt.Errorf("no audio to transcribe")
+	}
+	if req.Filename == "" {
+		return nil, fmt.Errorf("audio filename is required to detect its format")
+	}
+
+	profile, err := c.config.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.RetryPolicy(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := c.getProvider(profile.Provider)
+	if err != nil {
+		return nil, err
+	}
+	transcriber, ok := provider.(providers.Transcriber)
+	if !ok || !providers.Supports(provider, providers.CapTranscription) {
+		return nil, errIncapable(profile, providers.CapTranscription)
+	}
+
+	endpoint, err := c.endpoint(profile)
+	if err != nil {
+		return nil, err
+	}
+	transcribeReq := providers.TranscribeRequest{
+		Model:    profile.Model,
+		Audio:    req.Audio,
+		Filename: req.Filename,
+		Language: req.Language,
+		Prompt:   req.Prompt,
+		Endpoint: endpoint,
+	}
+
+	var providerResp *providers.TranscribeResponse
+	err = policy.do(func() error {
+		return c.withKeyFailover(profile, &transcribeReq.APIKey, func() error {
+			providerResp, err = transcriber.Transcribe(transcribeReq)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TranscribeResponse{Text: providerResp.Text, Model: providerResp.Model}, nil
+}
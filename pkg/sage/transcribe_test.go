@@ -0,0 +1,47 @@
+package sage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Transcribe(t *testing.T) {
+	client := setupTestClient(t)
+
+	var gotModel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotModel = r.FormValue("model")
+		w.Write([]byte(`{"text": "Remember to buy milk."}`))
+	}))
+	defer server.Close()
+
+	client.AddProviderAccount("openai", "default", "sk-test")
+	cfg := client.config.Providers["openai"]
+	cfg.BaseURL = server.URL
+	client.config.Providers["openai"] = cfg
+	client.AddProfile("whisper", Profile{Provider: "openai", Account: "default", Model: "whisper-1"})
+
+	resp, err := client.Transcribe("whisper", TranscribeRequest{Audio: []byte("mp3"), Filename: "memo.mp3"})
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	if resp.Text != "Remember to buy milk." || gotModel != "whisper-1" {
+		t.Errorf("Text = %q, model = %q", resp.Text, gotModel)
+	}
+
+	if _, err := client.Transcribe("whisper", TranscribeRequest{Filename: "memo.mp3"}); err == nil {
+		t.Error("Transcribe() should reject empty audio")
+	}
+}
+
+func TestClient_Transcribe_Unsupported(t *testing.T) {
+	client := setupTestClient(t)
+
+	client.AddProviderAccount("anthropic", "default", "sk-ant")
+	client.AddProfile("claude", Profile{Provider: "anthropic", Account: "default", Model: "claude-3-5-haiku-latest"})
+
+	if _, err := client.Transcribe("claude", TranscribeRequest{Audio: []byte("mp3"), Filename: "memo.mp3"}); err == nil {
+		t.Error("Transcribe() should fail for a provider without transcription")
+	}
+}
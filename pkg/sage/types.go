@@ -1,11 +1,94 @@
 // Package sage provides a unified interface for LLM providers.
 package sage
 
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
 // Request is the input for a completion.
 type Request struct {
 	Prompt    string
 	System    string
 	MaxTokens int
+
+	// Model overrides the profile's model for this request, e.g. to try
+	// another model on the same provider account. Empty uses the profile's.
+	Model string
+
+	// Sampling parameters. Nil leaves the provider's default; use Float and
+	// Int to set one. Anthropic and Bedrock don't take the penalties or Seed.
+	Temperature      *float64 // 0 to 2; lower is more deterministic
+	TopP             *float64 // 0 to 1; nucleus sampling cutoff
+	FrequencyPenalty *float64 // -2 to 2
+	PresencePenalty  *float64 // -2 to 2
+	Seed             *int     // Best-effort reproducible sampling
+
+	// Logprobs asks for each output token's log probability, with up to
+	// TopLogprobs (0 to 20) most likely alternatives. Only OpenAI-compatible
+	// providers return them.
+	Logprobs    bool
+	TopLogprobs int
+
+	// ReasoningEffort is "low", "medium" or "high", bounding how long a
+	// reasoning model (OpenAI's o-series) thinks before it answers. Empty
+	// uses the profile's ReasoningEffort. Only OpenAI-compatible providers
+	// take it.
+	ReasoningEffort string
+
+	// ExtraBody is merged into the provider's JSON request body, on top of
+	// the provider's and profile's extra_body. For parameters sage doesn't
+	// model yet, such as Anthropic's top_k or OpenAI's logit_bias.
+	ExtraBody map[string]any
+
+	// Messages holds earlier turns of a conversation, oldest first. They are
+	// sent before Prompt, which may be empty if the last message is the
+	// user's.
+	Messages []Message
+
+	// Images are sent with Prompt to models that accept image input.
+	// LoadImage reads one from a file, URL or data: URL.
+	Images []Image
+
+	// Documents, such as PDFs, are sent with Prompt to providers that accept
+	// document input. LoadDocument reads one from a file or data: URL.
+	Documents []Document
+
+	// ResponseFormat, if set, asks the model for JSON output, optionally
+	// matching a schema.
+	ResponseFormat *ResponseFormat
+
+	// RequestID correlates this call across logs and provider dashboards.
+	// A random ID is generated if empty.
+	RequestID string
+
+	// User identifies the end user on whose behalf the request is made.
+	// Forwarded as OpenAI's user and Anthropic's metadata.user_id.
+	User string
+
+	// IdempotencyKey lets providers that support it discard duplicate
+	// deliveries of the same request. Complete generates one if empty, so
+	// its retries can't be billed twice.
+	IdempotencyKey string
+
+	// ResumeOnDisconnect makes CompleteStream reopen a stream that drops
+	// mid-response, asking the model to continue from the content already
+	// received rather than starting over.
+	ResumeOnDisconnect bool
+
+	// IdleTimeout aborts a stream when nothing arrives for this long.
+	// Provider keep-alives count as activity. Zero means no limit.
+	IdleTimeout time.Duration
+
+	// OnHeartbeat, if set, is called for each keep-alive a stream receives.
+	OnHeartbeat func()
+
+	// NoCache sends the request even if the client's cache (see
+	// Client.SetCache) has a response for it, and doesn't store the result.
+	NoCache bool
 }
 
 // Response is the result of a completion.
@@ -13,6 +96,110 @@ type Response struct {
 	Content string
 	Model   string
 	Usage   Usage
+
+	RequestID         string // Sage's request ID (caller-supplied or generated)
+	ProviderRequestID string // ID assigned by the provider, if returned
+
+	// Citations lists the sources a search-backed model (Perplexity) cited,
+	// in the order its [n] markers refer to.
+	Citations []Citation
+
+	// SystemFingerprint identifies the backend configuration (OpenAI). Runs
+	// with the same Seed are only expected to match while it's unchanged.
+	SystemFingerprint string
+
+	// FinishReason is why the model stopped: FinishStop, FinishLength
+	// (truncated at MaxTokens), FinishToolUse, FinishContentFilter, the
+	// provider's own value if it has no equivalent, or empty if the provider
+	// doesn't say (Replicate).
+	FinishReason string
+
+	// Logprobs holds each output token's log probability when
+	// Request.Logprobs is set.
+	Logprobs []TokenLogprob
+
+	// Cached is set when the response came from the client's cache rather
+	// than the provider. Timing then only has Total.
+	Cached bool
+
+	Timing Timing
+}
+
+// Finish reasons reported in Response.FinishReason and Chunk.FinishReason.
+const (
+	FinishStop          = providers.FinishStop          // The model finished or hit a stop sequence
+	FinishLength        = providers.FinishLength        // Cut off at MaxTokens
+	FinishToolUse       = providers.FinishToolUse       // Stopped to call a tool
+	FinishContentFilter = providers.FinishContentFilter // Withheld or cut off by a safety filter
+)
+
+// Float returns a pointer to f, for setting Request's sampling parameters.
+func Float(f float64) *float64 {
+	return &f
+}
+
+// Int returns a pointer to i, for setting Request.Seed.
+func Int(i int) *int {
+	return &i
+}
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role    string `json:"role"` // "user", "assistant" or "system"
+	Content string `json:"content"`
+}
+
+// Image is an image input: a URL the provider fetches, or image data.
+type Image struct {
+	URL       string // http(s) URL
+	Data      []byte // Image bytes, if URL is empty
+	MediaType string // e.g. "image/png"; detected from Data if empty
+}
+
+// Document is a document input: a PDF or plain text.
+type Document struct {
+	Data      []byte
+	MediaType string // "application/pdf" or a text/ type; detected from Data if empty
+	Name      string // File name, shown to the model where the provider allows
+}
+
+// ResponseFormat asks for JSON output. OpenAI-compatible providers use
+// response_format, Ollama its format option, Gemini a JSON response type, and
+// Anthropic a tool Claude is forced to call.
+type ResponseFormat struct {
+	Type   string          `json:"type"`           // "json_object" or "json_schema"
+	Name   string          `json:"name,omitempty"` // Schema name (default "response")
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// TokenLogprob is an output token's log probability.
+type TokenLogprob struct {
+	Token       string         `json:"token"`
+	Logprob     float64        `json:"logprob"`
+	TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"` // Most likely alternatives
+}
+
+// Probability returns the token's probability, from 0 to 1.
+func (t TokenLogprob) Probability() float64 {
+	return math.Exp(t.Logprob)
+}
+
+// Citation is a source cited by a response.
+type Citation struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// Timing breaks down how long a completion took.
+type Timing struct {
+	Total time.Duration // Whole call, including retries
+
+	// FirstToken is the time until the first content arrived. Non-streaming
+	// responses arrive all at once, so for Complete it's when the response
+	// was received.
+	FirstToken time.Duration
+
+	Provider time.Duration // Round trip of the successful provider request
 }
 
 // Chunk is a streaming response piece.
@@ -20,6 +207,32 @@ type Chunk struct {
 	Content string
 	Done    bool
 	Error   error
+
+	// Timing is set on the final (Done) chunk. FirstToken is the time until
+	// the first content chunk; Provider covers the successful stream from
+	// request to last chunk.
+	Timing *Timing
+
+	// ProviderRequestID is set on the Done chunk by providers that assign
+	// an ID to a streamed request (e.g. a Replicate prediction ID).
+	ProviderRequestID string
+
+	// Citations is set on the Done chunk for search-backed models.
+	Citations []Citation
+
+	// SystemFingerprint is set on the Done chunk by providers that report it.
+	SystemFingerprint string
+
+	// FinishReason is set on the Done chunk, as in Response.
+	FinishReason string
+
+	// Usage is set on the Done chunk by providers that report token counts
+	// for streams (all but Replicate). After ResumeOnDisconnect reopens a
+	// stream, it covers only the final attempt.
+	Usage *Usage
+
+	// Logprobs covers this chunk's tokens when Request.Logprobs is set.
+	Logprobs []TokenLogprob
 }
 
 // Usage contains token counts.
@@ -34,6 +247,36 @@ type Profile struct {
 	Provider string `json:"provider"`
 	Account  string `json:"account"`
 	Model    string `json:"model"`
+
+	// System is an inline system prompt used when a request doesn't set one.
+	System string `json:"system,omitempty"`
+
+	// SystemFile is a path to a system prompt file, re-read on every request.
+	// Relative paths are resolved against the config directory. Takes
+	// precedence over System.
+	SystemFile string `json:"system_file,omitempty"`
+
+	// ReasoningEffort is used when a request doesn't set one.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+
+	// RemapDeprecated sends requests for deprecated or retired models to
+	// the successor recommended by the model catalog.
+	RemapDeprecated bool `json:"remap_deprecated,omitempty"`
+
+	// Betas lists provider beta features to opt into, in addition to the
+	// provider's betas (sent as Anthropic's anthropic-beta header).
+	Betas []string `json:"betas,omitempty"`
+
+	// ExtraBody is merged into the provider's JSON request body, on top of
+	// the provider's extra_body. For parameters sage doesn't model yet.
+	ExtraBody map[string]any `json:"extra_body,omitempty"`
+
+	// Retry overrides the global retry settings for this profile.
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// projectDir is the directory of the project config that set the
+	// profile. SystemFile must stay inside it.
+	projectDir string
 }
 
 // ProviderAccount stores credentials for a provider account.
@@ -44,7 +287,13 @@ type ProviderAccount struct {
 
 // ProviderInfo describes a configured provider.
 type ProviderInfo struct {
-	Name     string   `json:"name"`
-	Accounts []string `json:"accounts"`
-	BaseURL  string   `json:"base_url,omitempty"`
+	Name     string            `json:"name"`
+	Accounts []string          `json:"accounts"`
+	BaseURL  string            `json:"base_url,omitempty"`
+	Betas    []string          `json:"betas,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Platform string            `json:"platform,omitempty"`
+	Proxy    string            `json:"proxy,omitempty"` // Password redacted
+
+	APIVersion string `json:"api_version,omitempty"`
 }
@@ -0,0 +1,221 @@
+package sage
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/not-emily/sage/pkg/sage/providers"
+)
+
+// Config issue severities.
+const (
+	IssueError   = "error"   // Requests relying on the setting fail
+	IssueWarning = "warning" // Nothing fails, but the setting has no effect
+)
+
+// ConfigIssue is a problem ValidateConfig found in the config.
+type ConfigIssue struct {
+	Severity string `json:"severity"`
+	Path     string `json:"path"` // Config key, e.g. "profiles.fast.account"
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"` // What to change or run
+}
+
+func (i ConfigIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// ValidateConfig checks the config for mistakes that otherwise only surface
+// when a request is made: unknown providers, bad base URLs and proxies,
+// profiles using accounts that don't exist, a missing default profile, and
+// stored keys no account uses. Issues are sorted by path.
+func (c *Client) ValidateConfig() []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(severity, path, fix, format string, args ...any) {
+		issues = append(issues, ConfigIssue{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...), Fix: fix})
+	}
+
+	for name, p := range c.config.Providers {
+		path := "providers." + name
+		if !providers.Exists(name) {
+			fix := "rename or remove it"
+			if s := closestProvider(name); s != "" {
+				fix = fmt.Sprintf("rename it to %s, or remove it", s)
+			}
+			add(IssueError, path, fix, "unknown provider %s", name)
+		}
+		if len(p.Accounts) == 0 {
+			add(IssueWarning, path+".accounts", fmt.Sprintf("run 'sage provider add %s', or remove the provider", name), "no accounts")
+		}
+		if p.BaseURL != "" {
+			if err := checkBaseURL(p.BaseURL); err != nil {
+				add(IssueError, path+".base_url", "set a full URL such as https://api.example.com/v1", "%v", err)
+			}
+		}
+		if p.Proxy != "" {
+			if err := ValidateProxy(p.Proxy); err != nil {
+				add(IssueError, path+".proxy", `set a URL such as http://host:port, or "direct"`, "%v", err)
+			}
+		}
+		if p.Platform != "" {
+			switch {
+			case name != "anthropic":
+				add(IssueError, path+".platform", "remove it", "platform is only supported for anthropic")
+			case p.Platform != "bedrock" && p.Platform != "vertex":
+				add(IssueError, path+".platform", "set it to bedrock or vertex", "unknown platform %q", p.Platform)
+			}
+		}
+		for _, field := range []struct {
+			key     string
+			entries map[string]string
+		}{{"api_key_env", p.APIKeyEnv}, {"api_key_cmd", p.APIKeyCmd}} {
+			for account := range field.entries {
+				if !containsString(p.Accounts, account) {
+					add(IssueWarning, path+"."+field.key+"."+account, "remove the entry, or add the account to accounts",
+						"account %s doesn't exist", account)
+				}
+			}
+		}
+	}
+
+	for name, p := range c.config.Profiles {
+		path := "profiles." + name
+		fixIn := func(fix string) string {
+			if c.config.IsProjectProfile(name) {
+				return fmt.Sprintf("edit %s: %s", c.config.ProjectFile(), fix)
+			}
+			return fix
+		}
+
+		providerConfig, configured := c.config.Providers[p.Provider]
+		switch {
+		case p.Provider == "":
+			add(IssueError, path+".provider", fixIn("set provider"), "no provider")
+		case !providers.Exists(p.Provider):
+			add(IssueError, path+".provider", fixIn("set provider to one of: "+strings.Join(providers.List(), ", ")),
+				"unknown provider %s", p.Provider)
+		case !configured:
+			add(IssueError, path+".provider", fmt.Sprintf("run 'sage provider add %s'", p.Provider),
+				"provider %s is not configured", p.Provider)
+		case !containsString(providerConfig.Accounts, p.Account):
+			fix := fmt.Sprintf("run 'sage provider add %s --account=%s'", p.Provider, p.Account)
+			if len(providerConfig.Accounts) > 0 {
+				fix += fmt.Sprintf(", or set account to one of: %s", strings.Join(providerConfig.Accounts, ", "))
+			}
+			add(IssueError, path+".account", fix, "provider %s has no account %q", p.Provider, p.Account)
+		}
+		if p.Model == "" {
+			add(IssueError, path+".model", fixIn("set model"), "no model")
+		}
+		if err := checkReasoningEffort(p.ReasoningEffort); err != nil {
+			add(IssueError, path+".reasoning_effort", fixIn("set it to low, medium or high"), "%v", err)
+		}
+		if p.SystemFile != "" {
+			if _, err := p.SystemPrompt(); err != nil {
+				add(IssueError, path+".system_file", fixIn("create the file, or fix the path"), "%v", err)
+			}
+		}
+		if p.Retry != nil {
+			if _, err := retryPolicy(c.config.Retry, p.Retry); err != nil {
+				add(IssueError, path+".retry", fixIn("fix the retry settings"), "%v", err)
+			}
+		}
+	}
+
+	if def := c.config.DefaultProfile; def != "" {
+		if _, ok := c.config.Profiles[def]; !ok {
+			fix := "run 'sage profile set-default <name>'"
+			if names := c.profileNames(); len(names) > 0 {
+				fix += " with one of: " + strings.Join(names, ", ")
+			}
+			add(IssueError, "default_profile", fix, "profile %s doesn't exist", def)
+		}
+	} else if len(c.config.Profiles) > 0 {
+		add(IssueWarning, "default_profile", "run 'sage profile set-default <name>'",
+			"no default profile: requests must name a profile")
+	}
+
+	if c.config.Retry != nil {
+		if _, err := retryPolicy(c.config.Retry); err != nil {
+			add(IssueError, "retry", "fix the retry settings", "%v", err)
+		}
+	}
+	if c.config.Age != nil && len(c.config.Age.Recipients) == 0 {
+		add(IssueError, "age.recipients", "run 'sage secrets age' with recipients", "no age recipients: the secrets can't be saved")
+	}
+
+	dangling := make(map[string]bool)
+	for id := range c.secrets {
+		account, _, _ := strings.Cut(id, "#")
+		providerName, accountName, ok := strings.Cut(account, ":")
+		if ok && !containsString(c.config.Providers[providerName].Accounts, accountName) {
+			dangling[account] = true
+		}
+	}
+	for account := range dangling {
+		add(IssueWarning, "secrets."+account, fmt.Sprintf("run 'sage secrets rm %s'", account),
+			"stored key for an account that isn't configured")
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Path < issues[j].Path
+	})
+	return issues
+}
+
+// checkBaseURL checks that a base URL is an absolute http or https URL.
+func checkBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base URL %q: want http:// or https:// and a host", baseURL)
+	}
+	return nil
+}
+
+// profileNames returns the configured profile names, sorted.
+func (c *Client) profileNames() []string {
+	names := make([]string, 0, len(c.config.Profiles))
+	for name := range c.config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// closestProvider returns the registered provider name within two edits of
+// name, for suggesting a fix to a typo, or "" if there is none.
+func closestProvider(name string) string {
+	best, bestDist := "", 3
+	for _, p := range providers.List() {
+		if d := editDistance(strings.ToLower(name), p); d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
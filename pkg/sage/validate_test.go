@@ -0,0 +1,76 @@
+package sage
+
+import "testing"
+
+func TestClient_ValidateConfig(t *testing.T) {
+	config := &Config{
+		Providers: map[string]ProviderConfig{
+			"openai": {Accounts: []string{"default"}, BaseURL: "api.example.com/v1"},
+			"opnai":  {Accounts: []string{"default"}},
+			"ollama": {Accounts: []string{"default"}, BaseURL: "http://localhost:11434"},
+			"groq":   {Accounts: []string{"default"}, APIKeyEnv: map[string]string{"work": "GROQ_WORK_KEY"}},
+		},
+		Profiles: map[string]Profile{
+			"ok":      {Provider: "openai", Account: "default", Model: "gpt-4o"},
+			"work":    {Provider: "openai", Account: "work", Model: "gpt-4o"},
+			"claude":  {Provider: "anthropic", Account: "default", Model: "claude-sonnet-4-5"},
+			"nomodel": {Provider: "ollama", Account: "default"},
+		},
+		DefaultProfile: "missing",
+	}
+	secrets := map[string]string{
+		"openai:default": "sk-a",
+		"openai:old":     "sk-b",
+		"openai:old#2":   "sk-c",
+		"ollama:default": "x",
+	}
+	client, err := NewClientWith(config, secrets)
+	if err != nil {
+		t.Fatalf("NewClientWith() error = %v", err)
+	}
+
+	want := []struct{ severity, path string }{
+		{IssueError, "default_profile"},
+		{IssueError, "profiles.claude.provider"},
+		{IssueError, "profiles.nomodel.model"},
+		{IssueError, "profiles.work.account"},
+		{IssueWarning, "providers.groq.api_key_env.work"},
+		{IssueError, "providers.openai.base_url"},
+		{IssueError, "providers.opnai"},
+		{IssueWarning, "secrets.openai:old"},
+	}
+	issues := client.ValidateConfig()
+	if len(issues) != len(want) {
+		t.Fatalf("ValidateConfig() = %v, want %d issues", issues, len(want))
+	}
+	for i, w := range want {
+		if issues[i].Severity != w.severity || issues[i].Path != w.path {
+			t.Errorf("issue %d = %v, want %s at %s", i, issues[i], w.severity, w.path)
+		}
+		if issues[i].Fix == "" {
+			t.Errorf("issue %v has no fix", issues[i])
+		}
+	}
+	if fix := issues[6].Fix; fix != "rename it to openai, or remove it" {
+		t.Errorf("unknown provider fix = %q, want a suggestion", fix)
+	}
+}
+
+func TestClient_ValidateConfig_Valid(t *testing.T) {
+	config := &Config{
+		Providers: map[string]ProviderConfig{
+			"openai": {Accounts: []string{"default"}, BaseURL: "https://api.openai.com/v1"},
+		},
+		Profiles: map[string]Profile{
+			"default": {Provider: "openai", Account: "default", Model: "gpt-4o"},
+		},
+		DefaultProfile: "default",
+	}
+	client, err := NewClientWith(config, map[string]string{"openai:default": "sk-a"})
+	if err != nil {
+		t.Fatalf("NewClientWith() error = %v", err)
+	}
+	if issues := client.ValidateConfig(); len(issues) != 0 {
+		t.Errorf("ValidateConfig() = %v, want no issues", issues)
+	}
+}
@@ -0,0 +1,723 @@
+package sage
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The YAML support covers what config files use: block maps and lists,
+// flow [lists] and {maps}, plain and quoted scalars, | and > block scalars,
+// and comments. Anchors, aliases, tags and multiple documents aren't
+// supported.
+
+// yamlParser parses YAML one line at a time.
+type yamlParser struct {
+	lines    []string
+	pos      int
+	comments *configComments
+	pending  []string
+}
+
+// yamlLine is a line's structure: its indentation, content and trailing
+// comment.
+type yamlLine struct {
+	indent  int
+	text    string
+	comment string
+}
+
+// parseYAML parses a YAML config file into a tree and its comments.
+func parseYAML(src string) (*configNode, *configComments, error) {
+	p := &yamlParser{
+		lines:    strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n"),
+		comments: newConfigComments(),
+	}
+
+	root := newMapNode()
+	if l, ok, err := p.peek(); err != nil {
+		return nil, nil, err
+	} else if ok {
+		if l.indent != 0 {
+			return nil, nil, p.errorf("unexpected indentation")
+		}
+		if isYAMLListItem(l.text) {
+			return nil, nil, p.errorf("config must be a map, not a list")
+		}
+		if root, err = p.parseMap(0, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, ok, err := p.peek(); err != nil {
+		return nil, nil, err
+	} else if ok {
+		return nil, nil, p.errorf("unexpected indentation")
+	}
+	p.comments.finish(p.pending)
+	return root, p.comments, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// peek returns the next line with content, collecting the comment and
+// blank lines before it.
+func (p *yamlParser) peek() (yamlLine, bool, error) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := strings.TrimRight(p.lines[p.pos], " \t")
+		content := strings.TrimLeft(raw, " ")
+		switch {
+		case content == "":
+			addPending(&p.pending, "")
+			continue
+		case strings.HasPrefix(content, "#"):
+			addPending(&p.pending, content)
+			continue
+		case p.pos == 0 && content == "---":
+			continue
+		case strings.HasPrefix(content, "\t"):
+			return yamlLine{}, false, p.errorf("tabs can't indent YAML")
+		case content == "---" || content == "...":
+			return yamlLine{}, false, p.errorf("multiple documents aren't supported")
+		}
+		text, comment := splitYAMLComment(content)
+		return yamlLine{indent: len(raw) - len(content), text: text, comment: comment}, true, nil
+	}
+	return yamlLine{}, false, nil
+}
+
+// splitYAMLComment splits a trailing comment from a line's content. A #
+// starts a comment after whitespace, outside quotes.
+func splitYAMLComment(s string) (text, comment string) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:-", s[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t"), s[i:]
+		}
+	}
+	return s, ""
+}
+
+// isYAMLListItem reports whether a line's content is a block list item.
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseMap parses the block map whose keys are indented by indent.
+func (p *yamlParser) parseMap(indent int, path []string) (*configNode, error) {
+	n := newMapNode()
+	for {
+		l, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || l.indent < indent {
+			return n, nil
+		}
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if isYAMLListItem(l.text) {
+			return nil, p.errorf("expected a key, found a list item")
+		}
+
+		key, rest, ok, err := splitYAMLKey(l.text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if !ok {
+			return nil, p.errorf("expected \"key: value\", found %q", l.text)
+		}
+		cpath := commentPath(path, key)
+		p.comments.take(cpath, &p.pending)
+		if l.comment != "" {
+			p.comments.inline[cpath] = l.comment
+		}
+		line := p.pos
+		p.pos++
+
+		value, err := p.parseValue(indent, rest, append(path[:len(path):len(path)], key), true)
+		if err != nil {
+			return nil, err
+		}
+		if err := n.set(key, value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line+1, err)
+		}
+	}
+}
+
+// parseList parses the block list whose items are indented by indent.
+func (p *yamlParser) parseList(indent int, path []string) (*configNode, error) {
+	n := &configNode{kind: listNode}
+	for {
+		l, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || l.indent < indent || (l.indent == indent && !isYAMLListItem(l.text)) {
+			return n, nil
+		}
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+
+		ipath := append(path[:len(path):len(path)], strconv.Itoa(len(n.items)))
+		p.comments.take(strings.Join(ipath, "\x00"), &p.pending)
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if _, _, isMap, _ := splitYAMLKey(rest); isMap && rest[0] != '[' && rest[0] != '{' {
+			// "- key: value" starts a map indented to its first key, which
+			// is parsed as though it began the line
+			raw := p.lines[p.pos]
+			itemIndent := len(raw) - len(strings.TrimLeft(raw[l.indent+1:], " "))
+			p.lines[p.pos] = strings.Repeat(" ", itemIndent) + raw[itemIndent:]
+			item, err := p.parseMap(itemIndent, ipath)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+
+		if l.comment != "" {
+			p.comments.inline[strings.Join(ipath, "\x00")] = l.comment
+		}
+		p.pos++
+		item, err := p.parseValue(indent, rest, ipath, false)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+	}
+}
+
+// parseValue parses the value after a key or list item indented by indent:
+// rest of its line, or the block below it.
+func (p *yamlParser) parseValue(indent int, rest string, path []string, isKey bool) (*configNode, error) {
+	if rest == "" {
+		l, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ok && l.indent > indent && isYAMLListItem(l.text):
+			return p.parseList(l.indent, path)
+		case ok && l.indent > indent:
+			return p.parseMap(l.indent, path)
+		case ok && l.indent == indent && isKey && isYAMLListItem(l.text):
+			// Lists may start at their key's indentation
+			return p.parseList(indent, path)
+		}
+		return &configNode{text: "null"}, nil
+	}
+
+	switch rest[0] {
+	case '|', '>':
+		return p.parseBlockScalar(indent, rest)
+	case '[', '{':
+		// Flow collections may continue over the following lines
+		text := rest
+		for !flowClosed(text) && p.pos < len(p.lines) {
+			more, _ := splitYAMLComment(strings.TrimSpace(p.lines[p.pos]))
+			text += " " + more
+			p.pos++
+		}
+		f := &yamlFlow{src: text}
+		n, err := f.parse()
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if f.skipSpace(); f.pos < len(f.src) {
+			return nil, p.errorf("unexpected %q after %c", f.src[f.pos:], rest[0])
+		}
+		return n, nil
+	case '&', '*', '!':
+		return nil, p.errorf("YAML anchors, aliases and tags aren't supported")
+	}
+
+	n, err := parseYAMLScalar(rest)
+	if err != nil {
+		// The scalar's line has been consumed
+		return nil, fmt.Errorf("line %d: %v", p.pos, err)
+	}
+	return n, nil
+}
+
+// flowClosed reports whether a flow collection's brackets are balanced.
+func flowClosed(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// parseBlockScalar parses a | (literal) or > (folded) block scalar whose
+// header is header, under a key indented by indent.
+func (p *yamlParser) parseBlockScalar(indent int, header string) (*configNode, error) {
+	style, chomp := header[0], byte(0)
+	contentIndent := 0
+	for _, c := range header[1:] {
+		switch {
+		case c == '-' || c == '+':
+			chomp = byte(c)
+		case c >= '1' && c <= '9':
+			contentIndent = indent + int(c-'0')
+		default:
+			return nil, p.errorf("invalid block scalar header %q", header)
+		}
+	}
+
+	var lines []string
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := strings.TrimRight(p.lines[p.pos], " \t\r")
+		content := strings.TrimLeft(raw, " ")
+		if content == "" {
+			lines = append(lines, "")
+			continue
+		}
+		lineIndent := len(raw) - len(content)
+		if contentIndent == 0 {
+			if lineIndent <= indent {
+				break
+			}
+			contentIndent = lineIndent
+		}
+		if lineIndent < contentIndent {
+			break
+		}
+		lines = append(lines, raw[contentIndent:])
+	}
+
+	// Trailing blank lines belong to the block only when kept; the
+	// comments and keys after it get them back otherwise
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	body := lines[:len(lines)-trailing]
+	if chomp != '+' && trailing > 0 {
+		addPending(&p.pending, "")
+	}
+
+	var text string
+	if style == '|' {
+		text = strings.Join(body, "\n")
+	} else {
+		text = foldYAMLLines(body)
+	}
+	switch {
+	case len(body) == 0:
+	case chomp == '+':
+		text += strings.Repeat("\n", trailing+1)
+	case chomp == 0:
+		text += "\n"
+	}
+	return &configNode{text: text, quoted: true}, nil
+}
+
+// foldYAMLLines joins the lines of a folded block scalar: lines are joined
+// with spaces, blank lines become newlines, and more indented lines are
+// kept as they are.
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			indented := strings.HasPrefix(line, " ") || strings.HasPrefix(prev, " ")
+			switch {
+			case line == "" || prev == "" || indented:
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// splitYAMLKey splits "key: value" into its key and value. ok is false if
+// text isn't a key.
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" {
+		return "", "", false, nil
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		key, n, err := parseYAMLQuoted(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := text[n:]
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		return key, strings.TrimSpace(after[1:]), true, nil
+	}
+	if strings.ContainsRune("[{&*!|>", rune(text[0])) {
+		return "", "", false, nil
+	}
+	if i := strings.Index(text, ": "); i >= 0 {
+		return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:]), true, nil
+	}
+	if strings.HasSuffix(text, ":") {
+		return strings.TrimSpace(text[:len(text)-1]), "", true, nil
+	}
+	return "", "", false, nil
+}
+
+// parseYAMLScalar parses a scalar that makes up the rest of a line.
+func parseYAMLScalar(text string) (*configNode, error) {
+	if text[0] != '"' && text[0] != '\'' {
+		// "model: gpt: 4" is a map within a map on one line, which YAML
+		// doesn't allow, not the string "gpt: 4"
+		if strings.Contains(text, ": ") || strings.HasSuffix(text, ":") {
+			return nil, fmt.Errorf("unexpected \":\" in %q: quote values that contain \": \"", text)
+		}
+		return &configNode{text: text}, nil
+	}
+	s, n, err := parseYAMLQuoted(text)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(text) {
+		return nil, fmt.Errorf("unexpected %q after quoted string", text[n:])
+	}
+	return &configNode{text: s, quoted: true}, nil
+}
+
+// parseYAMLQuoted parses the single or double-quoted string that starts s,
+// returning it and its length in s.
+func parseYAMLQuoted(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && quote == '"':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			r, n, err := unescapeYAML(s[i+1:])
+			if err != nil {
+				return "", 0, err
+			}
+			b.WriteString(r)
+			i += n
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// yamlEscapes are the single-character escapes of double-quoted strings.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+	'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+}
+
+// unescapeYAML decodes the escape sequence that starts s, after its
+// backslash, returning it and its length.
+func unescapeYAML(s string) (string, int, error) {
+	if r, ok := yamlEscapes[s[0]]; ok {
+		return r, 1, nil
+	}
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if size == 0 || len(s) < 1+size {
+		return "", 0, fmt.Errorf("invalid escape \\%c", s[0])
+	}
+	code, err := strconv.ParseUint(s[1:1+size], 16, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid escape \\%s", s[:1+size])
+	}
+	return string(rune(code)), 1 + size, nil
+}
+
+// yamlFlow parses a flow collection: [a, b] or {a: 1, b: 2}.
+type yamlFlow struct {
+	src string
+	pos int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.src) && (f.src[f.pos] == ' ' || f.src[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) parse() (*configNode, error) {
+	f.skipSpace()
+	if f.pos >= len(f.src) {
+		return nil, fmt.Errorf("unterminated flow collection")
+	}
+	switch f.src[f.pos] {
+	case '[':
+		f.pos++
+		n := &configNode{kind: listNode, flow: true}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.src) && f.src[f.pos] == ']' {
+				f.pos++
+				return n, nil
+			}
+			item, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		n := newMapNode()
+		n.flow = true
+		for {
+			f.skipSpace()
+			if f.pos < len(f.src) && f.src[f.pos] == '}' {
+				f.pos++
+				return n, nil
+			}
+			key, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			if key.kind != scalarNode {
+				return nil, fmt.Errorf("map keys must be scalars")
+			}
+			f.skipSpace()
+			if f.pos >= len(f.src) || f.src[f.pos] != ':' {
+				return nil, fmt.Errorf("expected : after key %q", key.text)
+			}
+			f.pos++
+			value, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			if err := n.set(key.text, value); err != nil {
+				return nil, err
+			}
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		s, n, err := parseYAMLQuoted(f.src[f.pos:])
+		if err != nil {
+			return nil, err
+		}
+		f.pos += n
+		return &configNode{text: s, quoted: true}, nil
+	}
+
+	// Plain scalars end at a separator, or a colon followed by a space
+	start := f.pos
+	for f.pos < len(f.src) {
+		c := f.src[f.pos]
+		if c == ',' || c == ']' || c == '}' || (c == ':' && (f.pos+1 == len(f.src) || f.src[f.pos+1] == ' ')) {
+			break
+		}
+		f.pos++
+	}
+	return &configNode{text: strings.TrimSpace(f.src[start:f.pos])}, nil
+}
+
+// separator consumes the comma after a flow item, or leaves the closing
+// bracket for the caller.
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	switch {
+	case f.pos >= len(f.src):
+		return fmt.Errorf("unterminated flow collection")
+	case f.src[f.pos] == ',':
+		f.pos++
+		return nil
+	case f.src[f.pos] == closing:
+		return nil
+	}
+	return fmt.Errorf("expected , or %c, found %q", closing, f.src[f.pos:])
+}
+
+// emitYAML writes root as a YAML config file with comments.
+func emitYAML(buf *bytes.Buffer, root *configNode, comments *configComments) {
+	emitYAMLMap(buf, root, comments, nil, 0)
+	for _, line := range comments.footer {
+		buf.WriteString(line + "\n")
+	}
+}
+
+func emitYAMLMap(buf *bytes.Buffer, n *configNode, comments *configComments, path []string, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, key := range n.keys {
+		cpath := commentPath(path, key)
+		writeComments(buf, comments.before[cpath], pad)
+		buf.WriteString(pad + yamlString(key) + ":")
+		emitYAMLValue(buf, n.fields[key], comments, append(path[:len(path):len(path)], key), indent, comments.inline[cpath])
+	}
+}
+
+// emitYAMLValue writes value after its key or "-", followed by the key's
+// inline comment.
+func emitYAMLValue(buf *bytes.Buffer, value *configNode, comments *configComments, path []string, indent int, comment string) {
+	inline := ""
+	if comment != "" {
+		inline = " " + comment
+	}
+
+	switch {
+	case value.flow && value.kind != scalarNode:
+		buf.WriteString(" " + yamlFlowString(value) + inline + "\n")
+	case value.kind == mapNode && len(value.keys) > 0:
+		buf.WriteString(inline + "\n")
+		emitYAMLMap(buf, value, comments, path, indent+2)
+	case value.kind == listNode && len(value.items) > 0:
+		buf.WriteString(inline + "\n")
+		pad := strings.Repeat(" ", indent+2)
+		for i, item := range value.items {
+			ipath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			writeComments(buf, comments.before[strings.Join(ipath, "\x00")], pad)
+			if item.kind == mapNode && len(item.keys) > 0 {
+				// The item's first key goes on the "-" line
+				var sub bytes.Buffer
+				emitYAMLMap(&sub, item, comments, ipath, indent+4)
+				buf.WriteString(pad + "- " + strings.TrimPrefix(sub.String(), pad+"  "))
+				continue
+			}
+			buf.WriteString(pad + "-")
+			emitYAMLValue(buf, item, comments, ipath, indent+2, comments.inline[strings.Join(ipath, "\x00")])
+		}
+	case value.kind == mapNode:
+		buf.WriteString(" {}" + inline + "\n")
+	case value.kind == listNode:
+		buf.WriteString(" []" + inline + "\n")
+	case value.quoted && strings.Contains(value.text, "\n") && yamlBlockSafe(value.text):
+		header := "|"
+		if !strings.HasSuffix(value.text, "\n") {
+			header = "|-"
+		}
+		buf.WriteString(" " + header + inline + "\n")
+		pad := strings.Repeat(" ", indent+2)
+		for _, line := range strings.Split(strings.TrimSuffix(value.text, "\n"), "\n") {
+			if line == "" {
+				buf.WriteString("\n")
+			} else {
+				buf.WriteString(pad + line + "\n")
+			}
+		}
+	case value.quoted:
+		buf.WriteString(" " + yamlString(value.text) + inline + "\n")
+	default:
+		buf.WriteString(" " + value.text + inline + "\n")
+	}
+}
+
+// yamlFlowString formats n on one line: [a, b] or {a: 1}.
+func yamlFlowString(n *configNode) string {
+	switch n.kind {
+	case mapNode:
+		parts := make([]string, len(n.keys))
+		for i, k := range n.keys {
+			parts[i] = yamlFlowString(&configNode{text: k, quoted: true}) + ": " + yamlFlowString(n.fields[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case listNode:
+		parts := make([]string, len(n.items))
+		for i, item := range n.items {
+			parts[i] = yamlFlowString(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	if !n.quoted {
+		return n.text
+	}
+	if strings.ContainsAny(n.text, ",[]{}") {
+		return strconv.Quote(n.text)
+	}
+	return yamlString(n.text)
+}
+
+// writeComments writes comment lines indented by pad.
+func writeComments(buf *bytes.Buffer, lines []string, pad string) {
+	for _, line := range lines {
+		if line == "" {
+			buf.WriteString("\n")
+		} else {
+			buf.WriteString(pad + line + "\n")
+		}
+	}
+}
+
+// yamlBlockSafe reports whether s can be written as a | block scalar, which
+// can't express leading spaces on its first line, trailing spaces, or more
+// than one trailing newline.
+func yamlBlockSafe(s string) bool {
+	if strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") || strings.HasSuffix(s, "\n\n") {
+		return false
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasSuffix(line, " ") || strings.ContainsFunc(line, func(r rune) bool { return isControl(r) && r != '\t' }) {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlString returns s as a plain scalar if it would read back as the same
+// string, or else double-quoted.
+func yamlString(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || !utf8.ValidString(s) ||
+		strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.ContainsFunc(s, isControl) {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off":
+		return strconv.Quote(s)
+	}
+	if _, ok := yamlNumber(s); ok || jsonNumber.MatchString(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// isControl reports whether r is a control character, which plain and
+// block scalars can't contain.
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
@@ -0,0 +1,174 @@
+package sage
+
+import (
+	"strings"
+	"testing"
+)
+
+const testYAMLConfig = `# sage config
+default_profile: local  # used by "sage complete"
+
+providers:
+  # Local models
+  ollama:
+    accounts: [default]
+  openai:
+    accounts:
+    - default
+    - work
+    api_version: 2024-06-01
+profiles:
+  local:
+    provider: ollama
+    account: default
+    model: 3.5
+    system: |
+      Be terse.
+      # Not a comment
+
+      Answer in English.
+    extra_body:
+      stop:
+        - "END"
+        - {a: 1, b: true}
+`
+
+func TestDecodeConfig_YAML(t *testing.T) {
+	cfg, err := decodeConfig("config.yaml", []byte(testYAMLConfig))
+	if err != nil {
+		t.Fatalf("decodeConfig() error = %v", err)
+	}
+
+	if cfg.DefaultProfile != "local" {
+		t.Errorf("DefaultProfile = %q, want local", cfg.DefaultProfile)
+	}
+	openai := cfg.Providers["openai"]
+	if len(openai.Accounts) != 2 || openai.Accounts[1] != "work" || openai.APIVersion != "2024-06-01" {
+		t.Errorf("openai = %+v", openai)
+	}
+	if accounts := cfg.Providers["ollama"].Accounts; len(accounts) != 1 || accounts[0] != "default" {
+		t.Errorf("ollama accounts = %v, want [default]", accounts)
+	}
+
+	// Unquoted scalars are strings where the config expects strings
+	local := cfg.Profiles["local"]
+	if local.Model != "3.5" {
+		t.Errorf("Model = %q, want 3.5", local.Model)
+	}
+	if want := "Be terse.\n# Not a comment\n\nAnswer in English.\n"; local.System != want {
+		t.Errorf("System = %q, want %q", local.System, want)
+	}
+	stop := local.ExtraBody["stop"].([]any)
+	if stop[0] != "END" || stop[1].(map[string]any)["b"] != true {
+		t.Errorf("extra_body stop = %#v", stop)
+	}
+}
+
+func TestDecodeConfig_YAMLErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"a: 1\na: 2\n", "line 2: duplicate key"},
+		{"a: [1, 2\n", "unterminated"},
+		{"a: &x 1\n", "anchors"},
+		{"- a\n", "must be a map"},
+		{"model: gpt: 4\n", `line 1: unexpected ":" in "gpt: 4"`},
+		{"a:\n  b: c:\n", "line 2: unexpected"},
+	}
+	for _, tt := range tests {
+		if _, err := decodeConfig("config.yaml", []byte(tt.src)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("decodeConfig(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeConfig_YAMLNumbers(t *testing.T) {
+	src := `profiles:
+  local:
+    model: .5
+    retry:
+      jitter: .5
+      max_retries: +3
+    extra_body:
+      temperature: 1.
+      top_k: 1e2
+      seed: 007
+`
+	cfg, err := decodeConfig("config.yaml", []byte(src))
+	if err != nil {
+		t.Fatalf("decodeConfig() error = %v", err)
+	}
+	local := cfg.Profiles["local"]
+	if local.Model != ".5" {
+		t.Errorf("Model = %q, want .5", local.Model)
+	}
+	if r := local.Retry; r == nil || r.Jitter == nil || *r.Jitter != 0.5 || r.MaxRetries == nil || *r.MaxRetries != 3 {
+		t.Errorf("retry = %+v, want jitter 0.5 and max_retries 3", r)
+	}
+	for key, want := range map[string]float64{"temperature": 1, "top_k": 100, "seed": 7} {
+		if got := local.ExtraBody[key]; got != want {
+			t.Errorf("extra_body %s = %#v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestEncodeConfig_YAMLKeepsComments(t *testing.T) {
+	cfg, _ := decodeConfig("config.yaml", []byte(testYAMLConfig))
+	cfg.DefaultProfile = "fast"
+	cfg.Profiles["fast"] = Profile{Provider: "openai", Account: "work", Model: "gpt-4o-mini"}
+
+	data, err := encodeConfig("config.yaml", cfg, []byte(testYAMLConfig))
+	if err != nil {
+		t.Fatalf("encodeConfig() error = %v", err)
+	}
+	out := string(data)
+
+	for _, want := range []string{
+		"# sage config\ndefault_profile: fast # used by \"sage complete\"\n",
+		"  # Local models\n  ollama:\n    accounts: [default]\n",
+		"    system: |\n      Be terse.\n      # Not a comment\n\n      Answer in English.\n",
+		"  fast:\n    provider: openai\n    account: work\n    model: gpt-4o-mini\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("encoded config missing %q:\n%s", want, out)
+		}
+	}
+	// Keys keep the file's order, and empty fields aren't added
+	if strings.Index(out, "default_profile") > strings.Index(out, "providers") || strings.Contains(out, `name: ""`) {
+		t.Errorf("encoded config reordered or padded:\n%s", out)
+	}
+
+	again, err := decodeConfig("config.yaml", data)
+	if err != nil {
+		t.Fatalf("decodeConfig() of encoded config error = %v", err)
+	}
+	if again.Profiles["local"].System != cfg.Profiles["local"].System || again.Profiles["fast"].Model != "gpt-4o-mini" {
+		t.Errorf("round trip = %+v", again.Profiles)
+	}
+}
+
+func TestYAMLString(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"gpt-4o", "gpt-4o"},
+		{"http://localhost:11434", "http://localhost:11434"},
+		{"", `""`},
+		{"true", `"true"`},
+		{"3.5", `"3.5"`},
+		{".5", `".5"`},
+		{"007", `"007"`},
+		{"- item", `"- item"`},
+		{"a: b", `"a: b"`},
+		{"a #b", `"a #b"`},
+		{"line\nbreak", `"line\nbreak"`},
+	}
+	for _, tt := range tests {
+		if got := yamlString(tt.s); got != tt.want {
+			t.Errorf("yamlString(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}